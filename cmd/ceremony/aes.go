@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/letsencrypt/boulder/pkcs11helpers"
+	"github.com/miekg/pkcs11"
+)
+
+// aesArgs constructs the secret key template attributes sent to the device and
+// specifies which mechanism should be used. keyLen specifies the length of the
+// key to be generated on the device in bits. The key can only be used to wrap
+// and unwrap other keys, and can never be extracted from the device.
+func aesArgs(label string, keyLen uint, keyID []byte) ([]*pkcs11.Mechanism, []*pkcs11.Attribute) {
+	mechanism := []*pkcs11.Mechanism{
+		pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_GEN, nil),
+	}
+	attrs := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		// Set requested key length, which CKA_VALUE_LEN specifies in bytes
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, keyLen/8),
+		// Prevent attributes being retrieved
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		// Prevent the key being extracted from the device
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		// Allow the key to wrap and unwrap other keys, and nothing else
+		pkcs11.NewAttribute(pkcs11.CKA_WRAP, true),
+		pkcs11.NewAttribute(pkcs11.CKA_UNWRAP, true),
+		pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, false),
+		pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, false),
+	}
+	return mechanism, attrs
+}
+
+// aesGenerate is used to generate an AES wrapping key of the size specified by
+// keyLen, in bits, with the given label. It returns the random key ID that the
+// HSM uses to identify the key.
+func aesGenerate(session *pkcs11helpers.Session, label string, keyLen uint) ([]byte, error) {
+	_, err := session.FindObject([]*pkcs11.Attribute{
+		{Type: pkcs11.CKA_LABEL, Value: []byte(label)},
+	})
+	if err != pkcs11helpers.ErrNoObject {
+		return nil, fmt.Errorf("expected no preexisting objects with label %q in slot for key storage. got error: %w", label, err)
+	}
+
+	keyID := make([]byte, 4)
+	_, err = newRandReader(session).Read(keyID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Generating %d bit AES wrapping key with ID %x\n", keyLen, keyID)
+	mechanism, attrs := aesArgs(label, keyLen, keyID)
+	_, err = session.GenerateKey(mechanism, attrs)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Key generated")
+	return keyID, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/letsencrypt/boulder/pkcs11helpers"
+	"github.com/letsencrypt/boulder/test"
+	"github.com/miekg/pkcs11"
+)
+
+func TestAESGenerate(t *testing.T) {
+	ctx := setupCtx()
+	s := &pkcs11helpers.Session{Module: &ctx, Session: 0}
+
+	// Test aesGenerate fails when a key with the label already exists
+	ctx.FindObjectsFunc = func(pkcs11.SessionHandle, int) ([]pkcs11.ObjectHandle, bool, error) {
+		return []pkcs11.ObjectHandle{1}, false, nil
+	}
+	_, err := aesGenerate(s, "label", 256)
+	test.AssertError(t, err, "aesGenerate didn't fail with an existing key")
+	ctx.FindObjectsFunc = func(pkcs11.SessionHandle, int) ([]pkcs11.ObjectHandle, bool, error) {
+		return nil, false, nil
+	}
+
+	// Test aesGenerate fails when GenerateKey fails
+	ctx.GenerateKeyFunc = func(pkcs11.SessionHandle, []*pkcs11.Mechanism, []*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+		return 0, errors.New("bad")
+	}
+	_, err = aesGenerate(s, "label", 256)
+	test.AssertError(t, err, "aesGenerate didn't fail when GenerateKey failed")
+
+	// Test aesGenerate generates a non-extractable AES key of the requested
+	// length, which can only wrap and unwrap keys
+	var mechanism []*pkcs11.Mechanism
+	var attrs []*pkcs11.Attribute
+	ctx.GenerateKeyFunc = func(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, a []*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+		mechanism, attrs = m, a
+		return 0, nil
+	}
+	keyID, err := aesGenerate(s, "label", 256)
+	test.AssertNotError(t, err, "aesGenerate failed")
+	test.AssertByteEquals(t, keyID, []byte{1, 2, 3, 0})
+	test.AssertEquals(t, len(mechanism), 1)
+	test.AssertEquals(t, mechanism[0].Mechanism, uint(pkcs11.CKM_AES_KEY_GEN))
+
+	expected := map[uint]*pkcs11.Attribute{
+		pkcs11.CKA_CLASS:       pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.CKA_KEY_TYPE:    pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+		pkcs11.CKA_LABEL:       pkcs11.NewAttribute(pkcs11.CKA_LABEL, "label"),
+		pkcs11.CKA_VALUE_LEN:   pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+		pkcs11.CKA_SENSITIVE:   pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.CKA_EXTRACTABLE: pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.CKA_WRAP:        pkcs11.NewAttribute(pkcs11.CKA_WRAP, true),
+		pkcs11.CKA_UNWRAP:      pkcs11.NewAttribute(pkcs11.CKA_UNWRAP, true),
+		pkcs11.CKA_ENCRYPT:     pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, false),
+		pkcs11.CKA_DECRYPT:     pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, false),
+	}
+	for _, attr := range attrs {
+		want, ok := expected[attr.Type]
+		if !ok {
+			continue
+		}
+		test.Assert(t, bytes.Equal(attr.Value, want.Value), "unexpected value for attribute")
+		delete(expected, attr.Type)
+	}
+	test.AssertEquals(t, len(expected), 0)
+}
+
+func TestWrappingKeyCeremony(t *testing.T) {
+	useSoftTokens(t)
+	config := []byte(`ceremony-type: wrapping-key
+pkcs11:
+    module: module
+    store-key-in-slot: 3
+    store-key-with-label: wrapping key
+key:
+    aes-key-length: 128
+`)
+	err := wrappingKeyCeremony(config, false, "")
+	test.AssertNotError(t, err, "wrapping-key ceremony failed")
+
+	session, err := initializeSession("module", 3, pkcs11.CKU_USER, "")
+	test.AssertNotError(t, err, "failed to open session")
+	_, err = session.FindObject([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, "wrapping key"),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 16),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+	})
+	test.AssertNotError(t, err, "wrapping key not found on the token")
+
+	// A second key with the same label isn't generated.
+	err = wrappingKeyCeremony(config, false, "")
+	test.AssertError(t, err, "wrapping-key ceremony didn't fail with an existing label")
+	test.AssertContains(t, err.Error(), "expected no preexisting objects with label \"wrapping key\"")
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/letsencrypt/boulder/strictyaml"
+)
+
+const (
+	bundleRequestType = "certificate-request"
+	bundleResultType  = "certificate-result"
+)
+
+// transferBundle is a signed file which is carried across the air gap to an
+// offline signer. Payload is the JSON encoding of a bundleRequest, and
+// Signature is a signature over its SHA-256 digest. Payload is kept as raw
+// bytes so that the signature covers exactly what was exported.
+type transferBundle struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// bundleRequest is the payload of a bundle produced by the bundle-export
+// ceremony. It contains everything the offline signer needs in order to issue
+// a certificate, along with metadata describing the expected result.
+type bundleRequest struct {
+	Type string `json:"type"`
+	// CSR is the DER encoded certificate signing request whose public key will
+	// be certified.
+	CSR []byte `json:"csr"`
+	// CertificateProfile is the YAML encoding of the certProfile to issue with.
+	CertificateProfile []byte `json:"certificate-profile"`
+	// SubjectPublicKeySHA256 is the hex encoded SHA-256 digest of the DER
+	// SubjectPublicKeyInfo which the issued certificate is expected to certify.
+	SubjectPublicKeySHA256 string `json:"subject-public-key-sha256"`
+}
+
+// bundleResult is the result file produced by the bundle-import ceremony. It
+// isn't signed, since the issuer's key must only be used to sign certificates,
+// CRLs, and OCSP responses, but the certificate it contains is authenticated
+// by its own signature.
+type bundleResult struct {
+	Type string `json:"type"`
+	// RequestSHA256 is the hex encoded SHA-256 digest of the request bundle
+	// which the certificate was issued from.
+	RequestSHA256 string `json:"request-sha256"`
+	// Certificate is the DER encoded issued certificate.
+	Certificate []byte `json:"certificate"`
+}
+
+// signBundle JSON encodes payload and returns a transferBundle containing it,
+// signed by signer.
+func signBundle(payload any, signer crypto.Signer) ([]byte, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bundle payload: %w", err)
+	}
+	digest := sha256.Sum256(payloadBytes)
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign bundle: %w", err)
+	}
+	return json.MarshalIndent(transferBundle{Payload: payloadBytes, Signature: signature}, "", "  ")
+}
+
+// strictJSONUnmarshal decodes data into v, rejecting unknown fields and any
+// trailing data.
+func strictJSONUnmarshal(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("trailing data after JSON object")
+	}
+	return nil
+}
+
+// openBundle verifies that bundleBytes is a transferBundle signed by pub and
+// decodes its payload into payload. It returns the payload type, which the
+// caller must check.
+func openBundle(bundleBytes []byte, pub crypto.PublicKey, payload any) (string, error) {
+	var tb transferBundle
+	err := strictJSONUnmarshal(bundleBytes, &tb)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse bundle: %w", err)
+	}
+	digest := sha256.Sum256(tb.Payload)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest[:], tb.Signature) {
+			return "", errors.New("bundle signature is invalid")
+		}
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], tb.Signature)
+		if err != nil {
+			return "", errors.New("bundle signature is invalid")
+		}
+	default:
+		return "", fmt.Errorf("unsupported bundle signer public key type %T", pub)
+	}
+	var header struct {
+		Type string `json:"type"`
+	}
+	err = json.Unmarshal(tb.Payload, &header)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse bundle payload: %w", err)
+	}
+	err = strictJSONUnmarshal(tb.Payload, payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse bundle payload: %w", err)
+	}
+	return header.Type, nil
+}
+
+// loadCSR loads and parses the PEM encoded certificate signing request at
+// filename, checking its signature and public key.
+func loadCSR(filename string) (*x509.CertificateRequest, error) {
+	csrPEM, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(csrPEM)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, fmt.Errorf("no CERTIFICATE REQUEST PEM block in %s", filename)
+	}
+	return parseBundleCSR(block.Bytes)
+}
+
+// parseBundleCSR parses a DER encoded certificate signing request, checking
+// its signature and public key.
+func parseBundleCSR(der []byte) (*x509.CertificateRequest, error) {
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSR: %w", err)
+	}
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, fmt.Errorf("CSR signature is invalid: %w", err)
+	}
+	err = kp.GoodKey(context.Background(), csr.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("CSR public key is unacceptable: %w", err)
+	}
+	return csr, nil
+}
+
+func spkiSHA256(spki []byte) string {
+	digest := sha256.Sum256(spki)
+	return hex.EncodeToString(digest[:])
+}
+
+type bundleExportConfig struct {
+	CeremonyType string              `yaml:"ceremony-type"`
+	PKCS11       PKCS11SigningConfig `yaml:"pkcs11"`
+	Inputs       struct {
+		CSRPath              string `yaml:"csr-path"`
+		SigningPublicKeyPath string `yaml:"signing-public-key-path"`
+	} `yaml:"inputs"`
+	Outputs struct {
+		BundlePath string `yaml:"bundle-path"`
+	} `yaml:"outputs"`
+	CertProfile certProfile `yaml:"certificate-profile"`
+}
+
+func (bec bundleExportConfig) validate() error {
+	err := bec.PKCS11.validate()
+	if err != nil {
+		return err
+	}
+
+	// Input fields
+	if bec.Inputs.CSRPath == "" {
+		return errors.New("inputs.csr-path is required")
+	}
+	if bec.Inputs.SigningPublicKeyPath == "" {
+		return errors.New("inputs.signing-public-key-path is required")
+	}
+
+	// Output fields
+	err = checkOutputFile(bec.Outputs.BundlePath, "bundle-path")
+	if err != nil {
+		return err
+	}
+
+	// Certificate profile
+	err = bec.CertProfile.verifyProfile(intermediateCert)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// bundleExportCeremony packages a CSR and the certificate profile to issue it
+// with into a bundle signed by the key at inputs.signing-public-key-path, for
+// transfer to the offline signer which will run the bundle-import ceremony.
+func bundleExportCeremony(configBytes []byte, allowNonstandard bool, softwareKeyPath string) error {
+	var config bundleExportConfig
+	err := strictyaml.Unmarshal(configBytes, &config)
+	if err != nil {
+		return configErrorf("failed to parse config: %w", err)
+	}
+	log.Printf("Preparing bundle-export ceremony for %s\n", config.Outputs.BundlePath)
+	config.PKCS11.softwareKeyPath = softwareKeyPath
+	err = config.validate()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	err = config.CertProfile.checkNonstandard(allowNonstandard)
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	csr, err := loadCSR(config.Inputs.CSRPath)
+	if err != nil {
+		return fmt.Errorf("failed to load CSR %q: %w", config.Inputs.CSRPath, err)
+	}
+	profileBytes, err := yaml.Marshal(config.CertProfile)
+	if err != nil {
+		return fmt.Errorf("failed to encode certificate profile: %w", err)
+	}
+	signingPub, _, err := loadPubKey(config.Inputs.SigningPublicKeyPath)
+	if err != nil {
+		return err
+	}
+	signer, _, err := openSigner(config.PKCS11, signingPub)
+	if err != nil {
+		return err
+	}
+	bundle, err := signBundle(bundleRequest{
+		Type:                   bundleRequestType,
+		CSR:                    csr.Raw,
+		CertificateProfile:     profileBytes,
+		SubjectPublicKeySHA256: spkiSHA256(csr.RawSubjectPublicKeyInfo),
+	}, signer)
+	if err != nil {
+		return err
+	}
+	err = writeFile(config.Outputs.BundlePath, bundle)
+	if err != nil {
+		return fmt.Errorf("failed to write bundle to %q: %w", config.Outputs.BundlePath, err)
+	}
+	log.Printf("Bundle written to %s\n", config.Outputs.BundlePath)
+	return nil
+}
+
+type bundleImportConfig struct {
+	CeremonyType string              `yaml:"ceremony-type"`
+	PKCS11       PKCS11SigningConfig `yaml:"pkcs11"`
+	Inputs       struct {
+		BundlePath                string `yaml:"bundle-path"`
+		BundleSignerPublicKeyPath string `yaml:"bundle-signer-public-key-path"`
+		IssuerCertificatePath     string `yaml:"issuer-certificate-path"`
+	} `yaml:"inputs"`
+	Outputs struct {
+		CertificatePath  string `yaml:"certificate-path"`
+		ResultBundlePath string `yaml:"result-bundle-path"`
+		LintReportPath   string `yaml:"lint-report-path"`
+	} `yaml:"outputs"`
+	SkipLints []string `yaml:"skip-lints"`
+}
+
+func (bic bundleImportConfig) validate() error {
+	err := bic.PKCS11.validate()
+	if err != nil {
+		return err
+	}
+
+	// Input fields
+	if bic.Inputs.BundlePath == "" {
+		return errors.New("inputs.bundle-path is required")
+	}
+	if bic.Inputs.BundleSignerPublicKeyPath == "" {
+		return errors.New("inputs.bundle-signer-public-key-path is required")
+	}
+	if bic.Inputs.IssuerCertificatePath == "" {
+		return errors.New("inputs.issuer-certificate-path is required")
+	}
+
+	// Output fields
+	err = checkOutputFile(bic.Outputs.CertificatePath, "certificate-path")
+	if err != nil {
+		return err
+	}
+	err = checkOutputFile(bic.Outputs.ResultBundlePath, "result-bundle-path")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// bundleImportCeremony verifies a bundle produced by the bundle-export
+// ceremony, issues the intermediate certificate it requests, and writes both
+// the certificate and a result bundle, which records the request it was issued
+// from, for transfer back across the air gap.
+func bundleImportCeremony(configBytes []byte, allowNonstandard bool, softwareKeyPath string) error {
+	var config bundleImportConfig
+	err := strictyaml.Unmarshal(configBytes, &config)
+	if err != nil {
+		return configErrorf("failed to parse config: %w", err)
+	}
+	log.Printf("Preparing bundle-import ceremony for %s\n", config.Outputs.CertificatePath)
+	config.PKCS11.softwareKeyPath = softwareKeyPath
+	err = config.validate()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+
+	bundleBytes, err := os.ReadFile(config.Inputs.BundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle %q: %w", config.Inputs.BundlePath, err)
+	}
+	bundleSignerPub, _, err := loadPubKey(config.Inputs.BundleSignerPublicKeyPath)
+	if err != nil {
+		return err
+	}
+	var req bundleRequest
+	payloadType, err := openBundle(bundleBytes, bundleSignerPub, &req)
+	if err != nil {
+		return err
+	}
+	if payloadType != bundleRequestType {
+		return fmt.Errorf("bundle has type %q, expected %q", payloadType, bundleRequestType)
+	}
+	csr, err := parseBundleCSR(req.CSR)
+	if err != nil {
+		return err
+	}
+	if spkiSHA256(csr.RawSubjectPublicKeyInfo) != req.SubjectPublicKeySHA256 {
+		return errors.New("bundle CSR public key doesn't match subject-public-key-sha256")
+	}
+	var profile certProfile
+	err = strictyaml.Unmarshal(req.CertificateProfile, &profile)
+	if err != nil {
+		return fmt.Errorf("failed to parse bundle certificate profile: %w", err)
+	}
+	err = profile.verifyProfile(intermediateCert)
+	if err != nil {
+		return fmt.Errorf("bundle certificate profile is invalid: %w", err)
+	}
+	err = profile.checkNonstandard(allowNonstandard)
+	if err != nil {
+		return fmt.Errorf("bundle certificate profile is invalid: %w", err)
+	}
+	uniqueIDs, err := profile.uniqueIDs()
+	if err != nil {
+		return fmt.Errorf("bundle certificate profile is invalid: %w", err)
+	}
+	expectedSubject, err := profile.expectedSubject()
+	if err != nil {
+		return fmt.Errorf("bundle certificate profile is invalid: %w", err)
+	}
+
+	issuer, err := loadCert(config.Inputs.IssuerCertificatePath)
+	if err != nil {
+		return fmt.Errorf("failed to load issuer certificate %q: %w", config.Inputs.IssuerCertificatePath, err)
+	}
+	signer, randReader, err := openSigner(config.PKCS11, issuer.PublicKey)
+	if err != nil {
+		return err
+	}
+	template, err := makeTemplate(randReader, &profile, csr.RawSubjectPublicKeyInfo, nil, intermediateCert)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate profile: %w", err)
+	}
+	err = setAuthorityKeyID(template, &profile, issuer)
+	if err != nil {
+		return err
+	}
+	lintCert, err := issueLintCertAndPerformLinting(template, issuer, csr.PublicKey, signer, uniqueIDs, config.SkipLints, config.Outputs.LintReportPath)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(issuer.RawSubject, lintCert.RawIssuer) {
+		return fmt.Errorf("mismatch between issuer RawSubject and lintCert RawIssuer DER bytes: \"%x\" != \"%x\"", issuer.RawSubject, lintCert.RawIssuer)
+	}
+	finalCert, err := signAndWriteCert(template, issuer, lintCert, csr.PublicKey, signer, uniqueIDs, expectedSubject, config.Outputs.CertificatePath)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(lintCert.RawTBSCertificate, finalCert.RawTBSCertificate) {
+		return fmt.Errorf("mismatch between lintCert and finalCert RawTBSCertificate DER bytes: \"%x\" != \"%x\"", lintCert.RawTBSCertificate, finalCert.RawTBSCertificate)
+	}
+
+	requestDigest := sha256.Sum256(bundleBytes)
+	result, err := json.MarshalIndent(bundleResult{
+		Type:          bundleResultType,
+		RequestSHA256: hex.EncodeToString(requestDigest[:]),
+		Certificate:   finalCert.Raw,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode result bundle: %w", err)
+	}
+	err = writeFile(config.Outputs.ResultBundlePath, result)
+	if err != nil {
+		return fmt.Errorf("failed to write result bundle to %q: %w", config.Outputs.ResultBundlePath, err)
+	}
+	log.Printf("Result bundle written to %s\n", config.Outputs.ResultBundlePath)
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate root key")
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root", Organization: []string{"organization"}, Country: []string{"US"}},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootKey.Public(), rootKey)
+	test.AssertNotError(t, err, "failed to create root certificate")
+	rootPath := path.Join(dir, "root.cert.pem")
+	writePEMFile(t, rootPath, "CERTIFICATE", rootDER)
+	rootKeyDER, err := x509.MarshalPKCS8PrivateKey(rootKey)
+	test.AssertNotError(t, err, "failed to marshal root key")
+	rootKeyPath := path.Join(dir, "root.key.pem")
+	writePEMFile(t, rootKeyPath, "PRIVATE KEY", rootKeyDER)
+
+	// The bundle signer is the online key which vouches for the request.
+	bundleKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate bundle signer key")
+	bundleKeyDER, err := x509.MarshalPKCS8PrivateKey(bundleKey)
+	test.AssertNotError(t, err, "failed to marshal bundle signer key")
+	bundleKeyPath := path.Join(dir, "bundle.key.pem")
+	writePEMFile(t, bundleKeyPath, "PRIVATE KEY", bundleKeyDER)
+	bundlePubDER, err := x509.MarshalPKIXPublicKey(bundleKey.Public())
+	test.AssertNotError(t, err, "failed to marshal bundle signer public key")
+	bundlePubPath := path.Join(dir, "bundle.pubkey.pem")
+	writePEMFile(t, bundlePubPath, "PUBLIC KEY", bundlePubDER)
+
+	intKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate intermediate key")
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "intermediate"},
+	}, intKey)
+	test.AssertNotError(t, err, "failed to create CSR")
+	csrPath := path.Join(dir, "int.csr.pem")
+	writePEMFile(t, csrPath, "CERTIFICATE REQUEST", csrDER)
+
+	bundlePath := path.Join(dir, "request.bundle")
+	exportConfig := fmt.Sprintf(`ceremony-type: bundle-export
+inputs:
+    csr-path: %s
+    signing-public-key-path: %s
+outputs:
+    bundle-path: %s
+certificate-profile:
+    signature-algorithm: ECDSAWithSHA384
+    common-name: intermediate
+    organization: organization
+    country: US
+    not-before: 2020-01-01 00:00:00
+    not-after: 2027-01-01 00:00:00
+    crl-url: http://crl.example.org/crl
+    issuer-url: http://issuer.example.org/root
+    policies:
+        - oid: 2.23.140.1.2.1
+    key-usages:
+        - Digital Signature
+        - Cert Sign
+        - CRL Sign
+`, csrPath, bundlePubPath, bundlePath)
+	err = bundleExportCeremony([]byte(exportConfig), false, bundleKeyPath)
+	test.AssertNotError(t, err, "bundle-export ceremony failed")
+
+	intCertPath := path.Join(dir, "int.cert.pem")
+	resultPath := path.Join(dir, "result.bundle")
+	importConfig := func(bundlePath string) []byte {
+		return []byte(fmt.Sprintf(`ceremony-type: bundle-import
+inputs:
+    bundle-path: %s
+    bundle-signer-public-key-path: %s
+    issuer-certificate-path: %s
+outputs:
+    certificate-path: %s
+    result-bundle-path: %s
+`, bundlePath, bundlePubPath, rootPath, intCertPath, resultPath))
+	}
+
+	// A bundle whose payload has been modified is rejected.
+	bundleBytes, err := os.ReadFile(bundlePath)
+	test.AssertNotError(t, err, "failed to read bundle")
+	var tb transferBundle
+	err = json.Unmarshal(bundleBytes, &tb)
+	test.AssertNotError(t, err, "failed to parse bundle")
+	tb.Payload = bytes.Replace(tb.Payload, []byte(bundleRequestType), []byte("certificate-rEquest"), 1)
+	tampered, err := json.Marshal(tb)
+	test.AssertNotError(t, err, "failed to encode tampered bundle")
+	tamperedPath := path.Join(dir, "tampered.bundle")
+	err = os.WriteFile(tamperedPath, tampered, 0600)
+	test.AssertNotError(t, err, "failed to write tampered bundle")
+	err = bundleImportCeremony(importConfig(tamperedPath), false, rootKeyPath)
+	test.AssertError(t, err, "bundle-import ceremony accepted a tampered bundle")
+	test.AssertEquals(t, err.Error(), "bundle signature is invalid")
+
+	err = bundleImportCeremony(importConfig(bundlePath), false, rootKeyPath)
+	test.AssertNotError(t, err, "bundle-import ceremony failed")
+
+	intCert, err := loadCert(intCertPath)
+	test.AssertNotError(t, err, "failed to load intermediate certificate")
+	root, err := x509.ParseCertificate(rootDER)
+	test.AssertNotError(t, err, "failed to parse root certificate")
+	test.AssertNotError(t, intCert.CheckSignatureFrom(root), "intermediate isn't signed by the root")
+	test.Assert(t, intKey.PublicKey.Equal(intCert.PublicKey), "intermediate certifies the wrong key")
+	test.AssertEquals(t, intCert.NotAfter, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// The result bundle isn't signed, since the issuer's key only signs
+	// certificates, CRLs, and OCSP responses, and refers to the request.
+	resultBytes, err := os.ReadFile(resultPath)
+	test.AssertNotError(t, err, "failed to read result bundle")
+	var result bundleResult
+	err = strictJSONUnmarshal(resultBytes, &result)
+	test.AssertNotError(t, err, "failed to parse result bundle")
+	test.AssertEquals(t, result.Type, bundleResultType)
+	test.AssertByteEquals(t, result.Certificate, intCert.Raw)
+	requestDigest := sha256.Sum256(bundleBytes)
+	test.AssertEquals(t, result.RequestSHA256, hex.EncodeToString(requestDigest[:]))
+
+	// A result bundle can't be imported as a request.
+	_, err = openBundle(resultBytes, bundleKey.Public(), &bundleRequest{})
+	test.AssertError(t, err, "opened a result bundle as a request")
+}
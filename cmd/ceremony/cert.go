@@ -6,13 +6,22 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"math/big"
+	"net"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+	"github.com/letsencrypt/boulder/linter/lints/cpcps"
 )
 
 type policyInfoConfig struct {
@@ -22,6 +31,19 @@ type policyInfoConfig struct {
 	CPSURI string `yaml:"cps-uri"`
 }
 
+// barePolicyWarnings returns a warning for each policy which has no cps-uri.
+// Relying parties which expect a pointer to the CPS will only see the bare
+// policy OID, which isn't an error but should be noted in the ceremony record.
+func barePolicyWarnings(policies []policyInfoConfig) []string {
+	var warnings []string
+	for _, policy := range policies {
+		if policy.CPSURI == "" {
+			warnings = append(warnings, fmt.Sprintf("policy %s has no cps-uri, relying parties expecting a CPS pointer will see a bare OID", policy.OID))
+		}
+	}
+	return warnings
+}
+
 // certProfile contains the information required to generate a certificate
 type certProfile struct {
 	// SignatureAlgorithm should contain one of the allowed signature algorithms
@@ -32,6 +54,12 @@ type certProfile struct {
 	CommonName string `yaml:"common-name"`
 	// Organization should contain the requested subject organization
 	Organization string `yaml:"organization"`
+	// Organizations may be set instead of Organization to request more than
+	// one subject organization, in the given order
+	Organizations []string `yaml:"organizations"`
+	// OrganizationalUnits should contain any requested subject organizational
+	// units, in the given order
+	OrganizationalUnits []string `yaml:"organizational-units"`
 	// Country should contain the requested subject country code
 	Country string `yaml:"country"`
 
@@ -41,7 +69,9 @@ type certProfile struct {
 	NotBefore string `yaml:"not-before"`
 	// NotAfter should contain the requested NotAfter date for the
 	// certificate in the format "2006-01-02 15:04:05". Dates will
-	// always be UTC.
+	// always be UTC. Root certificates may instead use the literal
+	// "99991231235959Z" to indicate that they have no well-defined
+	// expiration date.
 	NotAfter string `yaml:"not-after"`
 
 	// OCSPURL should contain the URL at which a OCSP responder that
@@ -59,9 +89,266 @@ type certProfile struct {
 	// policies extension. It should be empty for Root certs, and contain the
 	// BRs "domain-validated" Reserved Policy Identifier for Intermediates.
 	Policies []policyInfoConfig `yaml:"policies"`
+	// AdditionalPermittedPolicies should contain any policy OIDs, such as an
+	// organization-specific policy, which subordinate CA certificates may
+	// include in Policies alongside the BRs "domain-validated" Reserved Policy
+	// Identifier. It may only be set for subordinate CAs.
+	AdditionalPermittedPolicies []string `yaml:"additional-permitted-policies"`
 
 	// KeyUsages should contain the set of key usage bits to set
 	KeyUsages []string `yaml:"key-usages"`
+
+	// CustomExtensions should contain any additional extensions which should
+	// be included verbatim in the certificate.
+	CustomExtensions []customExtensionConfig `yaml:"custom-extensions"`
+
+	// RequestedExtensions should contain the extensions to request in the
+	// PKCS#9 extensionRequest attribute of a CSR. It may only be set for CSRs.
+	RequestedExtensions *requestedExtensionsConfig `yaml:"requested-extensions"`
+
+	// OmitSKI, if true, causes the subject key identifier extension to be
+	// left out of the certificate. It may only be set for root certificates.
+	OmitSKI bool `yaml:"omit-ski"`
+
+	// AKIForm should contain the form of the authority key identifier, either
+	// akiFormKeyID or akiFormIssuerSerial. Defaults to akiFormKeyID.
+	AKIForm string `yaml:"aki-form"`
+
+	// IssuerUniqueID and SubjectUniqueID should contain the hex encoded
+	// issuerUniqueID and subjectUniqueID fields of the certificate. RFC 5280
+	// forbids these fields, so they may only be set for building test corpora
+	// and require --allow-nonstandard.
+	IssuerUniqueID  string `yaml:"issuer-unique-id"`
+	SubjectUniqueID string `yaml:"subject-unique-id"`
+
+	// ExpectedSubjectDER should contain the hex encoded DER of the subject
+	// name the signed certificate is expected to have. If set, the ceremony
+	// is aborted before the certificate is written if its subject differs
+	// in any byte.
+	ExpectedSubjectDER string `yaml:"expected-subject-der"`
+
+	// QCStatements should contain the statements of a qcStatements extension,
+	// as described in RFC 3739 Section 3.2.6. If empty the extension is
+	// omitted.
+	QCStatements []qcStatementConfig `yaml:"qc-statements"`
+
+	// PermittedDNSDomains, ExcludedDNSDomains, PermittedIPRanges, and
+	// ExcludedIPRanges should contain the subtrees of a critical name
+	// constraints extension, as described in RFC 5280 Section 4.2.1.10. The IP
+	// ranges should be in CIDR notation. If all are empty the extension is
+	// omitted. They may only be set for intermediate certificates.
+	PermittedDNSDomains []string `yaml:"permitted-dns-domains"`
+	ExcludedDNSDomains  []string `yaml:"excluded-dns-domains"`
+	PermittedIPRanges   []string `yaml:"permitted-ip-ranges"`
+	ExcludedIPRanges    []string `yaml:"excluded-ip-ranges"`
+}
+
+// hasNameConstraints returns true if any of the name constraints fields of the
+// profile are set.
+func (profile *certProfile) hasNameConstraints() bool {
+	return len(profile.PermittedDNSDomains) != 0 || len(profile.ExcludedDNSDomains) != 0 ||
+		len(profile.PermittedIPRanges) != 0 || len(profile.ExcludedIPRanges) != 0
+}
+
+// parseIPRanges parses the CIDR notation IP ranges of the named name
+// constraints field. Ranges with host bits set are rejected, since the
+// constraint would silently apply to the whole network instead.
+func parseIPRanges(field string, ranges []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, r := range ranges {
+		ip, ipNet, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s contains invalid CIDR %q: %w", field, r, err)
+		}
+		if !ip.Equal(ipNet.IP) {
+			return nil, fmt.Errorf("%s contains CIDR %q with host bits set, did you mean %q?", field, r, ipNet)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// nameConstraintIPRanges returns the parsed permitted-ip-ranges and
+// excluded-ip-ranges of the profile.
+func (profile *certProfile) nameConstraintIPRanges() ([]*net.IPNet, []*net.IPNet, error) {
+	permitted, err := parseIPRanges("permitted-ip-ranges", profile.PermittedIPRanges)
+	if err != nil {
+		return nil, nil, err
+	}
+	excluded, err := parseIPRanges("excluded-ip-ranges", profile.ExcludedIPRanges)
+	if err != nil {
+		return nil, nil, err
+	}
+	return permitted, excluded, nil
+}
+
+// qcStatementConfig describes a single QCStatement of a qcStatements
+// extension.
+type qcStatementConfig struct {
+	// OID should contain the dotted decimal statementId
+	OID string `yaml:"oid"`
+	// ValueHex should contain the hex encoded DER statementInfo. If empty the
+	// statementInfo is omitted.
+	ValueHex string `yaml:"value-hex"`
+}
+
+// oidQCStatements is the id-pe-qcStatements extension OID from RFC 3739
+// Section 3.2.6.
+var oidQCStatements = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 3}
+
+// qcStatement is the RFC 3739 Section 3.2.6 QCStatement structure.
+type qcStatement struct {
+	StatementID   asn1.ObjectIdentifier
+	StatementInfo asn1.RawValue `asn1:"optional"`
+}
+
+// qcStatementsExtension returns the non-critical qcStatements extension
+// containing statements, in order.
+func qcStatementsExtension(statements []qcStatementConfig) (pkix.Extension, error) {
+	var encoded []qcStatement
+	seen := make(map[string]bool)
+	for _, statement := range statements {
+		oid, err := parseDottedOID(statement.OID)
+		if err != nil {
+			return pkix.Extension{}, fmt.Errorf("invalid qc-statements.oid %q: %w", statement.OID, err)
+		}
+		if seen[oid.String()] {
+			return pkix.Extension{}, fmt.Errorf("qc-statements contains %s more than once", oid)
+		}
+		seen[oid.String()] = true
+		qcs := qcStatement{StatementID: oid}
+		if statement.ValueHex != "" {
+			value, err := hex.DecodeString(statement.ValueHex)
+			if err != nil {
+				return pkix.Extension{}, fmt.Errorf("invalid qc-statements.value-hex for %s: %w", oid, err)
+			}
+			rest, err := asn1.Unmarshal(value, &qcs.StatementInfo)
+			if err != nil || len(rest) != 0 {
+				return pkix.Extension{}, fmt.Errorf("qc-statements value for %s is not a single DER encoded value", oid)
+			}
+		}
+		encoded = append(encoded, qcs)
+	}
+	value, err := asn1.Marshal(encoded)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("failed to encode qc-statements: %w", err)
+	}
+	return pkix.Extension{Id: oidQCStatements, Value: value}, nil
+}
+
+// certUniqueIDs contains the decoded issuerUniqueID and subjectUniqueID of a
+// certificate. Either may be empty, in which case that field is omitted.
+type certUniqueIDs struct {
+	issuer  []byte
+	subject []byte
+}
+
+// present returns true if either unique ID is set.
+func (ids certUniqueIDs) present() bool {
+	return len(ids.issuer) != 0 || len(ids.subject) != 0
+}
+
+// uniqueIDs returns the decoded IssuerUniqueID and SubjectUniqueID of the
+// profile.
+func (profile *certProfile) uniqueIDs() (certUniqueIDs, error) {
+	issuer, err := hex.DecodeString(profile.IssuerUniqueID)
+	if err != nil {
+		return certUniqueIDs{}, fmt.Errorf("issuer-unique-id is not valid hex: %w", err)
+	}
+	subject, err := hex.DecodeString(profile.SubjectUniqueID)
+	if err != nil {
+		return certUniqueIDs{}, fmt.Errorf("subject-unique-id is not valid hex: %w", err)
+	}
+	return certUniqueIDs{issuer: issuer, subject: subject}, nil
+}
+
+// expectedSubject returns the decoded ExpectedSubjectDER of the profile, or nil
+// if it isn't set.
+func (profile *certProfile) expectedSubject() ([]byte, error) {
+	if profile.ExpectedSubjectDER == "" {
+		return nil, nil
+	}
+	der, err := hex.DecodeString(profile.ExpectedSubjectDER)
+	if err != nil {
+		return nil, fmt.Errorf("expected-subject-der is not valid hex: %w", err)
+	}
+	return der, nil
+}
+
+// checkNonstandard returns an error if the profile contains any nonstandard
+// fields and allowNonstandard is false.
+func (profile *certProfile) checkNonstandard(allowNonstandard bool) error {
+	if allowNonstandard {
+		return nil
+	}
+	if profile.IssuerUniqueID != "" || profile.SubjectUniqueID != "" {
+		return errors.New("issuer-unique-id and subject-unique-id can only be set with --allow-nonstandard")
+	}
+	return nil
+}
+
+const (
+	// akiFormKeyID identifies the issuer by its subject key identifier.
+	akiFormKeyID = "key-id"
+	// akiFormIssuerSerial identifies the issuer by the name of its own issuer
+	// and its serial number, as required by some legacy cross-signs.
+	akiFormIssuerSerial = "issuer-serial"
+)
+
+// customExtensionConfig describes an extension which the tool doesn't natively
+// model. Exactly one of ValueHex and ValueBase64 must be set.
+type customExtensionConfig struct {
+	// OID should contain the dotted decimal extension OID
+	OID string `yaml:"oid"`
+	// Critical should indicate whether the extension is marked critical
+	Critical bool `yaml:"critical"`
+	// ValueHex should contain the hex encoded DER extension value
+	ValueHex string `yaml:"value-hex"`
+	// ValueBase64 should contain the base64 encoded DER extension value
+	ValueBase64 string `yaml:"value-base64"`
+}
+
+// extension returns the pkix.Extension described by the config.
+func (cec customExtensionConfig) extension() (pkix.Extension, error) {
+	oid, err := parseOID(cec.OID)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("invalid custom-extensions.oid %q: %w", cec.OID, err)
+	}
+	var value []byte
+	switch {
+	case cec.ValueHex != "" && cec.ValueBase64 != "":
+		return pkix.Extension{}, fmt.Errorf("only one of custom-extensions.value-hex and custom-extensions.value-base64 can be set for %s", oid)
+	case cec.ValueHex != "":
+		value, err = hex.DecodeString(cec.ValueHex)
+		if err != nil {
+			return pkix.Extension{}, fmt.Errorf("invalid custom-extensions.value-hex for %s: %w", oid, err)
+		}
+	case cec.ValueBase64 != "":
+		value, err = base64.StdEncoding.DecodeString(cec.ValueBase64)
+		if err != nil {
+			return pkix.Extension{}, fmt.Errorf("invalid custom-extensions.value-base64 for %s: %w", oid, err)
+		}
+	default:
+		return pkix.Extension{}, fmt.Errorf("one of custom-extensions.value-hex or custom-extensions.value-base64 is required for %s", oid)
+	}
+	var raw asn1.RawValue
+	rest, err := asn1.Unmarshal(value, &raw)
+	if err != nil || len(rest) != 0 {
+		return pkix.Extension{}, fmt.Errorf("custom-extensions value for %s is not a single DER encoded value", oid)
+	}
+	return pkix.Extension{Id: oid, Critical: cec.Critical, Value: value}, nil
+}
+
+// requestedExtensionsConfig contains the extensions which a CSR asks its
+// issuer to include in the resulting certificate.
+type requestedExtensionsConfig struct {
+	// BasicConstraintsCA should contain the requested basicConstraints cA
+	// flag. If unset no basicConstraints extension is requested.
+	BasicConstraintsCA *bool `yaml:"basic-constraints-ca"`
+	// KeyUsages should contain the set of key usage bits to request
+	KeyUsages []string `yaml:"key-usages"`
+	// ExtKeyUsages should contain the set of extended key usages to request
+	ExtKeyUsages []string `yaml:"ext-key-usages"`
 }
 
 // AllowedSigAlgs contains the allowed signature algorithms
@@ -74,6 +361,19 @@ var AllowedSigAlgs = map[string]x509.SignatureAlgorithm{
 	"ECDSAWithSHA512": x509.ECDSAWithSHA512,
 }
 
+// noWellDefinedExpiration is the GeneralizedTime value which RFC 5280 Section
+// 4.1.2.5 reserves for certificates that have no well-defined expiration date.
+const noWellDefinedExpiration = "99991231235959Z"
+
+// parseNotAfter parses the NotAfter date of a certificate profile, mapping the
+// special noWellDefinedExpiration value to the corresponding time.
+func parseNotAfter(notAfter string) (time.Time, error) {
+	if notAfter == noWellDefinedExpiration {
+		return time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC), nil
+	}
+	return time.Parse(time.DateTime, notAfter)
+}
+
 type certType int
 
 const (
@@ -85,15 +385,58 @@ const (
 	requestCert
 )
 
-// Subject returns a pkix.Name from the appropriate certProfile fields
+// organizations returns the subject organizations of the profile, from either
+// the organization or the organizations field.
+func (profile *certProfile) organizations() []string {
+	if len(profile.Organizations) != 0 {
+		return profile.Organizations
+	}
+	if profile.Organization != "" {
+		return []string{profile.Organization}
+	}
+	return nil
+}
+
+// Subject returns a pkix.Name from the appropriate certProfile fields. A
+// pkix.Name encodes the values of a multi-valued field as a single multi-valued
+// RDN, so if the profile has more than one organization or organizational unit
+// every attribute is also listed in ExtraNames, which overrides the fields and
+// is encoded with one RDN per value, in the order crypto/x509 uses for them.
 func (profile *certProfile) Subject() pkix.Name {
-	return pkix.Name{
-		CommonName:   profile.CommonName,
-		Organization: []string{profile.Organization},
-		Country:      []string{profile.Country},
+	name := pkix.Name{
+		CommonName:         profile.CommonName,
+		Organization:       profile.organizations(),
+		OrganizationalUnit: profile.OrganizationalUnits,
+		Country:            []string{profile.Country},
+	}
+	if len(name.Organization) <= 1 && len(name.OrganizationalUnit) <= 1 {
+		return name
 	}
+	attributes := []struct {
+		oid    asn1.ObjectIdentifier
+		values []string
+	}{
+		{asn1.ObjectIdentifier{2, 5, 4, 6}, name.Country},
+		{asn1.ObjectIdentifier{2, 5, 4, 10}, name.Organization},
+		{asn1.ObjectIdentifier{2, 5, 4, 11}, name.OrganizationalUnit},
+		{asn1.ObjectIdentifier{2, 5, 4, 3}, []string{name.CommonName}},
+	}
+	for _, attr := range attributes {
+		for _, value := range attr.values {
+			if value == "" {
+				continue
+			}
+			name.ExtraNames = append(name.ExtraNames, pkix.AttributeTypeAndValue{Type: attr.oid, Value: value})
+		}
+	}
+	return name
 }
 
+// maxOrganizationLength is the X.520 ub-organization-name upper bound, in
+// characters, which is also enforced by zlint's
+// e_subject_organization_name_max_length lint.
+const maxOrganizationLength = 64
+
 func (profile *certProfile) verifyProfile(ct certType) error {
 	if ct == requestCert {
 		if profile.NotBefore != "" {
@@ -120,7 +463,54 @@ func (profile *certProfile) verifyProfile(ct certType) error {
 		if profile.KeyUsages != nil {
 			return errors.New("key-usages cannot be set for a CSR")
 		}
+		if profile.CustomExtensions != nil {
+			return errors.New("custom-extensions cannot be set for a CSR")
+		}
+		if profile.AKIForm != "" {
+			return errors.New("aki-form cannot be set for a CSR")
+		}
+		if profile.IssuerUniqueID != "" || profile.SubjectUniqueID != "" {
+			return errors.New("issuer-unique-id and subject-unique-id cannot be set for a CSR")
+		}
+		if profile.ExpectedSubjectDER != "" {
+			return errors.New("expected-subject-der cannot be set for a CSR")
+		}
+		if profile.QCStatements != nil {
+			return errors.New("qc-statements cannot be set for a CSR")
+		}
+		if profile.RequestedExtensions != nil {
+			err := profile.RequestedExtensions.verify()
+			if err != nil {
+				return err
+			}
+		}
 	} else {
+		if profile.RequestedExtensions != nil {
+			return errors.New("requested-extensions can only be set for a CSR")
+		}
+		if profile.OmitSKI && ct != rootCert {
+			return errors.New("omit-ski can only be set for root certificates")
+		}
+		if profile.AKIForm != "" && ct == rootCert {
+			return errors.New("aki-form cannot be set for root certificates")
+		}
+		if profile.AKIForm != "" && profile.AKIForm != akiFormKeyID && profile.AKIForm != akiFormIssuerSerial {
+			return fmt.Errorf("aki-form %q is not one of %q or %q", profile.AKIForm, akiFormKeyID, akiFormIssuerSerial)
+		}
+		_, err := profile.uniqueIDs()
+		if err != nil {
+			return err
+		}
+		_, err = profile.expectedSubject()
+		if err != nil {
+			return err
+		}
+		if len(profile.QCStatements) != 0 {
+			_, err = qcStatementsExtension(profile.QCStatements)
+			if err != nil {
+				return err
+			}
+		}
 		if profile.NotBefore == "" {
 			return errors.New("not-before is required")
 		}
@@ -134,17 +524,50 @@ func (profile *certProfile) verifyProfile(ct certType) error {
 	if profile.CommonName == "" {
 		return errors.New("common-name is required")
 	}
-	if profile.Organization == "" {
+	if profile.Organization != "" && len(profile.Organizations) != 0 {
+		return errors.New("organization and organizations cannot both be set")
+	}
+	if len(profile.organizations()) == 0 {
 		return errors.New("organization is required")
 	}
+	if slices.Contains(profile.Organizations, "") {
+		return errors.New("organizations cannot contain an empty value")
+	}
+	for _, o := range profile.organizations() {
+		if utf8.RuneCountInString(o) > maxOrganizationLength {
+			return fmt.Errorf("organization %q is longer than the maximum of %d characters", o, maxOrganizationLength)
+		}
+	}
+	if slices.Contains(profile.OrganizationalUnits, "") {
+		return errors.New("organizational-units cannot contain an empty value")
+	}
 	if profile.Country == "" {
 		return errors.New("country is required")
 	}
 
+	seenCustomExtensions := make(map[string]bool)
+	for _, cec := range profile.CustomExtensions {
+		ext, err := cec.extension()
+		if err != nil {
+			return err
+		}
+		for _, emitted := range emittedExtensions {
+			if ext.Id.Equal(emitted) {
+				return fmt.Errorf("custom-extensions cannot contain %s, which is already emitted by this tool", ext.Id)
+			}
+		}
+		if seenCustomExtensions[ext.Id.String()] {
+			return fmt.Errorf("custom-extensions contains %s more than once", ext.Id)
+		}
+		seenCustomExtensions[ext.Id.String()] = true
+	}
+
 	if ct == rootCert {
 		if len(profile.Policies) != 0 {
 			return errors.New("policies should not be set on root certs")
 		}
+	} else if profile.NotAfter == noWellDefinedExpiration {
+		return fmt.Errorf("not-after of %q is only allowed for root certs", noWellDefinedExpiration)
 	}
 
 	if ct == intermediateCert || ct == crossCert {
@@ -157,9 +580,32 @@ func (profile *certProfile) verifyProfile(ct certType) error {
 
 		// BR 7.1.2.10.5 CA Certificate Certificate Policies
 		// OID 2.23.140.1.2.1 is an anyPolicy
-		if len(profile.Policies) != 1 || profile.Policies[0].OID != "2.23.140.1.2.1" {
+		reserved := 0
+		for _, policy := range profile.Policies {
+			if policy.OID == "2.23.140.1.2.1" {
+				reserved++
+			}
+		}
+		if reserved != 1 {
 			return errors.New("policy should be exactly BRs domain-validated for subordinate CAs")
 		}
+		for _, oid := range profile.AdditionalPermittedPolicies {
+			_, err := parseOID(oid)
+			if err != nil {
+				return fmt.Errorf("additional-permitted-policies contains %q: %w", oid, err)
+			}
+		}
+		for _, policy := range profile.Policies {
+			if policy.OID == "2.23.140.1.2.1" || slices.Contains(profile.AdditionalPermittedPolicies, policy.OID) {
+				continue
+			}
+			if len(profile.AdditionalPermittedPolicies) == 0 {
+				return errors.New("policy should be exactly BRs domain-validated for subordinate CAs")
+			}
+			return fmt.Errorf("policy %s is not permitted for subordinate CAs, as it isn't listed in additional-permitted-policies", policy.OID)
+		}
+	} else if len(profile.AdditionalPermittedPolicies) != 0 {
+		return errors.New("additional-permitted-policies can only be set for subordinate CAs")
 	}
 
 	if ct == ocspCert || ct == crlCert {
@@ -173,9 +619,103 @@ func (profile *certProfile) verifyProfile(ct certType) error {
 			return errors.New("ocsp-url cannot be set for a delegated signer")
 		}
 	}
+
+	if profile.hasNameConstraints() {
+		if ct != intermediateCert {
+			return errors.New("permitted-dns-domains, excluded-dns-domains, permitted-ip-ranges, and excluded-ip-ranges can only be set for intermediate certificates")
+		}
+		for _, domains := range [][]string{profile.PermittedDNSDomains, profile.ExcludedDNSDomains} {
+			for _, domain := range domains {
+				if domain == "" {
+					return errors.New("permitted-dns-domains and excluded-dns-domains cannot contain an empty domain")
+				}
+			}
+		}
+		_, _, err := profile.nameConstraintIPRanges()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, kuStr := range profile.KeyUsages {
+		if _, ok := stringToKeyUsage[kuStr]; !ok {
+			return fmt.Errorf("unknown key-usages value %q", kuStr)
+		}
+	}
+
+	if ct != requestCert {
+		err := profile.checkValidity(ct)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxValidity is the longest validity period which the CPS permits for each
+// type of CA certificate.
+var maxValidity = map[certType]time.Duration{
+	// CPS 7.1: "Root CA Certificate Validity Period: Up to 25 years."
+	rootCert: 25 * 365 * lints.BRDay,
+	// CPS 7.1: "Intermediate CA Certificate Validity Period: Up to 8 years."
+	intermediateCert: cpcps.MaxSubordinateCAValidity,
+	crossCert:        cpcps.MaxSubordinateCAValidity,
+}
+
+// checkValidity parses the not-before and not-after dates of the profile, as
+// makeTemplate does, and checks that they describe a validity period which is
+// positive and no longer than maxValidity permits for ct.
+func (profile *certProfile) checkValidity(ct certType) error {
+	notBefore, err := time.Parse(time.DateTime, profile.NotBefore)
+	if err != nil {
+		return fmt.Errorf("failed to parse not-before: %w", err)
+	}
+	notAfter, err := parseNotAfter(profile.NotAfter)
+	if err != nil {
+		return fmt.Errorf("failed to parse not-after: %w", err)
+	}
+	if !notAfter.After(notBefore) {
+		return errors.New("not-after must be after not-before")
+	}
+	// Roots with no well-defined expiration date are exempt from the maximum
+	// validity period.
+	if profile.NotAfter == noWellDefinedExpiration {
+		return nil
+	}
+	// RFC 5280 4.1.2.5: "The validity period for a certificate is the period
+	// of time from notBefore through notAfter, inclusive."
+	validity := notAfter.Add(time.Second).Sub(notBefore)
+	limit, ok := maxValidity[ct]
+	if ok && validity > limit {
+		return fmt.Errorf("validity period from not-before to not-after is %s, which is longer than the maximum of %d days", validity, limit/lints.BRDay)
+	}
 	return nil
 }
 
+// parseDottedOID parses a dotted decimal OID. Unlike parseOID it allows arcs to
+// be zero, as in the ETSI statement OIDs used by qcStatements, but it checks
+// that the first two arcs can be DER encoded.
+func parseDottedOID(oidStr string) (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	for _, a := range strings.Split(oidStr, ".") {
+		i, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 {
+			return nil, errors.New("OID components must be >= 0")
+		}
+		oid = append(oid, i)
+	}
+	if len(oid) < 2 {
+		return nil, errors.New("OIDs must have at least two components")
+	}
+	if oid[0] > 2 || (oid[0] < 2 && oid[1] >= 40) {
+		return nil, errors.New("OID has an invalid first or second component")
+	}
+	return oid, nil
+}
+
 func parseOID(oidStr string) (asn1.ObjectIdentifier, error) {
 	var oid asn1.ObjectIdentifier
 	for _, a := range strings.Split(oidStr, ".") {
@@ -197,7 +737,173 @@ var stringToKeyUsage = map[string]x509.KeyUsage{
 	"Cert Sign":         x509.KeyUsageCertSign,
 }
 
-var oidOCSPNoCheck = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+// defaultSubordinateKeyUsage is the key usage of intermediate and cross
+// certificates whose profile doesn't set key-usages.
+const defaultSubordinateKeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+// keyUsage returns the key usage bits of a certificate of type ct issued from
+// the profile. Delegated signers always get the single bit they need, and
+// intermediate and cross certificates get defaultSubordinateKeyUsage if the
+// profile doesn't set key-usages.
+func (profile *certProfile) keyUsage(ct certType) (x509.KeyUsage, error) {
+	switch ct {
+	case ocspCert:
+		return x509.KeyUsageDigitalSignature, nil
+	case crlCert:
+		return x509.KeyUsageCRLSign, nil
+	}
+	var ku x509.KeyUsage
+	for _, kuStr := range profile.KeyUsages {
+		kuBit, ok := stringToKeyUsage[kuStr]
+		if !ok {
+			return 0, fmt.Errorf("unknown key usage %q", kuStr)
+		}
+		ku |= kuBit
+	}
+	if len(profile.KeyUsages) == 0 && (ct == intermediateCert || ct == crossCert) {
+		ku = defaultSubordinateKeyUsage
+	}
+	if ku == 0 {
+		return 0, errors.New("at least one key usage must be set")
+	}
+	return ku, nil
+}
+
+var stringToExtKeyUsage = map[string]asn1.ObjectIdentifier{
+	"Server Auth":  {1, 3, 6, 1, 5, 5, 7, 3, 1},
+	"Client Auth":  {1, 3, 6, 1, 5, 5, 7, 3, 2},
+	"OCSP Signing": {1, 3, 6, 1, 5, 5, 7, 3, 9},
+}
+
+var (
+	oidOCSPNoCheck            = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+	oidSubjectKeyIdentifier   = asn1.ObjectIdentifier{2, 5, 29, 14}
+	oidKeyUsage               = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidBasicConstraints       = asn1.ObjectIdentifier{2, 5, 29, 19}
+	oidAuthorityKeyIdentifier = asn1.ObjectIdentifier{2, 5, 29, 35}
+	oidExtendedKeyUsage       = asn1.ObjectIdentifier{2, 5, 29, 37}
+)
+
+// emittedExtensions contains the OIDs of the extensions which this tool, or
+// x509.CreateCertificate on its behalf, may emit. Custom extensions with these
+// OIDs would replace the natively modeled extension, so they are rejected.
+var emittedExtensions = []asn1.ObjectIdentifier{
+	oidSubjectKeyIdentifier,     // subjectKeyIdentifier
+	oidAuthorityKeyIdentifier,   // authorityKeyIdentifier
+	oidKeyUsage,                 // keyUsage
+	oidBasicConstraints,         // basicConstraints
+	oidExtendedKeyUsage,         // extKeyUsage
+	{2, 5, 29, 17},              // subjectAltName
+	{2, 5, 29, 30},              // nameConstraints
+	{2, 5, 29, 31},              // cRLDistributionPoints
+	{2, 5, 29, 32},              // certificatePolicies
+	{1, 3, 6, 1, 5, 5, 7, 1, 1}, // authorityInfoAccess
+	oidOCSPNoCheck,              // id-pkix-ocsp-nocheck
+	oidQCStatements,             // qcStatements
+}
+
+func (rec *requestedExtensionsConfig) verify() error {
+	if rec.BasicConstraintsCA == nil && len(rec.KeyUsages) == 0 && len(rec.ExtKeyUsages) == 0 {
+		return errors.New("requested-extensions must request at least one extension")
+	}
+	var ku x509.KeyUsage
+	for _, kuStr := range rec.KeyUsages {
+		kuBit, ok := stringToKeyUsage[kuStr]
+		if !ok {
+			return fmt.Errorf("unknown requested-extensions.key-usages value %q", kuStr)
+		}
+		ku |= kuBit
+	}
+	for _, ekuStr := range rec.ExtKeyUsages {
+		_, ok := stringToExtKeyUsage[ekuStr]
+		if !ok {
+			return fmt.Errorf("unknown requested-extensions.ext-key-usages value %q", ekuStr)
+		}
+	}
+	// RFC 5280 Section 4.2.1.9: "If the keyCertSign bit is asserted, then the
+	// cA bit in the basic constraints extension MUST also be asserted."
+	isCA := rec.BasicConstraintsCA != nil && *rec.BasicConstraintsCA
+	if ku&x509.KeyUsageCertSign != 0 && !isCA {
+		return errors.New("requested-extensions.key-usages can only contain \"Cert Sign\" if basic-constraints-ca is true")
+	}
+	if isCA && len(rec.KeyUsages) > 0 && ku&x509.KeyUsageCertSign == 0 {
+		return errors.New("requested-extensions.key-usages must contain \"Cert Sign\" if basic-constraints-ca is true")
+	}
+	return nil
+}
+
+// reverseBitsInAByte reverses the order of the bits in a byte, which is
+// required to encode a x509.KeyUsage as an ASN.1 BIT STRING.
+func reverseBitsInAByte(in byte) byte {
+	b1 := in>>4 | in<<4
+	b2 := b1>>2&0x33 | b1<<2&0xcc
+	b3 := b2>>1&0x55 | b2<<1&0xaa
+	return b3
+}
+
+// marshalKeyUsage returns the DER encoding of a keyUsage extension value
+// asserting the bits in ku.
+func marshalKeyUsage(ku x509.KeyUsage) ([]byte, error) {
+	a := []byte{reverseBitsInAByte(byte(ku)), reverseBitsInAByte(byte(ku >> 8))}
+	if a[1] == 0 {
+		a = a[:1]
+	}
+	bitLength := len(a) * 8
+	for i := 0; i < 8; i++ {
+		if a[len(a)-1]&(1<<i) != 0 {
+			break
+		}
+		bitLength--
+	}
+	return asn1.Marshal(asn1.BitString{Bytes: a, BitLength: bitLength})
+}
+
+// extensions returns the extensions which should be included in the
+// extensionRequest attribute of a CSR. BasicConstraints and KeyUsage are
+// marked critical, as they would be in the issued certificate.
+func (rec *requestedExtensionsConfig) extensions() ([]pkix.Extension, error) {
+	var exts []pkix.Extension
+	if rec.BasicConstraintsCA != nil {
+		value, err := asn1.Marshal(struct {
+			IsCA bool `asn1:"optional"`
+		}{*rec.BasicConstraintsCA})
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, pkix.Extension{Id: oidBasicConstraints, Critical: true, Value: value})
+	}
+	if len(rec.KeyUsages) > 0 {
+		var ku x509.KeyUsage
+		for _, kuStr := range rec.KeyUsages {
+			kuBit, ok := stringToKeyUsage[kuStr]
+			if !ok {
+				return nil, fmt.Errorf("unknown key usage %q", kuStr)
+			}
+			ku |= kuBit
+		}
+		value, err := marshalKeyUsage(ku)
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, pkix.Extension{Id: oidKeyUsage, Critical: true, Value: value})
+	}
+	if len(rec.ExtKeyUsages) > 0 {
+		var ekus []asn1.ObjectIdentifier
+		for _, ekuStr := range rec.ExtKeyUsages {
+			eku, ok := stringToExtKeyUsage[ekuStr]
+			if !ok {
+				return nil, fmt.Errorf("unknown extended key usage %q", ekuStr)
+			}
+			ekus = append(ekus, eku)
+		}
+		value, err := asn1.Marshal(ekus)
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, pkix.Extension{Id: oidExtendedKeyUsage, Value: value})
+	}
+	return exts, nil
+}
 
 func generateSKID(pk []byte) ([]byte, error) {
 	var pkixPublicKey struct {
@@ -231,32 +937,24 @@ func makeTemplate(randReader io.Reader, profile *certProfile, pubKey []byte, tbc
 		issuingCertificateURL = []string{profile.IssuerURL}
 	}
 
-	subjectKeyID, err := generateSKID(pubKey)
-	if err != nil {
-		return nil, err
+	var subjectKeyID []byte
+	if !profile.OmitSKI {
+		var err error
+		subjectKeyID, err = generateSKID(pubKey)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	serial := make([]byte, 16)
-	_, err = randReader.Read(serial)
+	_, err := randReader.Read(serial)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate serial number: %s", err)
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
 	}
 
-	var ku x509.KeyUsage
-	for _, kuStr := range profile.KeyUsages {
-		kuBit, ok := stringToKeyUsage[kuStr]
-		if !ok {
-			return nil, fmt.Errorf("unknown key usage %q", kuStr)
-		}
-		ku |= kuBit
-	}
-	if ct == ocspCert {
-		ku = x509.KeyUsageDigitalSignature
-	} else if ct == crlCert {
-		ku = x509.KeyUsageCRLSign
-	}
-	if ku == 0 {
-		return nil, errors.New("at least one key usage must be set")
+	ku, err := profile.keyUsage(ct)
+	if err != nil {
+		return nil, err
 	}
 
 	cert := &x509.Certificate{
@@ -282,7 +980,7 @@ func makeTemplate(randReader io.Reader, profile *certProfile, pubKey []byte, tbc
 			return nil, err
 		}
 		cert.NotBefore = notBefore
-		notAfter, err := time.Parse(time.DateTime, profile.NotAfter)
+		notAfter, err := parseNotAfter(profile.NotAfter)
 		if err != nil {
 			return nil, err
 		}
@@ -315,6 +1013,9 @@ func makeTemplate(randReader io.Reader, profile *certProfile, pubKey []byte, tbc
 		cert.MaxPathLenZero = tbcs.MaxPathLenZero
 	}
 
+	for _, warning := range barePolicyWarnings(profile.Policies) {
+		log.Printf("WARNING: %s\n", warning)
+	}
 	for _, policyConfig := range profile.Policies {
 		oid, err := parseOID(policyConfig.OID)
 		if err != nil {
@@ -323,9 +1024,133 @@ func makeTemplate(randReader io.Reader, profile *certProfile, pubKey []byte, tbc
 		cert.PolicyIdentifiers = append(cert.PolicyIdentifiers, oid)
 	}
 
+	if profile.hasNameConstraints() {
+		permitted, excluded, err := profile.nameConstraintIPRanges()
+		if err != nil {
+			return nil, err
+		}
+		// RFC 5280 Section 4.2.1.10: "Conforming CAs MUST mark this
+		// extension as critical".
+		cert.PermittedDNSDomainsCritical = true
+		cert.PermittedDNSDomains = profile.PermittedDNSDomains
+		cert.ExcludedDNSDomains = profile.ExcludedDNSDomains
+		cert.PermittedIPRanges = permitted
+		cert.ExcludedIPRanges = excluded
+	}
+
+	if len(profile.QCStatements) != 0 {
+		ext, err := qcStatementsExtension(profile.QCStatements)
+		if err != nil {
+			return nil, err
+		}
+		cert.ExtraExtensions = append(cert.ExtraExtensions, ext)
+	}
+
+	for _, cec := range profile.CustomExtensions {
+		ext, err := cec.extension()
+		if err != nil {
+			return nil, err
+		}
+		cert.ExtraExtensions = append(cert.ExtraExtensions, ext)
+	}
+
 	return cert, nil
 }
 
+// issuerSerialAKI is the RFC 5280 Section 4.2.1.1 AuthorityKeyIdentifier
+// structure with only the authorityCertIssuer and authorityCertSerialNumber
+// fields present.
+type issuerSerialAKI struct {
+	AuthorityCertIssuer       asn1.RawValue
+	AuthorityCertSerialNumber *big.Int `asn1:"tag:2"`
+}
+
+// setAuthorityKeyID populates the authority key identifier of template, which
+// is to be issued by issuer, in the form requested by profile.
+func setAuthorityKeyID(template *x509.Certificate, profile *certProfile, issuer *x509.Certificate) error {
+	switch profile.AKIForm {
+	case "", akiFormKeyID:
+		template.AuthorityKeyId = issuer.SubjectKeyId
+	case akiFormIssuerSerial:
+		// authorityCertIssuer is a GeneralNames containing the issuer's issuer
+		// as a directoryName, which is explicitly tagged as Name is a CHOICE.
+		directoryName, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: issuer.RawIssuer})
+		if err != nil {
+			return fmt.Errorf("failed to encode authority key identifier issuer: %w", err)
+		}
+		value, err := asn1.Marshal(issuerSerialAKI{
+			AuthorityCertIssuer:       asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: directoryName},
+			AuthorityCertSerialNumber: issuer.SerialNumber,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to encode authority key identifier: %w", err)
+		}
+		// x509.CreateCertificate doesn't generate an authority key identifier
+		// when ExtraExtensions already contains one.
+		template.AuthorityKeyId = nil
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidAuthorityKeyIdentifier, Value: value})
+	default:
+		return fmt.Errorf("unknown aki-form %q", profile.AKIForm)
+	}
+	return nil
+}
+
+// fillSubjectFromCSR sets the common-name, organization, and country of
+// profile from the subject of csr. A field which is already set, including
+// organizations, must match the CSR, and organizational-units must be unset.
+// The CSR's subject must contain at most one of each of those attributes and
+// no others.
+func (profile *certProfile) fillSubjectFromCSR(csr *x509.CertificateRequest) error {
+	subject := csr.Subject
+	if len(subject.Organization) > 1 || len(subject.Country) > 1 {
+		return errors.New("CSR subject contains more than one organization or country")
+	}
+	expected := len(subject.Organization) + len(subject.Country)
+	if subject.CommonName != "" {
+		expected++
+	}
+	if len(subject.Names) != expected {
+		return errors.New("CSR subject contains attributes other than commonName, organization, and country")
+	}
+
+	if len(profile.Organizations) != 0 && !slices.Equal(profile.Organizations, subject.Organization) {
+		return fmt.Errorf("certificate-profile.organizations is %q, but the CSR subject contains %q", profile.Organizations, subject.Organization)
+	}
+	if len(profile.OrganizationalUnits) != 0 {
+		return fmt.Errorf("certificate-profile.organizational-units is %q, but the CSR subject contains none", profile.OrganizationalUnits)
+	}
+
+	type subjectField struct {
+		name    string
+		profile *string
+		csr     string
+	}
+	fields := []subjectField{{"common-name", &profile.CommonName, subject.CommonName}}
+	// The organization is only filled in if the profile doesn't list its
+	// organizations, which were compared with the CSR above.
+	if len(profile.Organizations) == 0 {
+		fields = append(fields, subjectField{"organization", &profile.Organization, strings.Join(subject.Organization, "")})
+	}
+	fields = append(fields, subjectField{"country", &profile.Country, strings.Join(subject.Country, "")})
+	for _, f := range fields {
+		if *f.profile == "" {
+			*f.profile = f.csr
+		} else if *f.profile != f.csr {
+			return fmt.Errorf("certificate-profile.%s is %q, but the CSR subject contains %q", f.name, *f.profile, f.csr)
+		}
+	}
+	return nil
+}
+
+// setSubjectAltNamesFromCSR copies the subject alternative names requested by
+// csr into template.
+func setSubjectAltNamesFromCSR(template *x509.Certificate, csr *x509.CertificateRequest) {
+	template.DNSNames = csr.DNSNames
+	template.EmailAddresses = csr.EmailAddresses
+	template.IPAddresses = csr.IPAddresses
+	template.URIs = csr.URIs
+}
+
 // failReader exists to be passed to x509.CreateCertificate which requires
 // a source of randomness for signing methods that require a source of
 // randomness. Since HSM based signing will generate its own randomness
@@ -339,11 +1164,21 @@ func (fr *failReader) Read([]byte) (int, error) {
 }
 
 func generateCSR(profile *certProfile, signer crypto.Signer) ([]byte, error) {
-	csrDER, err := x509.CreateCertificateRequest(&failReader{}, &x509.CertificateRequest{
+	template := &x509.CertificateRequest{
 		Subject: profile.Subject(),
-	}, signer)
+	}
+	if profile.RequestedExtensions != nil {
+		exts, err := profile.RequestedExtensions.extensions()
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct requested extensions: %w", err)
+		}
+		// x509.CreateCertificateRequest places ExtraExtensions in the PKCS#9
+		// extensionRequest attribute.
+		template.ExtraExtensions = exts
+	}
+	csrDER, err := x509.CreateCertificateRequest(&failReader{}, template, signer)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create and sign CSR: %s", err)
+		return nil, fmt.Errorf("failed to create and sign CSR: %w", err)
 	}
 	return csrDER, nil
 }
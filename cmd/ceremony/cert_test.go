@@ -2,19 +2,28 @@ package main
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"math/big"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/letsencrypt/boulder/linter"
 	"github.com/letsencrypt/boulder/pkcs11helpers"
 	"github.com/letsencrypt/boulder/test"
 	"github.com/miekg/pkcs11"
@@ -62,6 +71,49 @@ func TestMakeSubject(t *testing.T) {
 	test.AssertDeepEquals(t, profile.Subject(), expectedSubject)
 }
 
+func TestMakeSubjectMultipleValues(t *testing.T) {
+	profile := &certProfile{
+		CommonName:          "common name",
+		Organizations:       []string{"first organization", "second organization"},
+		OrganizationalUnits: []string{"first unit", "second unit"},
+		Country:             "US",
+	}
+	subject := profile.Subject()
+	test.AssertDeepEquals(t, subject.Organization, []string{"first organization", "second organization"})
+	test.AssertDeepEquals(t, subject.OrganizationalUnit, []string{"first unit", "second unit"})
+
+	// Each value is its own RDN, in the order they're listed, and the
+	// attributes are in the order crypto/x509 always encodes them.
+	var types, values []string
+	for _, rdn := range subject.ToRDNSequence() {
+		test.AssertEquals(t, len(rdn), 1)
+		types = append(types, rdn[0].Type.String())
+		values = append(values, rdn[0].Value.(string))
+	}
+	test.AssertDeepEquals(t, types, []string{"2.5.4.6", "2.5.4.10", "2.5.4.10", "2.5.4.11", "2.5.4.11", "2.5.4.3"})
+	test.AssertDeepEquals(t, values, []string{"US", "first organization", "second organization", "first unit", "second unit", "common name"})
+	test.AssertEquals(t, subject.String(), "CN=common name,OU=second unit,OU=first unit,O=second organization,O=first organization,C=US")
+
+	// The singular organization field produces the same subject as a single
+	// element organizations list.
+	single := &certProfile{CommonName: "common name", Organization: "organization", Country: "US"}
+	list := &certProfile{CommonName: "common name", Organizations: []string{"organization"}, Country: "US"}
+	test.AssertDeepEquals(t, single.Subject(), list.Subject())
+}
+
+func TestBarePolicyWarnings(t *testing.T) {
+	warnings := barePolicyWarnings([]policyInfoConfig{
+		{OID: "2.23.140.1.2.1"},
+		{OID: "1.3.6.1.4.1.44947.1.1.1", CPSURI: "http://cps.example.org"},
+	})
+	test.AssertDeepEquals(t, warnings, []string{
+		"policy 2.23.140.1.2.1 has no cps-uri, relying parties expecting a CPS pointer will see a bare OID",
+	})
+
+	warnings = barePolicyWarnings([]policyInfoConfig{{OID: "2.23.140.1.2.1", CPSURI: "http://cps.example.org"}})
+	test.AssertEquals(t, len(warnings), 0)
+}
+
 func TestMakeTemplateRoot(t *testing.T) {
 	s, ctx := pkcs11helpers.NewSessionWithMock()
 	profile := &certProfile{}
@@ -173,6 +225,43 @@ func TestMakeTemplateRestrictedCrossCertificate(t *testing.T) {
 	test.AssertEquals(t, cert.ExtKeyUsage[0], x509.ExtKeyUsageServerAuth)
 }
 
+func TestMakeTemplateKeyUsage(t *testing.T) {
+	s, ctx := pkcs11helpers.NewSessionWithMock()
+	ctx.GenerateRandomFunc = realRand
+	randReader := newRandReader(s)
+	pubKey := samplePubkey()
+	profile := &certProfile{
+		SignatureAlgorithm: "SHA256WithRSA",
+		CommonName:         "common name",
+		Organization:       "organization",
+		Country:            "country",
+		CRLURL:             "crl",
+		IssuerURL:          "issuer",
+		NotAfter:           "2020-10-10 11:31:00",
+		NotBefore:          "2020-10-10 11:31:00",
+	}
+
+	// Without key-usages subordinate CAs get the usages which every
+	// intermediate has been issued with, while roots must list theirs.
+	_, err := makeTemplate(randReader, profile, pubKey, nil, rootCert)
+	test.AssertError(t, err, "makeTemplate didn't fail for a root without key usages")
+	for _, ct := range []certType{intermediateCert, crossCert} {
+		cert, err := makeTemplate(randReader, profile, pubKey, &x509.Certificate{}, ct)
+		test.AssertNotError(t, err, "makeTemplate failed without key usages")
+		test.AssertEquals(t, cert.KeyUsage, x509.KeyUsageDigitalSignature|x509.KeyUsageCertSign|x509.KeyUsageCRLSign)
+	}
+
+	profile.KeyUsages = []string{"Digital Signature", "Cert Sign", "CRL Sign"}
+	cert, err := makeTemplate(randReader, profile, pubKey, nil, intermediateCert)
+	test.AssertNotError(t, err, "makeTemplate failed with the default key usages")
+	test.AssertEquals(t, cert.KeyUsage, defaultSubordinateKeyUsage)
+
+	profile.KeyUsages = []string{"Digital Signature", "Cert Sign"}
+	cert, err = makeTemplate(randReader, profile, pubKey, nil, intermediateCert)
+	test.AssertNotError(t, err, "makeTemplate failed with custom key usages")
+	test.AssertEquals(t, cert.KeyUsage, x509.KeyUsageDigitalSignature|x509.KeyUsageCertSign)
+}
+
 func TestMakeTemplateOCSP(t *testing.T) {
 	s, ctx := pkcs11helpers.NewSessionWithMock()
 	ctx.GenerateRandomFunc = realRand
@@ -253,23 +342,23 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore: "a",
+				NotBefore: "2020-01-01 00:00:00",
 			},
 			certType:    []certType{intermediateCert, crossCert},
 			expectedErr: "not-after is required",
 		},
 		{
 			profile: certProfile{
-				NotBefore: "a",
-				NotAfter:  "b",
+				NotBefore: "2020-01-01 00:00:00",
+				NotAfter:  "2025-01-01 00:00:00",
 			},
 			certType:    []certType{intermediateCert, crossCert},
 			expectedErr: "signature-algorithm is required",
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 			},
 			certType:    []certType{intermediateCert, crossCert},
@@ -277,8 +366,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 			},
@@ -287,8 +376,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -298,8 +387,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -311,8 +400,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -325,8 +414,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -340,8 +429,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -356,19 +445,290 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:                   "2020-01-01 00:00:00",
+				NotAfter:                    "2025-01-01 00:00:00",
+				SignatureAlgorithm:          "c",
+				CommonName:                  "d",
+				Organization:                "e",
+				Country:                     "f",
+				OCSPURL:                     "g",
+				CRLURL:                      "h",
+				IssuerURL:                   "i",
+				Policies:                    []policyInfoConfig{{OID: "2.23.140.1.2.1"}, {OID: "1.3.6.1.4.1.44947.1.1.1"}},
+				AdditionalPermittedPolicies: []string{"1.3.6.1.4.1.44947.1.1.1"},
+			},
+			certType: []certType{intermediateCert, crossCert},
+		},
+		{
+			profile: certProfile{
+				NotBefore:                   "2020-01-01 00:00:00",
+				NotAfter:                    "2025-01-01 00:00:00",
+				SignatureAlgorithm:          "c",
+				CommonName:                  "d",
+				Organization:                "e",
+				Country:                     "f",
+				OCSPURL:                     "g",
+				CRLURL:                      "h",
+				IssuerURL:                   "i",
+				Policies:                    []policyInfoConfig{{OID: "2.23.140.1.2.1"}, {OID: "1.2.3"}},
+				AdditionalPermittedPolicies: []string{"1.3.6.1.4.1.44947.1.1.1"},
+			},
+			certType:    []certType{intermediateCert, crossCert},
+			expectedErr: "policy 1.2.3 is not permitted for subordinate CAs, as it isn't listed in additional-permitted-policies",
+		},
+		{
+			profile: certProfile{
+				NotBefore:                   "2020-01-01 00:00:00",
+				NotAfter:                    "2025-01-01 00:00:00",
+				SignatureAlgorithm:          "c",
+				CommonName:                  "d",
+				Organization:                "e",
+				Country:                     "f",
+				OCSPURL:                     "g",
+				CRLURL:                      "h",
+				IssuerURL:                   "i",
+				Policies:                    []policyInfoConfig{{OID: "1.3.6.1.4.1.44947.1.1.1"}},
+				AdditionalPermittedPolicies: []string{"1.3.6.1.4.1.44947.1.1.1"},
+			},
+			certType:    []certType{intermediateCert, crossCert},
+			expectedErr: "policy should be exactly BRs domain-validated for subordinate CAs",
+		},
+		{
+			profile: certProfile{
+				NotBefore:                   "2020-01-01 00:00:00",
+				NotAfter:                    "2025-01-01 00:00:00",
+				SignatureAlgorithm:          "c",
+				CommonName:                  "d",
+				Organization:                "e",
+				Country:                     "f",
+				AdditionalPermittedPolicies: []string{"1.3.6.1.4.1.44947.1.1.1"},
+			},
+			certType:    []certType{rootCert},
+			expectedErr: "additional-permitted-policies can only be set for subordinate CAs",
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
+				SignatureAlgorithm: "c",
+				CommonName:         "d",
+				Organization:       "e",
+				Country:            "f",
+			},
+			certType: []certType{rootCert},
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
+				SignatureAlgorithm: "c",
+				CommonName:         "d",
+				Organization:       "e",
+				Country:            "f",
+				KeyUsages:          []string{"Cert Sign", "Key Agreement"},
+			},
+			certType:    []certType{rootCert},
+			expectedErr: "unknown key-usages value \"Key Agreement\"",
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
+				SignatureAlgorithm: "c",
+				CommonName:         "d",
+				Organization:       "e",
+				Country:            "f",
+				KeyUsages:          []string{"Cert Sign", "digitalSignature"},
+			},
+			certType:    []certType{rootCert},
+			expectedErr: "unknown key-usages value \"digitalSignature\"",
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
+				Country:            "f",
 				Organization:       "e",
+				Organizations:      []string{"e"},
+			},
+			certType:    []certType{rootCert},
+			expectedErr: "organization and organizations cannot both be set",
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
+				SignatureAlgorithm: "c",
+				CommonName:         "d",
+				Country:            "f",
+				Organizations:      []string{},
+			},
+			certType:    []certType{rootCert},
+			expectedErr: "organization is required",
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
+				SignatureAlgorithm: "c",
+				CommonName:         "d",
+				Country:            "f",
+				Organizations:      []string{"e", ""},
+			},
+			certType:    []certType{rootCert},
+			expectedErr: "organizations cannot contain an empty value",
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
+				SignatureAlgorithm: "c",
+				CommonName:         "d",
+				Country:            "f",
+				Organizations:      []string{"e", strings.Repeat("o", 65)},
+			},
+			certType:    []certType{rootCert},
+			expectedErr: fmt.Sprintf("organization %q is longer than the maximum of 64 characters", strings.Repeat("o", 65)),
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
+				SignatureAlgorithm: "c",
+				CommonName:         "d",
 				Country:            "f",
+				Organization:       strings.Repeat("é", 65),
+			},
+			certType:    []certType{rootCert},
+			expectedErr: fmt.Sprintf("organization %q is longer than the maximum of 64 characters", strings.Repeat("é", 65)),
+		},
+		{
+			profile: certProfile{
+				NotBefore:           "2020-01-01 00:00:00",
+				NotAfter:            "2025-01-01 00:00:00",
+				SignatureAlgorithm:  "c",
+				CommonName:          "d",
+				Country:             "f",
+				Organizations:       []string{"e"},
+				OrganizationalUnits: []string{""},
+			},
+			certType:    []certType{rootCert},
+			expectedErr: "organizational-units cannot contain an empty value",
+		},
+		{
+			profile: certProfile{
+				NotBefore:           "2020-01-01 00:00:00",
+				NotAfter:            "2025-01-01 00:00:00",
+				SignatureAlgorithm:  "c",
+				CommonName:          "d",
+				Country:             "f",
+				Organizations:       []string{"e", "f"},
+				OrganizationalUnits: []string{"g", "h"},
 			},
 			certType: []certType{rootCert},
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:           "2020-01-01 00:00:00",
+				NotAfter:            "2025-01-01 00:00:00",
+				SignatureAlgorithm:  "c",
+				CommonName:          "d",
+				Organization:        "e",
+				Country:             "f",
+				CRLURL:              "h",
+				IssuerURL:           "i",
+				Policies:            []policyInfoConfig{{OID: "2.23.140.1.2.1"}},
+				PermittedDNSDomains: []string{"example.org"},
+			},
+			certType:    []certType{crossCert},
+			expectedErr: "permitted-dns-domains, excluded-dns-domains, permitted-ip-ranges, and excluded-ip-ranges can only be set for intermediate certificates",
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
+				SignatureAlgorithm: "c",
+				CommonName:         "d",
+				Organization:       "e",
+				Country:            "f",
+				CRLURL:             "h",
+				IssuerURL:          "i",
+				Policies:           []policyInfoConfig{{OID: "2.23.140.1.2.1"}},
+				ExcludedDNSDomains: []string{""},
+			},
+			certType:    []certType{intermediateCert},
+			expectedErr: "permitted-dns-domains and excluded-dns-domains cannot contain an empty domain",
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
+				SignatureAlgorithm: "c",
+				CommonName:         "d",
+				Organization:       "e",
+				Country:            "f",
+				CRLURL:             "h",
+				IssuerURL:          "i",
+				Policies:           []policyInfoConfig{{OID: "2.23.140.1.2.1"}},
+				PermittedIPRanges:  []string{"8.8.8.0"},
+			},
+			certType:    []certType{intermediateCert},
+			expectedErr: "permitted-ip-ranges contains invalid CIDR \"8.8.8.0\": invalid CIDR address: 8.8.8.0",
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
+				SignatureAlgorithm: "c",
+				CommonName:         "d",
+				Organization:       "e",
+				Country:            "f",
+				CRLURL:             "h",
+				IssuerURL:          "i",
+				Policies:           []policyInfoConfig{{OID: "2.23.140.1.2.1"}},
+				ExcludedIPRanges:   []string{"8.8.8.0/33"},
+			},
+			certType:    []certType{intermediateCert},
+			expectedErr: "excluded-ip-ranges contains invalid CIDR \"8.8.8.0/33\": invalid CIDR address: 8.8.8.0/33",
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
+				SignatureAlgorithm: "c",
+				CommonName:         "d",
+				Organization:       "e",
+				Country:            "f",
+				CRLURL:             "h",
+				IssuerURL:          "i",
+				Policies:           []policyInfoConfig{{OID: "2.23.140.1.2.1"}},
+				PermittedIPRanges:  []string{"8.8.8.8/24"},
+			},
+			certType:    []certType{intermediateCert},
+			expectedErr: "permitted-ip-ranges contains CIDR \"8.8.8.8/24\" with host bits set, did you mean \"8.8.8.0/24\"?",
+		},
+		{
+			profile: certProfile{
+				NotBefore:           "2020-01-01 00:00:00",
+				NotAfter:            "2025-01-01 00:00:00",
+				SignatureAlgorithm:  "c",
+				CommonName:          "d",
+				Organization:        "e",
+				Country:             "f",
+				CRLURL:              "h",
+				IssuerURL:           "i",
+				Policies:            []policyInfoConfig{{OID: "2.23.140.1.2.1"}},
+				PermittedDNSDomains: []string{"example.org"},
+				PermittedIPRanges:   []string{"8.8.8.0/24", "2001:db8::/32"},
+			},
+			certType: []certType{intermediateCert},
+		},
+		{
+			profile: certProfile{
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -381,8 +741,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -395,8 +755,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -409,8 +769,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -421,8 +781,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -435,8 +795,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -449,8 +809,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -463,8 +823,8 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore:          "a",
-				NotAfter:           "b",
+				NotBefore:          "2020-01-01 00:00:00",
+				NotAfter:           "2025-01-01 00:00:00",
 				SignatureAlgorithm: "c",
 				CommonName:         "d",
 				Organization:       "e",
@@ -475,7 +835,7 @@ func TestVerifyProfile(t *testing.T) {
 		},
 		{
 			profile: certProfile{
-				NotBefore: "a",
+				NotBefore: "2020-01-01 00:00:00",
 			},
 			certType:    []certType{requestCert},
 			expectedErr: "not-before cannot be set for a CSR",
@@ -529,6 +889,62 @@ func TestVerifyProfile(t *testing.T) {
 			certType:    []certType{requestCert},
 			expectedErr: "key-usages cannot be set for a CSR",
 		},
+		{
+			profile: certProfile{
+				AKIForm: akiFormKeyID,
+			},
+			certType:    []certType{requestCert},
+			expectedErr: "aki-form cannot be set for a CSR",
+		},
+		{
+			profile: certProfile{
+				AKIForm: akiFormIssuerSerial,
+			},
+			certType:    []certType{rootCert},
+			expectedErr: "aki-form cannot be set for root certificates",
+		},
+		{
+			profile: certProfile{
+				AKIForm: "issuer",
+			},
+			certType:    []certType{intermediateCert, crossCert, ocspCert, crlCert},
+			expectedErr: "aki-form \"issuer\" is not one of \"key-id\" or \"issuer-serial\"",
+		},
+		{
+			profile: certProfile{
+				SubjectUniqueID: "01",
+			},
+			certType:    []certType{requestCert},
+			expectedErr: "issuer-unique-id and subject-unique-id cannot be set for a CSR",
+		},
+		{
+			profile: certProfile{
+				IssuerUniqueID: "zz",
+			},
+			certType:    []certType{rootCert, intermediateCert, crossCert, ocspCert, crlCert},
+			expectedErr: "issuer-unique-id is not valid hex: encoding/hex: invalid byte: U+007A 'z'",
+		},
+		{
+			profile: certProfile{
+				SubjectUniqueID: "012",
+			},
+			certType:    []certType{rootCert, intermediateCert, crossCert, ocspCert, crlCert},
+			expectedErr: "subject-unique-id is not valid hex: encoding/hex: odd length hex string",
+		},
+		{
+			profile: certProfile{
+				ExpectedSubjectDER: "3000",
+			},
+			certType:    []certType{requestCert},
+			expectedErr: "expected-subject-der cannot be set for a CSR",
+		},
+		{
+			profile: certProfile{
+				ExpectedSubjectDER: "30zz",
+			},
+			certType:    []certType{rootCert, intermediateCert, crossCert, ocspCert, crlCert},
+			expectedErr: "expected-subject-der is not valid hex: encoding/hex: invalid byte: U+007A 'z'",
+		},
 	} {
 		for _, ct := range tc.certType {
 			err := tc.profile.verifyProfile(ct)
@@ -543,29 +959,368 @@ func TestVerifyProfile(t *testing.T) {
 	}
 }
 
-func TestGenerateCSR(t *testing.T) {
+func TestMakeTemplateCustomExtensions(t *testing.T) {
+	s, ctx := pkcs11helpers.NewSessionWithMock()
+	ctx.GenerateRandomFunc = realRand
 	profile := &certProfile{
-		CommonName:   "common name",
-		Organization: "organization",
-		Country:      "country",
+		SignatureAlgorithm: "SHA256WithRSA",
+		CommonName:         "common name",
+		Organization:       "organization",
+		Country:            "country",
+		NotBefore:          "2018-05-18 11:31:00",
+		NotAfter:           "2019-05-18 11:31:00",
+		KeyUsages:          []string{"Cert Sign"},
+		CustomExtensions: []customExtensionConfig{
+			{OID: "1.2.3.4", Critical: true, ValueHex: "0500"},
+			{OID: "1.2.3.5", ValueBase64: "DAJoaQ=="},
+		},
 	}
+	test.AssertNotError(t, profile.verifyProfile(rootCert), "verifyProfile failed with valid custom extensions")
 
-	signer, err := rsa.GenerateKey(rand.Reader, 1024)
-	test.AssertNotError(t, err, "failed to generate test key")
-
-	csrBytes, err := generateCSR(profile, &wrappedSigner{signer})
-	test.AssertNotError(t, err, "failed to generate CSR")
+	cert, err := makeTemplate(newRandReader(s), profile, samplePubkey(), nil, rootCert)
+	test.AssertNotError(t, err, "makeTemplate failed with valid custom extensions")
+	test.AssertEquals(t, len(cert.ExtraExtensions), 2)
+	test.Assert(t, cert.ExtraExtensions[0].Id.Equal(asn1.ObjectIdentifier{1, 2, 3, 4}), "unexpected OID in first custom extension")
+	test.Assert(t, cert.ExtraExtensions[0].Critical, "first custom extension should be critical")
+	test.AssertByteEquals(t, cert.ExtraExtensions[0].Value, []byte{5, 0})
+	test.Assert(t, cert.ExtraExtensions[1].Id.Equal(asn1.ObjectIdentifier{1, 2, 3, 5}), "unexpected OID in second custom extension")
+	test.Assert(t, !cert.ExtraExtensions[1].Critical, "second custom extension should not be critical")
+	test.AssertByteEquals(t, cert.ExtraExtensions[1].Value, []byte{12, 2, 'h', 'i'})
 
-	csr, err := x509.ParseCertificateRequest(csrBytes)
-	test.AssertNotError(t, err, "failed to parse CSR")
-	test.AssertNotError(t, csr.CheckSignature(), "CSR signature check failed")
-	test.AssertEquals(t, len(csr.Extensions), 0)
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "failed to generate test key")
+	certBytes, err := x509.CreateCertificate(rand.Reader, cert, cert, k.Public(), k)
+	test.AssertNotError(t, err, "failed to create certificate")
+	parsed, err := x509.ParseCertificate(certBytes)
+	test.AssertNotError(t, err, "failed to parse certificate")
+	found := 0
+	for _, ext := range parsed.Extensions {
+		if ext.Id.Equal(asn1.ObjectIdentifier{1, 2, 3, 4}) {
+			test.Assert(t, ext.Critical, "1.2.3.4 should be critical in the issued certificate")
+			found++
+		}
+		if ext.Id.Equal(asn1.ObjectIdentifier{1, 2, 3, 5}) {
+			test.Assert(t, !ext.Critical, "1.2.3.5 should not be critical in the issued certificate")
+			found++
+		}
+	}
+	test.AssertEquals(t, found, 2)
+}
 
-	test.AssertEquals(t, csr.Subject.String(), fmt.Sprintf("CN=%s,O=%s,C=%s",
-		profile.CommonName, profile.Organization, profile.Country))
+func TestVerifyProfileCustomExtensions(t *testing.T) {
+	base := certProfile{
+		NotBefore:          "2020-01-01 00:00:00",
+		NotAfter:           "2025-01-01 00:00:00",
+		SignatureAlgorithm: "c",
+		CommonName:         "d",
+		Organization:       "e",
+		Country:            "f",
+	}
+	for _, tc := range []struct {
+		name        string
+		exts        []customExtensionConfig
+		expectedErr string
+	}{
+		{
+			name:        "bad OID",
+			exts:        []customExtensionConfig{{OID: "a.b", ValueHex: "0500"}},
+			expectedErr: "invalid custom-extensions.oid \"a.b\": strconv.Atoi: parsing \"a\": invalid syntax",
+		},
+		{
+			name:        "no value",
+			exts:        []customExtensionConfig{{OID: "1.2.3"}},
+			expectedErr: "one of custom-extensions.value-hex or custom-extensions.value-base64 is required for 1.2.3",
+		},
+		{
+			name:        "both values",
+			exts:        []customExtensionConfig{{OID: "1.2.3", ValueHex: "0500", ValueBase64: "BQA="}},
+			expectedErr: "only one of custom-extensions.value-hex and custom-extensions.value-base64 can be set for 1.2.3",
+		},
+		{
+			name:        "bad hex",
+			exts:        []customExtensionConfig{{OID: "1.2.3", ValueHex: "zz"}},
+			expectedErr: "invalid custom-extensions.value-hex for 1.2.3: encoding/hex: invalid byte: U+007A 'z'",
+		},
+		{
+			name:        "bad base64",
+			exts:        []customExtensionConfig{{OID: "1.2.3", ValueBase64: "!"}},
+			expectedErr: "invalid custom-extensions.value-base64 for 1.2.3: illegal base64 data at input byte 0",
+		},
+		{
+			name:        "not DER",
+			exts:        []customExtensionConfig{{OID: "1.2.3", ValueHex: "050001"}},
+			expectedErr: "custom-extensions value for 1.2.3 is not a single DER encoded value",
+		},
+		{
+			name:        "collides with emitted extension",
+			exts:        []customExtensionConfig{{OID: "2.5.29.15", ValueHex: "03020106"}},
+			expectedErr: "custom-extensions cannot contain 2.5.29.15, which is already emitted by this tool",
+		},
+		{
+			name:        "duplicate",
+			exts:        []customExtensionConfig{{OID: "1.2.3", ValueHex: "0500"}, {OID: "1.2.3", ValueHex: "0500"}},
+			expectedErr: "custom-extensions contains 1.2.3 more than once",
+		},
+		{
+			name: "good",
+			exts: []customExtensionConfig{{OID: "1.2.3", ValueHex: "0500"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			profile := base
+			profile.CustomExtensions = tc.exts
+			err := profile.verifyProfile(rootCert)
+			if err != nil {
+				if tc.expectedErr != err.Error() {
+					t.Fatalf("Expected %q, got %q", tc.expectedErr, err.Error())
+				}
+			} else if tc.expectedErr != "" {
+				t.Fatalf("verifyProfile didn't fail, expected %q", tc.expectedErr)
+			}
+		})
+	}
 }
 
-func TestLoadCert(t *testing.T) {
+func TestMakeTemplateQCStatements(t *testing.T) {
+	s, ctx := pkcs11helpers.NewSessionWithMock()
+	ctx.GenerateRandomFunc = realRand
+	profile := &certProfile{
+		SignatureAlgorithm: "SHA256WithRSA",
+		CommonName:         "common name",
+		Organization:       "organization",
+		Country:            "country",
+		NotBefore:          "2018-05-18 11:31:00",
+		NotAfter:           "2019-05-18 11:31:00",
+		KeyUsages:          []string{"Cert Sign"},
+		// id-etsi-qcs-QcCompliance, which has no statementInfo.
+		QCStatements: []qcStatementConfig{{OID: "0.4.0.1862.1.1"}},
+	}
+	test.AssertNotError(t, profile.verifyProfile(rootCert), "verifyProfile failed with a valid qc-statements")
+
+	cert, err := makeTemplate(newRandReader(s), profile, samplePubkey(), nil, rootCert)
+	test.AssertNotError(t, err, "makeTemplate failed with a valid qc-statements")
+	test.AssertEquals(t, len(cert.ExtraExtensions), 1)
+	test.Assert(t, cert.ExtraExtensions[0].Id.Equal(oidQCStatements), "unexpected OID in qcStatements extension")
+	test.Assert(t, !cert.ExtraExtensions[0].Critical, "qcStatements extension should not be critical")
+	test.AssertByteEquals(t, cert.ExtraExtensions[0].Value, []byte{
+		0x30, 0x0a, // QCStatements
+		0x30, 0x08, // QCStatement
+		0x06, 0x06, 0x04, 0x00, 0x8e, 0x46, 0x01, 0x01, // 0.4.0.1862.1.1
+	})
+
+	profile.QCStatements = nil
+	cert, err = makeTemplate(newRandReader(s), profile, samplePubkey(), nil, rootCert)
+	test.AssertNotError(t, err, "makeTemplate failed without qc-statements")
+	test.AssertEquals(t, len(cert.ExtraExtensions), 0)
+}
+
+func TestVerifyProfileQCStatements(t *testing.T) {
+	base := certProfile{
+		NotBefore:          "2020-01-01 00:00:00",
+		NotAfter:           "2025-01-01 00:00:00",
+		SignatureAlgorithm: "c",
+		CommonName:         "d",
+		Organization:       "e",
+		Country:            "f",
+	}
+	for _, tc := range []struct {
+		name        string
+		statements  []qcStatementConfig
+		expectedErr string
+	}{
+		{
+			name:        "bad OID",
+			statements:  []qcStatementConfig{{OID: "3.1"}},
+			expectedErr: "invalid qc-statements.oid \"3.1\": OID has an invalid first or second component",
+		},
+		{
+			name:        "not DER",
+			statements:  []qcStatementConfig{{OID: "0.4.0.1862.1.6", ValueHex: "050001"}},
+			expectedErr: "qc-statements value for 0.4.0.1862.1.6 is not a single DER encoded value",
+		},
+		{
+			name:        "duplicate",
+			statements:  []qcStatementConfig{{OID: "0.4.0.1862.1.1"}, {OID: "0.4.0.1862.1.1"}},
+			expectedErr: "qc-statements contains 0.4.0.1862.1.1 more than once",
+		},
+		{
+			name: "good",
+			statements: []qcStatementConfig{
+				{OID: "0.4.0.1862.1.1"},
+				{OID: "0.4.0.1862.1.6", ValueHex: "3009060704008e46010603"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			profile := base
+			profile.QCStatements = tc.statements
+			err := profile.verifyProfile(rootCert)
+			if err != nil {
+				if tc.expectedErr != err.Error() {
+					t.Fatalf("Expected %q, got %q", tc.expectedErr, err.Error())
+				}
+			} else if tc.expectedErr != "" {
+				t.Fatalf("verifyProfile didn't fail, expected %q", tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestGenerateCSR(t *testing.T) {
+	profile := &certProfile{
+		CommonName:   "common name",
+		Organization: "organization",
+		Country:      "country",
+	}
+
+	signer, err := rsa.GenerateKey(rand.Reader, 1024)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	csrBytes, err := generateCSR(profile, &wrappedSigner{signer})
+	test.AssertNotError(t, err, "failed to generate CSR")
+
+	csr, err := x509.ParseCertificateRequest(csrBytes)
+	test.AssertNotError(t, err, "failed to parse CSR")
+	test.AssertNotError(t, csr.CheckSignature(), "CSR signature check failed")
+	test.AssertEquals(t, len(csr.Extensions), 0)
+
+	test.AssertEquals(t, csr.Subject.String(), fmt.Sprintf("CN=%s,O=%s,C=%s",
+		profile.CommonName, profile.Organization, profile.Country))
+}
+
+func TestWriteCSR(t *testing.T) {
+	profile := &certProfile{
+		CommonName:   "common name",
+		Organization: "organization",
+		Country:      "country",
+	}
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	csrBytes, err := generateCSR(profile, &wrappedSigner{k})
+	test.AssertNotError(t, err, "failed to generate CSR")
+
+	dir := t.TempDir()
+	pemPath := dir + "/csr.pem"
+	derPath := dir + "/csr.der"
+	err = writeCSR(csrBytes, pemPath, derPath)
+	test.AssertNotError(t, err, "writeCSR failed")
+
+	pemBytes, err := os.ReadFile(pemPath)
+	test.AssertNotError(t, err, "failed to read PEM CSR")
+	block, _ := pem.Decode(pemBytes)
+	test.AssertEquals(t, block.Type, "CERTIFICATE REQUEST")
+	pemCSR, err := x509.ParseCertificateRequest(block.Bytes)
+	test.AssertNotError(t, err, "failed to parse PEM CSR")
+
+	derBytes, err := os.ReadFile(derPath)
+	test.AssertNotError(t, err, "failed to read DER CSR")
+	derCSR, err := x509.ParseCertificateRequest(derBytes)
+	test.AssertNotError(t, err, "failed to parse DER CSR")
+	test.AssertDeepEquals(t, derCSR, pemCSR)
+
+	// The DER output is optional.
+	err = writeCSR(csrBytes, dir+"/only.pem", "")
+	test.AssertNotError(t, err, "writeCSR failed without a DER path")
+}
+
+func TestGenerateCSRRequestedExtensions(t *testing.T) {
+	isCA := true
+	profile := &certProfile{
+		CommonName:   "common name",
+		Organization: "organization",
+		Country:      "country",
+		RequestedExtensions: &requestedExtensionsConfig{
+			BasicConstraintsCA: &isCA,
+			KeyUsages:          []string{"Cert Sign", "CRL Sign"},
+			ExtKeyUsages:       []string{"Server Auth"},
+		},
+	}
+	test.AssertNotError(t, profile.verifyProfile(requestCert), "verifyProfile failed with valid requested extensions")
+
+	signer, err := rsa.GenerateKey(rand.Reader, 1024)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	csrBytes, err := generateCSR(profile, &wrappedSigner{signer})
+	test.AssertNotError(t, err, "failed to generate CSR")
+
+	csr, err := x509.ParseCertificateRequest(csrBytes)
+	test.AssertNotError(t, err, "failed to parse CSR")
+	test.AssertNotError(t, csr.CheckSignature(), "CSR signature check failed")
+
+	// The extensionRequest attribute should contain exactly the requested
+	// extensions, in order, with the expected DER encoded values.
+	test.AssertEquals(t, len(csr.Extensions), 3)
+	test.Assert(t, csr.Extensions[0].Id.Equal(oidBasicConstraints), "unexpected OID in first extension")
+	test.Assert(t, csr.Extensions[0].Critical, "basicConstraints should be critical")
+	test.AssertEquals(t, hex.EncodeToString(csr.Extensions[0].Value), "30030101ff")
+	test.Assert(t, csr.Extensions[1].Id.Equal(oidKeyUsage), "unexpected OID in second extension")
+	test.Assert(t, csr.Extensions[1].Critical, "keyUsage should be critical")
+	test.AssertEquals(t, hex.EncodeToString(csr.Extensions[1].Value), "03020106")
+	test.Assert(t, csr.Extensions[2].Id.Equal(oidExtendedKeyUsage), "unexpected OID in third extension")
+	test.Assert(t, !csr.Extensions[2].Critical, "extKeyUsage should not be critical")
+	test.AssertEquals(t, hex.EncodeToString(csr.Extensions[2].Value), "300a06082b06010505070301")
+}
+
+func TestRequestedExtensionsVerify(t *testing.T) {
+	isCA, notCA := true, false
+	for _, tc := range []struct {
+		name        string
+		config      requestedExtensionsConfig
+		expectedErr string
+	}{
+		{
+			name:        "empty",
+			config:      requestedExtensionsConfig{},
+			expectedErr: "requested-extensions must request at least one extension",
+		},
+		{
+			name:        "unknown key usage",
+			config:      requestedExtensionsConfig{KeyUsages: []string{"a"}},
+			expectedErr: "unknown requested-extensions.key-usages value \"a\"",
+		},
+		{
+			name:        "unknown ext key usage",
+			config:      requestedExtensionsConfig{ExtKeyUsages: []string{"a"}},
+			expectedErr: "unknown requested-extensions.ext-key-usages value \"a\"",
+		},
+		{
+			name:        "cert sign without CA",
+			config:      requestedExtensionsConfig{BasicConstraintsCA: &notCA, KeyUsages: []string{"Cert Sign"}},
+			expectedErr: "requested-extensions.key-usages can only contain \"Cert Sign\" if basic-constraints-ca is true",
+		},
+		{
+			name:        "CA without cert sign",
+			config:      requestedExtensionsConfig{BasicConstraintsCA: &isCA, KeyUsages: []string{"Digital Signature"}},
+			expectedErr: "requested-extensions.key-usages must contain \"Cert Sign\" if basic-constraints-ca is true",
+		},
+		{
+			name:   "CA only",
+			config: requestedExtensionsConfig{BasicConstraintsCA: &isCA},
+		},
+		{
+			name:   "leaf",
+			config: requestedExtensionsConfig{BasicConstraintsCA: &notCA, KeyUsages: []string{"Digital Signature"}, ExtKeyUsages: []string{"Client Auth"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.verify()
+			if err != nil {
+				if tc.expectedErr != err.Error() {
+					t.Fatalf("Expected %q, got %q", tc.expectedErr, err.Error())
+				}
+			} else if tc.expectedErr != "" {
+				t.Fatalf("verify didn't fail, expected %q", tc.expectedErr)
+			}
+		})
+	}
+
+	profile := &certProfile{RequestedExtensions: &requestedExtensionsConfig{BasicConstraintsCA: &isCA}}
+	err := profile.verifyProfile(rootCert)
+	test.AssertError(t, err, "verifyProfile didn't fail with requested-extensions on a root")
+	test.AssertEquals(t, err.Error(), "requested-extensions can only be set for a CSR")
+}
+
+func TestLoadCert(t *testing.T) {
 	_, err := loadCert("../../test/hierarchy/int-e1.cert.pem")
 	test.AssertNotError(t, err, "should not have errored")
 
@@ -579,3 +1334,583 @@ func TestLoadCert(t *testing.T) {
 	_, err = loadCert("../../test/test-root.pubkey.pem")
 	test.AssertError(t, err, "should have failed when trying to parse a public key")
 }
+
+func TestSignRootNoWellDefinedExpiration(t *testing.T) {
+	s, ctx := pkcs11helpers.NewSessionWithMock()
+	ctx.GenerateRandomFunc = realRand
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	pubBytes, err := x509.MarshalPKIXPublicKey(k.Public())
+	test.AssertNotError(t, err, "failed to marshal test key")
+
+	profile := &certProfile{
+		SignatureAlgorithm: "ECDSAWithSHA256",
+		CommonName:         "common name",
+		Organization:       "organization",
+		Country:            "US",
+		NotBefore:          "2020-01-01 00:00:00",
+		NotAfter:           noWellDefinedExpiration,
+		KeyUsages:          []string{"Cert Sign", "CRL Sign"},
+	}
+	test.AssertNotError(t, profile.verifyProfile(rootCert), "verifyProfile failed for root")
+	test.AssertError(t, profile.verifyProfile(intermediateCert), "verifyProfile didn't fail for intermediate")
+
+	template, err := makeTemplate(newRandReader(s), profile, pubBytes, nil, rootCert)
+	test.AssertNotError(t, err, "makeTemplate failed")
+	signer := &wrappedSigner{k}
+	lintCert, err := issueLintCertAndPerformLinting(template, template, k.Public(), signer, certUniqueIDs{}, []string{"n_ca_digital_signature_not_set"}, "")
+	test.AssertNotError(t, err, "linting failed")
+	cert, err := signAndWriteCert(template, template, lintCert, k.Public(), signer, certUniqueIDs{}, nil, t.TempDir()+"/root.pem")
+	test.AssertNotError(t, err, "signAndWriteCert failed")
+	test.AssertEquals(t, cert.NotAfter, time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC))
+
+	var tbs struct {
+		Version  int `asn1:"optional,explicit,default:0,tag:0"`
+		Serial   *big.Int
+		SigAlg   pkix.AlgorithmIdentifier
+		Issuer   asn1.RawValue
+		Validity struct {
+			NotBefore asn1.RawValue
+			NotAfter  asn1.RawValue
+		}
+	}
+	_, err = asn1.Unmarshal(cert.RawTBSCertificate, &tbs)
+	test.AssertNotError(t, err, "failed to parse TBS certificate")
+	test.AssertEquals(t, tbs.Validity.NotAfter.Tag, asn1.TagGeneralizedTime)
+	test.AssertEquals(t, string(tbs.Validity.NotAfter.Bytes), noWellDefinedExpiration)
+}
+
+// countingSigner counts the signatures it makes.
+type countingSigner struct {
+	wrappedSigner
+	calls int
+}
+
+func (cs *countingSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	cs.calls++
+	return cs.wrappedSigner.Sign(rand, digest, opts)
+}
+
+func TestSignRootOmitSKI(t *testing.T) {
+	s, ctx := pkcs11helpers.NewSessionWithMock()
+	ctx.GenerateRandomFunc = realRand
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	pubBytes, err := x509.MarshalPKIXPublicKey(k.Public())
+	test.AssertNotError(t, err, "failed to marshal test key")
+
+	profile := &certProfile{
+		SignatureAlgorithm: "ECDSAWithSHA256",
+		CommonName:         "common name",
+		Organization:       "organization",
+		Country:            "US",
+		NotBefore:          "2020-01-01 00:00:00",
+		NotAfter:           "2040-01-01 00:00:00",
+		KeyUsages:          []string{"Cert Sign", "CRL Sign"},
+		OmitSKI:            true,
+	}
+	test.AssertNotError(t, profile.verifyProfile(rootCert), "verifyProfile failed for root")
+	err = profile.verifyProfile(intermediateCert)
+	test.AssertError(t, err, "verifyProfile didn't fail for intermediate")
+	test.AssertEquals(t, err.Error(), "omit-ski can only be set for root certificates")
+
+	template, err := makeTemplate(newRandReader(s), profile, pubBytes, nil, rootCert)
+	test.AssertNotError(t, err, "makeTemplate failed")
+	test.AssertEquals(t, len(template.SubjectKeyId), 0)
+	signer := &countingSigner{wrappedSigner: wrappedSigner{k}}
+
+	// The linted certificate omits the subject key identifier, just as the
+	// issued one does, so the lint for it must be skipped.
+	_, err = issueLintCertAndPerformLinting(template, template, k.Public(), signer, certUniqueIDs{}, []string{"n_ca_digital_signature_not_set"}, "")
+	test.AssertError(t, err, "linting didn't flag the missing subject key identifier")
+	test.AssertContains(t, err.Error(), "e_ext_subject_key_identifier_missing_ca")
+	lintCert, err := issueLintCertAndPerformLinting(template, template, k.Public(), signer, certUniqueIDs{}, []string{"n_ca_digital_signature_not_set", "e_ext_subject_key_identifier_missing_ca"}, "")
+	test.AssertNotError(t, err, "linting failed")
+	test.AssertEquals(t, len(lintCert.SubjectKeyId), 0)
+
+	cert, err := signAndWriteCert(template, template, lintCert, k.Public(), signer, certUniqueIDs{}, nil, t.TempDir()+"/root.pem")
+	test.AssertNotError(t, err, "signAndWriteCert failed")
+	test.AssertEquals(t, signer.calls, 1)
+	test.AssertEquals(t, len(cert.SubjectKeyId), 0)
+
+	// Apart from the linter's throwaway public key, the issued certificate is
+	// exactly the one which was linted.
+	lintTBS, _, err := parseTBSCertificate(lintCert.Raw)
+	test.AssertNotError(t, err, "failed to parse linting TBS certificate")
+	lintTBS.PublicKey = asn1.RawValue{FullBytes: pubBytes}
+	expectedTBS, err := asn1.Marshal(*lintTBS)
+	test.AssertNotError(t, err, "failed to encode linting TBS certificate")
+	test.AssertByteEquals(t, cert.RawTBSCertificate, expectedTBS)
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidSubjectKeyIdentifier) {
+			t.Fatal("certificate contains a subject key identifier extension")
+		}
+	}
+	test.AssertEquals(t, len(cert.Extensions), 2)
+	test.AssertNotError(t, cert.CheckSignatureFrom(cert), "re-signed certificate has an invalid signature")
+}
+
+func TestCheckNonstandard(t *testing.T) {
+	profile := &certProfile{}
+	test.AssertNotError(t, profile.checkNonstandard(false), "checkNonstandard failed for a standard profile")
+
+	profile.IssuerUniqueID = "01"
+	err := profile.checkNonstandard(false)
+	test.AssertError(t, err, "checkNonstandard didn't fail")
+	test.AssertEquals(t, err.Error(), "issuer-unique-id and subject-unique-id can only be set with --allow-nonstandard")
+	test.AssertNotError(t, profile.checkNonstandard(true), "checkNonstandard failed with allowNonstandard")
+}
+
+func TestSignRootUniqueIDs(t *testing.T) {
+	s, ctx := pkcs11helpers.NewSessionWithMock()
+	ctx.GenerateRandomFunc = realRand
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	pubBytes, err := x509.MarshalPKIXPublicKey(k.Public())
+	test.AssertNotError(t, err, "failed to marshal test key")
+
+	// The notBefore is current, so that every lint which flags the unique IDs
+	// is in effect.
+	profile := &certProfile{
+		SignatureAlgorithm: "ECDSAWithSHA256",
+		CommonName:         "common name",
+		Organization:       "organization",
+		Country:            "US",
+		NotBefore:          "2025-01-01 00:00:00",
+		NotAfter:           "2039-12-31 23:59:59",
+		KeyUsages:          []string{"Cert Sign", "CRL Sign"},
+		IssuerUniqueID:     "0102",
+		SubjectUniqueID:    "0304",
+	}
+	test.AssertNotError(t, profile.verifyProfile(rootCert), "verifyProfile failed for root")
+	uniqueIDs, err := profile.uniqueIDs()
+	test.AssertNotError(t, err, "uniqueIDs failed")
+
+	template, err := makeTemplate(newRandReader(s), profile, pubBytes, nil, rootCert)
+	test.AssertNotError(t, err, "makeTemplate failed")
+	signer := &countingSigner{wrappedSigner: wrappedSigner{k}}
+
+	// The unique IDs are set in the linting certificate, so linting fails
+	// unless the lint which flags them, as documented in the README, is
+	// skipped.
+	reportPath := t.TempDir() + "/lints.json"
+	_, err = issueLintCertAndPerformLinting(template, template, k.Public(), signer, uniqueIDs, []string{"n_ca_digital_signature_not_set"}, reportPath)
+	test.AssertError(t, err, "linting didn't flag the unique IDs")
+	test.AssertContains(t, err.Error(), "e_cert_contains_unique_identifier")
+	reportJSON, err := os.ReadFile(reportPath)
+	test.AssertNotError(t, err, "failed to read lint report")
+	var report []linter.LintReportEntry
+	test.AssertNotError(t, json.Unmarshal(reportJSON, &report), "failed to parse lint report")
+	var status string
+	for _, entry := range report {
+		if entry.Name == "e_cert_contains_unique_identifier" {
+			status = entry.Status
+		}
+	}
+	test.AssertEquals(t, status, "error")
+
+	lintCert, err := issueLintCertAndPerformLinting(template, template, k.Public(), signer, uniqueIDs, []string{"n_ca_digital_signature_not_set", "e_cert_contains_unique_identifier"}, "")
+	test.AssertNotError(t, err, "linting failed")
+	cert, err := signAndWriteCert(template, template, lintCert, k.Public(), signer, uniqueIDs, nil, t.TempDir()+"/root.pem")
+	test.AssertNotError(t, err, "signAndWriteCert failed")
+	test.AssertEquals(t, signer.calls, 1)
+	test.AssertNotError(t, cert.CheckSignatureFrom(cert), "certificate has an invalid signature")
+
+	tbs, _, err := parseTBSCertificate(cert.Raw)
+	test.AssertNotError(t, err, "failed to parse TBS certificate")
+	test.AssertDeepEquals(t, tbs.IssuerUniqueID, asn1.BitString{Bytes: []byte{1, 2}, BitLength: 16})
+	test.AssertDeepEquals(t, tbs.SubjectUniqueID, asn1.BitString{Bytes: []byte{3, 4}, BitLength: 16})
+
+	// Apart from the linter's throwaway public key, the issued certificate is
+	// exactly the one which was linted.
+	lintTBS, _, err := parseTBSCertificate(lintCert.Raw)
+	test.AssertNotError(t, err, "failed to parse linting TBS certificate")
+	lintTBS.PublicKey = asn1.RawValue{FullBytes: pubBytes}
+	expectedTBS, err := asn1.Marshal(*lintTBS)
+	test.AssertNotError(t, err, "failed to encode linting TBS certificate")
+	test.AssertByteEquals(t, cert.RawTBSCertificate, expectedTBS)
+}
+
+func TestSignRootExpectedSubject(t *testing.T) {
+	s, ctx := pkcs11helpers.NewSessionWithMock()
+	ctx.GenerateRandomFunc = realRand
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	pubBytes, err := x509.MarshalPKIXPublicKey(k.Public())
+	test.AssertNotError(t, err, "failed to marshal test key")
+
+	profile := &certProfile{
+		SignatureAlgorithm: "ECDSAWithSHA256",
+		CommonName:         "common name",
+		Organization:       "organization",
+		Country:            "US",
+		NotBefore:          "2020-01-01 00:00:00",
+		NotAfter:           "2040-01-01 00:00:00",
+		KeyUsages:          []string{"Cert Sign", "CRL Sign"},
+	}
+	template, err := makeTemplate(newRandReader(s), profile, pubBytes, nil, rootCert)
+	test.AssertNotError(t, err, "makeTemplate failed")
+	signer := &wrappedSigner{k}
+	lintCert, err := issueLintCertAndPerformLinting(template, template, k.Public(), signer, certUniqueIDs{}, []string{"n_ca_digital_signature_not_set"}, "")
+	test.AssertNotError(t, err, "linting failed")
+
+	// The expected subject DER is computed independently of the template.
+	expected, err := asn1.Marshal(pkix.Name{
+		CommonName:   "common name",
+		Organization: []string{"organization"},
+		Country:      []string{"US"},
+	}.ToRDNSequence())
+	test.AssertNotError(t, err, "failed to marshal expected subject")
+	profile.ExpectedSubjectDER = hex.EncodeToString(expected)
+	expectedSubject, err := profile.expectedSubject()
+	test.AssertNotError(t, err, "expectedSubject failed")
+	_, err = signAndWriteCert(template, template, lintCert, k.Public(), signer, certUniqueIDs{}, expectedSubject, t.TempDir()+"/root.pem")
+	test.AssertNotError(t, err, "signAndWriteCert failed for a matching expected subject")
+
+	// The same name with the commonName encoded as a UTF8String instead of a
+	// PrintableString differs in its encoding, and is rejected.
+	mismatched := pkix.Name{
+		Organization: []string{"organization"},
+		Country:      []string{"US"},
+	}.ToRDNSequence()
+	mismatched = append(mismatched, pkix.RelativeDistinguishedNameSET{{
+		Type:  asn1.ObjectIdentifier{2, 5, 4, 3},
+		Value: asn1.RawValue{Tag: asn1.TagUTF8String, Bytes: []byte("common name")},
+	}})
+	expected, err = asn1.Marshal(mismatched)
+	test.AssertNotError(t, err, "failed to marshal mismatched subject")
+	certPath := t.TempDir() + "/root.pem"
+	_, err = signAndWriteCert(template, template, lintCert, k.Public(), signer, certUniqueIDs{}, expected, certPath)
+	test.AssertError(t, err, "signAndWriteCert didn't fail for a mismatched expected subject")
+	test.AssertContains(t, err.Error(), "doesn't match the expected-subject-der")
+	_, err = os.Stat(certPath)
+	test.Assert(t, os.IsNotExist(err), "certificate was written despite a mismatched subject")
+}
+
+func TestIssueLintCertLintReport(t *testing.T) {
+	s, ctx := pkcs11helpers.NewSessionWithMock()
+	ctx.GenerateRandomFunc = realRand
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	pubBytes, err := x509.MarshalPKIXPublicKey(k.Public())
+	test.AssertNotError(t, err, "failed to marshal test key")
+
+	profile := &certProfile{
+		SignatureAlgorithm: "ECDSAWithSHA256",
+		CommonName:         "common name",
+		Organization:       "organization",
+		Country:            "US",
+		NotBefore:          "2020-01-01 00:00:00",
+		NotAfter:           "2040-01-01 00:00:00",
+		KeyUsages:          []string{"Cert Sign", "CRL Sign"},
+	}
+	template, err := makeTemplate(newRandReader(s), profile, pubBytes, nil, rootCert)
+	test.AssertNotError(t, err, "makeTemplate failed")
+
+	reportPath := t.TempDir() + "/lint-report.json"
+	_, err = issueLintCertAndPerformLinting(template, template, k.Public(), &wrappedSigner{k}, certUniqueIDs{}, []string{"n_ca_digital_signature_not_set"}, reportPath)
+	test.AssertNotError(t, err, "linting failed")
+
+	reportJSON, err := os.ReadFile(reportPath)
+	test.AssertNotError(t, err, "failed to read lint report")
+	var report []linter.LintReportEntry
+	err = json.Unmarshal(reportJSON, &report)
+	test.AssertNotError(t, err, "failed to parse lint report")
+	statuses := make(map[string]string)
+	for _, entry := range report {
+		statuses[entry.Name] = entry.Status
+	}
+	test.AssertEquals(t, statuses["n_ca_digital_signature_not_set"], linter.LintStatusSkipped)
+	test.AssertEquals(t, statuses["e_ext_subject_key_identifier_missing_ca"], "pass")
+	test.AssertEquals(t, statuses["e_sub_cert_aia_does_not_contain_ocsp_url"], "not applicable")
+
+	// The report must not be overwritten.
+	_, err = issueLintCertAndPerformLinting(template, template, k.Public(), &wrappedSigner{k}, certUniqueIDs{}, []string{"n_ca_digital_signature_not_set"}, reportPath)
+	test.AssertError(t, err, "linting should have failed to write an existing lint report")
+}
+
+func TestIssueLintCertEnableLints(t *testing.T) {
+	s, ctx := pkcs11helpers.NewSessionWithMock()
+	ctx.GenerateRandomFunc = realRand
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	pubBytes, err := x509.MarshalPKIXPublicKey(k.Public())
+	test.AssertNotError(t, err, "failed to marshal test key")
+
+	profile := &certProfile{
+		SignatureAlgorithm: "ECDSAWithSHA256",
+		CommonName:         "common name",
+		Organization:       "organization",
+		Country:            "US",
+		NotBefore:          "2025-01-01 00:00:00",
+		NotAfter:           "2044-12-31 23:59:59",
+		KeyUsages:          []string{"Cert Sign", "CRL Sign"},
+	}
+	template, err := makeTemplate(newRandReader(s), profile, pubBytes, nil, rootCert)
+	test.AssertNotError(t, err, "makeTemplate failed")
+	// Moving keyUsage into ExtraExtensions makes x509.CreateCertificate emit
+	// it last, out of canonical order.
+	keyUsage, err := asn1.Marshal(asn1.BitString{Bytes: []byte{0x06}, BitLength: 7})
+	test.AssertNotError(t, err, "failed to marshal keyUsage")
+	template.KeyUsage = 0
+	template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: asn1.ObjectIdentifier{2, 5, 29, 15}, Critical: true, Value: keyUsage})
+	skipLints := []string{"n_ca_digital_signature_not_set"}
+
+	defer func() { enableLints = nil }()
+	_, err = issueLintCertAndPerformLinting(template, template, k.Public(), &wrappedSigner{k}, certUniqueIDs{}, skipLints, "")
+	test.AssertNotError(t, err, "linting failed without --enable-lints")
+
+	enableLints = []string{"e_cert_extensions_not_canonical_order"}
+	_, err = issueLintCertAndPerformLinting(template, template, k.Public(), &wrappedSigner{k}, certUniqueIDs{}, skipLints, "")
+	test.AssertError(t, err, "linting didn't fail for extensions out of canonical order")
+	test.AssertContains(t, err.Error(), "e_cert_extensions_not_canonical_order")
+}
+
+func TestSetAuthorityKeyID(t *testing.T) {
+	issuerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate issuer key")
+	issuerTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(0x1234),
+		Subject:               pkix.Name{CommonName: "issuer"},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+	}
+	issuerDER, err := x509.CreateCertificate(rand.Reader, issuerTmpl, issuerTmpl, issuerKey.Public(), issuerKey)
+	test.AssertNotError(t, err, "failed to create issuer certificate")
+	issuer, err := x509.ParseCertificate(issuerDER)
+	test.AssertNotError(t, err, "failed to parse issuer certificate")
+
+	// The issuer is self-signed, so its issuer is the DER encoded Name
+	// SEQUENCE { SET { SEQUENCE { commonName, PrintableString "issuer" } } }.
+	issuerName := []byte{0x30, 0x11, 0x31, 0x0f, 0x30, 0x0d, 0x06, 0x03, 0x55, 0x04, 0x03, 0x13, 0x06, 'i', 's', 's', 'u', 'e', 'r'}
+	test.AssertByteEquals(t, issuer.RawIssuer, issuerName)
+
+	for _, tc := range []struct {
+		form        string
+		expectedAKI []byte
+		expectedErr string
+	}{
+		{
+			form: "",
+			// SEQUENCE { [0] 01020304 }
+			expectedAKI: []byte{0x30, 0x06, 0x80, 0x04, 1, 2, 3, 4},
+		},
+		{
+			form:        akiFormKeyID,
+			expectedAKI: []byte{0x30, 0x06, 0x80, 0x04, 1, 2, 3, 4},
+		},
+		{
+			form: akiFormIssuerSerial,
+			// SEQUENCE { [1] { [4] { issuerName } }, [2] 1234 }
+			expectedAKI: append(append([]byte{0x30, 0x1b, 0xa1, 0x15, 0xa4, 0x13}, issuerName...), 0x82, 0x02, 0x12, 0x34),
+		},
+		{
+			form:        "key-identifier",
+			expectedErr: "unknown aki-form \"key-identifier\"",
+		},
+	} {
+		t.Run(tc.form, func(t *testing.T) {
+			subjectKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			test.AssertNotError(t, err, "failed to generate subject key")
+			template := &x509.Certificate{
+				SerialNumber: big.NewInt(2),
+				Subject:      pkix.Name{CommonName: "subject"},
+				NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				NotAfter:     time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			}
+			err = setAuthorityKeyID(template, &certProfile{AKIForm: tc.form}, issuer)
+			if tc.expectedErr != "" {
+				test.AssertError(t, err, "setAuthorityKeyID didn't fail")
+				test.AssertEquals(t, err.Error(), tc.expectedErr)
+				return
+			}
+			test.AssertNotError(t, err, "setAuthorityKeyID failed")
+
+			certDER, err := x509.CreateCertificate(rand.Reader, template, issuer, subjectKey.Public(), issuerKey)
+			test.AssertNotError(t, err, "failed to create certificate")
+			cert, err := x509.ParseCertificate(certDER)
+			test.AssertNotError(t, err, "failed to parse certificate")
+			var akis [][]byte
+			for _, ext := range cert.Extensions {
+				if ext.Id.Equal(oidAuthorityKeyIdentifier) {
+					akis = append(akis, ext.Value)
+				}
+			}
+			test.AssertEquals(t, len(akis), 1)
+			test.AssertByteEquals(t, akis[0], tc.expectedAKI)
+		})
+	}
+}
+
+func TestCheckValidity(t *testing.T) {
+	cases := []struct {
+		name          string
+		ct            certType
+		notBefore     string
+		notAfter      string
+		expectedError string
+	}{
+		{
+			name:      "valid root",
+			ct:        rootCert,
+			notBefore: "2020-01-01 00:00:00",
+			notAfter:  "2044-01-01 00:00:00",
+		},
+		{
+			name:      "valid intermediate",
+			ct:        intermediateCert,
+			notBefore: "2020-01-01 00:00:00",
+			notAfter:  "2027-01-01 00:00:00",
+		},
+		{
+			name:      "valid cross-certificate",
+			ct:        crossCert,
+			notBefore: "2020-01-01 00:00:00",
+			notAfter:  "2027-01-01 00:00:00",
+		},
+		{
+			name:      "root with no well-defined expiration",
+			ct:        rootCert,
+			notBefore: "2020-01-01 00:00:00",
+			notAfter:  noWellDefinedExpiration,
+		},
+		{
+			name:      "long-lived ocsp signer",
+			ct:        ocspCert,
+			notBefore: "2020-01-01 00:00:00",
+			notAfter:  "2040-01-01 00:00:00",
+		},
+		{
+			name:          "swapped dates",
+			ct:            intermediateCert,
+			notBefore:     "2025-01-01 00:00:00",
+			notAfter:      "2020-01-01 00:00:00",
+			expectedError: "not-after must be after not-before",
+		},
+		{
+			name:          "equal dates",
+			ct:            intermediateCert,
+			notBefore:     "2020-01-01 00:00:00",
+			notAfter:      "2020-01-01 00:00:00",
+			expectedError: "not-after must be after not-before",
+		},
+		{
+			name:          "over-long intermediate",
+			ct:            intermediateCert,
+			notBefore:     "2020-01-01 00:00:00",
+			notAfter:      "2029-01-01 00:00:00",
+			expectedError: "which is longer than the maximum of 2920 days",
+		},
+		{
+			name:          "over-long cross-certificate",
+			ct:            crossCert,
+			notBefore:     "2020-01-01 00:00:00",
+			notAfter:      "2029-01-01 00:00:00",
+			expectedError: "which is longer than the maximum of 2920 days",
+		},
+		{
+			name:          "over-long root",
+			ct:            rootCert,
+			notBefore:     "2020-01-01 00:00:00",
+			notAfter:      "2046-01-01 00:00:00",
+			expectedError: "which is longer than the maximum of 9125 days",
+		},
+		{
+			name:          "unparseable not-before",
+			ct:            intermediateCert,
+			notBefore:     "2020-01-01",
+			notAfter:      "2025-01-01 00:00:00",
+			expectedError: "failed to parse not-before",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			profile := &certProfile{NotBefore: tc.notBefore, NotAfter: tc.notAfter}
+			err := profile.checkValidity(tc.ct)
+			if tc.expectedError == "" {
+				test.AssertNotError(t, err, "checkValidity failed")
+			} else {
+				test.AssertError(t, err, "checkValidity didn't fail")
+				test.AssertContains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestFillSubjectFromCSR(t *testing.T) {
+	cases := []struct {
+		name          string
+		profile       certProfile
+		subject       pkix.Name
+		expected      certProfile
+		expectedError string
+	}{
+		{
+			name:     "empty profile",
+			subject:  pkix.Name{CommonName: "cn", Organization: []string{"o"}, Country: []string{"US"}},
+			expected: certProfile{CommonName: "cn", Organization: "o", Country: "US"},
+		},
+		{
+			name:     "matching profile",
+			profile:  certProfile{CommonName: "cn", Organization: "o"},
+			subject:  pkix.Name{CommonName: "cn", Organization: []string{"o"}, Country: []string{"US"}},
+			expected: certProfile{CommonName: "cn", Organization: "o", Country: "US"},
+		},
+		{
+			name:          "conflicting profile",
+			profile:       certProfile{CommonName: "other"},
+			subject:       pkix.Name{CommonName: "cn", Organization: []string{"o"}, Country: []string{"US"}},
+			expectedError: "certificate-profile.common-name is \"other\", but the CSR subject contains \"cn\"",
+		},
+		{
+			name:     "matching organizations",
+			profile:  certProfile{Organizations: []string{"o"}},
+			subject:  pkix.Name{CommonName: "cn", Organization: []string{"o"}, Country: []string{"US"}},
+			expected: certProfile{CommonName: "cn", Organizations: []string{"o"}, Country: "US"},
+		},
+		{
+			name:          "conflicting organizations",
+			profile:       certProfile{Organizations: []string{"o", "p"}},
+			subject:       pkix.Name{CommonName: "cn", Organization: []string{"o"}, Country: []string{"US"}},
+			expectedError: "certificate-profile.organizations is [\"o\" \"p\"], but the CSR subject contains [\"o\"]",
+		},
+		{
+			name:          "profile organizational units",
+			profile:       certProfile{OrganizationalUnits: []string{"ou"}},
+			subject:       pkix.Name{CommonName: "cn", Organization: []string{"o"}, Country: []string{"US"}},
+			expectedError: "certificate-profile.organizational-units is [\"ou\"], but the CSR subject contains none",
+		},
+		{
+			name:          "multiple organizations",
+			subject:       pkix.Name{CommonName: "cn", Organization: []string{"o", "p"}, Country: []string{"US"}},
+			expectedError: "CSR subject contains more than one organization or country",
+		},
+		{
+			name:          "organizational unit",
+			subject:       pkix.Name{CommonName: "cn", Organization: []string{"o"}, OrganizationalUnit: []string{"ou"}, Country: []string{"US"}},
+			expectedError: "CSR subject contains attributes other than commonName, organization, and country",
+		},
+	}
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: tc.subject}, k)
+			test.AssertNotError(t, err, "failed to create CSR")
+			csr, err := x509.ParseCertificateRequest(csrDER)
+			test.AssertNotError(t, err, "failed to parse CSR")
+			err = tc.profile.fillSubjectFromCSR(csr)
+			if tc.expectedError != "" {
+				test.AssertError(t, err, "fillSubjectFromCSR didn't fail")
+				test.AssertEquals(t, err.Error(), tc.expectedError)
+				return
+			}
+			test.AssertNotError(t, err, "fillSubjectFromCSR failed")
+			test.AssertDeepEquals(t, tc.profile, tc.expected)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/letsencrypt/boulder/linter"
+)
+
+// The steps of a root ceremony which are recorded in its checkpoint.
+const (
+	stepKeyGenerated = "key-generated"
+	stepCertLinted   = "cert-linted"
+	stepCertSigned   = "cert-signed"
+)
+
+// checkpoint records the steps of a root ceremony which have completed, so
+// that a ceremony which is interrupted after its key has been generated can be
+// resumed without generating another.
+type checkpoint struct {
+	// ConfigHash is the hex encoded SHA-256 digest of the ceremony config. A
+	// checkpoint can only be resumed with the config which wrote it.
+	ConfigHash string           `json:"config-hash"`
+	Steps      []checkpointStep `json:"steps"`
+
+	// path is the file which the checkpoint is written to.
+	path string
+}
+
+// checkpointStep is a completed step of a ceremony.
+type checkpointStep struct {
+	Name string `json:"name"`
+	// Outputs are the files written by the step.
+	Outputs []reportFile `json:"outputs"`
+	// KeyID is the HSM key pair object ID, recorded by the key generation
+	// step.
+	KeyID []byte `json:"key-id,omitempty"`
+	// LintCert is the DER encoded linting certificate, and Lints are the lint
+	// results, recorded by the linting step.
+	LintCert []byte                   `json:"lint-cert,omitempty"`
+	Lints    []linter.LintReportEntry `json:"lints,omitempty"`
+}
+
+// loadCheckpoint loads the checkpoint at path, which must have been written
+// for the config configBytes, and checks that every output which it records is
+// unchanged. If there's no checkpoint at path a checkpoint which records no
+// steps is returned.
+func loadCheckpoint(path string, configBytes []byte) (*checkpoint, error) {
+	digest := sha256.Sum256(configBytes)
+	cp := &checkpoint{ConfigHash: hex.EncodeToString(digest[:]), path: path}
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %q: %w", path, err)
+	}
+	var saved checkpoint
+	err = json.Unmarshal(contents, &saved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %q: %w", path, err)
+	}
+	if saved.ConfigHash != cp.ConfigHash {
+		return nil, fmt.Errorf("checkpoint %q was written for a different config, with SHA-256 %s", path, saved.ConfigHash)
+	}
+	for _, step := range saved.Steps {
+		for _, output := range step.Outputs {
+			contents, err := os.ReadFile(output.Path)
+			if err != nil {
+				return nil, fmt.Errorf("checkpoint %q records %s %q, written by step %s, which can't be read: %w", path, output.Field, output.Path, step.Name, err)
+			}
+			digest := sha256.Sum256(contents)
+			if hex.EncodeToString(digest[:]) != output.SHA256 {
+				return nil, fmt.Errorf("checkpoint %q records %s %q, written by step %s, with SHA-256 %s, but it has changed", path, output.Field, output.Path, step.Name, output.SHA256)
+			}
+		}
+	}
+	cp.Steps = saved.Steps
+	return cp, nil
+}
+
+// resuming returns true if cp records a completed step.
+func (cp *checkpoint) resuming() bool {
+	return cp != nil && len(cp.Steps) > 0
+}
+
+// step returns the completed step called name, or nil if it hasn't completed.
+func (cp *checkpoint) step(name string) *checkpointStep {
+	if cp == nil {
+		return nil
+	}
+	for i := range cp.Steps {
+		if cp.Steps[i].Name == name {
+			return &cp.Steps[i]
+		}
+	}
+	return nil
+}
+
+// checkOutputFile is like the checkOutputFile function, except that it allows
+// filename to exist if it was written by a completed step.
+func (cp *checkpoint) checkOutputFile(filename, fieldname string) error {
+	if cp != nil {
+		for _, step := range cp.Steps {
+			for _, output := range step.Outputs {
+				if output.Path == filename {
+					return nil
+				}
+			}
+		}
+	}
+	return checkOutputFile(filename, fieldname)
+}
+
+// record adds step, which wrote outputs, to the completed steps and rewrites
+// the checkpoint. Empty output paths, for optional outputs, are ignored. It
+// does nothing if cp is nil.
+func (cp *checkpoint) record(step checkpointStep, outputs ...dryRunPath) error {
+	if cp == nil {
+		return nil
+	}
+	var err error
+	step.Outputs, err = hashReportFiles(outputs, "")
+	if err != nil {
+		return err
+	}
+	cp.Steps = append(cp.Steps, step)
+	cpJSON, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	// The checkpoint is replaced by renaming, so that an interruption while
+	// it is being written doesn't lose the steps which it already records.
+	tmp := cp.path + ".tmp"
+	err = os.WriteFile(tmp, append(cpJSON, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint to %q: %w", tmp, failure{exitWrite, err})
+	}
+	err = os.Rename(tmp, cp.path)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint to %q: %w", cp.path, failure{exitWrite, err})
+	}
+	log.Printf("Checkpoint written to %q after step %s\n", cp.path, step.Name)
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+
+	"github.com/letsencrypt/boulder/pkcs11helpers"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// interruptibleSoftToken is a softToken which can be made to fail at a step of
+// the root ceremony, as if the ceremony had been interrupted there.
+type interruptibleSoftToken struct {
+	*softToken
+	// interruptAt is the step which fails, either stepCertLinted or
+	// stepCertSigned, or empty if none do.
+	interruptAt string
+}
+
+func (ist *interruptibleSoftToken) GenerateRandom(s pkcs11.SessionHandle, c int) ([]byte, error) {
+	// Once the key has been generated, randomness is only needed for the
+	// serial number of the certificate to be linted.
+	if ist.interruptAt == stepCertLinted && len(ist.keys) > 0 {
+		return nil, errors.New("interrupted before linting")
+	}
+	return ist.softToken.GenerateRandom(s, c)
+}
+
+func (ist *interruptibleSoftToken) SignInit(s pkcs11.SessionHandle, m []*pkcs11.Mechanism, o pkcs11.ObjectHandle) error {
+	if ist.interruptAt == stepCertSigned {
+		return errors.New("interrupted before signing")
+	}
+	return ist.softToken.SignInit(s, m, o)
+}
+
+// checkpointSteps returns the names of the steps recorded by the checkpoint at
+// filename.
+func checkpointSteps(t *testing.T, filename string) []string {
+	t.Helper()
+	contents, err := os.ReadFile(filename)
+	test.AssertNotError(t, err, "failed to read checkpoint")
+	var cp checkpoint
+	test.AssertNotError(t, json.Unmarshal(contents, &cp), "failed to parse checkpoint")
+	var names []string
+	for _, step := range cp.Steps {
+		names = append(names, step.Name)
+	}
+	return names
+}
+
+func TestRootCeremonyCheckpoint(t *testing.T) {
+	tmp := t.TempDir()
+	token := &interruptibleSoftToken{softToken: &softToken{keys: make(map[pkcs11.ObjectHandle]*ecdsa.PrivateKey)}}
+	initializeSession = func(string, uint, uint, string) (*pkcs11helpers.Session, error) {
+		return &pkcs11helpers.Session{Module: token}, nil
+	}
+	t.Cleanup(func() { initializeSession = pkcs11helpers.Initialize })
+
+	pubKeyPath := path.Join(tmp, "root.pubkey.pem")
+	certPath := path.Join(tmp, "root.cert.pem")
+	lintReportPath := path.Join(tmp, "root.lints.json")
+	reportPath := path.Join(tmp, "root.report.json")
+	checkpointPath := path.Join(tmp, "root.checkpoint.json")
+	config := []byte(fmt.Sprintf(`ceremony-type: root
+pkcs11:
+    module: module
+    store-key-in-slot: 0
+    store-key-with-label: root key
+key:
+    type: ecdsa
+    ecdsa-curve: P-384
+outputs:
+    public-key-path: %s
+    certificate-path: %s
+    lint-report-path: %s
+    report-path: %s
+    checkpoint-path: %s
+certificate-profile:
+    signature-algorithm: ECDSAWithSHA384
+    common-name: root
+    organization: organization
+    country: US
+    not-before: 2020-01-01 00:00:00
+    not-after: 2040-01-01 00:00:00
+    key-usages:
+        - Cert Sign
+        - CRL Sign
+skip-lints:
+    - n_ca_digital_signature_not_set
+`, pubKeyPath, certPath, lintReportPath, reportPath, checkpointPath))
+
+	// withPIN returns config with pkcs11.pin set to pin, as --pin-fd does.
+	withPIN := func(pin string) []byte {
+		t.Helper()
+		configBytes, err := setConfigPIN(config, func() ([]byte, error) { return []byte(pin), nil })
+		test.AssertNotError(t, err, "setConfigPIN failed")
+		return configBytes
+	}
+
+	// Interrupted after the key is generated.
+	token.interruptAt = stepCertLinted
+	err := rootCeremony(withPIN("1234"), config, false, false, "")
+	test.AssertError(t, err, "root ceremony wasn't interrupted before linting")
+	test.AssertDeepEquals(t, checkpointSteps(t, checkpointPath), []string{stepKeyGenerated})
+	// The checkpoint is written for the config as read from disk, not the
+	// config with the PIN injected.
+	contents, err := os.ReadFile(checkpointPath)
+	test.AssertNotError(t, err, "failed to read checkpoint")
+	var cp checkpoint
+	test.AssertNotError(t, json.Unmarshal(contents, &cp), "failed to parse checkpoint")
+	digest := sha256.Sum256(config)
+	test.AssertEquals(t, cp.ConfigHash, hex.EncodeToString(digest[:]))
+	_, err = os.Stat(lintReportPath)
+	test.Assert(t, os.IsNotExist(err), "lint report was written before linting")
+	pubKey, _, err := loadPubKey(pubKeyPath)
+	test.AssertNotError(t, err, "failed to load generated public key")
+
+	// Resumed with a PIN supplied differently, and interrupted after the
+	// certificate is linted.
+	token.interruptAt = stepCertSigned
+	err = rootCeremony(withPIN("5678"), config, false, false, "")
+	test.AssertError(t, err, "root ceremony wasn't interrupted before signing")
+	test.AssertDeepEquals(t, checkpointSteps(t, checkpointPath), []string{stepKeyGenerated, stepCertLinted})
+	_, err = os.Stat(certPath)
+	test.Assert(t, os.IsNotExist(err), "certificate was written before signing")
+
+	// Resumed to completion, certifying the key which was first generated.
+	token.interruptAt = ""
+	err = rootCeremony(config, config, false, false, "")
+	test.AssertNotError(t, err, "resumed root ceremony failed")
+	test.AssertDeepEquals(t, checkpointSteps(t, checkpointPath), []string{stepKeyGenerated, stepCertLinted, stepCertSigned})
+	test.AssertEquals(t, len(token.keys), 1)
+	cert, err := loadCert(certPath)
+	test.AssertNotError(t, err, "failed to load signed certificate")
+	test.AssertDeepEquals(t, cert.PublicKey, pubKey)
+	test.AssertNotError(t, cert.CheckSignatureFrom(cert), "root doesn't verify with its own key")
+	_, err = os.Stat(reportPath)
+	test.AssertNotError(t, err, "ceremony report wasn't written")
+
+	// Resuming a completed ceremony does nothing.
+	err = rootCeremony(config, config, false, false, "")
+	test.AssertNotError(t, err, "resuming a completed root ceremony failed")
+	unchanged, err := loadCert(certPath)
+	test.AssertNotError(t, err, "failed to load signed certificate")
+	test.AssertByteEquals(t, unchanged.Raw, cert.Raw)
+
+	// A checkpoint can't be resumed with a different config.
+	changed := append(config, "# changed\n"...)
+	err = rootCeremony(changed, changed, false, false, "")
+	test.AssertError(t, err, "root ceremony resumed a checkpoint with a different config")
+	test.AssertContains(t, err.Error(), "was written for a different config")
+	test.AssertEquals(t, exitCodeFor(err), exitConfig)
+
+	// Or once an output which it records has changed.
+	test.AssertNotError(t, os.WriteFile(lintReportPath, []byte("[]\n"), 0644), "failed to modify lint report")
+	err = rootCeremony(config, config, false, false, "")
+	test.AssertError(t, err, "root ceremony resumed a checkpoint with a modified output")
+	test.AssertContains(t, err.Error(), fmt.Sprintf("outputs.lint-report-path %q, written by step %s", lintReportPath, stepCertLinted))
+	test.AssertEquals(t, exitCodeFor(err), exitConfig)
+}
+
+func TestRootConfigValidateCheckpoint(t *testing.T) {
+	tmp := t.TempDir()
+	pubKeyPath := path.Join(tmp, "root.pubkey.pem")
+	test.AssertNotError(t, writeFile(pubKeyPath, []byte("key")), "failed to write public key")
+
+	var config rootConfig
+	config.Outputs.PublicKeyPath = pubKeyPath
+	config.Outputs.CertificatePath = path.Join(tmp, "root.cert.pem")
+	config.Outputs.CheckpointPath = path.Join(tmp, "root.checkpoint.json")
+
+	// Without a checkpoint an existing output is rejected.
+	err := config.checkpoint.checkOutputFile(config.Outputs.PublicKeyPath, "public-key-path")
+	test.AssertError(t, err, "checkOutputFile allowed an existing output without a checkpoint")
+
+	// With a checkpoint an existing output is allowed only if a completed
+	// step wrote it.
+	config.checkpoint, err = loadCheckpoint(config.Outputs.CheckpointPath, []byte("config"))
+	test.AssertNotError(t, err, "loading a missing checkpoint failed")
+	test.Assert(t, !config.checkpoint.resuming(), "a missing checkpoint is being resumed")
+	err = config.checkpoint.record(checkpointStep{Name: stepKeyGenerated},
+		dryRunPath{field: "outputs.public-key-path", path: pubKeyPath})
+	test.AssertNotError(t, err, "failed to record step")
+	test.AssertNotError(t, config.checkpoint.checkOutputFile(pubKeyPath, "public-key-path"), "checkOutputFile rejected a recorded output")
+	test.AssertError(t, config.checkpoint.checkOutputFile(config.Outputs.CheckpointPath, "checkpoint-path"), "checkOutputFile allowed an unrecorded output")
+
+	resumed, err := loadCheckpoint(config.Outputs.CheckpointPath, []byte("config"))
+	test.AssertNotError(t, err, "failed to load checkpoint")
+	test.Assert(t, resumed.resuming(), "a written checkpoint isn't being resumed")
+	test.Assert(t, resumed.step(stepKeyGenerated) != nil, "key generation step wasn't recorded")
+	test.Assert(t, resumed.step(stepCertLinted) == nil, "linting step was recorded")
+}
@@ -1,18 +1,60 @@
 package main
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"log"
 	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/jmhodges/clock"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+
 	"github.com/letsencrypt/boulder/linter"
 )
 
-func generateCRL(signer crypto.Signer, issuer *x509.Certificate, thisUpdate, nextUpdate time.Time, number int64, revokedCertificates []x509.RevocationListEntry) ([]byte, error) {
+// oidCRLNumber is the id-ce-cRLNumber extension OID from RFC 5280 Section
+// 5.2.3.
+var oidCRLNumber = asn1.ObjectIdentifier{2, 5, 29, 20}
+
+// oidDeltaCRLIndicator is the id-ce-deltaCRLIndicator extension OID from RFC
+// 5280 Section 5.2.4.
+var oidDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+
+// crlReasons are the CRLReason codes defined by RFC 5280 Section 5.3.1. Value
+// 7 is not used, and no codes beyond aACompromise (10) are defined.
+var crlReasons = map[int]string{
+	0:  "unspecified",
+	1:  "keyCompromise",
+	2:  "cACompromise",
+	3:  "affiliationChanged",
+	4:  "superseded",
+	5:  "cessationOfOperation",
+	6:  "certificateHold",
+	8:  "removeFromCRL",
+	9:  "privilegeWithdrawn",
+	10: "aACompromise",
+}
+
+// crlClock is the clock which signed CRLs' thisUpdate is checked against. It
+// is replaced by a fake clock in tests.
+var crlClock = clock.New()
+
+// generateCRL creates and signs a CRL. If baseNumber is non-zero the CRL is a
+// delta CRL, containing a critical delta CRL indicator extension referencing
+// the base CRL with that number, and revokedCertificates should only contain
+// the revocations since that base CRL.
+func generateCRL(signer crypto.Signer, issuer *x509.Certificate, thisUpdate, nextUpdate time.Time, number, baseNumber int64, revokedCertificates []x509.RevocationListEntry) ([]byte, error) {
 	template := &x509.RevocationList{
 		RevokedCertificateEntries: revokedCertificates,
 		Number:                    big.NewInt(number),
@@ -20,6 +62,31 @@ func generateCRL(signer crypto.Signer, issuer *x509.Certificate, thisUpdate, nex
 		NextUpdate:                nextUpdate,
 	}
 
+	skipLints := []string{
+		// We skip this lint because our ceremony tooling issues CRLs with validity
+		// periods up to 12 months, but the lint only allows up to 10 days (which
+		// is the limit for CRLs containing Subscriber Certificates).
+		"e_crl_validity_period",
+		// We skip this lint because it is only applicable for sharded/partitioned
+		// CRLs, which our Subscriber CRLs are, but our higher-level CRLs issued by
+		// this tool are not.
+		"e_crl_has_idp",
+	}
+	if baseNumber != 0 {
+		if number <= baseNumber {
+			return nil, errors.New("number must be greater than baseNumber")
+		}
+		baseNumberDER, err := asn1.Marshal(big.NewInt(baseNumber))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode baseNumber: %w", err)
+		}
+		// RFC 5280 Section 5.2.4: "This extension MUST be marked critical."
+		template.ExtraExtensions = []pkix.Extension{{Id: oidDeltaCRLIndicator, Critical: true, Value: baseNumberDER}}
+		// We skip this lint because it forbids the delta CRL indicator
+		// extension, which a delta CRL is required to contain.
+		skipLints = append(skipLints, "e_crl_is_not_delta")
+	}
+
 	if nextUpdate.Before(thisUpdate) {
 		return nil, errors.New("thisUpdate must be before nextUpdate")
 	}
@@ -35,16 +102,16 @@ func generateCRL(signer crypto.Signer, issuer *x509.Certificate, thisUpdate, nex
 		return nil, errors.New("nextUpdate must be less than 12 months after thisUpdate")
 	}
 
-	err := linter.CheckCRL(template, issuer, signer, []string{
-		// We skip this lint because our ceremony tooling issues CRLs with validity
-		// periods up to 12 months, but the lint only allows up to 10 days (which
-		// is the limit for CRLs containing Subscriber Certificates).
-		"e_crl_validity_period",
-		// We skip this lint because it is only applicable for sharded/partitioned
-		// CRLs, which our Subscriber CRLs are, but our higher-level CRLs issued by
-		// this tool are not.
-		"e_crl_has_idp",
-	})
+	err := checkCRLDoesNotRevokeIssuer(revokedCertificates, issuer)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCRLNoDuplicateSerials(revokedCertificates)
+	if err != nil {
+		return nil, err
+	}
+
+	err = linter.CheckCRL(template, issuer, signer, skipLints)
 	if err != nil {
 		return nil, fmt.Errorf("crl failed pre-issuance lint: %w", err)
 	}
@@ -58,6 +125,210 @@ func generateCRL(signer crypto.Signer, issuer *x509.Certificate, thisUpdate, nex
 	if err != nil {
 		return nil, err
 	}
+	err = checkCRLUpdateOrder(crlBytes)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCRLAuthorityKeyID(crlBytes, issuer)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCRLIssuerName(crlBytes, issuer)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCRLNumberLength(crlBytes)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCRLThisUpdateNotInFuture(crlBytes, crlClock)
+	if err != nil {
+		return nil, err
+	}
 
 	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlBytes}), nil
 }
+
+// checkCRLDoesNotRevokeIssuer verifies that none of the revoked entries has the
+// same serial number as issuer. A CRL can't usefully revoke the certificate it
+// is validated with, so such an entry is a mistake in the ceremony config.
+func checkCRLDoesNotRevokeIssuer(revokedCertificates []x509.RevocationListEntry, issuer *x509.Certificate) error {
+	for _, entry := range revokedCertificates {
+		if entry.SerialNumber.Cmp(issuer.SerialNumber) == 0 {
+			return fmt.Errorf("revoked certificate serial %x is the serial of the issuing certificate", entry.SerialNumber)
+		}
+	}
+	return nil
+}
+
+// checkCRLNoDuplicateSerials verifies that no two revoked entries have the same
+// serial number, such as a certificate listed in revoked-certificates which is
+// also in revoked-certificates-dir. RFC 5280 Section 5.1.2.6 identifies a
+// revoked certificate by its serial, so a CRL must list each serial once.
+func checkCRLNoDuplicateSerials(revokedCertificates []x509.RevocationListEntry) error {
+	seen := make(map[string]bool, len(revokedCertificates))
+	for _, entry := range revokedCertificates {
+		serial := entry.SerialNumber.String()
+		if seen[serial] {
+			return fmt.Errorf("revoked certificate serial %x is listed more than once", entry.SerialNumber)
+		}
+		seen[serial] = true
+	}
+	return nil
+}
+
+// checkCRLUpdateOrder parses the provided DER encoded CRL and verifies that the
+// encoded nextUpdate strictly follows the encoded thisUpdate.
+func checkCRLUpdateOrder(crlDER []byte) error {
+	crl, err := x509.ParseRevocationList(crlDER)
+	if err != nil {
+		return fmt.Errorf("failed to parse signed CRL: %w", err)
+	}
+	if !crl.NextUpdate.After(crl.ThisUpdate) {
+		return fmt.Errorf("signed CRL nextUpdate (%s) is not after thisUpdate (%s)", crl.NextUpdate, crl.ThisUpdate)
+	}
+	return nil
+}
+
+// checkCRLAuthorityKeyID parses the provided DER encoded CRL and verifies that
+// its authorityKeyIdentifier matches the subjectKeyIdentifier of issuer, so
+// that relying parties can find the issuer when validating the CRL.
+func checkCRLAuthorityKeyID(crlDER []byte, issuer *x509.Certificate) error {
+	crl, err := x509.ParseRevocationList(crlDER)
+	if err != nil {
+		return fmt.Errorf("failed to parse signed CRL: %w", err)
+	}
+	if !bytes.Equal(crl.AuthorityKeyId, issuer.SubjectKeyId) {
+		return fmt.Errorf("signed CRL authorityKeyIdentifier (%x) doesn't match issuer subjectKeyIdentifier (%x)", crl.AuthorityKeyId, issuer.SubjectKeyId)
+	}
+	return nil
+}
+
+// checkCRLIssuerName parses the provided DER encoded CRL and verifies that its
+// issuer name is byte-for-byte identical to the subject of issuer. Relying
+// parties match a CRL to its issuer by name, so a CRL with a different issuer
+// name can't be associated with the certificate that signed it.
+func checkCRLIssuerName(crlDER []byte, issuer *x509.Certificate) error {
+	crl, err := x509.ParseRevocationList(crlDER)
+	if err != nil {
+		return fmt.Errorf("failed to parse signed CRL: %w", err)
+	}
+	if !bytes.Equal(crl.RawIssuer, issuer.RawSubject) {
+		return fmt.Errorf("signed CRL issuer (%s) doesn't match issuer certificate subject (%s)", crl.Issuer, issuer.Subject)
+	}
+	return nil
+}
+
+// checkCRLNumberLength parses the provided DER encoded CRL and verifies that
+// its CRLNumber is no longer than 20 octets, as required by RFC 5280 Section
+// 5.2.3. The length of the encoded INTEGER is checked, rather than that of the
+// parsed value, so that a leading sign octet is accounted for.
+func checkCRLNumberLength(crlDER []byte) error {
+	crl, err := x509.ParseRevocationList(crlDER)
+	if err != nil {
+		return fmt.Errorf("failed to parse signed CRL: %w", err)
+	}
+	for _, ext := range crl.Extensions {
+		if !ext.Id.Equal(oidCRLNumber) {
+			continue
+		}
+		value := cryptobyte.String(ext.Value)
+		var number cryptobyte.String
+		if !value.ReadASN1(&number, cryptobyte_asn1.INTEGER) || !value.Empty() {
+			return errors.New("failed to parse signed CRL number")
+		}
+		if len(number) > 20 {
+			return fmt.Errorf("signed CRL number is %d octets long, which is longer than 20 octets", len(number))
+		}
+		return nil
+	}
+	return errors.New("signed CRL doesn't contain a CRL number")
+}
+
+// checkCRLThisUpdateNotInFuture parses the provided DER encoded CRL and
+// verifies that its thisUpdate is not after the current time of clk, since some
+// clients reject a CRL which isn't valid yet.
+func checkCRLThisUpdateNotInFuture(crlDER []byte, clk clock.Clock) error {
+	crl, err := x509.ParseRevocationList(crlDER)
+	if err != nil {
+		return fmt.Errorf("failed to parse signed CRL: %w", err)
+	}
+	now := clk.Now()
+	if crl.ThisUpdate.After(now) {
+		return fmt.Errorf("signed CRL thisUpdate (%s) is after the current time (%s)", crl.ThisUpdate, now)
+	}
+	return nil
+}
+
+// oidInvalidityDate is the id-ce-invalidityDate CRL entry extension OID from
+// RFC 5280 Section 5.3.2.
+var oidInvalidityDate = asn1.ObjectIdentifier{2, 5, 29, 24}
+
+// revocationListEntry returns a CRL entry revoking cert at revokedAt. If reason
+// is non-zero the entry includes a reasonCode extension containing it, and if
+// invalidityDate is non-zero the entry includes an invalidityDate extension
+// containing it.
+func revocationListEntry(cert *x509.Certificate, revokedAt time.Time, reason int, invalidityDate time.Time) (x509.RevocationListEntry, error) {
+	revokedCert := x509.RevocationListEntry{
+		SerialNumber:   cert.SerialNumber,
+		RevocationTime: revokedAt,
+	}
+	if reason != 0 {
+		encReason, err := asn1.Marshal(reason)
+		if err != nil {
+			return x509.RevocationListEntry{}, fmt.Errorf("failed to marshal revocation reason %q: %w", reason, err)
+		}
+		revokedCert.Extensions = append(revokedCert.Extensions, pkix.Extension{
+			Id:    asn1.ObjectIdentifier{2, 5, 29, 21}, // id-ce-reasonCode
+			Value: encReason,
+		})
+	}
+	if !invalidityDate.IsZero() {
+		// RFC 5280 Section 5.3.2: "InvalidityDate ::= GeneralizedTime"
+		encDate, err := asn1.MarshalWithParams(invalidityDate.UTC(), "generalized")
+		if err != nil {
+			return x509.RevocationListEntry{}, fmt.Errorf("failed to marshal invalidity date %s: %w", invalidityDate, err)
+		}
+		revokedCert.Extensions = append(revokedCert.Extensions, pkix.Extension{
+			Id:    oidInvalidityDate,
+			Value: encDate,
+		})
+	}
+	return revokedCert, nil
+}
+
+// loadRevokedCertificatesDir loads every certificate in dir whose filename ends
+// in .cert.pem (PEM encoded) or .der (DER encoded). Other files are skipped
+// with a warning. It returns an error if any certificate can't be loaded, or
+// if the directory contains no certificates.
+func loadRevokedCertificatesDir(dir string) ([]*x509.Certificate, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revoked certificates directory %q: %w", dir, err)
+	}
+	var certs []*x509.Certificate
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		var cert *x509.Certificate
+		switch {
+		case entry.IsDir():
+			log.Printf("Skipping directory %q in revoked certificates directory\n", path)
+			continue
+		case strings.HasSuffix(entry.Name(), ".cert.pem"):
+			cert, err = loadCert(path)
+		case strings.HasSuffix(entry.Name(), ".der"):
+			cert, err = loadCertDER(path)
+		default:
+			log.Printf("Skipping %q in revoked certificates directory, it isn't a .cert.pem or .der file\n", path)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load revoked certificate %q: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("revoked certificates directory %q contains no certificates", dir)
+	}
+	return certs, nil
+}
@@ -1,45 +1,53 @@
 package main
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/pem"
+	"fmt"
 	"io"
 	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/jmhodges/clock"
+
+	"github.com/letsencrypt/boulder/strictyaml"
 	"github.com/letsencrypt/boulder/test"
 )
 
 func TestGenerateCRLTimeBounds(t *testing.T) {
-	_, err := generateCRL(nil, nil, time.Now().Add(time.Hour), time.Now(), 1, nil)
+	_, err := generateCRL(nil, nil, time.Now().Add(time.Hour), time.Now(), 1, 0, nil)
 	test.AssertError(t, err, "generateCRL did not fail")
 	test.AssertEquals(t, err.Error(), "thisUpdate must be before nextUpdate")
 
 	_, err = generateCRL(nil, &x509.Certificate{
 		NotBefore: time.Now().Add(time.Hour),
 		NotAfter:  time.Now(),
-	}, time.Now(), time.Now(), 1, nil)
+	}, time.Now(), time.Now(), 1, 0, nil)
 	test.AssertError(t, err, "generateCRL did not fail")
 	test.AssertEquals(t, err.Error(), "thisUpdate is before issuing certificate's notBefore")
 
 	_, err = generateCRL(nil, &x509.Certificate{
 		NotBefore: time.Now(),
 		NotAfter:  time.Now().Add(time.Hour * 2),
-	}, time.Now().Add(time.Hour), time.Now().Add(time.Hour*3), 1, nil)
+	}, time.Now().Add(time.Hour), time.Now().Add(time.Hour*3), 1, 0, nil)
 	test.AssertError(t, err, "generateCRL did not fail")
 	test.AssertEquals(t, err.Error(), "nextUpdate is after issuing certificate's notAfter")
 
 	_, err = generateCRL(nil, &x509.Certificate{
 		NotBefore: time.Now(),
 		NotAfter:  time.Now().Add(time.Hour * 24 * 370),
-	}, time.Now(), time.Now().Add(time.Hour*24*366), 1, nil)
+	}, time.Now(), time.Now().Add(time.Hour*24*366), 1, 0, nil)
 	test.AssertError(t, err, "generateCRL did not fail")
 	test.AssertEquals(t, err.Error(), "nextUpdate must be less than 12 months after thisUpdate")
 }
@@ -86,7 +94,7 @@ func TestGenerateCRLLints(t *testing.T) {
 	// However, only the last of those should show up in the error message,
 	// because the first two should be explicitly removed from the lint registry
 	// by the ceremony tool.
-	_, err = generateCRL(&wrappedSigner{k}, cert, time.Now().Add(time.Hour), time.Now().Add(100*24*time.Hour), 1, []x509.RevocationListEntry{
+	_, err = generateCRL(&wrappedSigner{k}, cert, time.Now().Add(time.Hour), time.Now().Add(100*24*time.Hour), 1, 0, []x509.RevocationListEntry{
 		{
 			SerialNumber:   big.NewInt(12345),
 			RevocationTime: time.Now().Add(time.Hour),
@@ -119,7 +127,7 @@ func TestGenerateCRL(t *testing.T) {
 	cert, err := x509.ParseCertificate(certBytes)
 	test.AssertNotError(t, err, "failed to parse test cert")
 
-	crlPEM, err := generateCRL(&wrappedSigner{k}, cert, time.Now().Add(time.Hour), time.Now().Add(time.Hour*2), 1, nil)
+	crlPEM, err := generateCRL(&wrappedSigner{k}, cert, time.Now(), time.Now().Add(time.Hour*2), 1, 0, nil)
 	test.AssertNotError(t, err, "generateCRL failed with valid profile")
 
 	pemBlock, _ := pem.Decode(crlPEM)
@@ -146,6 +154,160 @@ func TestGenerateCRL(t *testing.T) {
 	test.AssertEquals(t, number, 1)
 }
 
+func TestGenerateDeltaCRL(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "asd"},
+		SerialNumber:          big.NewInt(7),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCRLSign,
+		SubjectKeyId:          []byte{1, 2, 3},
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to generate test cert")
+	cert, err := x509.ParseCertificate(certBytes)
+	test.AssertNotError(t, err, "failed to parse test cert")
+
+	crlPEM, err := generateCRL(&wrappedSigner{k}, cert, time.Now(), time.Now().Add(time.Hour*2), 6, 5, []x509.RevocationListEntry{
+		{
+			SerialNumber:   big.NewInt(12345),
+			RevocationTime: time.Now().Add(time.Hour),
+		},
+	})
+	test.AssertNotError(t, err, "generateCRL failed with a base CRL number")
+
+	pemBlock, _ := pem.Decode(crlPEM)
+	goCRL, err := x509.ParseRevocationList(pemBlock.Bytes)
+	test.AssertNotError(t, err, "failed to parse CRL")
+	err = goCRL.CheckSignatureFrom(cert)
+	test.AssertNotError(t, err, "CRL signature check failed")
+	test.AssertEquals(t, goCRL.Number.Int64(), int64(6))
+	test.AssertEquals(t, len(goCRL.RevokedCertificateEntries), 1)
+
+	var indicator *pkix.Extension
+	for _, ext := range goCRL.Extensions {
+		if ext.Id.Equal(oidDeltaCRLIndicator) {
+			indicator = &ext
+		}
+	}
+	test.Assert(t, indicator != nil, "CRL doesn't contain a delta CRL indicator extension")
+	test.Assert(t, indicator.Critical, "delta CRL indicator extension isn't critical")
+	var baseNumber int
+	_, err = asn1.Unmarshal(indicator.Value, &baseNumber)
+	test.AssertNotError(t, err, "failed to parse delta CRL indicator extension")
+	test.AssertEquals(t, baseNumber, 5)
+
+	_, err = generateCRL(&wrappedSigner{k}, cert, time.Now().Add(time.Hour), time.Now().Add(time.Hour*2), 5, 5, nil)
+	test.AssertError(t, err, "generateCRL didn't fail with a number equal to the base CRL number")
+	test.AssertEquals(t, err.Error(), "number must be greater than baseNumber")
+}
+
+func TestCheckCRLUpdateOrder(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "asd"},
+		SerialNumber:          big.NewInt(7),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCRLSign,
+		SubjectKeyId:          []byte{1, 2, 3},
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to generate test cert")
+	cert, err := x509.ParseCertificate(certBytes)
+	test.AssertNotError(t, err, "failed to parse test cert")
+
+	thisUpdate := time.Now().Truncate(time.Second)
+	nextUpdate := thisUpdate.Add(time.Hour)
+
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: thisUpdate,
+		NextUpdate: nextUpdate,
+	}, cert, k)
+	test.AssertNotError(t, err, "failed to create ordered CRL")
+	test.AssertNotError(t, checkCRLUpdateOrder(crlDER), "checkCRLUpdateOrder failed with ordered CRL")
+
+	// x509.CreateRevocationList refuses to produce a reversed CRL, so swap
+	// the encoded (equal length) UTCTime values of an ordered CRL instead.
+	encodedThisUpdate := []byte(thisUpdate.UTC().Format("060102150405Z"))
+	encodedNextUpdate := []byte(nextUpdate.UTC().Format("060102150405Z"))
+	reversedDER := bytes.Replace(crlDER, encodedThisUpdate, []byte("placeholder!!"), 1)
+	reversedDER = bytes.Replace(reversedDER, encodedNextUpdate, encodedThisUpdate, 1)
+	reversedDER = bytes.Replace(reversedDER, []byte("placeholder!!"), encodedNextUpdate, 1)
+	err = checkCRLUpdateOrder(reversedDER)
+	test.AssertError(t, err, "checkCRLUpdateOrder didn't fail with reversed CRL")
+	test.AssertContains(t, err.Error(), "is not after thisUpdate")
+
+	crlDER, err = x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: thisUpdate,
+		NextUpdate: thisUpdate,
+	}, cert, k)
+	test.AssertNotError(t, err, "failed to create CRL with equal timestamps")
+	err = checkCRLUpdateOrder(crlDER)
+	test.AssertError(t, err, "checkCRLUpdateOrder didn't fail with equal timestamps")
+	test.AssertContains(t, err.Error(), "is not after thisUpdate")
+}
+
+func TestCheckCRLThisUpdateNotInFuture(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "asd"},
+		SerialNumber:          big.NewInt(7),
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCRLSign,
+		SubjectKeyId:          []byte{1, 2, 3},
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to generate test cert")
+	cert, err := x509.ParseCertificate(certBytes)
+	test.AssertNotError(t, err, "failed to parse test cert")
+
+	thisUpdate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: thisUpdate,
+		NextUpdate: thisUpdate.Add(time.Hour),
+	}, cert, k)
+	test.AssertNotError(t, err, "failed to create CRL")
+
+	fc := clock.NewFake()
+	fc.Set(thisUpdate)
+	test.AssertNotError(t, checkCRLThisUpdateNotInFuture(crlDER, fc), "checkCRLThisUpdateNotInFuture failed with thisUpdate equal to now")
+	fc.Add(time.Hour)
+	test.AssertNotError(t, checkCRLThisUpdateNotInFuture(crlDER, fc), "checkCRLThisUpdateNotInFuture failed with thisUpdate in the past")
+	fc.Set(thisUpdate.Add(-time.Second))
+	err = checkCRLThisUpdateNotInFuture(crlDER, fc)
+	test.AssertError(t, err, "checkCRLThisUpdateNotInFuture didn't fail with thisUpdate in the future")
+	test.AssertContains(t, err.Error(), "is after the current time")
+
+	// generateCRL refuses to produce a future-dated CRL.
+	defer func(clk clock.Clock) { crlClock = clk }(crlClock)
+	crlClock = fc
+	_, err = generateCRL(&wrappedSigner{k}, cert, thisUpdate, thisUpdate.Add(time.Hour), 1, 0, nil)
+	test.AssertError(t, err, "generateCRL didn't fail with thisUpdate in the future")
+	test.AssertContains(t, err.Error(), "is after the current time")
+	fc.Set(thisUpdate)
+	_, err = generateCRL(&wrappedSigner{k}, cert, thisUpdate, thisUpdate.Add(time.Hour), 1, 0, nil)
+	test.AssertNotError(t, err, "generateCRL failed with thisUpdate equal to now")
+}
+
 type asn1CRL struct {
 	TBS struct {
 		Version int `asn1:"optional"`
@@ -165,3 +327,369 @@ type asn1CRL struct {
 	SigAlg pkix.AlgorithmIdentifier
 	Sig    asn1.BitString
 }
+
+func TestCheckCRLAuthorityKeyID(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "asd"},
+		SerialNumber:          big.NewInt(7),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCRLSign,
+		SubjectKeyId:          []byte{1, 2, 3},
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to generate test cert")
+	cert, err := x509.ParseCertificate(certBytes)
+	test.AssertNotError(t, err, "failed to parse test cert")
+
+	template.SubjectKeyId = []byte{4, 5, 6}
+	otherCertBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to generate other test cert")
+	otherCert, err := x509.ParseCertificate(otherCertBytes)
+	test.AssertNotError(t, err, "failed to parse other test cert")
+
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+	}, cert, k)
+	test.AssertNotError(t, err, "failed to create CRL")
+
+	test.AssertNotError(t, checkCRLAuthorityKeyID(crlDER, cert), "checkCRLAuthorityKeyID failed with matching AKI")
+
+	err = checkCRLAuthorityKeyID(crlDER, otherCert)
+	test.AssertError(t, err, "checkCRLAuthorityKeyID didn't fail with mismatched AKI")
+	test.AssertContains(t, err.Error(), "authorityKeyIdentifier (010203) doesn't match issuer subjectKeyIdentifier (040506)")
+}
+
+func TestCheckCRLIssuerName(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "asd"},
+		SerialNumber:          big.NewInt(7),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCRLSign,
+		SubjectKeyId:          []byte{1, 2, 3},
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to generate test cert")
+	cert, err := x509.ParseCertificate(certBytes)
+	test.AssertNotError(t, err, "failed to parse test cert")
+
+	template.Subject = pkix.Name{CommonName: "qwe"}
+	otherCertBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to generate other test cert")
+	otherCert, err := x509.ParseCertificate(otherCertBytes)
+	test.AssertNotError(t, err, "failed to parse other test cert")
+
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+	}, cert, k)
+	test.AssertNotError(t, err, "failed to create CRL")
+
+	test.AssertNotError(t, checkCRLIssuerName(crlDER, cert), "checkCRLIssuerName failed with matching issuer name")
+
+	err = checkCRLIssuerName(crlDER, otherCert)
+	test.AssertError(t, err, "checkCRLIssuerName didn't fail with mismatched issuer name")
+	test.AssertContains(t, err.Error(), "signed CRL issuer (CN=asd) doesn't match issuer certificate subject (CN=qwe)")
+}
+
+func TestCheckCRLDoesNotRevokeIssuer(t *testing.T) {
+	issuer := &x509.Certificate{SerialNumber: big.NewInt(7)}
+	revokedAt := time.Now()
+
+	err := checkCRLDoesNotRevokeIssuer([]x509.RevocationListEntry{
+		{SerialNumber: big.NewInt(6), RevocationTime: revokedAt},
+		{SerialNumber: big.NewInt(8), RevocationTime: revokedAt},
+	}, issuer)
+	test.AssertNotError(t, err, "checkCRLDoesNotRevokeIssuer failed without an entry matching the issuer serial")
+
+	err = checkCRLDoesNotRevokeIssuer([]x509.RevocationListEntry{
+		{SerialNumber: big.NewInt(6), RevocationTime: revokedAt},
+		{SerialNumber: big.NewInt(7), RevocationTime: revokedAt},
+	}, issuer)
+	test.AssertError(t, err, "checkCRLDoesNotRevokeIssuer didn't fail with an entry matching the issuer serial")
+	test.AssertEquals(t, err.Error(), "revoked certificate serial 7 is the serial of the issuing certificate")
+}
+
+func TestCheckCRLNoDuplicateSerials(t *testing.T) {
+	revokedAt := time.Now()
+
+	err := checkCRLNoDuplicateSerials([]x509.RevocationListEntry{
+		{SerialNumber: big.NewInt(6), RevocationTime: revokedAt},
+		{SerialNumber: big.NewInt(8), RevocationTime: revokedAt},
+	})
+	test.AssertNotError(t, err, "checkCRLNoDuplicateSerials failed without duplicate serials")
+
+	// A certificate in both revoked-certificates and revoked-certificates-dir
+	// produces two entries, possibly with different revocation times.
+	err = checkCRLNoDuplicateSerials([]x509.RevocationListEntry{
+		{SerialNumber: big.NewInt(6), RevocationTime: revokedAt},
+		{SerialNumber: big.NewInt(26), RevocationTime: revokedAt},
+		{SerialNumber: big.NewInt(26), RevocationTime: revokedAt.Add(time.Hour)},
+	})
+	test.AssertError(t, err, "checkCRLNoDuplicateSerials didn't fail with a duplicate serial")
+	test.AssertEquals(t, err.Error(), "revoked certificate serial 1a is listed more than once")
+}
+
+// makeCRLWithNumber returns a DER encoded CRL issued by issuer containing a
+// CRLNumber extension with the provided number. Unlike
+// x509.CreateRevocationList it doesn't limit the length of the number.
+func makeCRLWithNumber(t *testing.T, issuer *x509.Certificate, k *ecdsa.PrivateKey, number *big.Int) []byte {
+	t.Helper()
+	numberDER, err := asn1.Marshal(number)
+	test.AssertNotError(t, err, "failed to encode CRL number")
+	tbs, err := asn1.Marshal(struct {
+		Version    int
+		Signature  pkix.AlgorithmIdentifier
+		Issuer     asn1.RawValue
+		ThisUpdate time.Time
+		NextUpdate time.Time
+		Extensions []pkix.Extension `asn1:"explicit,tag:0"`
+	}{
+		Version:    1,
+		Signature:  pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Issuer:     asn1.RawValue{FullBytes: issuer.RawSubject},
+		ThisUpdate: time.Now().UTC().Truncate(time.Second),
+		NextUpdate: time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+		Extensions: []pkix.Extension{{Id: oidCRLNumber, Value: numberDER}},
+	})
+	test.AssertNotError(t, err, "failed to encode tbsCertList")
+	digest := sha256.Sum256(tbs)
+	signature, err := ecdsa.SignASN1(rand.Reader, k, digest[:])
+	test.AssertNotError(t, err, "failed to sign tbsCertList")
+	crlDER, err := asn1.Marshal(struct {
+		TBSCertList        asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		SignatureValue     asn1.BitString
+	}{
+		TBSCertList:        asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	})
+	test.AssertNotError(t, err, "failed to encode CRL")
+	return crlDER
+}
+
+func TestCheckCRLNumberLength(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "asd"},
+		SerialNumber:          big.NewInt(7),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCRLSign,
+		SubjectKeyId:          []byte{1, 2, 3},
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to generate test cert")
+	cert, err := x509.ParseCertificate(certBytes)
+	test.AssertNotError(t, err, "failed to parse test cert")
+
+	for _, tc := range []struct {
+		name        string
+		number      *big.Int
+		expectedErr string
+	}{
+		{
+			name:   "one octet",
+			number: big.NewInt(1),
+		},
+		{
+			// 2^159-1 is the largest number which encodes to 20 octets.
+			name:   "20 octets",
+			number: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 159), big.NewInt(1)),
+		},
+		{
+			// 2^159 is only 20 octets long, but its most significant bit is set
+			// so the encoding requires a leading sign octet.
+			name:        "21 octets",
+			number:      new(big.Int).Lsh(big.NewInt(1), 159),
+			expectedErr: "signed CRL number is 21 octets long, which is longer than 20 octets",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			crlDER := makeCRLWithNumber(t, cert, k, tc.number)
+			err = checkCRLNumberLength(crlDER)
+			if tc.expectedErr != "" {
+				test.AssertError(t, err, "checkCRLNumberLength didn't fail")
+				test.AssertEquals(t, err.Error(), tc.expectedErr)
+			} else {
+				test.AssertNotError(t, err, "checkCRLNumberLength failed")
+			}
+		})
+	}
+}
+
+func TestLoadRevokedCertificatesDir(t *testing.T) {
+	dir := t.TempDir()
+
+	ee1PEM, err := os.ReadFile("../../test/hierarchy/ee-e1.cert.pem")
+	test.AssertNotError(t, err, "failed to read test cert")
+	err = os.WriteFile(filepath.Join(dir, "ee-e1.cert.pem"), ee1PEM, 0644)
+	test.AssertNotError(t, err, "failed to write test cert")
+
+	ee2PEM, err := os.ReadFile("../../test/hierarchy/ee-e2.cert.pem")
+	test.AssertNotError(t, err, "failed to read test cert")
+	block, _ := pem.Decode(ee2PEM)
+	test.AssertNotNil(t, block, "failed to decode test cert")
+	err = os.WriteFile(filepath.Join(dir, "ee-e2.der"), block.Bytes, 0644)
+	test.AssertNotError(t, err, "failed to write test cert")
+
+	err = os.WriteFile(filepath.Join(dir, "README.txt"), []byte("not a certificate"), 0644)
+	test.AssertNotError(t, err, "failed to write non-cert file")
+
+	certs, err := loadRevokedCertificatesDir(dir)
+	test.AssertNotError(t, err, "failed to load revoked certificates directory")
+	test.AssertEquals(t, len(certs), 2)
+	ee1, err := loadCert("../../test/hierarchy/ee-e1.cert.pem")
+	test.AssertNotError(t, err, "failed to load test cert")
+	ee2, err := loadCert("../../test/hierarchy/ee-e2.cert.pem")
+	test.AssertNotError(t, err, "failed to load test cert")
+	test.AssertByteEquals(t, certs[0].Raw, ee1.Raw)
+	test.AssertByteEquals(t, certs[1].Raw, ee2.Raw)
+
+	// A .der file which doesn't contain a certificate is an error, rather
+	// than being skipped.
+	err = os.WriteFile(filepath.Join(dir, "broken.der"), []byte("not a certificate"), 0644)
+	test.AssertNotError(t, err, "failed to write broken cert")
+	_, err = loadRevokedCertificatesDir(dir)
+	test.AssertError(t, err, "loadRevokedCertificatesDir didn't fail with an unparseable certificate")
+	test.AssertContains(t, err.Error(), "broken.der")
+
+	_, err = loadRevokedCertificatesDir(t.TempDir())
+	test.AssertError(t, err, "loadRevokedCertificatesDir didn't fail with an empty directory")
+	test.AssertContains(t, err.Error(), "contains no certificates")
+}
+
+func TestRevocationListEntry(t *testing.T) {
+	cert := &x509.Certificate{SerialNumber: big.NewInt(10)}
+	revokedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	entry, err := revocationListEntry(cert, revokedAt, 0, time.Time{})
+	test.AssertNotError(t, err, "failed to create entry without reason")
+	test.AssertEquals(t, entry.SerialNumber.Cmp(cert.SerialNumber), 0)
+	test.AssertEquals(t, entry.RevocationTime, revokedAt)
+	test.AssertEquals(t, len(entry.Extensions), 0)
+
+	entry, err = revocationListEntry(cert, revokedAt, 5, time.Time{})
+	test.AssertNotError(t, err, "failed to create entry with reason")
+	test.AssertEquals(t, len(entry.Extensions), 1)
+	test.AssertDeepEquals(t, entry.Extensions[0].Id, asn1.ObjectIdentifier{2, 5, 29, 21})
+
+	invalidityDate := time.Date(2019, 12, 30, 1, 2, 3, 0, time.UTC)
+	entry, err = revocationListEntry(cert, revokedAt, 1, invalidityDate)
+	test.AssertNotError(t, err, "failed to create entry with invalidity date")
+	test.AssertEquals(t, len(entry.Extensions), 2)
+	test.AssertDeepEquals(t, entry.Extensions[1].Id, oidInvalidityDate)
+	test.Assert(t, !entry.Extensions[1].Critical, "invalidity date extension should not be critical")
+	// GeneralizedTime "20191230010203Z"
+	test.AssertByteEquals(t, entry.Extensions[1].Value, append([]byte{0x18, 0x0f}, "20191230010203Z"...))
+}
+
+func TestMultiCRL(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// makeIssuer writes a CRL signing issuer certificate and its private key
+	// to dir, returning the certificate and the paths of both files.
+	makeIssuer := func(name string) (*x509.Certificate, string, string) {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		test.AssertNotError(t, err, "failed to generate test key")
+		template := &x509.Certificate{
+			Subject:               pkix.Name{CommonName: name},
+			SerialNumber:          big.NewInt(7),
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(365 * 24 * time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCRLSign,
+			SubjectKeyId:          []byte(name),
+		}
+		certDER, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+		test.AssertNotError(t, err, "failed to generate test cert")
+		cert, err := x509.ParseCertificate(certDER)
+		test.AssertNotError(t, err, "failed to parse test cert")
+		certPath := filepath.Join(dir, name+".cert.pem")
+		writePEMFile(t, certPath, "CERTIFICATE", certDER)
+		keyDER, err := x509.MarshalPKCS8PrivateKey(k)
+		test.AssertNotError(t, err, "failed to marshal test key")
+		keyPath := filepath.Join(dir, name+".key.pem")
+		writePEMFile(t, keyPath, "PRIVATE KEY", keyDER)
+		return cert, certPath, keyPath
+	}
+	issuerA, issuerAPath, keyAPath := makeIssuer("issuer-a")
+	issuerB, issuerBPath, keyBPath := makeIssuer("issuer-b")
+
+	entry := func(issuerPath, crlPath string, number int) string {
+		return fmt.Sprintf(`    - inputs:
+          issuer-certificate-path: %s
+      outputs:
+          crl-path: %s
+      crl-profile:
+          this-update: %s
+          next-update: %s
+          number: %d
+`, issuerPath, crlPath, now.Format(time.DateTime), now.Add(24*time.Hour).Format(time.DateTime), number)
+	}
+	crlAPath := filepath.Join(dir, "a.crl.pem")
+	crlBPath := filepath.Join(dir, "b.crl.pem")
+	configBytes := []byte("ceremony-type: multi-crl\ncrls:\n" + entry(issuerAPath, crlAPath, 1) + entry(issuerBPath, crlBPath, 2))
+
+	var config multiCRLConfig
+	err := strictyaml.Unmarshal(configBytes, &config)
+	test.AssertNotError(t, err, "failed to parse config")
+	test.AssertEquals(t, len(config.CRLs), 2)
+	config.CRLs[0].PKCS11.softwareKeyPath = keyAPath
+	config.CRLs[1].PKCS11.softwareKeyPath = keyBPath
+	test.AssertNotError(t, config.validate(), "validate failed")
+	test.AssertNotError(t, writeCRLs(config.CRLs), "writeCRLs failed")
+
+	for _, tc := range []struct {
+		path   string
+		issuer *x509.Certificate
+		number int64
+	}{
+		{crlAPath, issuerA, 1},
+		{crlBPath, issuerB, 2},
+	} {
+		crlPEM, err := os.ReadFile(tc.path)
+		test.AssertNotError(t, err, "failed to read CRL")
+		block, _ := pem.Decode(crlPEM)
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		test.AssertNotError(t, err, "failed to parse CRL")
+		test.AssertNotError(t, crl.CheckSignatureFrom(tc.issuer), "CRL isn't signed by its issuer")
+		test.AssertEquals(t, crl.Number.Int64(), tc.number)
+	}
+
+	// Two entries writing the same CRL are rejected before either is written.
+	collidingPath := filepath.Join(dir, "colliding.crl.pem")
+	configBytes = []byte("ceremony-type: multi-crl\ncrls:\n" + entry(issuerAPath, collidingPath, 3) + entry(issuerBPath, collidingPath, 4))
+	config = multiCRLConfig{}
+	err = strictyaml.Unmarshal(configBytes, &config)
+	test.AssertNotError(t, err, "failed to parse config")
+	config.CRLs[0].PKCS11.softwareKeyPath = keyAPath
+	config.CRLs[1].PKCS11.softwareKeyPath = keyBPath
+	err = config.validate()
+	test.AssertError(t, err, "validate didn't fail for colliding output paths")
+	test.AssertEquals(t, err.Error(), fmt.Sprintf("crls[1]: outputs.crl-path %q is already written by crls[0]", collidingPath))
+
+	err = multiCRLConfig{CeremonyType: "multi-crl"}.validate()
+	test.AssertError(t, err, "validate didn't fail without any CRLs")
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/letsencrypt/boulder/strictyaml"
+)
+
+// dryRunPath is a file path named by a ceremony config, identified by its
+// dotted config field name.
+type dryRunPath struct {
+	field string
+	path  string
+}
+
+// collectConfigPaths walks the config struct v and returns every file path it
+// names, split into the paths which are read and the paths which are written.
+// A path is any string field whose YAML name is "path" or ends with "-path",
+// and it is written if it is within an "outputs" object.
+func collectConfigPaths(v reflect.Value, prefix string, isOutput bool) (inputs, outputs []dryRunPath) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return collectConfigPaths(v.Elem(), prefix, isOutput)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			in, out := collectConfigPaths(v.Index(i), fmt.Sprintf("%s[%d]", prefix, i), isOutput)
+			inputs = append(inputs, in...)
+			outputs = append(outputs, out...)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			tag, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if tag == "" || tag == "-" {
+				continue
+			}
+			name := tag
+			if prefix != "" {
+				name = prefix + "." + tag
+			}
+			fieldValue := v.Field(i)
+			if fieldValue.Kind() != reflect.String {
+				in, out := collectConfigPaths(fieldValue, name, isOutput || tag == "outputs")
+				inputs = append(inputs, in...)
+				outputs = append(outputs, out...)
+				continue
+			}
+			if fieldValue.String() == "" || (tag != "path" && !strings.HasSuffix(tag, "-path")) {
+				continue
+			}
+			p := dryRunPath{field: name, path: fieldValue.String()}
+			if isOutput {
+				outputs = append(outputs, p)
+			} else {
+				inputs = append(inputs, p)
+			}
+		}
+	}
+	return inputs, outputs
+}
+
+// checkDryRunPaths returns an error if any input doesn't exist, or if any
+// output already exists, is named more than once, or can't be created because
+// its directory isn't writable.
+func checkDryRunPaths(inputs, outputs []dryRunPath) error {
+	for _, in := range inputs {
+		_, err := os.Stat(in.path)
+		if err != nil {
+			return fmt.Errorf("%s is %q, which can't be read: %w", in.field, in.path, err)
+		}
+	}
+	seen := make(map[string]string)
+	for _, out := range outputs {
+		if field, ok := seen[out.path]; ok {
+			return fmt.Errorf("%s and %s are both %q", field, out.field, out.path)
+		}
+		seen[out.path] = out.field
+		if _, err := os.Stat(out.path); !os.IsNotExist(err) {
+			return fmt.Errorf("%s is %q, which already exists", out.field, out.path)
+		}
+		probe, err := os.CreateTemp(filepath.Dir(out.path), ".ceremony-dry-run-*")
+		if err != nil {
+			return fmt.Errorf("%s is %q, which can't be written: %w", out.field, out.path, err)
+		}
+		probe.Close()
+		err = os.Remove(probe.Name())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dummyKey returns a throwaway software key of the same type and size as pub.
+func dummyKey(pub crypto.PublicKey) (crypto.Signer, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.GenerateKey(rand.Reader, k.Size()*8)
+	case *ecdsa.PublicKey:
+		return ecdsa.GenerateKey(k.Curve, rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// dummyKeyFromConfig returns a throwaway software key of the type described by
+// kgc, which must already have been validated.
+func dummyKeyFromConfig(kgc keyGenConfig) (crypto.Signer, error) {
+	if kgc.Type == "rsa" {
+		return rsa.GenerateKey(rand.Reader, int(kgc.RSAModLength))
+	}
+	return ecdsa.GenerateKey(stringToCurve[kgc.ECDSACurve], rand.Reader)
+}
+
+// dryRunLintRoot lints the self-signed root certificate described by profile,
+// using a throwaway key of the type described by kgc in place of the key
+// which the ceremony would generate, and returns the linting certificate.
+func dryRunLintRoot(profile *certProfile, kgc keyGenConfig, skipLints []string, allowNonstandard bool) (lintCert, error) {
+	err := profile.checkNonstandard(allowNonstandard)
+	if err != nil {
+		return nil, err
+	}
+	uniqueIDs, err := profile.uniqueIDs()
+	if err != nil {
+		return nil, err
+	}
+	key, err := dummyKeyFromConfig(kgc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate dummy key: %w", err)
+	}
+	keyDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	template, err := makeTemplate(rand.Reader, profile, keyDER, nil, rootCert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate profile: %w", err)
+	}
+	return issueLintCertAndPerformLinting(template, template, key.Public(), key, uniqueIDs, skipLints, "")
+}
+
+// dryRunLintIssued lints the certificate described by profile as it would be
+// issued by the certificate at issuerPath. The issuer's key is replaced by a
+// throwaway key of the same type, as is the subject public key if it is on the
+// token rather than at pubKeyPath. If toBeCrossSignedPath is set, the profile
+// is for a cross-certificate of the certificate at that path. It returns the
+// linting certificate.
+func dryRunLintIssued(profile *certProfile, ct certType, issuerPath, pubKeyPath, toBeCrossSignedPath string, skipLints []string, allowNonstandard bool) (lintCert, error) {
+	err := profile.checkNonstandard(allowNonstandard)
+	if err != nil {
+		return nil, err
+	}
+	uniqueIDs, err := profile.uniqueIDs()
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := loadCert(issuerPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load issuer certificate %q: %w", issuerPath, err)
+	}
+	signer, err := dummyKey(issuer.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate dummy issuer key: %w", err)
+	}
+	var pub crypto.PublicKey
+	var pubBytes []byte
+	if pubKeyPath != "" {
+		pub, pubBytes, err = loadPubKey(pubKeyPath)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		subjectKey, err := dummyKey(issuer.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate dummy subject key: %w", err)
+		}
+		pub = subjectKey.Public()
+		pubBytes, err = x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var toBeCrossSigned *x509.Certificate
+	if toBeCrossSignedPath != "" {
+		toBeCrossSigned, err = loadCert(toBeCrossSignedPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load toBeCrossSigned certificate %q: %w", toBeCrossSignedPath, err)
+		}
+	}
+	template, err := makeTemplate(rand.Reader, profile, pubBytes, toBeCrossSigned, ct)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate profile: %w", err)
+	}
+	err = setAuthorityKeyID(template, profile, issuer)
+	if err != nil {
+		return nil, err
+	}
+	return issueLintCertAndPerformLinting(template, issuer, pub, signer, uniqueIDs, skipLints, "")
+}
+
+// dryRun checks the ceremony configured by configBytes without opening a
+// PKCS#11 session or generating any keys on a token, and writes a summary of
+// what the ceremony would do to w. The config is parsed and validated as the
+// ceremony would, every input path must exist, and every output path must be
+// absent and writable. Any certificate the ceremony would issue is linted
+// using throwaway software keys. If tbsPath is set, the unsigned
+// TBSCertificate of the linted certificate is written to it, for review before
+// the ceremony. Its serial number is random, and any key which the ceremony
+// would generate is replaced by a throwaway key, so it only matches the
+// certificate which the ceremony will issue in its other fields.
+func dryRun(w io.Writer, configBytes []byte, ceremonyType string, allowNonstandard bool, softwareKeyPath, soPIN, tbsPath string) error {
+	var config any
+	var validate func() error
+	var lint func() (lintCert, error)
+	switch ceremonyType {
+	case "root":
+		var c rootConfig
+		config = &c
+		validate = func() error {
+			err := c.PKCS11.setSOPIN(soPIN)
+			if err != nil {
+				return err
+			}
+			return c.validate()
+		}
+		lint = func() (lintCert, error) { return dryRunLintRoot(&c.CertProfile, c.Key, c.SkipLints, allowNonstandard) }
+	case "key-and-root":
+		var c keyAndRootConfig
+		config = &c
+		validate = func() error {
+			err := c.PKCS11.setSOPIN(soPIN)
+			if err != nil {
+				return err
+			}
+			return c.validate()
+		}
+		lint = func() (lintCert, error) { return dryRunLintRoot(&c.CertProfile, c.Key, c.SkipLints, allowNonstandard) }
+	case "key":
+		var c keyConfig
+		config = &c
+		validate = func() error {
+			err := c.PKCS11.setSOPIN(soPIN)
+			if err != nil {
+				return err
+			}
+			return c.validate()
+		}
+	case "wrapping-key":
+		var c wrappingKeyConfig
+		config = &c
+		validate = func() error {
+			err := c.PKCS11.setSOPIN(soPIN)
+			if err != nil {
+				return err
+			}
+			return c.validate()
+		}
+	case "intermediate", "ocsp-signer", "crl-signer":
+		ct := map[string]certType{"intermediate": intermediateCert, "ocsp-signer": ocspCert, "crl-signer": crlCert}[ceremonyType]
+		var c intermediateConfig
+		config = &c
+		validate = func() error {
+			c.PKCS11.softwareKeyPath = softwareKeyPath
+			return c.validate(ct)
+		}
+		lint = func() (lintCert, error) {
+			return dryRunLintIssued(&c.CertProfile, ct, c.Inputs.IssuerCertificatePath, c.Inputs.PublicKeyPath, "", c.SkipLints, allowNonstandard)
+		}
+	case "cross-certificate":
+		var c crossCertConfig
+		config = &c
+		validate = func() error {
+			c.PKCS11.softwareKeyPath = softwareKeyPath
+			return c.validate()
+		}
+		lint = func() (lintCert, error) {
+			return dryRunLintIssued(&c.CertProfile, crossCert, c.Inputs.IssuerCertificatePath, c.Inputs.PublicKeyPath, c.Inputs.CertificateToCrossSignPath, c.SkipLints, allowNonstandard)
+		}
+	case "cross-csr":
+		var c csrConfig
+		config = &c
+		validate = func() error { return c.validate() }
+	case "pkcs11-config":
+		var c pkcs11ConfigConfig
+		config = &c
+		validate = func() error { return c.validate() }
+	case "ocsp-response":
+		var c ocspRespConfig
+		config = &c
+		validate = func() error { return c.validate() }
+	case "crl":
+		var c crlConfig
+		config = &c
+		validate = func() error { return c.validate() }
+	case "multi-crl":
+		var c multiCRLConfig
+		config = &c
+		validate = func() error { return c.validate() }
+	case "renew":
+		var c renewConfig
+		config = &c
+		validate = func() error { return c.validate() }
+	case "seed-hierarchy":
+		var c seedHierarchyConfig
+		config = &c
+		validate = func() error { return c.validate() }
+	case "bundle-export":
+		var c bundleExportConfig
+		config = &c
+		validate = func() error {
+			c.PKCS11.softwareKeyPath = softwareKeyPath
+			return c.validate()
+		}
+	case "bundle-import":
+		var c bundleImportConfig
+		config = &c
+		validate = func() error {
+			c.PKCS11.softwareKeyPath = softwareKeyPath
+			return c.validate()
+		}
+	default:
+		return fmt.Errorf("unknown ceremony-type %q", ceremonyType)
+	}
+
+	if tbsPath != "" && lint == nil {
+		return fmt.Errorf("--emit-tbs is not supported by the %s ceremony", ceremonyType)
+	}
+
+	err := strictyaml.Unmarshal(configBytes, config)
+	if err != nil {
+		return configErrorf("failed to parse config: %w", err)
+	}
+	err = validate()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	inputs, outputs := collectConfigPaths(reflect.ValueOf(config), "", false)
+	checkedOutputs := outputs
+	if tbsPath != "" {
+		err = checkOutputFile(tbsPath, "emit-tbs")
+		if err != nil {
+			return err
+		}
+		checkedOutputs = append(outputs[:len(outputs):len(outputs)], dryRunPath{field: "--emit-tbs", path: tbsPath})
+	}
+	err = checkDryRunPaths(inputs, checkedOutputs)
+	if err != nil {
+		return err
+	}
+	if lint != nil {
+		lc, err := lint()
+		if err != nil {
+			return fmt.Errorf("dummy certificate failed: %w", err)
+		}
+		if tbsPath != "" {
+			err = writeFile(tbsPath, lc.RawTBSCertificate)
+			if err != nil {
+				return fmt.Errorf("failed to write TBSCertificate to %q: %w", tbsPath, err)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "Dry run of %s ceremony passed, no PKCS#11 session was opened.\n", ceremonyType)
+	for _, in := range inputs {
+		fmt.Fprintf(w, "  would read %s: %s\n", in.field, in.path)
+	}
+	for _, out := range outputs {
+		fmt.Fprintf(w, "  would write %s: %s\n", out.field, out.path)
+	}
+	if lint != nil {
+		fmt.Fprintln(w, "  certificate profile passed linting with a dummy key")
+		if tbsPath != "" {
+			fmt.Fprintf(w, "  wrote unsigned TBSCertificate to %s\n", tbsPath)
+		}
+	} else {
+		fmt.Fprintln(w, "  no certificate profile was linted")
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestCollectConfigPaths(t *testing.T) {
+	var config multiCRLConfig
+	config.CRLs = make([]crlConfig, 2)
+	config.CRLs[0].PKCS11.ExpectedPublicKeyPath = "expected.pem"
+	config.CRLs[0].Inputs.IssuerCertificatePath = "issuer-a.pem"
+	config.CRLs[0].Outputs.CRLPath = "a.crl"
+	config.CRLs[1].Inputs.IssuerCertificatePath = "issuer-b.pem"
+	config.CRLs[1].Outputs.CRLPath = "b.crl"
+	config.CRLs[1].CRLProfile.RevokedCertificatesDir = &revokedCertificatesDirConfig{Path: "revoked"}
+
+	inputs, outputs := collectConfigPaths(reflect.ValueOf(&config), "", false)
+	test.AssertDeepEquals(t, inputs, []dryRunPath{
+		{"crls[0].pkcs11.expected-public-key-path", "expected.pem"},
+		{"crls[0].inputs.issuer-certificate-path", "issuer-a.pem"},
+		{"crls[1].inputs.issuer-certificate-path", "issuer-b.pem"},
+		{"crls[1].crl-profile.revoked-certificates-dir.path", "revoked"},
+	})
+	test.AssertDeepEquals(t, outputs, []dryRunPath{
+		{"crls[0].outputs.crl-path", "a.crl"},
+		{"crls[1].outputs.crl-path", "b.crl"},
+	})
+}
+
+func TestCheckDryRunPaths(t *testing.T) {
+	dir := t.TempDir()
+	existing := path.Join(dir, "existing.pem")
+	err := os.WriteFile(existing, []byte("existing"), 0600)
+	test.AssertNotError(t, err, "failed to write file")
+
+	err = checkDryRunPaths([]dryRunPath{{"inputs.a-path", existing}}, []dryRunPath{{"outputs.b-path", path.Join(dir, "b.pem")}})
+	test.AssertNotError(t, err, "checkDryRunPaths failed")
+
+	err = checkDryRunPaths([]dryRunPath{{"inputs.a-path", path.Join(dir, "missing.pem")}}, nil)
+	test.AssertError(t, err, "checkDryRunPaths didn't fail for a missing input")
+	test.AssertContains(t, err.Error(), "inputs.a-path is")
+
+	err = checkDryRunPaths(nil, []dryRunPath{{"outputs.b-path", existing}})
+	test.AssertError(t, err, "checkDryRunPaths didn't fail for an existing output")
+	test.AssertContains(t, err.Error(), "which already exists")
+
+	err = checkDryRunPaths(nil, []dryRunPath{{"outputs.b-path", path.Join(dir, "missing", "b.pem")}})
+	test.AssertError(t, err, "checkDryRunPaths didn't fail for an output in a missing directory")
+	test.AssertContains(t, err.Error(), "which can't be written")
+
+	out := path.Join(dir, "b.pem")
+	err = checkDryRunPaths(nil, []dryRunPath{{"outputs.b-path", out}, {"outputs.c-path", out}})
+	test.AssertError(t, err, "checkDryRunPaths didn't fail for a repeated output")
+	test.AssertContains(t, err.Error(), "outputs.b-path and outputs.c-path are both")
+
+	entries, err := os.ReadDir(dir)
+	test.AssertNotError(t, err, "failed to read directory")
+	test.AssertEquals(t, len(entries), 1)
+}
+
+func TestDryRunIntermediate(t *testing.T) {
+	dir := t.TempDir()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate root key")
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root", Organization: []string{"organization"}, Country: []string{"US"}},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootKey.Public(), rootKey)
+	test.AssertNotError(t, err, "failed to create root certificate")
+	rootPath := path.Join(dir, "root.cert.pem")
+	writePEMFile(t, rootPath, "CERTIFICATE", rootDER)
+
+	intKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate intermediate key")
+	intPubDER, err := x509.MarshalPKIXPublicKey(intKey.Public())
+	test.AssertNotError(t, err, "failed to marshal intermediate public key")
+	intPubPath := path.Join(dir, "int.pubkey.pem")
+	writePEMFile(t, intPubPath, "PUBLIC KEY", intPubDER)
+	intCertPath := path.Join(dir, "int.cert.pem")
+
+	// The PKCS#11 module doesn't exist, so the dry run would fail if it tried
+	// to use it.
+	config := func(crlURL string) []byte {
+		return []byte(fmt.Sprintf(`ceremony-type: intermediate
+pkcs11:
+    module: %s
+    signing-key-label: root signing key
+inputs:
+    public-key-path: %s
+    issuer-certificate-path: %s
+outputs:
+    certificate-path: %s
+certificate-profile:
+    signature-algorithm: ECDSAWithSHA384
+    common-name: intermediate
+    organization: organization
+    country: US
+    not-before: 2020-01-01 00:00:00
+    not-after: 2027-01-01 00:00:00
+    crl-url: %s
+    issuer-url: http://issuer.example.org/root
+    policies:
+        - oid: 2.23.140.1.2.1
+    key-usages:
+        - Digital Signature
+        - Cert Sign
+        - CRL Sign
+`, path.Join(dir, "missing-module.so"), intPubPath, rootPath, intCertPath, crlURL))
+	}
+
+	var summary bytes.Buffer
+	err = dryRun(&summary, config("http://crl.example.org/crl"), "intermediate", false, "", "", "")
+	test.AssertNotError(t, err, "dry run failed")
+	test.AssertContains(t, summary.String(), "would read inputs.issuer-certificate-path: "+rootPath)
+	test.AssertContains(t, summary.String(), "would write outputs.certificate-path: "+intCertPath)
+	test.AssertContains(t, summary.String(), "passed linting with a dummy key")
+	_, err = os.Stat(intCertPath)
+	test.Assert(t, os.IsNotExist(err), "dry run wrote the certificate")
+
+	// With a TBS path the dry run also writes the TBSCertificate which the
+	// dummy certificate was signed over.
+	tbsPath := path.Join(dir, "int.tbs.der")
+	summary.Reset()
+	err = dryRun(&summary, config("http://crl.example.org/crl"), "intermediate", false, "", "", tbsPath)
+	test.AssertNotError(t, err, "dry run with a TBS path failed")
+	test.AssertContains(t, summary.String(), "wrote unsigned TBSCertificate to "+tbsPath)
+	tbsDER, err := os.ReadFile(tbsPath)
+	test.AssertNotError(t, err, "failed to read TBSCertificate")
+	// Wrap the TBSCertificate in a certificate with a placeholder signature,
+	// which x509.ParseCertificate doesn't check, so that it can be parsed.
+	certDER, err := asn1.Marshal(certificateASN1{
+		TBSCertificate:     asn1.RawValue{FullBytes: tbsDER},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}},
+		SignatureValue:     asn1.BitString{Bytes: []byte{0}, BitLength: 8},
+	})
+	test.AssertNotError(t, err, "failed to encode certificate")
+	cert, err := x509.ParseCertificate(certDER)
+	test.AssertNotError(t, err, "emitted TBSCertificate didn't parse")
+	root, err := x509.ParseCertificate(rootDER)
+	test.AssertNotError(t, err, "failed to parse root certificate")
+	test.AssertByteEquals(t, cert.RawIssuer, root.RawSubject)
+	test.AssertEquals(t, cert.SignatureAlgorithm, x509.ECDSAWithSHA384)
+	test.AssertEquals(t, cert.Subject.String(), "CN=intermediate,O=organization,C=US")
+	test.AssertEquals(t, cert.NotBefore, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	test.AssertEquals(t, cert.NotAfter, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+	test.AssertDeepEquals(t, cert.CRLDistributionPoints, []string{"http://crl.example.org/crl"})
+	test.AssertDeepEquals(t, cert.IssuingCertificateURL, []string{"http://issuer.example.org/root"})
+	test.AssertEquals(t, cert.KeyUsage, x509.KeyUsageDigitalSignature|x509.KeyUsageCertSign|x509.KeyUsageCRLSign)
+	test.Assert(t, intKey.PublicKey.Equal(cert.PublicKey), "TBSCertificate has the wrong public key")
+
+	// An existing TBS path fails the dry run.
+	err = dryRun(&summary, config("http://crl.example.org/crl"), "intermediate", false, "", "", tbsPath)
+	test.AssertError(t, err, "dry run didn't fail for an existing TBS path")
+	test.AssertEquals(t, err.Error(), fmt.Sprintf("outputs.emit-tbs is %q, which already exists", tbsPath))
+
+	// As does a TBS path for a ceremony which doesn't issue a certificate.
+	err = dryRun(&summary, []byte("ceremony-type: cross-csr\n"), "cross-csr", false, "", "", path.Join(dir, "csr.tbs.der"))
+	test.AssertError(t, err, "dry run didn't fail for a TBS path with a cross-csr ceremony")
+	test.AssertEquals(t, err.Error(), "--emit-tbs is not supported by the cross-csr ceremony")
+
+	// A profile which fails linting fails the dry run.
+	err = dryRun(&summary, config("https://crl.example.org/crl"), "intermediate", false, "", "", "")
+	test.AssertError(t, err, "dry run didn't fail for a certificate which fails linting")
+	test.AssertContains(t, err.Error(), "dummy certificate failed: certificate failed pre-issuance lint")
+
+	// As does a config which doesn't validate.
+	err = dryRun(&summary, []byte("ceremony-type: intermediate\n"), "intermediate", false, "", "", "")
+	test.AssertError(t, err, "dry run didn't fail for an invalid config")
+	test.AssertContains(t, err.Error(), "failed to validate config")
+}
@@ -27,14 +27,14 @@ var curveToOIDDER = map[string][]byte{
 }
 
 // ecArgs constructs the private and public key template attributes sent to the
-// device and specifies which mechanism should be used. curve determines which
+// device and specifies that mechanism should be used. curve determines which
 // type of key should be generated.
-func ecArgs(label string, curve elliptic.Curve, keyID []byte) generateArgs {
+func ecArgs(label string, curve elliptic.Curve, keyID []byte, mechanism uint) generateArgs {
 	encodedCurve := curveToOIDDER[curve.Params().Name]
 	log.Printf("\tEncoded curve parameters for %s: %X\n", curve.Params().Name, encodedCurve)
 	return generateArgs{
 		mechanism: []*pkcs11.Mechanism{
-			pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil),
+			pkcs11.NewMechanism(mechanism, nil),
 		},
 		publicAttrs: []*pkcs11.Attribute{
 			pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
@@ -78,10 +78,11 @@ func ecPub(
 }
 
 // ecGenerate is used to generate and verify a ECDSA key pair of the type
-// specified by curveStr and with the provided label. It returns the public
+// specified by curveStr and with the provided label, using the key pair
+// generation mechanism specified by mechanism. It returns the public
 // part of the generated key pair as a ecdsa.PublicKey and the random key ID
 // that the HSM uses to identify the key pair.
-func ecGenerate(session *pkcs11helpers.Session, label, curveStr string) (*ecdsa.PublicKey, []byte, error) {
+func ecGenerate(session *pkcs11helpers.Session, label, curveStr string, mechanism uint) (*ecdsa.PublicKey, []byte, error) {
 	curve, present := stringToCurve[curveStr]
 	if !present {
 		return nil, nil, fmt.Errorf("curve %q not supported", curveStr)
@@ -92,7 +93,7 @@ func ecGenerate(session *pkcs11helpers.Session, label, curveStr string) (*ecdsa.
 		return nil, nil, err
 	}
 	log.Printf("Generating ECDSA key with curve %s and ID %x\n", curveStr, keyID)
-	args := ecArgs(label, curve, keyID)
+	args := ecArgs(label, curve, keyID, mechanism)
 	pub, _, err := session.GenerateKeyPair(args.mechanism, args.publicAttrs, args.privateAttrs)
 	if err != nil {
 		return nil, nil, err
@@ -44,14 +44,14 @@ func TestECGenerate(t *testing.T) {
 	test.AssertNotError(t, err, "Failed to generate a ECDSA test key")
 
 	// Test ecGenerate fails with unknown curve
-	_, _, err = ecGenerate(s, "", "bad-curve")
+	_, _, err = ecGenerate(s, "", "bad-curve", pkcs11.CKM_EC_KEY_PAIR_GEN)
 	test.AssertError(t, err, "ecGenerate accepted unknown curve")
 
 	// Test ecGenerate fails when GenerateKeyPair fails
 	ctx.GenerateKeyPairFunc = func(pkcs11.SessionHandle, []*pkcs11.Mechanism, []*pkcs11.Attribute, []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error) {
 		return 0, 0, errors.New("bad")
 	}
-	_, _, err = ecGenerate(s, "", "P-256")
+	_, _, err = ecGenerate(s, "", "P-256", pkcs11.CKM_EC_KEY_PAIR_GEN)
 	test.AssertError(t, err, "ecGenerate didn't fail on GenerateKeyPair error")
 
 	// Test ecGenerate fails when ecPub fails
@@ -61,7 +61,7 @@ func TestECGenerate(t *testing.T) {
 	ctx.GetAttributeValueFunc = func(pkcs11.SessionHandle, pkcs11.ObjectHandle, []*pkcs11.Attribute) ([]*pkcs11.Attribute, error) {
 		return nil, errors.New("bad")
 	}
-	_, _, err = ecGenerate(s, "", "P-256")
+	_, _, err = ecGenerate(s, "", "P-256", pkcs11.CKM_EC_KEY_PAIR_GEN)
 	test.AssertError(t, err, "ecGenerate didn't fail on ecPub error")
 
 	// Test ecGenerate fails when ecVerify fails
@@ -74,7 +74,7 @@ func TestECGenerate(t *testing.T) {
 	ctx.GenerateRandomFunc = func(pkcs11.SessionHandle, int) ([]byte, error) {
 		return nil, errors.New("yup")
 	}
-	_, _, err = ecGenerate(s, "", "P-256")
+	_, _, err = ecGenerate(s, "", "P-256", pkcs11.CKM_EC_KEY_PAIR_GEN)
 	test.AssertError(t, err, "ecGenerate didn't fail on ecVerify error")
 
 	// Test ecGenerate doesn't fail when everything works
@@ -87,7 +87,7 @@ func TestECGenerate(t *testing.T) {
 	ctx.SignFunc = func(_ pkcs11.SessionHandle, msg []byte) ([]byte, error) {
 		return ecPKCS11Sign(priv, msg)
 	}
-	_, _, err = ecGenerate(s, "", "P-256")
+	_, _, err = ecGenerate(s, "", "P-256", pkcs11.CKM_EC_KEY_PAIR_GEN)
 	test.AssertNotError(t, err, "ecGenerate didn't succeed when everything worked as expected")
 }
 
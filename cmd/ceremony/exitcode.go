@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/miekg/pkcs11"
+
+	"github.com/letsencrypt/boulder/linter"
+)
+
+// exitCode is the status the tool exits with when a ceremony fails. Each class
+// of failure has its own code, so that automation can tell them apart without
+// parsing the log.
+type exitCode int
+
+const (
+	// exitFailure is used for failures which don't fall into any other class.
+	exitFailure exitCode = 1
+	// exitConfig is used when the command line or config can't be parsed, or
+	// fails validation.
+	exitConfig exitCode = 2
+	// exitHSM is used when the PKCS#11 module can't be loaded, a session can't
+	// be opened, or the token returns an error.
+	exitHSM exitCode = 3
+	// exitLint is used when a certificate or CRL fails pre-issuance linting.
+	exitLint exitCode = 4
+	// exitWrite is used when an output file can't be written.
+	exitWrite exitCode = 5
+)
+
+// failure is an error which belongs to the class of failure identified by code.
+type failure struct {
+	code exitCode
+	err  error
+}
+
+func (f failure) Error() string {
+	return f.err.Error()
+}
+
+func (f failure) Unwrap() error {
+	return f.err
+}
+
+// configErrorf formats an error which is reported with exitConfig.
+func configErrorf(format string, a ...any) error {
+	return failure{exitConfig, fmt.Errorf(format, a...)}
+}
+
+// hsmErrorf formats an error which is reported with exitHSM.
+func hsmErrorf(format string, a ...any) error {
+	return failure{exitHSM, fmt.Errorf(format, a...)}
+}
+
+// exitCodeFor returns the exitCode for the class of failure which caused err.
+// Errors returned by the PKCS#11 module and lint failures are recognized
+// wherever they are wrapped, anything else which hasn't been classified is
+// reported with exitFailure.
+func exitCodeFor(err error) exitCode {
+	var f failure
+	if errors.As(err, &f) {
+		return f.code
+	}
+	if errors.Is(err, linter.ErrLinting) {
+		return exitLint
+	}
+	var p11Err pkcs11.Error
+	if errors.As(err, &p11Err) {
+		return exitHSM
+	}
+	return exitFailure
+}
+
+// fatalf logs the formatted message and exits with code.
+func fatalf(code exitCode, format string, a ...any) {
+	log.Printf(format, a...)
+	os.Exit(int(code))
+}
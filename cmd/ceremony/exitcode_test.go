@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/pkcs11"
+
+	"github.com/letsencrypt/boulder/linter"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	dir := t.TempDir()
+	existing := path.Join(dir, "existing")
+	test.AssertNotError(t, writeFile(existing, nil), "failed to write file")
+
+	testCases := []struct {
+		name string
+		err  error
+		want exitCode
+	}{
+		{
+			name: "unclassified",
+			err:  errors.New("something went wrong"),
+			want: exitFailure,
+		},
+		{
+			name: "config",
+			err:  fmt.Errorf("wrapped: %w", configErrorf("failed to validate config: %w", errors.New("bad"))),
+			want: exitConfig,
+		},
+		{
+			name: "session",
+			err:  hsmErrorf("failed to setup session and PKCS#11 context for slot %d: %w", 1, errors.New("bad")),
+			want: exitHSM,
+		},
+		{
+			name: "token",
+			err:  fmt.Errorf("failed to sign data: %w", pkcs11.Error(pkcs11.CKR_DEVICE_ERROR)),
+			want: exitHSM,
+		},
+		{
+			name: "lint",
+			err:  fmt.Errorf("certificate failed pre-issuance lint: %w", linter.ErrLinting),
+			want: exitLint,
+		},
+		{
+			name: "write",
+			err:  fmt.Errorf("failed to write certificate: %w", writeFile(existing, nil)),
+			want: exitWrite,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			test.AssertEquals(t, exitCodeFor(tc.err), tc.want)
+		})
+	}
+}
+
+func TestCeremonyExitCodes(t *testing.T) {
+	dir := t.TempDir()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate root key")
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root", Organization: []string{"organization"}, Country: []string{"US"}},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootKey.Public(), rootKey)
+	test.AssertNotError(t, err, "failed to create root certificate")
+	rootPath := path.Join(dir, "root.cert.pem")
+	writePEMFile(t, rootPath, "CERTIFICATE", rootDER)
+	rootKeyDER, err := x509.MarshalPKCS8PrivateKey(rootKey)
+	test.AssertNotError(t, err, "failed to marshal root key")
+	rootKeyPath := path.Join(dir, "root.key.pem")
+	writePEMFile(t, rootKeyPath, "PRIVATE KEY", rootKeyDER)
+
+	intKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate intermediate key")
+	intPubDER, err := x509.MarshalPKIXPublicKey(intKey.Public())
+	test.AssertNotError(t, err, "failed to marshal intermediate public key")
+	intPubPath := path.Join(dir, "int.pubkey.pem")
+	writePEMFile(t, intPubPath, "PUBLIC KEY", intPubDER)
+
+	config := fmt.Sprintf(`ceremony-type: intermediate
+inputs:
+    public-key-path: %s
+    issuer-certificate-path: %s
+outputs:
+    certificate-path: %s
+certificate-profile:
+    signature-algorithm: ECDSAWithSHA384
+    common-name: intermediate
+    organization: organization
+    country: US
+    not-before: 2020-01-01 00:00:00
+    not-after: 2027-01-01 00:00:00
+    crl-url: http://crl.example.org/crl
+    issuer-url: http://issuer.example.org/root
+    policies:
+        - oid: 2.23.140.1.2.1
+`, intPubPath, rootPath, path.Join(dir, "int.cert.pem"))
+
+	// A config which fails validation.
+	invalid := strings.Replace(config, "    common-name: intermediate\n", "", 1)
+	err = intermediateCeremony([]byte(invalid), intermediateCert, false, rootKeyPath, "")
+	test.AssertError(t, err, "intermediate ceremony didn't fail with an invalid config")
+	test.AssertEquals(t, exitCodeFor(err), exitConfig)
+
+	// An LDAP CA issuers URL passes validation, but fails linting.
+	ldap := strings.Replace(config, "issuer-url: http://", "issuer-url: ldap://", 1)
+	err = intermediateCeremony([]byte(ldap), intermediateCert, false, rootKeyPath, "")
+	test.AssertError(t, err, "intermediate ceremony didn't fail linting")
+	test.AssertContains(t, err.Error(), "certificate failed pre-issuance lint")
+	test.AssertEquals(t, exitCodeFor(err), exitLint)
+
+	err = intermediateCeremony([]byte(config), intermediateCert, false, rootKeyPath, "")
+	test.AssertNotError(t, err, "intermediate ceremony failed")
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"crypto"
+	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -44,41 +45,99 @@ type keyInfo struct {
 	id  []byte
 }
 
-func generateKey(session *pkcs11helpers.Session, label string, outputPath string, config keyGenConfig) (*keyInfo, error) {
+// generateKey generates the key pair described by config on the device, using
+// the PKCS#11 key pair generation mechanism, and writes its public key to
+// outputPath.
+func generateKey(session *pkcs11helpers.Session, label string, outputPath string, config keyGenConfig, mechanism uint) (*keyInfo, error) {
 	_, err := session.FindObject([]*pkcs11.Attribute{
 		{Type: pkcs11.CKA_LABEL, Value: []byte(label)},
 	})
 	if err != pkcs11helpers.ErrNoObject {
-		return nil, fmt.Errorf("expected no preexisting objects with label %q in slot for key storage. got error: %s", label, err)
+		return nil, fmt.Errorf("expected no preexisting objects with label %q in slot for key storage. got error: %w", label, err)
 	}
 
 	var pubKey crypto.PublicKey
 	var keyID []byte
 	switch config.Type {
 	case "rsa":
-		pubKey, keyID, err = rsaGenerate(session, label, config.RSAModLength)
+		pubKey, keyID, err = rsaGenerate(session, label, config.RSAModLength, mechanism)
 		if err != nil {
-			return nil, fmt.Errorf("failed to generate RSA key pair: %s", err)
+			return nil, fmt.Errorf("failed to generate RSA key pair: %w", err)
 		}
 	case "ecdsa":
-		pubKey, keyID, err = ecGenerate(session, label, config.ECDSACurve)
+		pubKey, keyID, err = ecGenerate(session, label, config.ECDSACurve, mechanism)
 		if err != nil {
-			return nil, fmt.Errorf("failed to generate ECDSA key pair: %s", err)
+			return nil, fmt.Errorf("failed to generate ECDSA key pair: %w", err)
 		}
+	case "mldsa":
+		pubKey, keyID, err = mldsaGenerate(session, label, config.MLDSAParamSet, mechanism)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ML-DSA key pair: %w", err)
+		}
+	}
+
+	der, err := writePublicKey(pubKey, outputPath)
+	if err != nil {
+		return nil, err
 	}
 
-	der, err := x509.MarshalPKIXPublicKey(pubKey)
+	return &keyInfo{key: pubKey, der: der, id: keyID}, nil
+}
+
+// writePublicKey writes pubKey to outputPath as a PEM PKIX public key, and
+// returns the DER encoding of its SubjectPublicKeyInfo.
+func writePublicKey(pubKey crypto.PublicKey, outputPath string) ([]byte, error) {
+	der, err := marshalPKIXPublicKey(pubKey)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to marshal public key: %s", err)
+		return nil, fmt.Errorf("Failed to marshal public key: %w", err)
 	}
 
 	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
 	log.Printf("Public key PEM:\n%s\n", pemBytes)
 	err = writeFile(outputPath, pemBytes)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to write public key to %q: %s", outputPath, err)
+		return nil, fmt.Errorf("Failed to write public key to %q: %w", outputPath, err)
 	}
 	log.Printf("Public key written to %q\n", outputPath)
+	return der, nil
+}
 
-	return &keyInfo{key: pubKey, der: der, id: keyID}, nil
+// getPublicKeyByLabel reads the RSA or ECDSA public key object with the given
+// label from the token.
+func getPublicKeyByLabel(session *pkcs11helpers.Session, label string) (crypto.PublicKey, error) {
+	for _, keyType := range []uint{pkcs11.CKK_RSA, pkcs11.CKK_EC} {
+		handle, err := session.FindObject([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, []byte(label)),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType),
+		})
+		if err == pkcs11helpers.ErrNoObject {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if keyType == pkcs11.CKK_RSA {
+			return session.GetRSAPublicKey(handle)
+		}
+		return session.GetECDSAPublicKey(handle)
+	}
+	return nil, fmt.Errorf("no RSA or ECDSA public key with label %q found", label)
+}
+
+// writePKCS1PublicKey writes pubKey, which must be an RSA public key, to
+// outputPath as a PKCS#1 "RSA PUBLIC KEY" PEM, for tools which don't accept
+// the PKIX form.
+func writePKCS1PublicKey(pubKey crypto.PublicKey, outputPath string) error {
+	rsaKey, ok := pubKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("a PKCS#1 public key can only be written for an RSA key, not %T", pubKey)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(rsaKey)})
+	err := writeFile(outputPath, pemBytes)
+	if err != nil {
+		return fmt.Errorf("Failed to write PKCS#1 public key to %q: %w", outputPath, err)
+	}
+	log.Printf("PKCS#1 public key written to %q\n", outputPath)
+	return nil
 }
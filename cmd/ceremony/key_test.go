@@ -63,7 +63,7 @@ func TestGenerateKeyRSA(t *testing.T) {
 	keyInfo, err := generateKey(s, "", keyPath, keyGenConfig{
 		Type:         "rsa",
 		RSAModLength: 1024,
-	})
+	}, pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN)
 	test.AssertNotError(t, err, "Failed to generate RSA key")
 	diskKeyBytes, err := os.ReadFile(keyPath)
 	test.AssertNotError(t, err, "Failed to load key from disk")
@@ -73,6 +73,96 @@ func TestGenerateKeyRSA(t *testing.T) {
 	test.AssertDeepEquals(t, diskKey, keyInfo.key)
 }
 
+func TestWritePKCS1PublicKey(t *testing.T) {
+	tmp := t.TempDir()
+
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 1024)
+	test.AssertNotError(t, err, "Failed to generate a test RSA key")
+	keyPath := path.Join(tmp, "test-rsa-key.pkcs1.pem")
+	err = writePKCS1PublicKey(rsaPriv.Public(), keyPath)
+	test.AssertNotError(t, err, "Failed to write PKCS#1 public key")
+
+	diskKeyBytes, err := os.ReadFile(keyPath)
+	test.AssertNotError(t, err, "Failed to load key from disk")
+	block, _ := pem.Decode(diskKeyBytes)
+	test.AssertEquals(t, block.Type, "RSA PUBLIC KEY")
+	diskKey, err := x509.ParsePKCS1PublicKey(block.Bytes)
+	test.AssertNotError(t, err, "Failed to parse disk key")
+	test.AssertEquals(t, diskKey.N.Cmp(rsaPriv.N), 0)
+	test.AssertEquals(t, diskKey.E, rsaPriv.E)
+
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "Failed to generate a test ECDSA key")
+	err = writePKCS1PublicKey(ecPriv.Public(), path.Join(tmp, "test-ec-key.pkcs1.pem"))
+	test.AssertError(t, err, "writePKCS1PublicKey didn't fail for an ECDSA key")
+}
+
+func TestLoadSubjectPubKeyFromToken(t *testing.T) {
+	useSoftTokens(t)
+	session, err := initializeSession("module", 1, pkcs11.CKU_USER, "")
+	test.AssertNotError(t, err, "failed to open session")
+	keyInfo, err := generateKey(session, "subject key", path.Join(t.TempDir(), "subject.pubkey.pem"), keyGenConfig{
+		Type:       "ecdsa",
+		ECDSACurve: "P-256",
+	}, pkcs11.CKM_EC_KEY_PAIR_GEN)
+	test.AssertNotError(t, err, "failed to generate key")
+
+	cfg := PKCS11SigningConfig{Module: "module", SigningSlot: 1, SigningLabel: "signing key"}
+	pub, der, err := loadSubjectPubKey("", "subject key", cfg)
+	test.AssertNotError(t, err, "failed to load public key from token")
+	test.AssertDeepEquals(t, pub, keyInfo.key)
+	test.AssertByteEquals(t, der, keyInfo.der)
+
+	_, _, err = loadSubjectPubKey("", "missing key", cfg)
+	test.AssertError(t, err, "loaded a public key which isn't on the token")
+	test.AssertEquals(t, err.Error(), `failed to load inputs.public-key-label "missing key": no RSA or ECDSA public key with label "missing key" found`)
+}
+
+func TestPKCS11ConfigCeremony(t *testing.T) {
+	useSoftTokens(t)
+	tmp := t.TempDir()
+	session, err := initializeSession("module", 2, pkcs11.CKU_USER, "")
+	test.AssertNotError(t, err, "failed to open session")
+	keyInfo, err := generateKey(session, "existing key", path.Join(tmp, "original.pubkey.pem"), keyGenConfig{
+		Type:       "ecdsa",
+		ECDSACurve: "P-256",
+	}, pkcs11.CKM_EC_KEY_PAIR_GEN)
+	test.AssertNotError(t, err, "failed to generate key")
+
+	configFor := func(label string) []byte {
+		return []byte(`ceremony-type: pkcs11-config
+pkcs11:
+    module: module
+    pin: "1234"
+    store-key-in-slot: 2
+    store-key-with-label: ` + label + `
+outputs:
+    public-key-path: ` + path.Join(tmp, label+".pubkey.pem") + `
+    pkcs11-config-path: ` + path.Join(tmp, label+".pkcs11.json") + `
+`)
+	}
+
+	err = pkcs11ConfigCeremony(configFor("existing key"))
+	test.AssertNotError(t, err, "pkcs11-config ceremony failed")
+	pubKey, _, err := loadPubKey(path.Join(tmp, "existing key.pubkey.pem"))
+	test.AssertNotError(t, err, "failed to load written public key")
+	test.AssertDeepEquals(t, pubKey, keyInfo.key)
+	pkcs11Config, err := os.ReadFile(path.Join(tmp, "existing key.pkcs11.json"))
+	test.AssertNotError(t, err, "failed to read written PKCS#11 config")
+	test.AssertEquals(t, string(pkcs11Config), `{"module": "module", "tokenLabel": "existing key", "pin": "1234"}`)
+
+	// No new key was generated.
+	token, ok := session.Module.(*softToken)
+	test.Assert(t, ok, "session isn't backed by a softToken")
+	test.AssertEquals(t, len(token.keys), 1)
+
+	err = pkcs11ConfigCeremony(configFor("missing key"))
+	test.AssertError(t, err, "pkcs11-config ceremony didn't fail for a missing key")
+	test.AssertContains(t, err.Error(), `failed to find key with label "missing key" in slot 2`)
+	_, err = os.Stat(path.Join(tmp, "missing key.pkcs11.json"))
+	test.Assert(t, os.IsNotExist(err), "PKCS#11 config was written for a missing key")
+}
+
 func setECGenerateFuncs(ctx *pkcs11helpers.MockCtx) {
 	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
@@ -99,7 +189,7 @@ func TestGenerateKeyEC(t *testing.T) {
 	keyInfo, err := generateKey(s, "", keyPath, keyGenConfig{
 		Type:       "ecdsa",
 		ECDSACurve: "P-256",
-	})
+	}, pkcs11.CKM_EC_KEY_PAIR_GEN)
 	test.AssertNotError(t, err, "Failed to generate ECDSA key")
 	diskKeyBytes, err := os.ReadFile(keyPath)
 	test.AssertNotError(t, err, "Failed to load key from disk")
@@ -139,7 +229,7 @@ func TestGenerateKeySlotHasSomethingWithLabel(t *testing.T) {
 	_, err := generateKey(s, label, keyPath, keyGenConfig{
 		Type:       "ecdsa",
 		ECDSACurve: "P-256",
-	})
+	}, pkcs11.CKM_EC_KEY_PAIR_GEN)
 	test.AssertError(t, err, "expected failure for a slot with an object already in it")
 	test.Assert(t, strings.HasPrefix(err.Error(), "expected no preexisting objects with label"), "wrong error")
 }
@@ -155,6 +245,125 @@ func TestGenerateKeySlotHasSomethingWithDifferentLabel(t *testing.T) {
 	_, err := generateKey(s, "someOtherLabel", keyPath, keyGenConfig{
 		Type:       "ecdsa",
 		ECDSACurve: "P-256",
-	})
+	}, pkcs11.CKM_EC_KEY_PAIR_GEN)
 	test.AssertNotError(t, err, "expected success even though there was an object with a different label")
 }
+
+func TestGenerateKeyAndRoot(t *testing.T) {
+	tmp := t.TempDir()
+
+	ctx := setupCtx()
+	setECGenerateFuncs(&ctx)
+	ctx.GenerateRandomFunc = realRand
+	// Before the key pair is generated no objects exist, afterwards the public
+	// and private keys are found by the signer.
+	generated := false
+	ctx.GenerateKeyPairFunc = func(pkcs11.SessionHandle, []*pkcs11.Mechanism, []*pkcs11.Attribute, []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error) {
+		generated = true
+		return 1, 2, nil
+	}
+	ctx.FindObjectsFunc = func(pkcs11.SessionHandle, int) ([]pkcs11.ObjectHandle, bool, error) {
+		if !generated {
+			return nil, false, nil
+		}
+		return []pkcs11.ObjectHandle{1}, false, nil
+	}
+	getAttributeValue := ctx.GetAttributeValueFunc
+	ctx.GetAttributeValueFunc = func(s pkcs11.SessionHandle, o pkcs11.ObjectHandle, attrs []*pkcs11.Attribute) ([]*pkcs11.Attribute, error) {
+		if len(attrs) == 1 && attrs[0].Type == pkcs11.CKA_ID {
+			return []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_ID, []byte{1})}, nil
+		}
+		return getAttributeValue(s, o, attrs)
+	}
+	s := &pkcs11helpers.Session{Module: &ctx, Session: 0}
+
+	var config keyAndRootConfig
+	config.PKCS11 = PKCS11KeyGenConfig{
+		Module:     "module",
+		StoreLabel: "root key",
+	}
+	config.Key = keyGenConfig{
+		Type:       "ecdsa",
+		ECDSACurve: "P-256",
+	}
+	config.Outputs.PublicKeyPath = path.Join(tmp, "root.pubkey.pem")
+	config.Outputs.PKCS11ConfigPath = path.Join(tmp, "root.json")
+	config.Outputs.CertificatePath = path.Join(tmp, "root.cert.pem")
+	config.CertProfile = certProfile{
+		SignatureAlgorithm: "ECDSAWithSHA256",
+		CommonName:         "root",
+		Organization:       "organization",
+		Country:            "US",
+		NotBefore:          "2020-01-01 00:00:00",
+		NotAfter:           "2040-01-01 00:00:00",
+		KeyUsages:          []string{"Cert Sign", "CRL Sign"},
+	}
+	config.SkipLints = []string{"n_ca_digital_signature_not_set"}
+	test.AssertNotError(t, config.validate(), "validate failed")
+
+	cert, err := generateKeyAndRoot(s, config, certUniqueIDs{}, nil)
+	test.AssertNotError(t, err, "generateKeyAndRoot failed")
+	test.AssertNotError(t, cert.CheckSignatureFrom(cert), "root doesn't verify with its own key")
+
+	// The root certifies the key written to the public key path.
+	diskKeyBytes, err := os.ReadFile(config.Outputs.PublicKeyPath)
+	test.AssertNotError(t, err, "Failed to load key from disk")
+	block, _ := pem.Decode(diskKeyBytes)
+	test.AssertByteEquals(t, cert.RawSubjectPublicKeyInfo, block.Bytes)
+	skid, err := generateSKID(block.Bytes)
+	test.AssertNotError(t, err, "generateSKID failed")
+	test.AssertByteEquals(t, cert.SubjectKeyId, skid)
+
+	diskCertBytes, err := os.ReadFile(config.Outputs.CertificatePath)
+	test.AssertNotError(t, err, "Failed to load certificate from disk")
+	block, _ = pem.Decode(diskCertBytes)
+	test.AssertByteEquals(t, block.Bytes, cert.Raw)
+
+	pkcs11Config, err := os.ReadFile(config.Outputs.PKCS11ConfigPath)
+	test.AssertNotError(t, err, "Failed to load PKCS#11 config from disk")
+	test.AssertEquals(t, string(pkcs11Config), `{"module": "module", "tokenLabel": "root key", "pin": ""}`)
+}
+
+func TestKeyCeremonyMultipleKeys(t *testing.T) {
+	tmp := t.TempDir()
+	token := &softToken{keys: make(map[pkcs11.ObjectHandle]*ecdsa.PrivateKey)}
+	sessions := 0
+	initializeSession = func(string, uint, uint, string) (*pkcs11helpers.Session, error) {
+		sessions++
+		return &pkcs11helpers.Session{Module: token}, nil
+	}
+	t.Cleanup(func() { initializeSession = pkcs11helpers.Initialize })
+
+	config := []byte(`ceremony-type: key
+pkcs11:
+    module: module
+    store-key-in-slot: 0
+key:
+    type: ecdsa
+    ecdsa-curve: P-256
+keys:
+    - store-key-with-label: key a
+      outputs:
+          public-key-path: ` + path.Join(tmp, "a.pubkey.pem") + `
+          pkcs11-config-path: ` + path.Join(tmp, "a.pkcs11.json") + `
+    - store-key-with-label: key b
+      outputs:
+          public-key-path: ` + path.Join(tmp, "b.pubkey.pem") + `
+`)
+	err := keyCeremony(config, false, "")
+	test.AssertNotError(t, err, "key ceremony failed")
+	test.AssertEquals(t, sessions, 1)
+	test.AssertEquals(t, len(token.keys), 2)
+
+	pubKeyA, _, err := loadPubKey(path.Join(tmp, "a.pubkey.pem"))
+	test.AssertNotError(t, err, "failed to load first public key")
+	pubKeyB, _, err := loadPubKey(path.Join(tmp, "b.pubkey.pem"))
+	test.AssertNotError(t, err, "failed to load second public key")
+	test.Assert(t, !pubKeyA.(*ecdsa.PublicKey).Equal(pubKeyB), "both entries wrote the same key")
+
+	pkcs11Config, err := os.ReadFile(path.Join(tmp, "a.pkcs11.json"))
+	test.AssertNotError(t, err, "failed to read PKCS#11 config")
+	test.AssertContains(t, string(pkcs11Config), `"tokenLabel": "key a"`)
+	_, err = os.Stat(path.Join(tmp, "b.pkcs11.json"))
+	test.Assert(t, os.IsNotExist(err), "PKCS#11 config was written for an entry without one")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter"
+)
+
+// lintCertificate runs every lint, except those named in skipLints, against
+// the PEM certificate at certPath and writes the name, source, and status of
+// each lint to w, one lint per line. Unlike issuance, which fails on any lint
+// result worse than pass, it only returns an error if a lint which wasn't
+// skipped returned an error or fatal result.
+func lintCertificate(w io.Writer, certPath string, skipLints []string) error {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return fmt.Errorf("no certificate in PEM file %s", certPath)
+	}
+	report, err := linter.ReportIssued(block.Bytes, skipLints)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, entry := range report {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", entry.Name, entry.Source, entry.Status)
+		if entry.Status == lint.Error.String() || entry.Status == lint.Fatal.String() {
+			failed = append(failed, entry.Name)
+		}
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", linter.ErrLinting, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// lintMain implements the lint subcommand, which lints an existing certificate
+// rather than running a ceremony.
+func lintMain(args []string) error {
+	lintFlags := flag.NewFlagSet("lint", flag.ExitOnError)
+	certPath := lintFlags.String("cert", "", "Path to the PEM certificate to lint")
+	skipLints := lintFlags.String("skip-lints", "", "Comma separated list of lints to skip")
+	_ = lintFlags.Parse(args)
+
+	if *certPath == "" {
+		return configErrorf("--cert is required")
+	}
+	var skip []string
+	if *skipLints != "" {
+		skip = strings.Split(*skipLints, ",")
+	}
+	return lintCertificate(os.Stdout, *certPath, skip)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/letsencrypt/boulder/linter"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestLintCertificate(t *testing.T) {
+	var out bytes.Buffer
+	err := lintCertificate(&out, "../../test/hierarchy/int-e1.cert.pem", nil)
+	test.AssertNotError(t, err, "lintCertificate failed for a compliant intermediate")
+	test.AssertContains(t, out.String(), "e_cert_has_unknown_critical_extension")
+
+	// Lint results worse than pass, but better than error, don't fail.
+	out.Reset()
+	err = lintCertificate(&out, "../../test/hierarchy/root-x1.cert.pem", nil)
+	test.AssertNotError(t, err, "lintCertificate failed for a notice")
+	test.AssertContains(t, out.String(), "info")
+
+	// The test hierarchy's subscriber certificates have no AIA or policies.
+	out.Reset()
+	err = lintCertificate(&out, "../../test/hierarchy/ee-e1.cert.pem", nil)
+	test.AssertError(t, err, "lintCertificate didn't fail for a noncompliant subscriber certificate")
+	test.AssertErrorIs(t, err, linter.ErrLinting)
+	test.AssertContains(t, err.Error(), "e_sub_cert_aia_missing")
+	test.AssertContains(t, out.String(), "e_sub_cert_aia_missing")
+
+	out.Reset()
+	err = lintCertificate(&out, "../../test/hierarchy/ee-e1.cert.pem", []string{
+		"e_dnsname_not_valid_tld",
+		"e_sub_cert_aia_does_not_contain_ocsp_url",
+		"e_sub_cert_aia_missing",
+		"e_sub_cert_cert_policy_empty",
+		"e_sub_cert_certificate_policies_missing",
+		"e_tls_server_cert_valid_time_longer_than_398_days",
+	})
+	test.AssertNotError(t, err, "lintCertificate failed with the failing lints skipped")
+	test.AssertContains(t, out.String(), linter.LintStatusSkipped)
+
+	err = lintCertificate(&out, "../../test/hierarchy/ee-e1.cert.pem", []string{"e_not_a_lint"})
+	test.AssertError(t, err, "lintCertificate didn't fail for an unknown skipped lint")
+
+	err = lintCertificate(&out, "../../test/hierarchy/ee-e1.key.pem", nil)
+	test.AssertError(t, err, "lintCertificate didn't fail for a key")
+}
@@ -6,29 +6,48 @@ import (
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
-	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math/big"
 	"os"
+	"path/filepath"
 	"slices"
+	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/miekg/pkcs11"
+	"github.com/zmap/zlint/v3/lint"
 	"golang.org/x/crypto/ocsp"
 	"gopkg.in/yaml.v3"
 
 	"github.com/letsencrypt/boulder/goodkey"
 	"github.com/letsencrypt/boulder/linter"
+	"github.com/letsencrypt/boulder/linter/lints"
 	"github.com/letsencrypt/boulder/pkcs11helpers"
 	"github.com/letsencrypt/boulder/strictyaml"
 )
 
 var kp goodkey.KeyPolicy
 
+// initializeSession opens a logged in session with the token in a slot of a
+// PKCS#11 module. It is a variable so that tests can substitute a software
+// token.
+var initializeSession = pkcs11helpers.Initialize
+
+// enableLints are the lints, disabled by default, which are named by the
+// --enable-lints flag and run against every certificate the ceremony lints.
+var enableLints []string
+
 func init() {
 	var err error
 	kp, err = goodkey.NewKeyPolicy(&goodkey.Config{FermatRounds: 100}, nil)
@@ -41,29 +60,133 @@ type lintCert *x509.Certificate
 
 // issueLintCertAndPerformLinting issues a linting certificate from a given
 // template certificate signed by a given issuer and returns a *lintCert or an
-// error. The lint certificate is linted prior to being returned. The public key
-// from the just issued lint certificate is checked by the GoodKey package.
-func issueLintCertAndPerformLinting(tbs, issuer *x509.Certificate, subjectPubKey crypto.PublicKey, signer crypto.Signer, skipLints []string) (lintCert, error) {
-	bytes, err := linter.Check(tbs, subjectPubKey, issuer, signer, skipLints)
+// error. Any uniqueIDs are set in the lint certificate, as they will be in the
+// issued one. The lint certificate is linted prior to being returned. The
+// public key from the just issued lint certificate is checked by the GoodKey
+// package. If lintReportPath is non-empty a JSON report of every lint which was
+// considered is written to it, even if linting fails.
+func issueLintCertAndPerformLinting(tbs, issuer *x509.Certificate, subjectPubKey crypto.PublicKey, signer crypto.Signer, uniqueIDs certUniqueIDs, skipLints []string, lintReportPath string) (lintCert, error) {
+	lc, _, err := issueLintCertWithReport(tbs, issuer, subjectPubKey, signer, uniqueIDs, skipLints, lintReportPath)
+	return lc, err
+}
+
+// issueLintCertWithReport is like issueLintCertAndPerformLinting, but also
+// returns the report of every lint which was considered.
+func issueLintCertWithReport(tbs, issuer *x509.Certificate, subjectPubKey crypto.PublicKey, signer crypto.Signer, uniqueIDs certUniqueIDs, skipLints []string, lintReportPath string) (lintCert, []linter.LintReportEntry, error) {
+	return issueModifiedLintCert(tbs, issuer, subjectPubKey, signer, certModifier(tbs, uniqueIDs), skipLints, lintReportPath)
+}
+
+// issueModifiedLintCert is like issueLintCertWithReport, but the linting
+// certificate is altered by modify, which may be nil, rather than by the
+// modifier for a set of unique IDs.
+func issueModifiedLintCert(tbs, issuer *x509.Certificate, subjectPubKey crypto.PublicKey, signer crypto.Signer, modify linter.Modifier, skipLints []string, lintReportPath string) (lintCert, []linter.LintReportEntry, error) {
+	bytes, report, err := linter.CheckModifiedWithReport(tbs, subjectPubKey, issuer, signer, skipLints, enableLints, lintExcludeSources(lintProfile(tbs, issuer), hasQCStatements(tbs)), modify)
+	if lintReportPath != "" && report != nil {
+		reportErr := writeLintReport(lintReportPath, report)
+		if reportErr != nil {
+			return nil, nil, reportErr
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("certificate failed pre-issuance lint: %w", err)
+		return nil, nil, fmt.Errorf("certificate failed pre-issuance lint: %w", err)
 	}
 	lc, err := x509.ParseCertificate(bytes)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	err = kp.GoodKey(context.Background(), lc.PublicKey)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	return lc, report, nil
+}
+
+// writeLintReport writes report to filename as indented JSON.
+func writeLintReport(filename string, report []linter.LintReportEntry) error {
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal lint report: %w", err)
+	}
+	err = writeFile(filename, append(reportJSON, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write lint report to %q: %w", filename, err)
+	}
+	log.Printf("Lint report written to %q\n", filename)
+	return nil
+}
+
+// lintProfileExcludes maps each type accepted by --explain-lints, as returned
+// by lintProfile, to the lint sources which aren't run against certificates of
+// that type.
+var lintProfileExcludes = map[string][]lint.LintSource{
+	"root":         {lints.LetsEncryptCPSIntermediate, lints.LetsEncryptCPSSubscriber},
+	"intermediate": {lints.LetsEncryptCPSRoot, lints.LetsEncryptCPSSubscriber},
+	"subscriber":   {lints.LetsEncryptCPSRoot, lints.LetsEncryptCPSIntermediate},
+}
+
+// lintExcludeSources returns the lint sources which aren't run against
+// certificates of the given type: those in lintProfileExcludes and the linter's
+// defaults. The default exclusion of the ETSI EN 319 412-5 lints is dropped if
+// the certificate has a qcStatements extension, which they check.
+func lintExcludeSources(certType string, qcStatements bool) []lint.LintSource {
+	exclude := slices.Clone(lintProfileExcludes[certType])
+	for _, source := range linter.DefaultExcludeSources {
+		if source == lint.EtsiEsi && qcStatements {
+			continue
+		}
+		exclude = append(exclude, source)
+	}
+	return exclude
+}
+
+// hasQCStatements returns true if tbs has a qcStatements extension, which the
+// ceremony only ever sets as an extra extension.
+func hasQCStatements(tbs *x509.Certificate) bool {
+	for _, ext := range tbs.ExtraExtensions {
+		if ext.Id.Equal(oidQCStatements) {
+			return true
+		}
+	}
+	return false
+}
+
+// lintProfile returns the type of certificate, in lintProfileExcludes, which
+// issuing tbs from issuer produces.
+func lintProfile(tbs, issuer *x509.Certificate) string {
+	switch {
+	case !tbs.IsCA:
+		return "subscriber"
+	case tbs.Subject.String() == issuer.Subject.String():
+		return "root"
+	default:
+		return "intermediate"
 	}
+}
 
-	return lc, nil
+// explainLints writes the name, source, and description of each lint which is
+// run against certificates of the given type to w, one lint per line.
+func explainLints(w io.Writer, certType string) error {
+	_, ok := lintProfileExcludes[certType]
+	if !ok {
+		return fmt.Errorf("unknown lint profile %q, must be one of root, intermediate, or subscriber", certType)
+	}
+	descriptions, err := linter.DescribeLints(enableLints, lintExcludeSources(certType, false))
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, d := range descriptions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Name, d.Source, d.Description)
+	}
+	return tw.Flush()
 }
 
 type keyGenConfig struct {
-	Type         string `yaml:"type"`
-	RSAModLength uint   `yaml:"rsa-mod-length"`
-	ECDSACurve   string `yaml:"ecdsa-curve"`
+	Type          string `yaml:"type"`
+	RSAModLength  uint   `yaml:"rsa-mod-length"`
+	ECDSACurve    string `yaml:"ecdsa-curve"`
+	MLDSAParamSet string `yaml:"mldsa-param-set"`
 }
 
 var allowedCurves = map[string]bool{
@@ -77,8 +200,8 @@ func (kgc keyGenConfig) validate() error {
 	if kgc.Type == "" {
 		return errors.New("key.type is required")
 	}
-	if kgc.Type != "rsa" && kgc.Type != "ecdsa" {
-		return errors.New("key.type can only be 'rsa' or 'ecdsa'")
+	if kgc.Type != "rsa" && kgc.Type != "ecdsa" && kgc.Type != "mldsa" {
+		return errors.New("key.type can only be 'rsa', 'ecdsa', or 'mldsa'")
 	}
 	if kgc.Type == "rsa" && (kgc.RSAModLength != 2048 && kgc.RSAModLength != 4096) {
 		return errors.New("key.rsa-mod-length can only be 2048 or 4096")
@@ -86,21 +209,81 @@ func (kgc keyGenConfig) validate() error {
 	if kgc.Type == "rsa" && kgc.ECDSACurve != "" {
 		return errors.New("if key.type = 'rsa' then key.ecdsa-curve is not used")
 	}
+	if kgc.Type == "rsa" && kgc.MLDSAParamSet != "" {
+		return errors.New("if key.type = 'rsa' then key.mldsa-param-set is not used")
+	}
 	if kgc.Type == "ecdsa" && !allowedCurves[kgc.ECDSACurve] {
 		return errors.New("key.ecdsa-curve can only be 'P-224', 'P-256', 'P-384', or 'P-521'")
 	}
 	if kgc.Type == "ecdsa" && kgc.RSAModLength != 0 {
 		return errors.New("if key.type = 'ecdsa' then key.rsa-mod-length is not used")
 	}
+	if kgc.Type == "ecdsa" && kgc.MLDSAParamSet != "" {
+		return errors.New("if key.type = 'ecdsa' then key.mldsa-param-set is not used")
+	}
+	if _, ok := mldsaParameterSets[kgc.MLDSAParamSet]; kgc.Type == "mldsa" && !ok {
+		return errors.New("key.mldsa-param-set can only be '44', '65', or '87'")
+	}
+	if kgc.Type == "mldsa" && kgc.RSAModLength != 0 {
+		return errors.New("if key.type = 'mldsa' then key.rsa-mod-length is not used")
+	}
+	if kgc.Type == "mldsa" && kgc.ECDSACurve != "" {
+		return errors.New("if key.type = 'mldsa' then key.ecdsa-curve is not used")
+	}
+
+	return nil
+}
+
+// pkcs11UserTypes maps the values of the pkcs11.user-type config field to the
+// PKCS#11 user type which the session is logged in as. The normal user is the
+// default.
+var pkcs11UserTypes = map[string]uint{
+	"":     pkcs11.CKU_USER,
+	"user": pkcs11.CKU_USER,
+	"so":   pkcs11.CKU_SO,
+}
 
+// checkUserType returns an error if userType isn't a valid pkcs11.user-type.
+func checkUserType(userType string) error {
+	if _, ok := pkcs11UserTypes[userType]; !ok {
+		return fmt.Errorf("pkcs11.user-type is %q, which is not one of \"user\" or \"so\"", userType)
+	}
 	return nil
 }
 
+// keygenMechanism is a PKCS#11 key pair generation mechanism, and the key.type
+// of the key pairs which it generates.
+type keygenMechanism struct {
+	keyType   string
+	mechanism uint
+}
+
+// keygenMechanisms maps the values of the pkcs11.keygen-mechanism config field
+// to the mechanism which generates the key pair.
+var keygenMechanisms = map[string]keygenMechanism{
+	"CKM_RSA_PKCS_KEY_PAIR_GEN":  {"rsa", pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN},
+	"CKM_RSA_X9_31_KEY_PAIR_GEN": {"rsa", pkcs11.CKM_RSA_X9_31_KEY_PAIR_GEN},
+	"CKM_EC_KEY_PAIR_GEN":        {"ecdsa", pkcs11.CKM_EC_KEY_PAIR_GEN},
+	"CKM_ML_DSA_KEY_PAIR_GEN":    {"mldsa", ckmMLDSAKeyPairGen},
+}
+
+// defaultKeygenMechanisms are the mechanisms used to generate each key.type
+// when pkcs11.keygen-mechanism isn't set.
+var defaultKeygenMechanisms = map[string]uint{
+	"rsa":   pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN,
+	"ecdsa": pkcs11.CKM_EC_KEY_PAIR_GEN,
+	"mldsa": ckmMLDSAKeyPairGen,
+}
+
 type PKCS11KeyGenConfig struct {
-	Module     string `yaml:"module"`
-	PIN        string `yaml:"pin"`
-	StoreSlot  uint   `yaml:"store-key-in-slot"`
-	StoreLabel string `yaml:"store-key-with-label"`
+	Module          string           `yaml:"module"`
+	PIN             string           `yaml:"pin"`
+	UserType        string           `yaml:"user-type"`
+	StoreSlot       uint             `yaml:"store-key-in-slot"`
+	StoreLabel      string           `yaml:"store-key-with-label"`
+	KeygenMechanism string           `yaml:"keygen-mechanism"`
+	InitToken       *initTokenConfig `yaml:"init-token"`
+	SignRetry       *signRetryConfig `yaml:"sign-retry"`
 }
 
 func (pkgc PKCS11KeyGenConfig) validate() error {
@@ -113,9 +296,74 @@ func (pkgc PKCS11KeyGenConfig) validate() error {
 	// key-slot is allowed to be 0 (which is a valid slot).
 	// PIN is allowed to be "", which will commonly happen when
 	// PIN entry is done via PED.
+	err := checkUserType(pkgc.UserType)
+	if err != nil {
+		return err
+	}
+	if _, ok := keygenMechanisms[pkgc.KeygenMechanism]; pkgc.KeygenMechanism != "" && !ok {
+		return fmt.Errorf("pkcs11.keygen-mechanism is %q, which is not one of \"CKM_RSA_PKCS_KEY_PAIR_GEN\", \"CKM_RSA_X9_31_KEY_PAIR_GEN\", \"CKM_EC_KEY_PAIR_GEN\", or \"CKM_ML_DSA_KEY_PAIR_GEN\"", pkgc.KeygenMechanism)
+	}
+	if pkgc.InitToken != nil {
+		// Initializing a token destroys everything on it, so the config must
+		// explicitly say that it is acting as the security officer.
+		if pkgc.UserType != "so" {
+			return errors.New("pkcs11.user-type must be \"so\" when pkcs11.init-token is set")
+		}
+		if pkgc.PIN == "" {
+			return errors.New("pkcs11.pin is required when pkcs11.init-token is set")
+		}
+		err := pkgc.InitToken.validate()
+		if err != nil {
+			return err
+		}
+	}
+	return pkgc.SignRetry.validate()
+}
+
+// checkKeygenMechanism returns an error if pkcs11.keygen-mechanism is set to a
+// mechanism which doesn't generate key pairs of keyType.
+func (pkgc PKCS11KeyGenConfig) checkKeygenMechanism(keyType string) error {
+	if pkgc.KeygenMechanism == "" {
+		return nil
+	}
+	if keygenMechanisms[pkgc.KeygenMechanism].keyType != keyType {
+		return fmt.Errorf("pkcs11.keygen-mechanism %s can't be used if key.type = '%s'", pkgc.KeygenMechanism, keyType)
+	}
+	return nil
+}
+
+// keygenMechanism returns the PKCS#11 mechanism used to generate key pairs of
+// keyType, which is pkcs11.keygen-mechanism if it is set.
+func (pkgc PKCS11KeyGenConfig) keygenMechanism(keyType string) uint {
+	if pkgc.KeygenMechanism == "" {
+		return defaultKeygenMechanisms[keyType]
+	}
+	return keygenMechanisms[pkgc.KeygenMechanism].mechanism
+}
+
+// setSOPIN sets the security officer PIN read from --so-pin-fd, which is only
+// used to initialize the token, so pkcs11.init-token must be set if it is.
+func (pkgc *PKCS11KeyGenConfig) setSOPIN(soPIN string) error {
+	if soPIN == "" {
+		return nil
+	}
+	if pkgc.InitToken == nil {
+		return errors.New("--so-pin-fd can only be used when pkcs11.init-token is set")
+	}
+	pkgc.InitToken.soPIN = soPIN
 	return nil
 }
 
+// sessionUserType returns the PKCS#11 user type to log in to the key's token
+// as. Once a token has been initialized by the security officer, the key is
+// generated by the normal user whose PIN initialization set.
+func (pkgc PKCS11KeyGenConfig) sessionUserType() uint {
+	if pkgc.InitToken != nil {
+		return pkcs11.CKU_USER
+	}
+	return pkcs11UserTypes[pkgc.UserType]
+}
+
 // checkOutputFile returns an error if the filename is empty,
 // or if a file already exists with that filename.
 func checkOutputFile(filename, fieldname string) error {
@@ -137,9 +385,17 @@ type rootConfig struct {
 	Outputs      struct {
 		PublicKeyPath   string `yaml:"public-key-path"`
 		CertificatePath string `yaml:"certificate-path"`
+		LintReportPath  string `yaml:"lint-report-path"`
+		ReportPath      string `yaml:"report-path"`
+		CheckpointPath  string `yaml:"checkpoint-path"`
 	} `yaml:"outputs"`
 	CertProfile certProfile `yaml:"certificate-profile"`
 	SkipLints   []string    `yaml:"skip-lints"`
+
+	// checkpoint records the progress of the ceremony in
+	// outputs.checkpoint-path. It is set by loadCheckpoint, and is nil if
+	// outputs.checkpoint-path isn't.
+	checkpoint *checkpoint
 }
 
 func (rc rootConfig) validate() error {
@@ -153,34 +409,81 @@ func (rc rootConfig) validate() error {
 	if err != nil {
 		return err
 	}
+	if rc.Key.Type == "mldsa" {
+		return errors.New("key.type 'mldsa' is only supported by the key ceremony")
+	}
+	err = rc.PKCS11.checkKeygenMechanism(rc.Key.Type)
+	if err != nil {
+		return err
+	}
 
-	// Output fields
-	err = checkOutputFile(rc.Outputs.PublicKeyPath, "public-key-path")
+	// Output fields. Outputs written by steps which the checkpoint records as
+	// completed are expected to exist.
+	err = rc.checkpoint.checkOutputFile(rc.Outputs.PublicKeyPath, "public-key-path")
 	if err != nil {
 		return err
 	}
-	err = checkOutputFile(rc.Outputs.CertificatePath, "certificate-path")
+	err = rc.checkpoint.checkOutputFile(rc.Outputs.CertificatePath, "certificate-path")
 	if err != nil {
 		return err
 	}
+	// LintReportPath is optional.
+	if rc.Outputs.LintReportPath != "" {
+		err = rc.checkpoint.checkOutputFile(rc.Outputs.LintReportPath, "lint-report-path")
+		if err != nil {
+			return err
+		}
+	}
+	// ReportPath is optional.
+	if rc.Outputs.ReportPath != "" {
+		err = rc.checkpoint.checkOutputFile(rc.Outputs.ReportPath, "report-path")
+		if err != nil {
+			return err
+		}
+	}
+	// CheckpointPath is optional, and only exists if it is being resumed.
+	if rc.Outputs.CheckpointPath != "" && !rc.checkpoint.resuming() {
+		err = checkOutputFile(rc.Outputs.CheckpointPath, "checkpoint-path")
+		if err != nil {
+			return err
+		}
+	}
 
 	// Certificate profile
 	err = rc.CertProfile.verifyProfile(rootCert)
 	if err != nil {
 		return err
 	}
+	if rc.CertProfile.OmitSKI && !slices.Contains(rc.SkipLints, "e_ext_subject_key_identifier_missing_ca") {
+		return errors.New("omit-ski requires skip-lints to contain \"e_ext_subject_key_identifier_missing_ca\"")
+	}
 
 	return nil
 }
 
 type PKCS11SigningConfig struct {
-	Module       string `yaml:"module"`
-	PIN          string `yaml:"pin"`
-	SigningSlot  uint   `yaml:"signing-key-slot"`
-	SigningLabel string `yaml:"signing-key-label"`
+	Module                string           `yaml:"module"`
+	PIN                   string           `yaml:"pin"`
+	UserType              string           `yaml:"user-type"`
+	SigningSlot           uint             `yaml:"signing-key-slot"`
+	SigningLabel          string           `yaml:"signing-key-label"`
+	ExpectedPublicKeyPath string           `yaml:"expected-public-key-path"`
+	SignRetry             *signRetryConfig `yaml:"sign-retry"`
+
+	// softwareKeyPath is set from --software-key rather than the config. If
+	// set, the key it contains is used for signing instead of a PKCS#11 token.
+	softwareKeyPath string
 }
 
 func (psc PKCS11SigningConfig) validate() error {
+	if psc.softwareKeyPath != "" {
+		pkcs11 := psc
+		pkcs11.softwareKeyPath = ""
+		if pkcs11 != (PKCS11SigningConfig{}) {
+			return errors.New("pkcs11 cannot be set when --software-key is used")
+		}
+		return nil
+	}
 	if psc.Module == "" {
 		return errors.New("pkcs11.module is required")
 	}
@@ -188,6 +491,27 @@ func (psc PKCS11SigningConfig) validate() error {
 		return errors.New("pkcs11.signing-key-label is required")
 	}
 	// key-slot is allowed to be 0 (which is a valid slot).
+	// expected-public-key-path is optional.
+	err := checkUserType(psc.UserType)
+	if err != nil {
+		return err
+	}
+	return psc.SignRetry.validate()
+}
+
+// checkPublicKeySource returns an error unless exactly one of
+// inputs.public-key-path and inputs.public-key-label is set. A label refers to
+// a public key on the signing token, so it can't be used with --software-key.
+func checkPublicKeySource(path, label string, psc PKCS11SigningConfig) error {
+	if path == "" && label == "" {
+		return errors.New("one of inputs.public-key-path or inputs.public-key-label is required")
+	}
+	if path != "" && label != "" {
+		return errors.New("inputs.public-key-path and inputs.public-key-label cannot both be set")
+	}
+	if label != "" && psc.softwareKeyPath != "" {
+		return errors.New("inputs.public-key-label cannot be used with --software-key")
+	}
 	return nil
 }
 
@@ -195,14 +519,23 @@ type intermediateConfig struct {
 	CeremonyType string              `yaml:"ceremony-type"`
 	PKCS11       PKCS11SigningConfig `yaml:"pkcs11"`
 	Inputs       struct {
-		PublicKeyPath         string `yaml:"public-key-path"`
-		IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+		PublicKeyPath              string `yaml:"public-key-path"`
+		PublicKeyLabel             string `yaml:"public-key-label"`
+		IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+		TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+		IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 	} `yaml:"inputs"`
 	Outputs struct {
 		CertificatePath string `yaml:"certificate-path"`
+		LintReportPath  string `yaml:"lint-report-path"`
+		ReportPath      string `yaml:"report-path"`
 	} `yaml:"outputs"`
 	CertProfile certProfile `yaml:"certificate-profile"`
 	SkipLints   []string    `yaml:"skip-lints"`
+
+	// csr is set by --from-csr, in which case the subject public key and
+	// subject alternative names are taken from it.
+	csr *x509.CertificateRequest
 }
 
 func (ic intermediateConfig) validate(ct certType) error {
@@ -212,24 +545,52 @@ func (ic intermediateConfig) validate(ct certType) error {
 	}
 
 	// Input fields
-	if ic.Inputs.PublicKeyPath == "" {
-		return errors.New("inputs.public-key-path is required")
+	if ic.csr != nil {
+		if ic.Inputs.PublicKeyPath != "" || ic.Inputs.PublicKeyLabel != "" {
+			return errors.New("inputs.public-key-path and inputs.public-key-label cannot be set when --from-csr is used")
+		}
+	} else {
+		err = checkPublicKeySource(ic.Inputs.PublicKeyPath, ic.Inputs.PublicKeyLabel, ic.PKCS11)
+		if err != nil {
+			return err
+		}
 	}
 	if ic.Inputs.IssuerCertificatePath == "" {
 		return errors.New("inputs.issuer-certificate is required")
 	}
+	// TrustAnchorCertificatePath may be omitted, unless IssuerBundlePath is set.
+	if ic.Inputs.IssuerBundlePath != "" && ic.Inputs.TrustAnchorCertificatePath == "" {
+		return errors.New("inputs.issuer-bundle-path requires inputs.trust-anchor-certificate-path")
+	}
 
 	// Output fields
 	err = checkOutputFile(ic.Outputs.CertificatePath, "certificate-path")
 	if err != nil {
 		return err
 	}
+	// LintReportPath is optional.
+	if ic.Outputs.LintReportPath != "" {
+		err = checkOutputFile(ic.Outputs.LintReportPath, "lint-report-path")
+		if err != nil {
+			return err
+		}
+	}
+	// ReportPath is optional.
+	if ic.Outputs.ReportPath != "" {
+		err = checkOutputFile(ic.Outputs.ReportPath, "report-path")
+		if err != nil {
+			return err
+		}
+	}
 
 	// Certificate profile
 	err = ic.CertProfile.verifyProfile(ct)
 	if err != nil {
 		return err
 	}
+	if ic.CertProfile.AKIForm == akiFormIssuerSerial && !slices.Contains(ic.SkipLints, "e_ext_authority_key_identifier_no_key_identifier") {
+		return errors.New("aki-form of \"issuer-serial\" requires skip-lints to contain \"e_ext_authority_key_identifier_no_key_identifier\"")
+	}
 
 	return nil
 }
@@ -239,11 +600,16 @@ type crossCertConfig struct {
 	PKCS11       PKCS11SigningConfig `yaml:"pkcs11"`
 	Inputs       struct {
 		PublicKeyPath              string `yaml:"public-key-path"`
+		PublicKeyLabel             string `yaml:"public-key-label"`
 		IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+		TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+		IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 		CertificateToCrossSignPath string `yaml:"certificate-to-cross-sign-path"`
 	} `yaml:"inputs"`
 	Outputs struct {
 		CertificatePath string `yaml:"certificate-path"`
+		LintReportPath  string `yaml:"lint-report-path"`
+		ReportPath      string `yaml:"report-path"`
 	} `yaml:"outputs"`
 	CertProfile certProfile `yaml:"certificate-profile"`
 	SkipLints   []string    `yaml:"skip-lints"`
@@ -254,12 +620,17 @@ func (csc crossCertConfig) validate() error {
 	if err != nil {
 		return err
 	}
-	if csc.Inputs.PublicKeyPath == "" {
-		return errors.New("inputs.public-key-path is required")
+	err = checkPublicKeySource(csc.Inputs.PublicKeyPath, csc.Inputs.PublicKeyLabel, csc.PKCS11)
+	if err != nil {
+		return err
 	}
 	if csc.Inputs.IssuerCertificatePath == "" {
 		return errors.New("inputs.issuer-certificate is required")
 	}
+	// TrustAnchorCertificatePath may be omitted, unless IssuerBundlePath is set.
+	if csc.Inputs.IssuerBundlePath != "" && csc.Inputs.TrustAnchorCertificatePath == "" {
+		return errors.New("inputs.issuer-bundle-path requires inputs.trust-anchor-certificate-path")
+	}
 	if csc.Inputs.CertificateToCrossSignPath == "" {
 		return errors.New("inputs.certificate-to-cross-sign-path is required")
 	}
@@ -267,10 +638,27 @@ func (csc crossCertConfig) validate() error {
 	if err != nil {
 		return err
 	}
+	// LintReportPath is optional.
+	if csc.Outputs.LintReportPath != "" {
+		err = checkOutputFile(csc.Outputs.LintReportPath, "lint-report-path")
+		if err != nil {
+			return err
+		}
+	}
+	// ReportPath is optional.
+	if csc.Outputs.ReportPath != "" {
+		err = checkOutputFile(csc.Outputs.ReportPath, "report-path")
+		if err != nil {
+			return err
+		}
+	}
 	err = csc.CertProfile.verifyProfile(crossCert)
 	if err != nil {
 		return err
 	}
+	if csc.CertProfile.AKIForm == akiFormIssuerSerial && !slices.Contains(csc.SkipLints, "e_ext_authority_key_identifier_no_key_identifier") {
+		return errors.New("aki-form of \"issuer-serial\" requires skip-lints to contain \"e_ext_authority_key_identifier_no_key_identifier\"")
+	}
 
 	return nil
 }
@@ -282,7 +670,8 @@ type csrConfig struct {
 		PublicKeyPath string `yaml:"public-key-path"`
 	} `yaml:"inputs"`
 	Outputs struct {
-		CSRPath string `yaml:"csr-path"`
+		CSRPath    string `yaml:"csr-path"`
+		CSRDERPath string `yaml:"csr-der-path"`
 	} `yaml:"outputs"`
 	CertProfile certProfile `yaml:"certificate-profile"`
 }
@@ -303,6 +692,13 @@ func (cc csrConfig) validate() error {
 	if err != nil {
 		return err
 	}
+	// CSRDERPath is optional.
+	if cc.Outputs.CSRDERPath != "" {
+		err = checkOutputFile(cc.Outputs.CSRDERPath, "csr-der-path")
+		if err != nil {
+			return err
+		}
+	}
 
 	// Certificate profile
 	err = cc.CertProfile.verifyProfile(requestCert)
@@ -313,17 +709,90 @@ func (cc csrConfig) validate() error {
 	return nil
 }
 
+// keyOutputsConfig contains the paths which a key ceremony writes for a
+// generated key.
+type keyOutputsConfig struct {
+	PublicKeyPath      string `yaml:"public-key-path"`
+	PKCS1PublicKeyPath string `yaml:"pkcs1-public-key-path"`
+	PKCS11ConfigPath   string `yaml:"pkcs11-config-path"`
+}
+
+// keyEntryConfig is one of several keys generated by a key ceremony, as one
+// of the keys of a keyConfig.
+type keyEntryConfig struct {
+	StoreLabel string           `yaml:"store-key-with-label"`
+	Outputs    keyOutputsConfig `yaml:"outputs"`
+}
+
 type keyConfig struct {
 	CeremonyType string             `yaml:"ceremony-type"`
 	PKCS11       PKCS11KeyGenConfig `yaml:"pkcs11"`
 	Key          keyGenConfig       `yaml:"key"`
-	Outputs      struct {
-		PublicKeyPath    string `yaml:"public-key-path"`
-		PKCS11ConfigPath string `yaml:"pkcs11-config-path"`
-	} `yaml:"outputs"`
+	Outputs      keyOutputsConfig   `yaml:"outputs"`
+	// Keys may be set instead of pkcs11.store-key-with-label and Outputs to
+	// generate several keys, each described by Key, in one session.
+	Keys []keyEntryConfig `yaml:"keys"`
+}
+
+// keys returns a config for each key which the ceremony generates: one for
+// each of Keys, or if it isn't set, kc itself.
+func (kc keyConfig) keys() []keyConfig {
+	if len(kc.Keys) == 0 {
+		return []keyConfig{kc}
+	}
+	var keys []keyConfig
+	for _, entry := range kc.Keys {
+		key := kc
+		key.PKCS11.StoreLabel = entry.StoreLabel
+		key.Outputs = entry.Outputs
+		key.Keys = nil
+		keys = append(keys, key)
+	}
+	return keys
 }
 
 func (kc keyConfig) validate() error {
+	if len(kc.Keys) == 0 {
+		return kc.validateKey()
+	}
+	if kc.PKCS11.StoreLabel != "" {
+		return errors.New("pkcs11.store-key-with-label cannot be set with keys, each entry has its own label")
+	}
+	if kc.Outputs != (keyOutputsConfig{}) {
+		return errors.New("outputs cannot be set with keys, each entry has its own outputs")
+	}
+	// Every key must have its own label and be written to its own files, so
+	// that one key can't be mistaken for or overwrite another.
+	labels := make(map[string]int)
+	paths := make(map[string]int)
+	for i, key := range kc.keys() {
+		err := key.validateKey()
+		if err != nil {
+			return fmt.Errorf("keys[%d]: %w", i, err)
+		}
+		if j, ok := labels[key.PKCS11.StoreLabel]; ok {
+			return fmt.Errorf("keys[%d]: store-key-with-label %q is already used by keys[%d]", i, key.PKCS11.StoreLabel, j)
+		}
+		labels[key.PKCS11.StoreLabel] = i
+		for _, output := range []struct{ field, path string }{
+			{"public-key-path", key.Outputs.PublicKeyPath},
+			{"pkcs1-public-key-path", key.Outputs.PKCS1PublicKeyPath},
+			{"pkcs11-config-path", key.Outputs.PKCS11ConfigPath},
+		} {
+			if output.path == "" {
+				continue
+			}
+			if j, ok := paths[output.path]; ok {
+				return fmt.Errorf("keys[%d]: outputs.%s %q is already written by keys[%d]", i, output.field, output.path, j)
+			}
+			paths[output.path] = i
+		}
+	}
+	return nil
+}
+
+// validateKey checks the config of a single key.
+func (kc keyConfig) validateKey() error {
 	err := kc.PKCS11.validate()
 	if err != nil {
 		return err
@@ -334,118 +803,465 @@ func (kc keyConfig) validate() error {
 	if err != nil {
 		return err
 	}
+	err = kc.PKCS11.checkKeygenMechanism(kc.Key.Type)
+	if err != nil {
+		return err
+	}
 
 	// Output fields
 	err = checkOutputFile(kc.Outputs.PublicKeyPath, "public-key-path")
 	if err != nil {
 		return err
 	}
+	// pkcs1-public-key-path is optional, and only meaningful for RSA keys.
+	if kc.Outputs.PKCS1PublicKeyPath != "" {
+		if kc.Key.Type != "rsa" {
+			return errors.New("outputs.pkcs1-public-key-path can only be set if key.type = 'rsa'")
+		}
+		err = checkOutputFile(kc.Outputs.PKCS1PublicKeyPath, "pkcs1-public-key-path")
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-type ocspRespConfig struct {
-	CeremonyType string              `yaml:"ceremony-type"`
-	PKCS11       PKCS11SigningConfig `yaml:"pkcs11"`
-	Inputs       struct {
-		CertificatePath                string `yaml:"certificate-path"`
-		IssuerCertificatePath          string `yaml:"issuer-certificate-path"`
-		DelegatedIssuerCertificatePath string `yaml:"delegated-issuer-certificate-path"`
-	} `yaml:"inputs"`
-	Outputs struct {
-		ResponsePath string `yaml:"response-path"`
-	} `yaml:"outputs"`
-	OCSPProfile struct {
-		ThisUpdate string `yaml:"this-update"`
-		NextUpdate string `yaml:"next-update"`
-		Status     string `yaml:"status"`
-	} `yaml:"ocsp-profile"`
+// wrappingKeyConfig describes an AES key generated on a token for wrapping
+// other keys, so that they can be backed up.
+type wrappingKeyConfig struct {
+	CeremonyType string             `yaml:"ceremony-type"`
+	PKCS11       PKCS11KeyGenConfig `yaml:"pkcs11"`
+	Key          struct {
+		AESKeyLength uint `yaml:"aes-key-length"`
+	} `yaml:"key"`
 }
 
-func (orc ocspRespConfig) validate() error {
-	err := orc.PKCS11.validate()
+func (wkc wrappingKeyConfig) validate() error {
+	err := wkc.PKCS11.validate()
 	if err != nil {
 		return err
 	}
-
-	// Input fields
-	if orc.Inputs.CertificatePath == "" {
-		return errors.New("inputs.certificate-path is required")
-	}
-	if orc.Inputs.IssuerCertificatePath == "" {
-		return errors.New("inputs.issuer-certificate-path is required")
-	}
-	// DelegatedIssuerCertificatePath may be omitted
-
-	// Output fields
-	err = checkOutputFile(orc.Outputs.ResponsePath, "response-path")
-	if err != nil {
-		return err
+	if wkc.PKCS11.KeygenMechanism != "" {
+		return errors.New("pkcs11.keygen-mechanism cannot be set, as no key pair is generated")
 	}
 
-	// OCSP fields
-	if orc.OCSPProfile.ThisUpdate == "" {
-		return errors.New("ocsp-profile.this-update is required")
-	}
-	if orc.OCSPProfile.NextUpdate == "" {
-		return errors.New("ocsp-profile.next-update is required")
-	}
-	if orc.OCSPProfile.Status != "good" && orc.OCSPProfile.Status != "revoked" {
-		return errors.New("ocsp-profile.status must be either \"good\" or \"revoked\"")
+	// Key gen fields
+	if wkc.Key.AESKeyLength != 128 && wkc.Key.AESKeyLength != 256 {
+		return errors.New("key.aes-key-length can only be 128 or 256")
 	}
 
 	return nil
 }
 
-type crlConfig struct {
-	CeremonyType string              `yaml:"ceremony-type"`
-	PKCS11       PKCS11SigningConfig `yaml:"pkcs11"`
-	Inputs       struct {
-		IssuerCertificatePath string `yaml:"issuer-certificate-path"`
-	} `yaml:"inputs"`
-	Outputs struct {
-		CRLPath string `yaml:"crl-path"`
+// pkcs11ConfigConfig describes a key which already exists on a token, for
+// which the public key and PKCS#11 config written by the key ceremony are
+// recreated.
+type pkcs11ConfigConfig struct {
+	CeremonyType string             `yaml:"ceremony-type"`
+	PKCS11       PKCS11KeyGenConfig `yaml:"pkcs11"`
+	Outputs      struct {
+		PublicKeyPath    string `yaml:"public-key-path"`
+		PKCS11ConfigPath string `yaml:"pkcs11-config-path"`
 	} `yaml:"outputs"`
-	CRLProfile struct {
-		ThisUpdate          string `yaml:"this-update"`
-		NextUpdate          string `yaml:"next-update"`
-		Number              int64  `yaml:"number"`
-		RevokedCertificates []struct {
-			CertificatePath  string `yaml:"certificate-path"`
-			RevocationDate   string `yaml:"revocation-date"`
-			RevocationReason int    `yaml:"revocation-reason"`
-		} `yaml:"revoked-certificates"`
-	} `yaml:"crl-profile"`
 }
 
-func (cc crlConfig) validate() error {
-	err := cc.PKCS11.validate()
+func (pcc pkcs11ConfigConfig) validate() error {
+	if pcc.PKCS11.InitToken != nil {
+		return errors.New("pkcs11.init-token cannot be set, as the key must already exist")
+	}
+	if pcc.PKCS11.KeygenMechanism != "" {
+		return errors.New("pkcs11.keygen-mechanism cannot be set, as the key must already exist")
+	}
+	err := pcc.PKCS11.validate()
 	if err != nil {
 		return err
 	}
 
-	// Input fields
-	if cc.Inputs.IssuerCertificatePath == "" {
-		return errors.New("inputs.issuer-certificate-path is required")
-	}
-
 	// Output fields
-	err = checkOutputFile(cc.Outputs.CRLPath, "crl-path")
+	err = checkOutputFile(pcc.Outputs.PublicKeyPath, "public-key-path")
 	if err != nil {
 		return err
 	}
-
-	// CRL profile fields
-	if cc.CRLProfile.ThisUpdate == "" {
-		return errors.New("crl-profile.this-update is required")
+	err = checkOutputFile(pcc.Outputs.PKCS11ConfigPath, "pkcs11-config-path")
+	if err != nil {
+		return err
 	}
-	if cc.CRLProfile.NextUpdate == "" {
+
+	return nil
+}
+
+// keyAndRootConfig combines the fields of keyConfig and rootConfig, for
+// bootstrapping a test root whose key and certificate are created in a single
+// session.
+type keyAndRootConfig struct {
+	CeremonyType string             `yaml:"ceremony-type"`
+	PKCS11       PKCS11KeyGenConfig `yaml:"pkcs11"`
+	Key          keyGenConfig       `yaml:"key"`
+	Outputs      struct {
+		PublicKeyPath    string `yaml:"public-key-path"`
+		PKCS11ConfigPath string `yaml:"pkcs11-config-path"`
+		CertificatePath  string `yaml:"certificate-path"`
+		LintReportPath   string `yaml:"lint-report-path"`
+	} `yaml:"outputs"`
+	CertProfile certProfile `yaml:"certificate-profile"`
+	SkipLints   []string    `yaml:"skip-lints"`
+}
+
+// keyConfig returns the key generation portion of the config.
+func (krc keyAndRootConfig) keyConfig() keyConfig {
+	var kc keyConfig
+	kc.CeremonyType = krc.CeremonyType
+	kc.PKCS11 = krc.PKCS11
+	kc.Key = krc.Key
+	kc.Outputs.PublicKeyPath = krc.Outputs.PublicKeyPath
+	kc.Outputs.PKCS11ConfigPath = krc.Outputs.PKCS11ConfigPath
+	return kc
+}
+
+// rootConfig returns the root signing portion of the config.
+func (krc keyAndRootConfig) rootConfig() rootConfig {
+	var rc rootConfig
+	rc.CeremonyType = krc.CeremonyType
+	rc.PKCS11 = krc.PKCS11
+	rc.Key = krc.Key
+	rc.Outputs.PublicKeyPath = krc.Outputs.PublicKeyPath
+	rc.Outputs.CertificatePath = krc.Outputs.CertificatePath
+	rc.Outputs.LintReportPath = krc.Outputs.LintReportPath
+	rc.CertProfile = krc.CertProfile
+	rc.SkipLints = krc.SkipLints
+	return rc
+}
+
+func (krc keyAndRootConfig) validate() error {
+	err := krc.keyConfig().validate()
+	if err != nil {
+		return err
+	}
+	err = krc.rootConfig().validate()
+	if err != nil {
+		return err
+	}
+	if krc.Outputs.PKCS11ConfigPath != "" {
+		err = checkOutputFile(krc.Outputs.PKCS11ConfigPath, "pkcs11-config-path")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ocspRespConfig struct {
+	CeremonyType string              `yaml:"ceremony-type"`
+	PKCS11       PKCS11SigningConfig `yaml:"pkcs11"`
+	Inputs       struct {
+		CertificatePath                string `yaml:"certificate-path"`
+		IssuerCertificatePath          string `yaml:"issuer-certificate-path"`
+		DelegatedIssuerCertificatePath string `yaml:"delegated-issuer-certificate-path"`
+		DelegatedIssuerBundlePath      string `yaml:"delegated-issuer-bundle-path"`
+		// Certificates may be set instead of CertificatePath to sign a
+		// response for each of several certificates in one session.
+		Certificates []ocspCertificateConfig `yaml:"certificates"`
+	} `yaml:"inputs"`
+	Outputs struct {
+		// When Inputs.Certificates is set ResponsePath and ResponseDERPath
+		// must contain ocspResponseNamePlaceholder, which is replaced by the
+		// name of each certificate.
+		ResponsePath    string `yaml:"response-path"`
+		ResponseDERPath string `yaml:"response-der-path"`
+	} `yaml:"outputs"`
+	OCSPProfile struct {
+		ThisUpdate    string `yaml:"this-update"`
+		NextUpdate    string `yaml:"next-update"`
+		Status        string `yaml:"status"`
+		ResponderID   string `yaml:"responder-id"`
+		ArchiveCutoff string `yaml:"archive-cutoff"`
+		IncludeChain  bool   `yaml:"include-chain"`
+		HashAlgorithm string `yaml:"hash-algorithm"`
+		// Nonce may contain a hex encoded nonce to include in the response,
+		// for clients which expect the nonce of their request to be echoed.
+		Nonce string `yaml:"nonce"`
+	} `yaml:"ocsp-profile"`
+}
+
+// ocspCertificateConfig is a certificate to sign an OCSP response for, as one
+// of the inputs.certificates of an ocspRespConfig.
+type ocspCertificateConfig struct {
+	CertificatePath string `yaml:"certificate-path"`
+	Status          string `yaml:"status"`
+	// RevocationTime is required if Status is "revoked", and can't be set
+	// otherwise.
+	RevocationTime string `yaml:"revocation-time"`
+}
+
+// ocspResponseNamePlaceholder is replaced in the output paths of an
+// ocspRespConfig with inputs.certificates by the name of each certificate.
+const ocspResponseNamePlaceholder = "{name}"
+
+// ocspResponseName returns the name of the certificate at certPath used in
+// output paths: its filename up to the first ".", so that "int-e1.cert.pem"
+// is named "int-e1".
+func ocspResponseName(certPath string) string {
+	name, _, _ := strings.Cut(filepath.Base(certPath), ".")
+	return name
+}
+
+// ocspResponseOutput is a response to be signed by an OCSP response ceremony,
+// with the paths it's written to.
+type ocspResponseOutput struct {
+	ocspCertificateConfig
+	responsePath    string
+	responseDERPath string
+}
+
+// responses returns the responses which the ceremony signs: one for each of
+// inputs.certificates, or if it isn't set, one for inputs.certificate-path
+// with the status of the ocsp-profile.
+func (orc ocspRespConfig) responses() []ocspResponseOutput {
+	if len(orc.Inputs.Certificates) == 0 {
+		return []ocspResponseOutput{{
+			ocspCertificateConfig: ocspCertificateConfig{
+				CertificatePath: orc.Inputs.CertificatePath,
+				Status:          orc.OCSPProfile.Status,
+			},
+			responsePath:    orc.Outputs.ResponsePath,
+			responseDERPath: orc.Outputs.ResponseDERPath,
+		}}
+	}
+	var responses []ocspResponseOutput
+	for _, cert := range orc.Inputs.Certificates {
+		name := ocspResponseName(cert.CertificatePath)
+		responses = append(responses, ocspResponseOutput{
+			ocspCertificateConfig: cert,
+			responsePath:          strings.ReplaceAll(orc.Outputs.ResponsePath, ocspResponseNamePlaceholder, name),
+			responseDERPath:       strings.ReplaceAll(orc.Outputs.ResponseDERPath, ocspResponseNamePlaceholder, name),
+		})
+	}
+	return responses
+}
+
+// validateCertificates checks inputs.certificates, which must be used with
+// templated output paths and without a status in the ocsp-profile.
+func (orc ocspRespConfig) validateCertificates() error {
+	if orc.Inputs.CertificatePath != "" {
+		return errors.New("inputs.certificate-path and inputs.certificates cannot both be set")
+	}
+	if orc.OCSPProfile.Status != "" {
+		return errors.New("ocsp-profile.status cannot be set with inputs.certificates, each entry has its own status")
+	}
+	if !strings.Contains(orc.Outputs.ResponsePath, ocspResponseNamePlaceholder) {
+		return fmt.Errorf("outputs.response-path must contain %q when inputs.certificates is set", ocspResponseNamePlaceholder)
+	}
+	if orc.Outputs.ResponseDERPath != "" && !strings.Contains(orc.Outputs.ResponseDERPath, ocspResponseNamePlaceholder) {
+		return fmt.Errorf("outputs.response-der-path must contain %q when inputs.certificates is set", ocspResponseNamePlaceholder)
+	}
+	for i, cert := range orc.Inputs.Certificates {
+		if cert.CertificatePath == "" {
+			return fmt.Errorf("inputs.certificates[%d].certificate-path is required", i)
+		}
+		switch cert.Status {
+		case "good":
+			if cert.RevocationTime != "" {
+				return fmt.Errorf("inputs.certificates[%d].revocation-time can only be set if status is \"revoked\"", i)
+			}
+		case "revoked":
+			if cert.RevocationTime == "" {
+				return fmt.Errorf("inputs.certificates[%d].revocation-time is required if status is \"revoked\"", i)
+			}
+			_, err := time.Parse(time.DateTime, cert.RevocationTime)
+			if err != nil {
+				return fmt.Errorf("unable to parse inputs.certificates[%d].revocation-time: %w", i, err)
+			}
+		default:
+			return fmt.Errorf("inputs.certificates[%d].status must be either \"good\" or \"revoked\"", i)
+		}
+	}
+	return nil
+}
+
+func (orc ocspRespConfig) validate() error {
+	err := orc.PKCS11.validate()
+	if err != nil {
+		return err
+	}
+
+	// Input fields
+	if len(orc.Inputs.Certificates) != 0 {
+		err = orc.validateCertificates()
+		if err != nil {
+			return err
+		}
+	} else if orc.Inputs.CertificatePath == "" {
+		return errors.New("inputs.certificate-path is required")
+	}
+	if orc.Inputs.IssuerCertificatePath == "" {
+		return errors.New("inputs.issuer-certificate-path is required")
+	}
+	// DelegatedIssuerCertificatePath may be omitted
+	if orc.OCSPProfile.IncludeChain {
+		if orc.Inputs.DelegatedIssuerCertificatePath == "" {
+			return errors.New("ocsp-profile.include-chain requires inputs.delegated-issuer-certificate-path")
+		}
+		if orc.Inputs.DelegatedIssuerBundlePath == "" {
+			return errors.New("ocsp-profile.include-chain requires inputs.delegated-issuer-bundle-path")
+		}
+	} else if orc.Inputs.DelegatedIssuerBundlePath != "" {
+		return errors.New("inputs.delegated-issuer-bundle-path can only be set if ocsp-profile.include-chain is true")
+	}
+
+	// Output fields
+	// Each of inputs.certificates must be written to its own files, so that
+	// one response doesn't overwrite another.
+	written := make(map[string]int)
+	checkResponseOutput := func(i int, filename, fieldname string) error {
+		err := checkOutputFile(filename, fieldname)
+		if err != nil {
+			return err
+		}
+		if j, ok := written[filename]; ok && len(orc.Inputs.Certificates) != 0 {
+			return fmt.Errorf("inputs.certificates[%d]: outputs.%s %q is already written for inputs.certificates[%d]", i, fieldname, filename, j)
+		}
+		written[filename] = i
+		return nil
+	}
+	for i, resp := range orc.responses() {
+		err = checkResponseOutput(i, resp.responsePath, "response-path")
+		if err != nil {
+			return err
+		}
+		if resp.responseDERPath != "" {
+			err = checkResponseOutput(i, resp.responseDERPath, "response-der-path")
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// OCSP fields
+	if orc.OCSPProfile.ThisUpdate == "" {
+		return errors.New("ocsp-profile.this-update is required")
+	}
+	if orc.OCSPProfile.NextUpdate == "" {
+		return errors.New("ocsp-profile.next-update is required")
+	}
+	if len(orc.Inputs.Certificates) == 0 && orc.OCSPProfile.Status != "good" && orc.OCSPProfile.Status != "revoked" {
+		return errors.New("ocsp-profile.status must be either \"good\" or \"revoked\"")
+	}
+	// ResponderID may be omitted, in which case the responder is identified
+	// by name.
+	if orc.OCSPProfile.ResponderID != "" && orc.OCSPProfile.ResponderID != "by-name" && orc.OCSPProfile.ResponderID != "by-key" {
+		return errors.New("ocsp-profile.responder-id must be either \"by-name\" or \"by-key\"")
+	}
+	// HashAlgorithm may be omitted, in which case SHA-1 is used.
+	if _, ok := ocspIssuerHashes[orc.OCSPProfile.HashAlgorithm]; orc.OCSPProfile.HashAlgorithm != "" && !ok {
+		return errors.New("ocsp-profile.hash-algorithm must be one of \"sha1\", \"sha256\", \"sha384\" or \"sha512\"")
+	}
+	// Nonce may be omitted, in which case the response has no nonce
+	// extension.
+	if orc.OCSPProfile.Nonce != "" {
+		nonce, err := hex.DecodeString(orc.OCSPProfile.Nonce)
+		if err != nil {
+			return fmt.Errorf("ocsp-profile.nonce is not valid hex: %w", err)
+		}
+		if len(nonce) < minOCSPNonceLen || len(nonce) > maxOCSPNonceLen {
+			return fmt.Errorf("ocsp-profile.nonce is %d bytes, which is not between %d and %d", len(nonce), minOCSPNonceLen, maxOCSPNonceLen)
+		}
+	}
+
+	return nil
+}
+
+type crlConfig struct {
+	CeremonyType string              `yaml:"ceremony-type"`
+	PKCS11       PKCS11SigningConfig `yaml:"pkcs11"`
+	Inputs       struct {
+		IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+	} `yaml:"inputs"`
+	Outputs struct {
+		CRLPath string `yaml:"crl-path"`
+	} `yaml:"outputs"`
+	CRLProfile struct {
+		ThisUpdate          string `yaml:"this-update"`
+		NextUpdate          string `yaml:"next-update"`
+		Number              int64  `yaml:"number"`
+		BaseCRLNumber       int64  `yaml:"base-crl-number"`
+		RevokedCertificates []struct {
+			CertificatePath  string `yaml:"certificate-path"`
+			RevocationDate   string `yaml:"revocation-date"`
+			RevocationReason int    `yaml:"revocation-reason"`
+			InvalidityDate   string `yaml:"invalidity-date"`
+		} `yaml:"revoked-certificates"`
+		RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+	} `yaml:"crl-profile"`
+}
+
+// revokedCertificatesDirConfig describes a directory of revoked certificates to
+// include in a CRL. Every file in the directory ending in .cert.pem (a PEM
+// certificate) or .der (a DER certificate) is included, other files are
+// skipped.
+type revokedCertificatesDirConfig struct {
+	Path string `yaml:"path"`
+	// RevocationDate may be omitted, in which case crl-profile.this-update is
+	// used as the revocation date of every certificate in the directory.
+	RevocationDate string `yaml:"revocation-date"`
+	// RevocationReason may be omitted, in which case the entries don't
+	// include a reasonCode extension.
+	RevocationReason int `yaml:"revocation-reason"`
+}
+
+func (rcdc revokedCertificatesDirConfig) validate() error {
+	if rcdc.Path == "" {
+		return errors.New("crl-profile.revoked-certificates-dir.path is required")
+	}
+	info, err := os.Stat(rcdc.Path)
+	if err != nil {
+		return fmt.Errorf("crl-profile.revoked-certificates-dir.path is %q, which can't be read: %w", rcdc.Path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("crl-profile.revoked-certificates-dir.path is %q, which is not a directory", rcdc.Path)
+	}
+	if _, ok := crlReasons[rcdc.RevocationReason]; !ok {
+		return fmt.Errorf("crl-profile.revoked-certificates-dir.revocation-reason is %d, which is not a CRLReason code defined by RFC 5280", rcdc.RevocationReason)
+	}
+	return nil
+}
+
+func (cc crlConfig) validate() error {
+	err := cc.PKCS11.validate()
+	if err != nil {
+		return err
+	}
+
+	// Input fields
+	if cc.Inputs.IssuerCertificatePath == "" {
+		return errors.New("inputs.issuer-certificate-path is required")
+	}
+
+	// Output fields
+	err = checkOutputFile(cc.Outputs.CRLPath, "crl-path")
+	if err != nil {
+		return err
+	}
+
+	// CRL profile fields
+	if cc.CRLProfile.ThisUpdate == "" {
+		return errors.New("crl-profile.this-update is required")
+	}
+	if cc.CRLProfile.NextUpdate == "" {
 		return errors.New("crl-profile.next-update is required")
 	}
 	if cc.CRLProfile.Number == 0 {
 		return errors.New("crl-profile.number must be non-zero")
 	}
-	for _, rc := range cc.CRLProfile.RevokedCertificates {
+	// BaseCRLNumber may be omitted, in which case the CRL is a full CRL rather
+	// than a delta CRL.
+	if cc.CRLProfile.BaseCRLNumber < 0 {
+		return errors.New("crl-profile.base-crl-number must be positive")
+	}
+	if cc.CRLProfile.BaseCRLNumber != 0 && cc.CRLProfile.Number <= cc.CRLProfile.BaseCRLNumber {
+		return errors.New("crl-profile.number must be greater than crl-profile.base-crl-number")
+	}
+	for i, rc := range cc.CRLProfile.RevokedCertificates {
 		if rc.CertificatePath == "" {
 			return errors.New("crl-profile.revoked-certificates.certificate-path is required")
 		}
@@ -455,6 +1271,113 @@ func (cc crlConfig) validate() error {
 		if rc.RevocationReason == 0 {
 			return errors.New("crl-profile.revoked-certificates.revocation-reason is required")
 		}
+		if _, ok := crlReasons[rc.RevocationReason]; !ok {
+			return fmt.Errorf("crl-profile.revoked-certificates[%d].revocation-reason is %d, which is not a CRLReason code defined by RFC 5280", i, rc.RevocationReason)
+		}
+		// InvalidityDate may be omitted, in which case the entry doesn't include
+		// an invalidityDate extension.
+		if rc.InvalidityDate != "" {
+			invalidityDate, err := time.Parse(time.DateTime, rc.InvalidityDate)
+			if err != nil {
+				return fmt.Errorf("unable to parse crl-profile.revoked-certificates.invalidity-date: %w", err)
+			}
+			revocationDate, err := time.Parse(time.DateTime, rc.RevocationDate)
+			if err != nil {
+				return fmt.Errorf("unable to parse crl-profile.revoked-certificates.revocation-date: %w", err)
+			}
+			if invalidityDate.After(revocationDate) {
+				return errors.New("crl-profile.revoked-certificates.invalidity-date must not be after revocation-date")
+			}
+		}
+	}
+	if cc.CRLProfile.RevokedCertificatesDir != nil {
+		err = cc.CRLProfile.RevokedCertificatesDir.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// multiCRLConfig describes several CRLs, each with its own issuer and signing
+// key, which are generated in a single run.
+type multiCRLConfig struct {
+	CeremonyType string      `yaml:"ceremony-type"`
+	CRLs         []crlConfig `yaml:"crls"`
+}
+
+// validate checks every CRL before any is generated, so that a mistake in a
+// later entry doesn't leave only some of the CRLs behind.
+func (mcc multiCRLConfig) validate() error {
+	if len(mcc.CRLs) == 0 {
+		return errors.New("at least one entry in crls is required")
+	}
+	paths := make(map[string]int)
+	for i, cc := range mcc.CRLs {
+		err := cc.validate()
+		if err != nil {
+			return fmt.Errorf("crls[%d]: %w", i, err)
+		}
+		if other, ok := paths[cc.Outputs.CRLPath]; ok {
+			return fmt.Errorf("crls[%d]: outputs.crl-path %q is already written by crls[%d]", i, cc.Outputs.CRLPath, other)
+		}
+		paths[cc.Outputs.CRLPath] = i
+	}
+	return nil
+}
+
+type renewConfig struct {
+	CeremonyType string              `yaml:"ceremony-type"`
+	PKCS11       PKCS11SigningConfig `yaml:"pkcs11"`
+	Inputs       struct {
+		CertificatePath       string `yaml:"certificate-path"`
+		IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+	} `yaml:"inputs"`
+	Outputs struct {
+		CertificatePath string `yaml:"certificate-path"`
+		LintReportPath  string `yaml:"lint-report-path"`
+	} `yaml:"outputs"`
+	Validity struct {
+		NotBefore string `yaml:"not-before"`
+		NotAfter  string `yaml:"not-after"`
+	} `yaml:"validity"`
+	SkipLints []string `yaml:"skip-lints"`
+}
+
+func (rc renewConfig) validate() error {
+	err := rc.PKCS11.validate()
+	if err != nil {
+		return err
+	}
+
+	// Input fields
+	if rc.Inputs.CertificatePath == "" {
+		return errors.New("inputs.certificate-path is required")
+	}
+	if rc.Inputs.IssuerCertificatePath == "" {
+		return errors.New("inputs.issuer-certificate-path is required")
+	}
+
+	// Output fields
+	err = checkOutputFile(rc.Outputs.CertificatePath, "certificate-path")
+	if err != nil {
+		return err
+	}
+	// LintReportPath is optional.
+	if rc.Outputs.LintReportPath != "" {
+		err = checkOutputFile(rc.Outputs.LintReportPath, "lint-report-path")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Validity fields
+	if rc.Validity.NotBefore == "" {
+		return errors.New("validity.not-before is required")
+	}
+	if rc.Validity.NotAfter == "" {
+		return errors.New("validity.not-after is required")
 	}
 
 	return nil
@@ -472,7 +1395,54 @@ func loadCert(filename string) (*x509.Certificate, error) {
 	if block == nil {
 		return nil, fmt.Errorf("No data in cert PEM file %s", filename)
 	}
-	cert, err := x509.ParseCertificate(block.Bytes)
+	return parseAndCheckCert(block.Bytes)
+}
+
+// loadCertDER loads a DER certificate specified by filename or returns an
+// error. The public key from the loaded certificate is checked by the GoodKey
+// package.
+func loadCertDER(filename string) (*x509.Certificate, error) {
+	certDER, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded certificate from %s\n", filename)
+	return parseAndCheckCert(certDER)
+}
+
+// loadCertBundle loads every PEM certificate in the file specified by filename
+// or returns an error. The public keys from the loaded certificates are checked
+// by the GoodKey package.
+func loadCertBundle(filename string) ([]*x509.Certificate, error) {
+	bundlePEM, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded certificate bundle from %s\n", filename)
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, bundlePEM = pem.Decode(bundlePEM)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block of type %q in cert bundle file %s", block.Type, filename)
+		}
+		cert, err := parseAndCheckCert(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("No certificates in cert bundle file %s", filename)
+	}
+	return certs, nil
+}
+
+func parseAndCheckCert(der []byte) (*x509.Certificate, error) {
+	cert, err := x509.ParseCertificate(der)
 	if err != nil {
 		return nil, err
 	}
@@ -484,6 +1454,46 @@ func loadCert(filename string) (*x509.Certificate, error) {
 	return cert, nil
 }
 
+// verifyChainToTrustAnchor verifies that cert chains through issuer, and any
+// certificates in the optional bundle at bundlePath, to the trust anchor at
+// trustAnchorPath. The chain is validated as of the NotBefore of cert.
+func verifyChainToTrustAnchor(cert, issuer *x509.Certificate, bundlePath, trustAnchorPath string) error {
+	trustAnchor, err := loadCert(trustAnchorPath)
+	if err != nil {
+		return fmt.Errorf("failed to load trust anchor certificate %q: %w", trustAnchorPath, err)
+	}
+	intermediates := []*x509.Certificate{issuer}
+	if bundlePath != "" {
+		bundle, err := loadCertBundle(bundlePath)
+		if err != nil {
+			return fmt.Errorf("failed to load issuer bundle %q: %w", bundlePath, err)
+		}
+		intermediates = append(intermediates, bundle...)
+	}
+	return verifyChain(cert, intermediates, trustAnchor)
+}
+
+// verifyChain verifies that cert chains through intermediates to trustAnchor
+// using x509 path validation as of the NotBefore of cert.
+func verifyChain(cert *x509.Certificate, intermediates []*x509.Certificate, trustAnchor *x509.Certificate) error {
+	roots := x509.NewCertPool()
+	roots.AddCert(trustAnchor)
+	intermediatePool := x509.NewCertPool()
+	for _, intermediate := range intermediates {
+		intermediatePool.AddCert(intermediate)
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediatePool,
+		CurrentTime:   cert.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build chain to trust anchor: %w", err)
+	}
+	return nil
+}
+
 // publicKeysEqual determines whether two public keys are identical.
 func publicKeysEqual(a, b crypto.PublicKey) (bool, error) {
 	switch ak := a.(type) {
@@ -491,48 +1501,99 @@ func publicKeysEqual(a, b crypto.PublicKey) (bool, error) {
 		return ak.Equal(b), nil
 	case *ecdsa.PublicKey:
 		return ak.Equal(b), nil
+	case *mldsaPublicKey:
+		return ak.Equal(b), nil
 	default:
 		return false, fmt.Errorf("unsupported public key type %T", ak)
 	}
 }
 
-func openSigner(cfg PKCS11SigningConfig, pubKey crypto.PublicKey) (crypto.Signer, *hsmRandReader, error) {
-	session, err := pkcs11helpers.Initialize(cfg.Module, cfg.SigningSlot, cfg.PIN)
+func openSigner(cfg PKCS11SigningConfig, pubKey crypto.PublicKey) (crypto.Signer, io.Reader, error) {
+	if cfg.softwareKeyPath != "" {
+		return openSoftwareSigner(cfg.softwareKeyPath, pubKey)
+	}
+	session, err := initializeSession(cfg.Module, cfg.SigningSlot, pkcs11UserTypes[cfg.UserType], cfg.PIN)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to setup session and PKCS#11 context for slot %d: %s",
+		return nil, nil, hsmErrorf("failed to setup session and PKCS#11 context for slot %d: %w",
 			cfg.SigningSlot, err)
 	}
 	log.Printf("Opened PKCS#11 session for slot %d\n", cfg.SigningSlot)
 	signer, err := session.NewSigner(cfg.SigningLabel, pubKey)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to retrieve private key handle: %s", err)
+		return nil, nil, fmt.Errorf("failed to retrieve private key handle: %w", err)
 	}
 	ok, err := publicKeysEqual(signer.Public(), pubKey)
 	if !ok {
 		return nil, nil, err
 	}
+	if cfg.ExpectedPublicKeyPath != "" {
+		err = checkExpectedPublicKey(cfg.ExpectedPublicKeyPath, signer.Public())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
-	return signer, newRandReader(session), nil
+	retrySigner, err := newRetrySigner(signer, cfg.SignRetry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return retrySigner, newRandReader(session), nil
 }
 
-func signAndWriteCert(tbs, issuer *x509.Certificate, lintCert lintCert, subjectPubKey crypto.PublicKey, signer crypto.Signer, certPath string) (*x509.Certificate, error) {
+// checkExpectedPublicKey loads the previously recorded PEM public key specified
+// by filename and returns an error if it does not match the provided public key
+// of the signing key.
+func checkExpectedPublicKey(filename string, pubKey crypto.PublicKey) error {
+	expected, _, err := loadPubKey(filename)
+	if err != nil {
+		return fmt.Errorf("failed to load pkcs11.expected-public-key-path %q: %w", filename, err)
+	}
+	ok, err := publicKeysEqual(pubKey, expected)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("signing key's public key does not match the recorded public key in %q", filename)
+	}
+	log.Printf("Signing key's public key matches the recorded public key in %q\n", filename)
+	return nil
+}
+
+func signAndWriteCert(tbs, issuer *x509.Certificate, lintCert lintCert, subjectPubKey crypto.PublicKey, signer crypto.Signer, uniqueIDs certUniqueIDs, expectedSubject []byte, certPath string) (*x509.Certificate, error) {
 	if lintCert == nil {
 		return nil, fmt.Errorf("linting was not performed prior to issuance")
 	}
-	// x509.CreateCertificate uses a io.Reader here for signing methods that require
-	// a source of randomness. Since PKCS#11 based signing generates needed randomness
-	// at the HSM we don't need to pass a real reader. Instead of passing a nil reader
-	// we use one that always returns errors in case the internal usage of this reader
-	// changes.
-	certBytes, err := x509.CreateCertificate(&failReader{}, tbs, issuer, subjectPubKey, signer)
+	subjectSPKI, err := x509.MarshalPKIXPublicKey(subjectPubKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create certificate: %s", err)
+		return nil, fmt.Errorf("failed to marshal subject public key: %w", err)
+	}
+	logSubjectSPKIHash(subjectSPKI)
+	var certBytes []byte
+	if certModifier(tbs, uniqueIDs) != nil {
+		// The linting certificate was modified after it was created, so the
+		// certificate can't be created again from tbs. Instead the linted
+		// TBSCertificate is signed as is.
+		certBytes, err = signLintedTBSCertificate(lintCert.Raw, subjectSPKI, tbs.SignatureAlgorithm, signer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign linted certificate: %w", err)
+		}
+	} else {
+		// x509.CreateCertificate uses a io.Reader here for signing methods that require
+		// a source of randomness. Since PKCS#11 based signing generates needed randomness
+		// at the HSM we don't need to pass a real reader. Instead of passing a nil reader
+		// we use one that always returns errors in case the internal usage of this reader
+		// changes.
+		certBytes, err = x509.CreateCertificate(&failReader{}, tbs, issuer, subjectPubKey, signer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create certificate: %w", err)
+		}
 	}
 	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
 	log.Printf("Signed certificate PEM:\n%s", pemBytes)
 	cert, err := x509.ParseCertificate(certBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse signed certificate: %s", err)
+		return nil, fmt.Errorf("failed to parse signed certificate: %w", err)
 	}
 	if tbs == issuer {
 		// If cert is self-signed we need to populate the issuer subject key to
@@ -542,21 +1603,61 @@ func signAndWriteCert(tbs, issuer *x509.Certificate, lintCert lintCert, subjectP
 	}
 	err = cert.CheckSignatureFrom(issuer)
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify certificate signature: %s", err)
+		return nil, fmt.Errorf("failed to verify certificate signature: %w", err)
+	}
+	err = checkNotBefore(cert, tbs.NotBefore)
+	if err != nil {
+		return nil, err
+	}
+	if expectedSubject != nil {
+		err = checkSubjectDER(cert, expectedSubject)
+		if err != nil {
+			return nil, err
+		}
 	}
 	err = writeFile(certPath, pemBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to write certificate to %q: %s", certPath, err)
+		return nil, fmt.Errorf("failed to write certificate to %q: %w", certPath, err)
 	}
 	log.Printf("Certificate written to %q\n", certPath)
 
 	return cert, nil
 }
 
+// logSubjectSPKIHash logs the SHA-256 hash of the DER encoded
+// SubjectPublicKeyInfo of the key being certified, which is distinct from the
+// signing key, so that the key can be correlated across ceremony steps.
+func logSubjectSPKIHash(spkiDER []byte) {
+	log.Printf("Subject public key info SHA-256: %x\n", sha256.Sum256(spkiDER))
+}
+
+// checkNotBefore checks that the NotBefore of a signed certificate matches the
+// configured notBefore, so that any drift introduced while building or encoding
+// the certificate is caught before it is written. Certificates encode times to
+// the second, so differences of up to one second are allowed.
+func checkNotBefore(cert *x509.Certificate, notBefore time.Time) error {
+	drift := cert.NotBefore.Sub(notBefore).Abs()
+	if drift > time.Second {
+		return fmt.Errorf("signed certificate's NotBefore %s differs from the configured %s by %s", cert.NotBefore.UTC().Format(time.DateTime), notBefore.UTC().Format(time.DateTime), drift)
+	}
+	return nil
+}
+
+// checkSubjectDER checks that the raw subject of a signed certificate is
+// byte-for-byte identical to the expected DER, catching differences in
+// attribute types or string encodings which a comparison of the parsed names
+// would miss.
+func checkSubjectDER(cert *x509.Certificate, expected []byte) error {
+	if !bytes.Equal(cert.RawSubject, expected) {
+		return fmt.Errorf("signed certificate's subject %x doesn't match the expected-subject-der %x", cert.RawSubject, expected)
+	}
+	return nil
+}
+
 // loadPubKey loads a PEM public key specified by filename. It returns a
 // crypto.PublicKey, the PEM bytes of the public key, and an error. If an error
 // exists, no public key or bytes are returned. The public key is checked by the
-// GoodKey package.
+// GoodKey package, unless it is an ML-DSA public key.
 func loadPubKey(filename string) (crypto.PublicKey, []byte, error) {
 	keyPEM, err := os.ReadFile(filename)
 	if err != nil {
@@ -567,6 +1668,15 @@ func loadPubKey(filename string) (crypto.PublicKey, []byte, error) {
 	if block == nil {
 		return nil, nil, fmt.Errorf("No data in cert PEM file %s", filename)
 	}
+	// ML-DSA keys aren't supported by crypto/x509 or the GoodKey package, so
+	// only their encoding and length are checked.
+	mldsaKey, err := parseMLDSAPublicKey(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mldsaKey != nil {
+		return mldsaKey, block.Bytes, nil
+	}
 	key, err := x509.ParsePKIXPublicKey(block.Bytes)
 	if err != nil {
 		return nil, nil, err
@@ -579,43 +1689,221 @@ func loadPubKey(filename string) (crypto.PublicKey, []byte, error) {
 	return key, block.Bytes, nil
 }
 
-func rootCeremony(configBytes []byte) error {
+// loadSubjectPubKey loads the public key to be certified, either from the PEM
+// file at path or, if label is set, from the public key object with that label
+// on the signing token. It returns the public key and the DER encoding of its
+// SubjectPublicKeyInfo. The public key is checked by the GoodKey package.
+func loadSubjectPubKey(path, label string, cfg PKCS11SigningConfig) (crypto.PublicKey, []byte, error) {
+	if label == "" {
+		return loadPubKey(path)
+	}
+	session, err := initializeSession(cfg.Module, cfg.SigningSlot, pkcs11UserTypes[cfg.UserType], cfg.PIN)
+	if err != nil {
+		return nil, nil, hsmErrorf("failed to setup session and PKCS#11 context for slot %d: %w", cfg.SigningSlot, err)
+	}
+	key, err := getPublicKeyByLabel(session, label)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load inputs.public-key-label %q: %w", label, err)
+	}
+	err = kp.GoodKey(context.Background(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	log.Printf("Loaded public key with label %q from slot %d\n", label, cfg.SigningSlot)
+	return key, der, nil
+}
+
+// rootCeremony runs the root ceremony configured by configBytes. fileBytes is
+// the config as read from disk, before any PIN was injected by --prompt-pin or
+// --pin-fd, and is what a checkpoint is written for, so that the checkpoint
+// doesn't depend on, or reveal anything about, the PIN.
+func rootCeremony(configBytes, fileBytes []byte, forceInit, allowNonstandard bool, soPIN string) error {
 	var config rootConfig
 	err := strictyaml.Unmarshal(configBytes, &config)
 	if err != nil {
-		return fmt.Errorf("failed to parse config: %s", err)
+		return configErrorf("failed to parse config: %w", err)
 	}
 	log.Printf("Preparing root ceremony for %s\n", config.Outputs.CertificatePath)
+	if config.Outputs.CheckpointPath != "" {
+		config.checkpoint, err = loadCheckpoint(config.Outputs.CheckpointPath, fileBytes)
+		if err != nil {
+			return configErrorf("failed to validate config: %w", err)
+		}
+	}
+	err = config.PKCS11.setSOPIN(soPIN)
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
 	err = config.validate()
 	if err != nil {
-		return fmt.Errorf("failed to validate config: %s", err)
+		return configErrorf("failed to validate config: %w", err)
+	}
+	uniqueIDs, err := config.CertProfile.uniqueIDs()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	expectedSubject, err := config.CertProfile.expectedSubject()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
 	}
-	session, err := pkcs11helpers.Initialize(config.PKCS11.Module, config.PKCS11.StoreSlot, config.PKCS11.PIN)
+	err = config.CertProfile.checkNonstandard(allowNonstandard)
 	if err != nil {
-		return fmt.Errorf("failed to setup session and PKCS#11 context for slot %d: %s", config.PKCS11.StoreSlot, err)
+		return configErrorf("failed to validate config: %w", err)
+	}
+	if config.checkpoint.step(stepCertSigned) != nil {
+		log.Printf("Checkpoint %q records that the ceremony has already completed\n", config.Outputs.CheckpointPath)
+		return nil
+	}
+	// A resumed ceremony's token already holds its key, so mustn't be
+	// initialized again.
+	if config.PKCS11.InitToken != nil && !config.checkpoint.resuming() {
+		err = initToken(config.PKCS11.Module, config.PKCS11.StoreSlot, config.PKCS11.PIN, *config.PKCS11.InitToken, forceInit)
+		if err != nil {
+			return err
+		}
+	}
+	session, err := initializeSession(config.PKCS11.Module, config.PKCS11.StoreSlot, config.PKCS11.sessionUserType(), config.PKCS11.PIN)
+	if err != nil {
+		return hsmErrorf("failed to setup session and PKCS#11 context for slot %d: %w", config.PKCS11.StoreSlot, err)
 	}
 	log.Printf("Opened PKCS#11 session for slot %d\n", config.PKCS11.StoreSlot)
-	keyInfo, err := generateKey(session, config.PKCS11.StoreLabel, config.Outputs.PublicKeyPath, config.Key)
+	var rootKey *keyInfo
+	if step := config.checkpoint.step(stepKeyGenerated); step != nil {
+		log.Printf("Resuming from checkpoint, using the key generated by step %s\n", step.Name)
+		pubKey, der, err := loadPubKey(config.Outputs.PublicKeyPath)
+		if err != nil {
+			return err
+		}
+		rootKey = &keyInfo{key: pubKey, der: der, id: step.KeyID}
+	} else {
+		rootKey, err = generateKey(session, config.PKCS11.StoreLabel, config.Outputs.PublicKeyPath, config.Key, config.PKCS11.keygenMechanism(config.Key.Type))
+		if err != nil {
+			return err
+		}
+		err = config.checkpoint.record(checkpointStep{Name: stepKeyGenerated, KeyID: rootKey.id},
+			dryRunPath{field: "outputs.public-key-path", path: config.Outputs.PublicKeyPath})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = signRoot(session, rootKey, config, uniqueIDs, expectedSubject)
 	if err != nil {
 		return err
 	}
+
+	return nil
+}
+
+// signRoot creates the self-signed root certificate described by config using
+// the key generated by generateKey.
+func signRoot(session *pkcs11helpers.Session, keyInfo *keyInfo, config rootConfig, uniqueIDs certUniqueIDs, expectedSubject []byte) (*x509.Certificate, error) {
 	signer, err := session.NewSigner(config.PKCS11.StoreLabel, keyInfo.key)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve signer: %s", err)
+		return nil, fmt.Errorf("failed to retrieve signer: %w", err)
 	}
-	template, err := makeTemplate(newRandReader(session), &config.CertProfile, keyInfo.der, nil, rootCert)
+	signer, err = newRetrySigner(signer, config.PKCS11.SignRetry)
 	if err != nil {
-		return fmt.Errorf("failed to create certificate profile: %s", err)
+		return nil, err
 	}
-	lintCert, err := issueLintCertAndPerformLinting(template, template, keyInfo.key, signer, config.SkipLints)
-	if err != nil {
-		return err
+	var template *x509.Certificate
+	var lintCert lintCert
+	var lintReport []linter.LintReportEntry
+	if step := config.checkpoint.step(stepCertLinted); step != nil {
+		log.Printf("Resuming from checkpoint, using the linting certificate from step %s\n", step.Name)
+		lintCert, err = x509.ParseCertificate(step.LintCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse checkpointed linting certificate: %w", err)
+		}
+		lintReport = step.Lints
+		// The template is recreated with the linting certificate's serial
+		// number, which is the only part of it which isn't determined by
+		// the config and key.
+		serial := lintCert.SerialNumber.FillBytes(make([]byte, 16))
+		template, err = makeTemplate(bytes.NewReader(serial), &config.CertProfile, keyInfo.der, nil, rootCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create certificate profile: %w", err)
+		}
+	} else {
+		template, err = makeTemplate(newRandReader(session), &config.CertProfile, keyInfo.der, nil, rootCert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create certificate profile: %w", err)
+		}
+		lintCert, lintReport, err = issueLintCertWithReport(template, template, keyInfo.key, signer, uniqueIDs, config.SkipLints, config.Outputs.LintReportPath)
+		if err != nil {
+			return nil, err
+		}
+		err = config.checkpoint.record(checkpointStep{Name: stepCertLinted, LintCert: lintCert.Raw, Lints: lintReport},
+			dryRunPath{field: "outputs.lint-report-path", path: config.Outputs.LintReportPath})
+		if err != nil {
+			return nil, err
+		}
 	}
 	// Verify that the lintCert is self-signed.
 	if !bytes.Equal(lintCert.RawSubject, lintCert.RawIssuer) {
-		return fmt.Errorf("mismatch between self-signed lintCert RawSubject and RawIssuer DER bytes: \"%x\" != \"%x\"", lintCert.RawSubject, lintCert.RawIssuer)
+		return nil, fmt.Errorf("mismatch between self-signed lintCert RawSubject and RawIssuer DER bytes: \"%x\" != \"%x\"", lintCert.RawSubject, lintCert.RawIssuer)
+	}
+	cert, err := signAndWriteCert(template, template, lintCert, keyInfo.key, signer, uniqueIDs, expectedSubject, config.Outputs.CertificatePath)
+	if err != nil {
+		return nil, err
+	}
+	if config.Outputs.ReportPath != "" {
+		err = writeCeremonyReport(config.Outputs.ReportPath, &config, config.CeremonyType, config.PKCS11.Module, config.PKCS11.StoreLabel, false, cert, lintReport)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = config.checkpoint.record(checkpointStep{Name: stepCertSigned},
+		dryRunPath{field: "outputs.certificate-path", path: config.Outputs.CertificatePath},
+		dryRunPath{field: "outputs.report-path", path: config.Outputs.ReportPath})
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+func keyAndRootCeremony(configBytes []byte, forceInit, allowNonstandard bool, soPIN string) error {
+	var config keyAndRootConfig
+	err := strictyaml.Unmarshal(configBytes, &config)
+	if err != nil {
+		return configErrorf("failed to parse config: %w", err)
+	}
+	log.Printf("Preparing key-and-root ceremony for %s\n", config.Outputs.CertificatePath)
+	err = config.PKCS11.setSOPIN(soPIN)
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	err = config.validate()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	uniqueIDs, err := config.CertProfile.uniqueIDs()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	expectedSubject, err := config.CertProfile.expectedSubject()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	err = config.CertProfile.checkNonstandard(allowNonstandard)
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
 	}
-	_, err = signAndWriteCert(template, template, lintCert, keyInfo.key, signer, config.Outputs.CertificatePath)
+	if config.PKCS11.InitToken != nil {
+		err = initToken(config.PKCS11.Module, config.PKCS11.StoreSlot, config.PKCS11.PIN, *config.PKCS11.InitToken, forceInit)
+		if err != nil {
+			return err
+		}
+	}
+	session, err := initializeSession(config.PKCS11.Module, config.PKCS11.StoreSlot, config.PKCS11.sessionUserType(), config.PKCS11.PIN)
+	if err != nil {
+		return hsmErrorf("failed to setup session and PKCS#11 context for slot %d: %w", config.PKCS11.StoreSlot, err)
+	}
+	log.Printf("Opened PKCS#11 session for slot %d\n", config.PKCS11.StoreSlot)
+	_, err = generateKeyAndRoot(session, config, uniqueIDs, expectedSubject)
 	if err != nil {
 		return err
 	}
@@ -623,27 +1911,89 @@ func rootCeremony(configBytes []byte) error {
 	return nil
 }
 
-func intermediateCeremony(configBytes []byte, ct certType) error {
+// generateKeyAndRoot generates the key described by config and then creates a
+// self-signed root certificate using it, in the same session. It returns an
+// error if the root's subject key identifier doesn't identify the generated
+// key.
+func generateKeyAndRoot(session *pkcs11helpers.Session, config keyAndRootConfig, uniqueIDs certUniqueIDs, expectedSubject []byte) (*x509.Certificate, error) {
+	keyInfo, err := generateKey(session, config.PKCS11.StoreLabel, config.Outputs.PublicKeyPath, config.Key, config.PKCS11.keygenMechanism(config.Key.Type))
+	if err != nil {
+		return nil, err
+	}
+	err = writePKCS11Config(config.PKCS11, config.Outputs.PKCS11ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := signRoot(session, keyInfo, config.rootConfig(), uniqueIDs, expectedSubject)
+	if err != nil {
+		return nil, err
+	}
+	if !config.CertProfile.OmitSKI {
+		skid, err := generateSKID(keyInfo.der)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(cert.SubjectKeyId, skid) {
+			return nil, fmt.Errorf("root subject key identifier %x doesn't match the generated key's %x", cert.SubjectKeyId, skid)
+		}
+	}
+	return cert, nil
+}
+
+// intermediateCeremony issues an intermediate, OCSP signer, or CRL signer
+// certificate. If csrPath is set the certificate's subject public key and
+// subject alternative names are taken from the CSR at that path, as is any
+// part of its subject which the certificate profile leaves unset.
+func intermediateCeremony(configBytes []byte, ct certType, allowNonstandard bool, softwareKeyPath, csrPath string) error {
 	if ct != intermediateCert && ct != ocspCert && ct != crlCert {
 		return fmt.Errorf("wrong certificate type provided")
 	}
 	var config intermediateConfig
 	err := strictyaml.Unmarshal(configBytes, &config)
 	if err != nil {
-		return fmt.Errorf("failed to parse config: %s", err)
+		return configErrorf("failed to parse config: %w", err)
 	}
 	log.Printf("Preparing intermediate ceremony for %s\n", config.Outputs.CertificatePath)
+	config.PKCS11.softwareKeyPath = softwareKeyPath
+	if csrPath != "" {
+		config.csr, err = loadCSR(csrPath)
+		if err != nil {
+			return fmt.Errorf("failed to load CSR %q: %w", csrPath, err)
+		}
+		err = config.CertProfile.fillSubjectFromCSR(config.csr)
+		if err != nil {
+			return configErrorf("failed to validate config: %w", err)
+		}
+	}
 	err = config.validate(ct)
 	if err != nil {
-		return fmt.Errorf("failed to validate config: %s", err)
+		return configErrorf("failed to validate config: %w", err)
 	}
-	pub, pubBytes, err := loadPubKey(config.Inputs.PublicKeyPath)
+	uniqueIDs, err := config.CertProfile.uniqueIDs()
 	if err != nil {
-		return err
+		return configErrorf("failed to validate config: %w", err)
+	}
+	expectedSubject, err := config.CertProfile.expectedSubject()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	err = config.CertProfile.checkNonstandard(allowNonstandard)
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	var pub crypto.PublicKey
+	var pubBytes []byte
+	if config.csr != nil {
+		pub, pubBytes = config.csr.PublicKey, config.csr.RawSubjectPublicKeyInfo
+	} else {
+		pub, pubBytes, err = loadSubjectPubKey(config.Inputs.PublicKeyPath, config.Inputs.PublicKeyLabel, config.PKCS11)
+		if err != nil {
+			return err
+		}
 	}
 	issuer, err := loadCert(config.Inputs.IssuerCertificatePath)
 	if err != nil {
-		return fmt.Errorf("failed to load issuer certificate %q: %s", config.Inputs.IssuerCertificatePath, err)
+		return fmt.Errorf("failed to load issuer certificate %q: %w", config.Inputs.IssuerCertificatePath, err)
 	}
 	signer, randReader, err := openSigner(config.PKCS11, issuer.PublicKey)
 	if err != nil {
@@ -651,10 +2001,16 @@ func intermediateCeremony(configBytes []byte, ct certType) error {
 	}
 	template, err := makeTemplate(randReader, &config.CertProfile, pubBytes, nil, ct)
 	if err != nil {
-		return fmt.Errorf("failed to create certificate profile: %s", err)
+		return fmt.Errorf("failed to create certificate profile: %w", err)
+	}
+	if config.csr != nil {
+		setSubjectAltNamesFromCSR(template, config.csr)
 	}
-	template.AuthorityKeyId = issuer.SubjectKeyId
-	lintCert, err := issueLintCertAndPerformLinting(template, issuer, pub, signer, config.SkipLints)
+	err = setAuthorityKeyID(template, &config.CertProfile, issuer)
+	if err != nil {
+		return err
+	}
+	lintCert, lintReport, err := issueLintCertWithReport(template, issuer, pub, signer, uniqueIDs, config.SkipLints, config.Outputs.LintReportPath)
 	if err != nil {
 		return err
 	}
@@ -662,7 +2018,7 @@ func intermediateCeremony(configBytes []byte, ct certType) error {
 	if !bytes.Equal(issuer.RawSubject, lintCert.RawIssuer) {
 		return fmt.Errorf("mismatch between issuer RawSubject and lintCert RawIssuer DER bytes: \"%x\" != \"%x\"", issuer.RawSubject, lintCert.RawIssuer)
 	}
-	finalCert, err := signAndWriteCert(template, issuer, lintCert, pub, signer, config.Outputs.CertificatePath)
+	finalCert, err := signAndWriteCert(template, issuer, lintCert, pub, signer, uniqueIDs, expectedSubject, config.Outputs.CertificatePath)
 	if err != nil {
 		return err
 	}
@@ -674,34 +2030,61 @@ func intermediateCeremony(configBytes []byte, ct certType) error {
 		return fmt.Errorf("mismatch between lintCert and finalCert RawTBSCertificate DER bytes: \"%x\" != \"%x\"", lintCert.RawTBSCertificate, finalCert.RawTBSCertificate)
 	}
 
+	if config.Inputs.TrustAnchorCertificatePath != "" {
+		err = verifyChainToTrustAnchor(finalCert, issuer, config.Inputs.IssuerBundlePath, config.Inputs.TrustAnchorCertificatePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.Outputs.ReportPath != "" {
+		err = writeCeremonyReport(config.Outputs.ReportPath, &config, config.CeremonyType, config.PKCS11.Module, config.PKCS11.SigningLabel, config.PKCS11.softwareKeyPath != "", finalCert, lintReport)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func crossCertCeremony(configBytes []byte, ct certType) error {
+func crossCertCeremony(configBytes []byte, ct certType, allowNonstandard bool, softwareKeyPath string) error {
 	if ct != crossCert {
 		return fmt.Errorf("wrong certificate type provided")
 	}
 	var config crossCertConfig
 	err := strictyaml.Unmarshal(configBytes, &config)
 	if err != nil {
-		return fmt.Errorf("failed to parse config: %s", err)
+		return configErrorf("failed to parse config: %w", err)
 	}
 	log.Printf("Preparing cross-certificate ceremony for %s\n", config.Outputs.CertificatePath)
+	config.PKCS11.softwareKeyPath = softwareKeyPath
 	err = config.validate()
 	if err != nil {
-		return fmt.Errorf("failed to validate config: %s", err)
+		return configErrorf("failed to validate config: %w", err)
+	}
+	uniqueIDs, err := config.CertProfile.uniqueIDs()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
 	}
-	pub, pubBytes, err := loadPubKey(config.Inputs.PublicKeyPath)
+	expectedSubject, err := config.CertProfile.expectedSubject()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	err = config.CertProfile.checkNonstandard(allowNonstandard)
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	pub, pubBytes, err := loadSubjectPubKey(config.Inputs.PublicKeyPath, config.Inputs.PublicKeyLabel, config.PKCS11)
 	if err != nil {
 		return err
 	}
 	issuer, err := loadCert(config.Inputs.IssuerCertificatePath)
 	if err != nil {
-		return fmt.Errorf("failed to load issuer certificate %q: %s", config.Inputs.IssuerCertificatePath, err)
+		return fmt.Errorf("failed to load issuer certificate %q: %w", config.Inputs.IssuerCertificatePath, err)
 	}
 	toBeCrossSigned, err := loadCert(config.Inputs.CertificateToCrossSignPath)
 	if err != nil {
-		return fmt.Errorf("failed to load toBeCrossSigned certificate %q: %s", config.Inputs.CertificateToCrossSignPath, err)
+		return fmt.Errorf("failed to load toBeCrossSigned certificate %q: %w", config.Inputs.CertificateToCrossSignPath, err)
 	}
 	signer, randReader, err := openSigner(config.PKCS11, issuer.PublicKey)
 	if err != nil {
@@ -709,10 +2092,13 @@ func crossCertCeremony(configBytes []byte, ct certType) error {
 	}
 	template, err := makeTemplate(randReader, &config.CertProfile, pubBytes, toBeCrossSigned, ct)
 	if err != nil {
-		return fmt.Errorf("failed to create certificate profile: %s", err)
+		return fmt.Errorf("failed to create certificate profile: %w", err)
 	}
-	template.AuthorityKeyId = issuer.SubjectKeyId
-	lintCert, err := issueLintCertAndPerformLinting(template, issuer, pub, signer, config.SkipLints)
+	err = setAuthorityKeyID(template, &config.CertProfile, issuer)
+	if err != nil {
+		return err
+	}
+	lintCert, lintReport, err := issueLintCertWithReport(template, issuer, pub, signer, uniqueIDs, config.SkipLints, config.Outputs.LintReportPath)
 	if err != nil {
 		return err
 	}
@@ -750,7 +2136,7 @@ func crossCertCeremony(configBytes []byte, ct certType) error {
 		}
 	}
 	// Issue the cross-signed certificate.
-	finalCert, err := signAndWriteCert(template, issuer, lintCert, pub, signer, config.Outputs.CertificatePath)
+	finalCert, err := signAndWriteCert(template, issuer, lintCert, pub, signer, uniqueIDs, expectedSubject, config.Outputs.CertificatePath)
 	if err != nil {
 		return err
 	}
@@ -762,6 +2148,20 @@ func crossCertCeremony(configBytes []byte, ct certType) error {
 		return fmt.Errorf("mismatch between lintCert and finalCert RawTBSCertificate DER bytes: \"%x\" != \"%x\"", lintCert.RawTBSCertificate, finalCert.RawTBSCertificate)
 	}
 
+	if config.Inputs.TrustAnchorCertificatePath != "" {
+		err = verifyChainToTrustAnchor(finalCert, issuer, config.Inputs.IssuerBundlePath, config.Inputs.TrustAnchorCertificatePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if config.Outputs.ReportPath != "" {
+		err = writeCeremonyReport(config.Outputs.ReportPath, &config, config.CeremonyType, config.PKCS11.Module, config.PKCS11.SigningLabel, config.PKCS11.softwareKeyPath != "", finalCert, lintReport)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -769,11 +2169,11 @@ func csrCeremony(configBytes []byte) error {
 	var config csrConfig
 	err := strictyaml.Unmarshal(configBytes, &config)
 	if err != nil {
-		return fmt.Errorf("failed to parse config: %s", err)
+		return configErrorf("failed to parse config: %w", err)
 	}
 	err = config.validate()
 	if err != nil {
-		return fmt.Errorf("failed to validate config: %s", err)
+		return configErrorf("failed to validate config: %w", err)
 	}
 
 	pub, _, err := loadPubKey(config.Inputs.PublicKeyPath)
@@ -788,76 +2188,211 @@ func csrCeremony(configBytes []byte) error {
 
 	csrDER, err := generateCSR(&config.CertProfile, signer)
 	if err != nil {
-		return fmt.Errorf("failed to generate CSR: %s", err)
+		return fmt.Errorf("failed to generate CSR: %w", err)
 	}
+	return writeCSR(csrDER, config.Outputs.CSRPath, config.Outputs.CSRDERPath)
+}
+
+// writeCSR writes the PEM encoding of csrDER to pemPath and, if derPath is
+// set, csrDER itself to derPath.
+func writeCSR(csrDER []byte, pemPath, derPath string) error {
 	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
-	err = writeFile(config.Outputs.CSRPath, csrPEM)
+	err := writeFile(pemPath, csrPEM)
 	if err != nil {
-		return fmt.Errorf("failed to write CSR to %q: %s", config.Outputs.CSRPath, err)
+		return fmt.Errorf("failed to write CSR to %q: %w", pemPath, err)
+	}
+	log.Printf("CSR written to %q\n", pemPath)
+
+	if derPath != "" {
+		err = writeFile(derPath, csrDER)
+		if err != nil {
+			return fmt.Errorf("failed to write DER encoded CSR to %q: %w", derPath, err)
+		}
+		log.Printf("DER encoded CSR written to %q\n", derPath)
 	}
-	log.Printf("CSR written to %q\n", config.Outputs.CSRPath)
 
 	return nil
 }
 
-func keyCeremony(configBytes []byte) error {
+func keyCeremony(configBytes []byte, forceInit bool, soPIN string) error {
 	var config keyConfig
 	err := strictyaml.Unmarshal(configBytes, &config)
 	if err != nil {
-		return fmt.Errorf("failed to parse config: %s", err)
+		return configErrorf("failed to parse config: %w", err)
+	}
+	err = config.PKCS11.setSOPIN(soPIN)
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
 	}
 	err = config.validate()
 	if err != nil {
-		return fmt.Errorf("failed to validate config: %s", err)
+		return configErrorf("failed to validate config: %w", err)
+	}
+	if config.PKCS11.InitToken != nil {
+		err = initToken(config.PKCS11.Module, config.PKCS11.StoreSlot, config.PKCS11.PIN, *config.PKCS11.InitToken, forceInit)
+		if err != nil {
+			return err
+		}
 	}
-	session, err := pkcs11helpers.Initialize(config.PKCS11.Module, config.PKCS11.StoreSlot, config.PKCS11.PIN)
+	session, err := initializeSession(config.PKCS11.Module, config.PKCS11.StoreSlot, config.PKCS11.sessionUserType(), config.PKCS11.PIN)
 	if err != nil {
-		return fmt.Errorf("failed to setup session and PKCS#11 context for slot %d: %s", config.PKCS11.StoreSlot, err)
+		return hsmErrorf("failed to setup session and PKCS#11 context for slot %d: %w", config.PKCS11.StoreSlot, err)
 	}
 	log.Printf("Opened PKCS#11 session for slot %d\n", config.PKCS11.StoreSlot)
-	if _, err = generateKey(session, config.PKCS11.StoreLabel, config.Outputs.PublicKeyPath, config.Key); err != nil {
-		return err
+	keys := config.keys()
+	for i, key := range keys {
+		if len(keys) > 1 {
+			log.Printf("Generating key %d of %d, with label %q\n", i+1, len(keys), key.PKCS11.StoreLabel)
+		}
+		err = generateKeyOutputs(session, key)
+		if err != nil {
+			if len(config.Keys) != 0 {
+				return fmt.Errorf("keys[%d]: %w", i, err)
+			}
+			return err
+		}
 	}
+	return nil
+}
 
-	if config.Outputs.PKCS11ConfigPath != "" {
-		contents := fmt.Sprintf(
-			`{"module": %q, "tokenLabel": %q, "pin": %q}`,
-			config.PKCS11.Module, config.PKCS11.StoreLabel, config.PKCS11.PIN,
-		)
-		err = writeFile(config.Outputs.PKCS11ConfigPath, []byte(contents))
+// generateKeyOutputs generates the key described by config, which must be a
+// single key, in session and writes its outputs.
+func generateKeyOutputs(session *pkcs11helpers.Session, config keyConfig) error {
+	keyInfo, err := generateKey(session, config.PKCS11.StoreLabel, config.Outputs.PublicKeyPath, config.Key, config.PKCS11.keygenMechanism(config.Key.Type))
+	if err != nil {
+		return err
+	}
+	if config.Outputs.PKCS1PublicKeyPath != "" {
+		err = writePKCS1PublicKey(keyInfo.key, config.Outputs.PKCS1PublicKeyPath)
 		if err != nil {
 			return err
 		}
 	}
 
+	return writePKCS11Config(config.PKCS11, config.Outputs.PKCS11ConfigPath)
+}
+
+func wrappingKeyCeremony(configBytes []byte, forceInit bool, soPIN string) error {
+	var config wrappingKeyConfig
+	err := strictyaml.Unmarshal(configBytes, &config)
+	if err != nil {
+		return configErrorf("failed to parse config: %w", err)
+	}
+	err = config.PKCS11.setSOPIN(soPIN)
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	err = config.validate()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	if config.PKCS11.InitToken != nil {
+		err = initToken(config.PKCS11.Module, config.PKCS11.StoreSlot, config.PKCS11.PIN, *config.PKCS11.InitToken, forceInit)
+		if err != nil {
+			return err
+		}
+	}
+	session, err := initializeSession(config.PKCS11.Module, config.PKCS11.StoreSlot, config.PKCS11.sessionUserType(), config.PKCS11.PIN)
+	if err != nil {
+		return hsmErrorf("failed to setup session and PKCS#11 context for slot %d: %w", config.PKCS11.StoreSlot, err)
+	}
+	log.Printf("Opened PKCS#11 session for slot %d\n", config.PKCS11.StoreSlot)
+	_, err = aesGenerate(session, config.PKCS11.StoreLabel, config.Key.AESKeyLength)
+	if err != nil {
+		return fmt.Errorf("failed to generate AES wrapping key: %w", err)
+	}
+	log.Printf("Wrapping key stored with label %q in slot %d\n", config.PKCS11.StoreLabel, config.PKCS11.StoreSlot)
 	return nil
 }
 
-func ocspRespCeremony(configBytes []byte) error {
-	var config ocspRespConfig
+func pkcs11ConfigCeremony(configBytes []byte) error {
+	var config pkcs11ConfigConfig
 	err := strictyaml.Unmarshal(configBytes, &config)
 	if err != nil {
-		return fmt.Errorf("failed to parse config: %s", err)
+		return configErrorf("failed to parse config: %w", err)
 	}
 	err = config.validate()
 	if err != nil {
-		return fmt.Errorf("failed to validate config: %s", err)
+		return configErrorf("failed to validate config: %w", err)
+	}
+	session, err := initializeSession(config.PKCS11.Module, config.PKCS11.StoreSlot, config.PKCS11.sessionUserType(), config.PKCS11.PIN)
+	if err != nil {
+		return hsmErrorf("failed to setup session and PKCS#11 context for slot %d: %w", config.PKCS11.StoreSlot, err)
+	}
+	log.Printf("Opened PKCS#11 session for slot %d\n", config.PKCS11.StoreSlot)
+
+	pubKey, err := getPublicKeyByLabel(session, config.PKCS11.StoreLabel)
+	if err != nil {
+		return fmt.Errorf("failed to find key with label %q in slot %d: %w", config.PKCS11.StoreLabel, config.PKCS11.StoreSlot, err)
+	}
+	// Check that the private key can be found as it would be for signing, so
+	// that the config isn't written for a key which can't be used.
+	_, err = session.NewSigner(config.PKCS11.StoreLabel, pubKey)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve private key handle: %w", err)
+	}
+
+	_, err = writePublicKey(pubKey, config.Outputs.PublicKeyPath)
+	if err != nil {
+		return err
 	}
+	return writePKCS11Config(config.PKCS11, config.Outputs.PKCS11ConfigPath)
+}
+
+// writePKCS11Config writes a JSON PKCS#11 config for the generated key to path,
+// if path is set.
+func writePKCS11Config(config PKCS11KeyGenConfig, path string) error {
+	if path == "" {
+		return nil
+	}
+	contents := fmt.Sprintf(
+		`{"module": %q, "tokenLabel": %q, "pin": %q}`,
+		config.Module, config.StoreLabel, config.PIN,
+	)
+	return writeFile(path, []byte(contents))
+}
 
-	cert, err := loadCert(config.Inputs.CertificatePath)
+func ocspRespCeremony(configBytes []byte) error {
+	var config ocspRespConfig
+	err := strictyaml.Unmarshal(configBytes, &config)
+	if err != nil {
+		return configErrorf("failed to parse config: %w", err)
+	}
+	err = config.validate()
 	if err != nil {
-		return fmt.Errorf("failed to load certificate %q: %s", config.Inputs.CertificatePath, err)
+		return configErrorf("failed to validate config: %w", err)
 	}
+	return writeOCSPResponses(config)
+}
+
+// writeOCSPResponses signs and writes every response configured by config, all
+// with the same signing key.
+func writeOCSPResponses(config ocspRespConfig) error {
 	issuer, err := loadCert(config.Inputs.IssuerCertificatePath)
 	if err != nil {
-		return fmt.Errorf("failed to load issuer certificate %q: %s", config.Inputs.IssuerCertificatePath, err)
+		return fmt.Errorf("failed to load issuer certificate %q: %w", config.Inputs.IssuerCertificatePath, err)
+	}
+	// Every certificate is loaded and checked against the issuer before the
+	// PKCS#11 session is opened, so that a certificate from another issuer
+	// doesn't leave only some of the responses behind.
+	responses := config.responses()
+	certs := make([]*x509.Certificate, len(responses))
+	for i, resp := range responses {
+		certs[i], err = loadCert(resp.CertificatePath)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate %q: %w", resp.CertificatePath, err)
+		}
+		err = certs[i].CheckSignatureFrom(issuer)
+		if err != nil {
+			return configErrorf("certificate %q was not issued by inputs.issuer-certificate-path %q: %w", resp.CertificatePath, config.Inputs.IssuerCertificatePath, err)
+		}
 	}
 	var signer crypto.Signer
 	var delegatedIssuer *x509.Certificate
 	if config.Inputs.DelegatedIssuerCertificatePath != "" {
 		delegatedIssuer, err = loadCert(config.Inputs.DelegatedIssuerCertificatePath)
 		if err != nil {
-			return fmt.Errorf("failed to load delegated issuer certificate %q: %s", config.Inputs.DelegatedIssuerCertificatePath, err)
+			return fmt.Errorf("failed to load delegated issuer certificate %q: %w", config.Inputs.DelegatedIssuerCertificatePath, err)
 		}
 
 		signer, _, err = openSigner(config.PKCS11, delegatedIssuer.PublicKey)
@@ -873,31 +2408,81 @@ func ocspRespCeremony(configBytes []byte) error {
 
 	thisUpdate, err := time.Parse(time.DateTime, config.OCSPProfile.ThisUpdate)
 	if err != nil {
-		return fmt.Errorf("unable to parse ocsp-profile.this-update: %s", err)
+		return fmt.Errorf("unable to parse ocsp-profile.this-update: %w", err)
 	}
 	nextUpdate, err := time.Parse(time.DateTime, config.OCSPProfile.NextUpdate)
 	if err != nil {
-		return fmt.Errorf("unable to parse ocsp-profile.next-update: %s", err)
+		return fmt.Errorf("unable to parse ocsp-profile.next-update: %w", err)
 	}
-	var status int
-	switch config.OCSPProfile.Status {
-	case "good":
-		status = int(ocsp.Good)
-	case "revoked":
-		status = int(ocsp.Revoked)
-	default:
-		// this shouldn't happen if the config is validated
-		return fmt.Errorf("unexpected ocsp-profile.stats: %s", config.OCSPProfile.Status)
+	var archiveCutoff time.Time
+	if config.OCSPProfile.ArchiveCutoff != "" {
+		archiveCutoff, err = time.Parse(time.DateTime, config.OCSPProfile.ArchiveCutoff)
+		if err != nil {
+			return fmt.Errorf("unable to parse ocsp-profile.archive-cutoff: %w", err)
+		}
 	}
 
-	resp, err := generateOCSPResponse(signer, issuer, delegatedIssuer, cert, thisUpdate, nextUpdate, status)
-	if err != nil {
-		return err
+	issuerHash := crypto.SHA1
+	if config.OCSPProfile.HashAlgorithm != "" {
+		issuerHash = ocspIssuerHashes[config.OCSPProfile.HashAlgorithm]
 	}
 
-	err = writeFile(config.Outputs.ResponsePath, resp)
+	nonce, err := hex.DecodeString(config.OCSPProfile.Nonce)
 	if err != nil {
-		return fmt.Errorf("failed to write OCSP response to %q: %s", config.Outputs.ResponsePath, err)
+		return fmt.Errorf("unable to parse ocsp-profile.nonce: %w", err)
+	}
+
+	var chain []*x509.Certificate
+	if config.OCSPProfile.IncludeChain {
+		chain, err = loadCertBundle(config.Inputs.DelegatedIssuerBundlePath)
+		if err != nil {
+			return fmt.Errorf("failed to load delegated issuer bundle %q: %w", config.Inputs.DelegatedIssuerBundlePath, err)
+		}
+	}
+
+	for i, respConfig := range responses {
+		var status int
+		var revokedAt time.Time
+		switch respConfig.Status {
+		case "good":
+			status = int(ocsp.Good)
+		case "revoked":
+			status = int(ocsp.Revoked)
+			if respConfig.RevocationTime != "" {
+				revokedAt, err = time.Parse(time.DateTime, respConfig.RevocationTime)
+				if err != nil {
+					return fmt.Errorf("unable to parse revocation-time of %q: %w", respConfig.CertificatePath, err)
+				}
+			}
+		default:
+			// this shouldn't happen if the config is validated
+			return fmt.Errorf("unexpected ocsp-profile.stats: %s", respConfig.Status)
+		}
+
+		resp, err := generateOCSPResponse(signer, issuer, delegatedIssuer, certs[i], thisUpdate, nextUpdate, archiveCutoff, status, revokedAt, issuerHash, config.OCSPProfile.ResponderID == "by-key", nonce)
+		if err != nil {
+			return err
+		}
+		if config.OCSPProfile.IncludeChain {
+			resp, err = includeOCSPResponderChain(resp, delegatedIssuer, chain)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = writeFile(respConfig.responsePath, encodeOCSPResponse(resp))
+		if err != nil {
+			return fmt.Errorf("failed to write OCSP response to %q: %w", respConfig.responsePath, err)
+		}
+		log.Printf("OCSP response written to %q\n", respConfig.responsePath)
+
+		if respConfig.responseDERPath != "" {
+			err = writeFile(respConfig.responseDERPath, resp)
+			if err != nil {
+				return fmt.Errorf("failed to write DER encoded OCSP response to %q: %w", respConfig.responseDERPath, err)
+			}
+			log.Printf("DER encoded OCSP response written to %q\n", respConfig.responseDERPath)
+		}
 	}
 
 	return nil
@@ -907,16 +2492,47 @@ func crlCeremony(configBytes []byte) error {
 	var config crlConfig
 	err := strictyaml.Unmarshal(configBytes, &config)
 	if err != nil {
-		return fmt.Errorf("failed to parse config: %s", err)
+		return configErrorf("failed to parse config: %w", err)
+	}
+	err = config.validate()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+	return writeCRL(config)
+}
+
+func multiCRLCeremony(configBytes []byte) error {
+	var config multiCRLConfig
+	err := strictyaml.Unmarshal(configBytes, &config)
+	if err != nil {
+		return configErrorf("failed to parse config: %w", err)
 	}
 	err = config.validate()
 	if err != nil {
-		return fmt.Errorf("failed to validate config: %s", err)
+		return configErrorf("failed to validate config: %w", err)
+	}
+	return writeCRLs(config.CRLs)
+}
+
+// writeCRLs generates and writes each of the given CRLs in turn, stopping at
+// the first failure.
+func writeCRLs(configs []crlConfig) error {
+	for i, config := range configs {
+		log.Printf("Generating CRL %d of %d, issued by %q\n", i+1, len(configs), config.Inputs.IssuerCertificatePath)
+		err := writeCRL(config)
+		if err != nil {
+			return fmt.Errorf("crls[%d]: %w", i, err)
+		}
 	}
+	return nil
+}
 
+// writeCRL generates, signs, and writes the CRL described by a validated
+// config.
+func writeCRL(config crlConfig) error {
 	issuer, err := loadCert(config.Inputs.IssuerCertificatePath)
 	if err != nil {
-		return fmt.Errorf("failed to load issuer certificate %q: %s", config.Inputs.IssuerCertificatePath, err)
+		return fmt.Errorf("failed to load issuer certificate %q: %w", config.Inputs.IssuerCertificatePath, err)
 	}
 	signer, _, err := openSigner(config.PKCS11, issuer.PublicKey)
 	if err != nil {
@@ -925,39 +2541,59 @@ func crlCeremony(configBytes []byte) error {
 
 	thisUpdate, err := time.Parse(time.DateTime, config.CRLProfile.ThisUpdate)
 	if err != nil {
-		return fmt.Errorf("unable to parse crl-profile.this-update: %s", err)
+		return fmt.Errorf("unable to parse crl-profile.this-update: %w", err)
 	}
 	nextUpdate, err := time.Parse(time.DateTime, config.CRLProfile.NextUpdate)
 	if err != nil {
-		return fmt.Errorf("unable to parse crl-profile.next-update: %s", err)
+		return fmt.Errorf("unable to parse crl-profile.next-update: %w", err)
 	}
 
 	var revokedCertificates []x509.RevocationListEntry
 	for _, rc := range config.CRLProfile.RevokedCertificates {
 		cert, err := loadCert(rc.CertificatePath)
 		if err != nil {
-			return fmt.Errorf("failed to load revoked certificate %q: %s", rc.CertificatePath, err)
+			return fmt.Errorf("failed to load revoked certificate %q: %w", rc.CertificatePath, err)
 		}
 		revokedAt, err := time.Parse(time.DateTime, rc.RevocationDate)
 		if err != nil {
 			return fmt.Errorf("unable to parse crl-profile.revoked-certificates.revocation-date")
 		}
-		revokedCert := x509.RevocationListEntry{
-			SerialNumber:   cert.SerialNumber,
-			RevocationTime: revokedAt,
+		var invalidityDate time.Time
+		if rc.InvalidityDate != "" {
+			invalidityDate, err = time.Parse(time.DateTime, rc.InvalidityDate)
+			if err != nil {
+				return fmt.Errorf("unable to parse crl-profile.revoked-certificates.invalidity-date")
+			}
 		}
-		encReason, err := asn1.Marshal(rc.RevocationReason)
+		revokedCert, err := revocationListEntry(cert, revokedAt, rc.RevocationReason, invalidityDate)
 		if err != nil {
-			return fmt.Errorf("failed to marshal revocation reason %q: %s", rc.RevocationReason, err)
+			return err
 		}
-		revokedCert.Extensions = []pkix.Extension{{
-			Id:    asn1.ObjectIdentifier{2, 5, 29, 21}, // id-ce-reasonCode
-			Value: encReason,
-		}}
 		revokedCertificates = append(revokedCertificates, revokedCert)
 	}
+	if config.CRLProfile.RevokedCertificatesDir != nil {
+		dirConfig := config.CRLProfile.RevokedCertificatesDir
+		revokedAt := thisUpdate
+		if dirConfig.RevocationDate != "" {
+			revokedAt, err = time.Parse(time.DateTime, dirConfig.RevocationDate)
+			if err != nil {
+				return fmt.Errorf("unable to parse crl-profile.revoked-certificates-dir.revocation-date")
+			}
+		}
+		certs, err := loadRevokedCertificatesDir(dirConfig.Path)
+		if err != nil {
+			return err
+		}
+		for _, cert := range certs {
+			revokedCert, err := revocationListEntry(cert, revokedAt, dirConfig.RevocationReason, time.Time{})
+			if err != nil {
+				return err
+			}
+			revokedCertificates = append(revokedCertificates, revokedCert)
+		}
+	}
 
-	crlBytes, err := generateCRL(signer, issuer, thisUpdate, nextUpdate, config.CRLProfile.Number, revokedCertificates)
+	crlBytes, err := generateCRL(signer, issuer, thisUpdate, nextUpdate, config.CRLProfile.Number, config.CRLProfile.BaseCRLNumber, revokedCertificates)
 	if err != nil {
 		return err
 	}
@@ -966,22 +2602,202 @@ func crlCeremony(configBytes []byte) error {
 
 	err = writeFile(config.Outputs.CRLPath, crlBytes)
 	if err != nil {
-		return fmt.Errorf("failed to write CRL to %q: %s", config.Outputs.CRLPath, err)
+		return fmt.Errorf("failed to write CRL to %q: %w", config.Outputs.CRLPath, err)
+	}
+
+	return nil
+}
+
+func renewCeremony(configBytes []byte) error {
+	var config renewConfig
+	err := strictyaml.Unmarshal(configBytes, &config)
+	if err != nil {
+		return configErrorf("failed to parse config: %w", err)
+	}
+	log.Printf("Preparing renew ceremony for %s\n", config.Outputs.CertificatePath)
+	err = config.validate()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+
+	notBefore, err := time.Parse(time.DateTime, config.Validity.NotBefore)
+	if err != nil {
+		return configErrorf("unable to parse validity.not-before: %w", err)
+	}
+	notAfter, err := parseNotAfter(config.Validity.NotAfter)
+	if err != nil {
+		return configErrorf("unable to parse validity.not-after: %w", err)
+	}
+	if !notAfter.After(notBefore) {
+		return configErrorf("validity.not-after must be after validity.not-before")
+	}
+
+	existing, err := loadCert(config.Inputs.CertificatePath)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %q: %w", config.Inputs.CertificatePath, err)
+	}
+	issuer, err := loadCert(config.Inputs.IssuerCertificatePath)
+	if err != nil {
+		return fmt.Errorf("failed to load issuer certificate %q: %w", config.Inputs.IssuerCertificatePath, err)
+	}
+	// Ensure that the configured issuer is the one which issued the existing
+	// certificate, so that the renewal is signed by the same key.
+	if !bytes.Equal(existing.RawIssuer, issuer.RawSubject) {
+		return fmt.Errorf("mismatch between issuer RawSubject and certificate RawIssuer DER bytes: \"%x\" != \"%x\"", issuer.RawSubject, existing.RawIssuer)
+	}
+	err = existing.CheckSignatureFrom(issuer)
+	if err != nil {
+		return fmt.Errorf("certificate %q was not signed by issuer certificate %q: %w", config.Inputs.CertificatePath, config.Inputs.IssuerCertificatePath, err)
+	}
+	signer, randReader, err := openSigner(config.PKCS11, issuer.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	serialBytes := make([]byte, 16)
+	_, err = randReader.Read(serialBytes)
+	if err != nil {
+		return fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	serial := big.NewInt(0).SetBytes(serialBytes)
+	tbs, sigAlgID, err := renewTBS(existing.Raw, serial, notBefore, notAfter)
+	if err != nil {
+		return fmt.Errorf("failed to renew certificate: %w", err)
+	}
+	// The linted TBSCertificate is exactly the one which is signed, apart from
+	// the subject public key of a self-signed certificate, which the linter
+	// replaces with its own.
+	selfSigned := bytes.Equal(existing.RawSubjectPublicKeyInfo, issuer.RawSubjectPublicKeyInfo)
+	template := renewTemplate(existing, serial, notBefore, notAfter)
+	modify := renewModifier(tbs, existing.SignatureAlgorithm, sigAlgID, selfSigned)
+	lintCert, _, err := issueModifiedLintCert(template, issuer, existing.PublicKey, signer, modify, config.SkipLints, config.Outputs.LintReportPath)
+	if err != nil {
+		return err
+	}
+
+	logSubjectSPKIHash(existing.RawSubjectPublicKeyInfo)
+	certBytes, err := signLintedTBSCertificate(lintCert.Raw, existing.RawSubjectPublicKeyInfo, existing.SignatureAlgorithm, signer)
+	if err != nil {
+		return fmt.Errorf("failed to renew certificate: %w", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
+	log.Printf("Signed certificate PEM:\n%s", pemBytes)
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse signed certificate: %w", err)
+	}
+	err = cert.CheckSignatureFrom(issuer)
+	if err != nil {
+		return fmt.Errorf("failed to verify certificate signature: %w", err)
+	}
+	// Ensure that the renewed certificate differs from the existing one only
+	// in its serial number and validity period, and that it is the
+	// certificate which was linted.
+	tbsDER, err := asn1.Marshal(*tbs)
+	if err != nil {
+		return fmt.Errorf("failed to encode renewed tbsCertificate: %w", err)
+	}
+	if !bytes.Equal(tbsDER, cert.RawTBSCertificate) {
+		return fmt.Errorf("mismatch between renewed and signed RawTBSCertificate DER bytes: \"%x\" != \"%x\"", tbsDER, cert.RawTBSCertificate)
+	}
+	if !selfSigned && !bytes.Equal(lintCert.RawTBSCertificate, cert.RawTBSCertificate) {
+		return fmt.Errorf("mismatch between lintCert and renewed certificate RawTBSCertificate DER bytes: \"%x\" != \"%x\"", lintCert.RawTBSCertificate, cert.RawTBSCertificate)
+	}
+	err = writeFile(config.Outputs.CertificatePath, pemBytes)
+	if err != nil {
+		return fmt.Errorf("failed to write certificate to %q: %w", config.Outputs.CertificatePath, err)
 	}
+	log.Printf("Certificate written to %q\n", config.Outputs.CertificatePath)
 
 	return nil
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		err := lintMain(os.Args[2:])
+		if err != nil {
+			fatalf(exitCodeFor(err), "Linting failed: %s", err)
+		}
+		return
+	}
+
 	configPath := flag.String("config", "", "Path to ceremony configuration file")
+	forceInit := flag.Bool("force-init", false, "Re-initialize a token configured with pkcs11.init-token even if it already contains objects")
+	softwareKeyPath := flag.String("software-key", "", "For testing only: path to a PEM private key to sign with instead of a PKCS#11 token, for the intermediate, ocsp-signer, crl-signer, cross-certificate, bundle-export, and bundle-import ceremonies")
+	allowNonstandard := flag.Bool("allow-nonstandard", false, "Allow certificate profile fields which produce certificates that violate RFC 5280, for building test corpora")
+	promptPIN := flag.Bool("prompt-pin", false, "Read the PKCS#11 PIN from the terminal, without echoing it, instead of from pkcs11.pin in the config")
+	pinFD := flag.Int("pin-fd", -1, "Read the PKCS#11 PIN from the first line of this open file descriptor instead of from pkcs11.pin in the config")
+	soPINFD := flag.Int("so-pin-fd", -1, "Read the security officer PIN for pkcs11.init-token from the next line of this open file descriptor instead of from pkcs11.init-token.so-pin-env-var")
+	dryRunFlag := flag.Bool("dry-run", false, "Validate the config, its input and output paths, and its certificate profile against a dummy key, then exit without opening a PKCS#11 session")
+	verifyFlag := flag.Bool("verify", false, "Check that the certificate written by a root, key-and-root, intermediate, ocsp-signer, crl-signer, or cross-certificate ceremony matches its config and is signed by its issuer, then exit")
+	fromCSR := flag.String("from-csr", "", "Path to a PEM CSR to take the subject public key, subject alternative names, and any unset subject fields from, for the intermediate, ocsp-signer, and crl-signer ceremonies")
+	emitTBS := flag.String("emit-tbs", "", "With --dry-run, write the unsigned DER encoded TBSCertificate of the linted dummy certificate to this path, for review before the ceremony")
+	explainLintsType := flag.String("explain-lints", "", "Print the lints run against root, intermediate, or subscriber certificates and exit")
+	enableLintsFlag := flag.String("enable-lints", "", "Comma separated list of lints which are disabled by default to run against every linted certificate, such as e_cert_extensions_not_canonical_order")
 	flag.Parse()
 
+	if *enableLintsFlag != "" {
+		enableLints = strings.Split(*enableLintsFlag, ",")
+		err := linter.CheckEnableLints(enableLints)
+		if err != nil {
+			fatalf(exitConfig, "Invalid --enable-lints: %s", err)
+		}
+	}
+
+	if *explainLintsType != "" {
+		err := explainLints(os.Stdout, *explainLintsType)
+		if err != nil {
+			fatalf(exitConfig, "Failed to explain lints: %s", err)
+		}
+		return
+	}
+
 	if *configPath == "" {
-		log.Fatal("--config is required")
+		fatalf(exitConfig, "--config is required")
 	}
 	configBytes, err := os.ReadFile(*configPath)
 	if err != nil {
-		log.Fatalf("Failed to read config file: %s", err)
+		fatalf(exitConfig, "Failed to read config file: %s", err)
+	}
+	// A PIN read from the terminal or a file descriptor is injected into
+	// configBytes, but never into fileBytes.
+	fileBytes := configBytes
+	if *promptPIN && *pinFD != -1 {
+		fatalf(exitConfig, "--prompt-pin and --pin-fd cannot both be used")
+	}
+	// The same file descriptor may deliver both PINs, one per line.
+	pinFiles := make(map[int]*os.File)
+	for _, fd := range []int{*pinFD, *soPINFD} {
+		if fd == -1 || pinFiles[fd] != nil {
+			continue
+		}
+		pinFiles[fd], err = openPINFD(fd)
+		if err != nil {
+			fatalf(exitConfig, "Failed to open PIN file descriptor: %s", err)
+		}
+	}
+	if *promptPIN || *pinFD != -1 {
+		// The PIN is zeroed once it has been copied into the config, but the
+		// copies made while parsing the config and logging in to the token are
+		// Go strings, which can't be.
+		configBytes, err = setConfigPIN(configBytes, func() ([]byte, error) {
+			if *pinFD != -1 {
+				return readPIN(pinFiles[*pinFD], io.Discard)
+			}
+			return readPIN(os.Stdin, os.Stderr)
+		})
+		if err != nil {
+			fatalf(exitCodeFor(err), "Failed to set PIN: %s", err)
+		}
+	}
+	var soPIN string
+	if *soPINFD != -1 {
+		pin, err := readPIN(pinFiles[*soPINFD], io.Discard)
+		if err != nil {
+			fatalf(exitConfig, "Failed to read security officer PIN: %s", err)
+		}
+		soPIN = string(pin)
+		clear(pin)
 	}
 	var ct struct {
 		CeremonyType string `yaml:"ceremony-type"`
@@ -993,56 +2809,142 @@ func main() {
 	// inside the switch statement.
 	err = yaml.Unmarshal(configBytes, &ct)
 	if err != nil {
-		log.Fatalf("Failed to parse config: %s", err)
+		fatalf(exitConfig, "Failed to parse config: %s", err)
+	}
+
+	switch ct.CeremonyType {
+	case "intermediate", "ocsp-signer", "crl-signer", "cross-certificate", "bundle-export", "bundle-import":
+	default:
+		if *softwareKeyPath != "" {
+			fatalf(exitConfig, "--software-key is not supported by the %s ceremony", ct.CeremonyType)
+		}
+	}
+	switch ct.CeremonyType {
+	case "root", "key-and-root", "key", "wrapping-key":
+	default:
+		if soPIN != "" {
+			fatalf(exitConfig, "--so-pin-fd is not supported by the %s ceremony", ct.CeremonyType)
+		}
+	}
+
+	switch ct.CeremonyType {
+	case "intermediate", "ocsp-signer", "crl-signer":
+		if *fromCSR != "" && *dryRunFlag {
+			fatalf(exitConfig, "--from-csr cannot be used with --dry-run")
+		}
+	default:
+		if *fromCSR != "" {
+			fatalf(exitConfig, "--from-csr is not supported by the %s ceremony", ct.CeremonyType)
+		}
+	}
+	if *verifyFlag {
+		if *dryRunFlag {
+			fatalf(exitConfig, "--verify and --dry-run cannot both be used")
+		}
+		err = verifyCeremony(os.Stdout, configBytes, ct.CeremonyType)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Verification failed: %s", err)
+		}
+		return
+	}
+	if *emitTBS != "" && !*dryRunFlag {
+		fatalf(exitConfig, "--emit-tbs can only be used with --dry-run")
+	}
+	if *dryRunFlag {
+		err = dryRun(os.Stdout, configBytes, ct.CeremonyType, *allowNonstandard, *softwareKeyPath, soPIN, *emitTBS)
+		if err != nil {
+			fatalf(exitCodeFor(err), "Dry run failed: %s", err)
+		}
+		return
 	}
 
 	switch ct.CeremonyType {
 	case "root":
-		err = rootCeremony(configBytes)
+		err = rootCeremony(configBytes, fileBytes, *forceInit, *allowNonstandard, soPIN)
 		if err != nil {
-			log.Fatalf("root ceremony failed: %s", err)
+			fatalf(exitCodeFor(err), "root ceremony failed: %s", err)
+		}
+	case "key-and-root":
+		err = keyAndRootCeremony(configBytes, *forceInit, *allowNonstandard, soPIN)
+		if err != nil {
+			fatalf(exitCodeFor(err), "key-and-root ceremony failed: %s", err)
 		}
 	case "cross-certificate":
-		err = crossCertCeremony(configBytes, crossCert)
+		err = crossCertCeremony(configBytes, crossCert, *allowNonstandard, *softwareKeyPath)
 		if err != nil {
-			log.Fatalf("cross-certificate ceremony failed: %s", err)
+			fatalf(exitCodeFor(err), "cross-certificate ceremony failed: %s", err)
 		}
 	case "intermediate":
-		err = intermediateCeremony(configBytes, intermediateCert)
+		err = intermediateCeremony(configBytes, intermediateCert, *allowNonstandard, *softwareKeyPath, *fromCSR)
 		if err != nil {
-			log.Fatalf("intermediate ceremony failed: %s", err)
+			fatalf(exitCodeFor(err), "intermediate ceremony failed: %s", err)
 		}
 	case "cross-csr":
 		err = csrCeremony(configBytes)
 		if err != nil {
-			log.Fatalf("cross-csr ceremony failed: %s", err)
+			fatalf(exitCodeFor(err), "cross-csr ceremony failed: %s", err)
 		}
 	case "ocsp-signer":
-		err = intermediateCeremony(configBytes, ocspCert)
+		err = intermediateCeremony(configBytes, ocspCert, *allowNonstandard, *softwareKeyPath, *fromCSR)
 		if err != nil {
-			log.Fatalf("ocsp signer ceremony failed: %s", err)
+			fatalf(exitCodeFor(err), "ocsp signer ceremony failed: %s", err)
 		}
 	case "key":
-		err = keyCeremony(configBytes)
+		err = keyCeremony(configBytes, *forceInit, soPIN)
+		if err != nil {
+			fatalf(exitCodeFor(err), "key ceremony failed: %s", err)
+		}
+	case "wrapping-key":
+		err = wrappingKeyCeremony(configBytes, *forceInit, soPIN)
 		if err != nil {
-			log.Fatalf("key ceremony failed: %s", err)
+			fatalf(exitCodeFor(err), "wrapping-key ceremony failed: %s", err)
 		}
 	case "ocsp-response":
 		err = ocspRespCeremony(configBytes)
 		if err != nil {
-			log.Fatalf("ocsp response ceremony failed: %s", err)
+			fatalf(exitCodeFor(err), "ocsp response ceremony failed: %s", err)
 		}
 	case "crl":
 		err = crlCeremony(configBytes)
 		if err != nil {
-			log.Fatalf("crl ceremony failed: %s", err)
+			fatalf(exitCodeFor(err), "crl ceremony failed: %s", err)
 		}
 	case "crl-signer":
-		err = intermediateCeremony(configBytes, crlCert)
+		err = intermediateCeremony(configBytes, crlCert, *allowNonstandard, *softwareKeyPath, *fromCSR)
+		if err != nil {
+			fatalf(exitCodeFor(err), "crl signer ceremony failed: %s", err)
+		}
+	case "renew":
+		err = renewCeremony(configBytes)
+		if err != nil {
+			fatalf(exitCodeFor(err), "renew ceremony failed: %s", err)
+		}
+	case "seed-hierarchy":
+		err = seedHierarchyCeremony(configBytes)
+		if err != nil {
+			fatalf(exitCodeFor(err), "seed-hierarchy ceremony failed: %s", err)
+		}
+	case "pkcs11-config":
+		err = pkcs11ConfigCeremony(configBytes)
+		if err != nil {
+			fatalf(exitCodeFor(err), "pkcs11-config ceremony failed: %s", err)
+		}
+	case "multi-crl":
+		err = multiCRLCeremony(configBytes)
+		if err != nil {
+			fatalf(exitCodeFor(err), "multi-crl ceremony failed: %s", err)
+		}
+	case "bundle-export":
+		err = bundleExportCeremony(configBytes, *allowNonstandard, *softwareKeyPath)
+		if err != nil {
+			fatalf(exitCodeFor(err), "bundle-export ceremony failed: %s", err)
+		}
+	case "bundle-import":
+		err = bundleImportCeremony(configBytes, *allowNonstandard, *softwareKeyPath)
 		if err != nil {
-			log.Fatalf("crl signer ceremony failed: %s", err)
+			fatalf(exitCodeFor(err), "bundle-import ceremony failed: %s", err)
 		}
 	default:
-		log.Fatalf("unknown ceremony-type, must be one of: root, cross-certificate, intermediate, cross-csr, ocsp-signer, key, ocsp-response, crl, crl-signer")
+		fatalf(exitConfig, "unknown ceremony-type, must be one of: root, key-and-root, cross-certificate, intermediate, cross-csr, ocsp-signer, key, wrapping-key, pkcs11-config, ocsp-response, crl, multi-crl, crl-signer, renew, seed-hierarchy, bundle-export, bundle-import")
 	}
 }
@@ -1,11 +1,30 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
 	"fmt"
 	"io/fs"
+	"log"
+	"math/big"
+	"os"
+	"path"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/miekg/pkcs11"
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+	"github.com/letsencrypt/boulder/pkcs11helpers"
+	"github.com/letsencrypt/boulder/strictyaml"
 	"github.com/letsencrypt/boulder/test"
 )
 
@@ -24,6 +43,52 @@ func TestLoadPubKey(t *testing.T) {
 	test.AssertError(t, err, "should have failed when trying to parse a certificate")
 }
 
+func TestCheckExpectedPublicKey(t *testing.T) {
+	pub, _, err := loadPubKey("../../test/test-root.pubkey.pem")
+	test.AssertNotError(t, err, "failed to load test public key")
+
+	err = checkExpectedPublicKey("../../test/test-root.pubkey.pem", pub)
+	test.AssertNotError(t, err, "should not have errored with matching recorded key")
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	err = checkExpectedPublicKey("../../test/test-root.pubkey.pem", k.Public())
+	test.AssertError(t, err, "should have errored with mismatching recorded key")
+	test.AssertContains(t, err.Error(), "does not match the recorded public key")
+
+	err = checkExpectedPublicKey("../../test/hierarchy/int-e1.cert.pem", pub)
+	test.AssertError(t, err, "should have errored with an unparseable recorded key")
+	test.AssertContains(t, err.Error(), "failed to load pkcs11.expected-public-key-path")
+}
+
+func TestVerifyChainToTrustAnchor(t *testing.T) {
+	eeE1, err := loadCert("../../test/hierarchy/ee-e1.cert.pem")
+	test.AssertNotError(t, err, "failed to load test cert")
+	intE1, err := loadCert("../../test/hierarchy/int-e1.cert.pem")
+	test.AssertNotError(t, err, "failed to load test issuer")
+	eeR3, err := loadCert("../../test/hierarchy/ee-r3.cert.pem")
+	test.AssertNotError(t, err, "failed to load test cert")
+
+	err = verifyChainToTrustAnchor(eeE1, intE1, "", "../../test/hierarchy/root-x2.cert.pem")
+	test.AssertNotError(t, err, "should have built a chain to the issuer's root")
+
+	// The cross-signed X2 in the bundle completes the chain to X1.
+	err = verifyChainToTrustAnchor(eeE1, intE1, "../../test/hierarchy/root-x2-cross.cert.pem", "../../test/hierarchy/root-x1.cert.pem")
+	test.AssertNotError(t, err, "should have built a chain through the issuer bundle")
+
+	err = verifyChainToTrustAnchor(eeE1, intE1, "", "../../test/hierarchy/root-x1.cert.pem")
+	test.AssertError(t, err, "should have failed to build a chain without the issuer bundle")
+	test.AssertContains(t, err.Error(), "failed to build chain to trust anchor: x509: certificate signed by unknown authority")
+
+	err = verifyChainToTrustAnchor(eeR3, intE1, "", "../../test/hierarchy/root-x2.cert.pem")
+	test.AssertError(t, err, "should have failed to build a chain through the wrong issuer")
+	test.AssertContains(t, err.Error(), "failed to build chain to trust anchor: x509: certificate signed by unknown authority")
+
+	err = verifyChainToTrustAnchor(eeE1, intE1, "../../test/hierarchy/int-e1.key.pem", "../../test/hierarchy/root-x2.cert.pem")
+	test.AssertError(t, err, "should have failed to load a bundle containing a private key")
+	test.AssertContains(t, err.Error(), "failed to load issuer bundle")
+}
+
 func TestCheckOutputFileSucceeds(t *testing.T) {
 	dir := t.TempDir()
 	err := checkOutputFile(dir+"/example", "foo")
@@ -74,7 +139,7 @@ func TestKeyGenConfigValidate(t *testing.T) {
 			config: keyGenConfig{
 				Type: "doop",
 			},
-			expectedError: "key.type can only be 'rsa' or 'ecdsa'",
+			expectedError: "key.type can only be 'rsa', 'ecdsa', or 'mldsa'",
 		},
 		{
 			name: "bad key.rsa-mod-length",
@@ -110,6 +175,50 @@ func TestKeyGenConfigValidate(t *testing.T) {
 			},
 			expectedError: "if key.type = 'ecdsa' then key.rsa-mod-length is not used",
 		},
+		{
+			name: "key.type is rsa but key.mldsa-param-set is present",
+			config: keyGenConfig{
+				Type:          "rsa",
+				RSAModLength:  2048,
+				MLDSAParamSet: "65",
+			},
+			expectedError: "if key.type = 'rsa' then key.mldsa-param-set is not used",
+		},
+		{
+			name: "key.type is ecdsa but key.mldsa-param-set is present",
+			config: keyGenConfig{
+				Type:          "ecdsa",
+				ECDSACurve:    "P-256",
+				MLDSAParamSet: "65",
+			},
+			expectedError: "if key.type = 'ecdsa' then key.mldsa-param-set is not used",
+		},
+		{
+			name: "bad key.mldsa-param-set",
+			config: keyGenConfig{
+				Type:          "mldsa",
+				MLDSAParamSet: "50",
+			},
+			expectedError: "key.mldsa-param-set can only be '44', '65', or '87'",
+		},
+		{
+			name: "key.type is mldsa but key.rsa-mod-length is present",
+			config: keyGenConfig{
+				Type:          "mldsa",
+				MLDSAParamSet: "65",
+				RSAModLength:  2048,
+			},
+			expectedError: "if key.type = 'mldsa' then key.rsa-mod-length is not used",
+		},
+		{
+			name: "key.type is mldsa but key.ecdsa-curve is present",
+			config: keyGenConfig{
+				Type:          "mldsa",
+				MLDSAParamSet: "65",
+				ECDSACurve:    "P-256",
+			},
+			expectedError: "if key.type = 'mldsa' then key.ecdsa-curve is not used",
+		},
 		{
 			name: "good rsa config",
 			config: keyGenConfig{
@@ -124,6 +233,13 @@ func TestKeyGenConfigValidate(t *testing.T) {
 				ECDSACurve: "P-256",
 			},
 		},
+		{
+			name: "good mldsa config",
+			config: keyGenConfig{
+				Type:          "mldsa",
+				MLDSAParamSet: "87",
+			},
+		},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -167,6 +283,20 @@ func TestRootConfigValidate(t *testing.T) {
 			},
 			expectedError: "key.type is required",
 		},
+		{
+			name: "mldsa key",
+			config: rootConfig{
+				PKCS11: PKCS11KeyGenConfig{
+					Module:     "module",
+					StoreLabel: "label",
+				},
+				Key: keyGenConfig{
+					Type:          "mldsa",
+					MLDSAParamSet: "65",
+				},
+			},
+			expectedError: "key.type 'mldsa' is only supported by the key ceremony",
+		},
 		{
 			name: "no outputs.public-key-path",
 			config: rootConfig{
@@ -195,6 +325,9 @@ func TestRootConfigValidate(t *testing.T) {
 				Outputs: struct {
 					PublicKeyPath   string `yaml:"public-key-path"`
 					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
+					CheckpointPath  string `yaml:"checkpoint-path"`
 				}{
 					PublicKeyPath: "path",
 				},
@@ -215,6 +348,9 @@ func TestRootConfigValidate(t *testing.T) {
 				Outputs: struct {
 					PublicKeyPath   string `yaml:"public-key-path"`
 					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
+					CheckpointPath  string `yaml:"checkpoint-path"`
 				}{
 					PublicKeyPath:   "path",
 					CertificatePath: "path",
@@ -236,13 +372,16 @@ func TestRootConfigValidate(t *testing.T) {
 				Outputs: struct {
 					PublicKeyPath   string `yaml:"public-key-path"`
 					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
+					CheckpointPath  string `yaml:"checkpoint-path"`
 				}{
 					PublicKeyPath:   "path",
 					CertificatePath: "path",
 				},
 				CertProfile: certProfile{
-					NotBefore:          "a",
-					NotAfter:           "b",
+					NotBefore:          "2020-01-01 00:00:00",
+					NotAfter:           "2025-01-01 00:00:00",
 					SignatureAlgorithm: "c",
 					CommonName:         "d",
 					Organization:       "e",
@@ -261,6 +400,76 @@ func TestRootConfigValidate(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "omit-ski without skipping SKI lint",
+			config: rootConfig{
+				PKCS11: PKCS11KeyGenConfig{
+					Module:     "module",
+					StoreLabel: "label",
+				},
+				Key: keyGenConfig{
+					Type:         "rsa",
+					RSAModLength: 2048,
+				},
+				Outputs: struct {
+					PublicKeyPath   string `yaml:"public-key-path"`
+					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
+					CheckpointPath  string `yaml:"checkpoint-path"`
+				}{
+					PublicKeyPath:   "path",
+					CertificatePath: "path",
+				},
+				CertProfile: certProfile{
+					NotBefore:          "2020-01-01 00:00:00",
+					NotAfter:           "2025-01-01 00:00:00",
+					SignatureAlgorithm: "c",
+					CommonName:         "d",
+					Organization:       "e",
+					Country:            "f",
+					OmitSKI:            true,
+				},
+				SkipLints: []string{"n_ca_digital_signature_not_set"},
+			},
+			expectedError: "omit-ski requires skip-lints to contain \"e_ext_subject_key_identifier_missing_ca\"",
+		},
+		{
+			name: "good config with omit-ski",
+			config: rootConfig{
+				PKCS11: PKCS11KeyGenConfig{
+					Module:     "module",
+					StoreLabel: "label",
+				},
+				Key: keyGenConfig{
+					Type:         "rsa",
+					RSAModLength: 2048,
+				},
+				Outputs: struct {
+					PublicKeyPath   string `yaml:"public-key-path"`
+					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
+					CheckpointPath  string `yaml:"checkpoint-path"`
+				}{
+					PublicKeyPath:   "path",
+					CertificatePath: "path",
+				},
+				CertProfile: certProfile{
+					NotBefore:          "2020-01-01 00:00:00",
+					NotAfter:           "2025-01-01 00:00:00",
+					SignatureAlgorithm: "c",
+					CommonName:         "d",
+					Organization:       "e",
+					Country:            "f",
+					OmitSKI:            true,
+				},
+				SkipLints: []string{
+					"e_ext_subject_key_identifier_missing_ca",
+					"n_ca_digital_signature_not_set",
+				},
+			},
+		},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -302,7 +511,27 @@ func TestIntermediateConfigValidate(t *testing.T) {
 					SigningLabel: "label",
 				},
 			},
-			expectedError: "inputs.public-key-path is required",
+			expectedError: "one of inputs.public-key-path or inputs.public-key-label is required",
+		},
+		{
+			name: "inputs.public-key-path and inputs.public-key-label",
+			config: intermediateConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
+					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
+				}{
+					PublicKeyPath:  "path",
+					PublicKeyLabel: "label",
+				},
+			},
+			expectedError: "inputs.public-key-path and inputs.public-key-label cannot both be set",
 		},
 		{
 			name: "no inputs.issuer-certificate-path",
@@ -312,14 +541,38 @@ func TestIntermediateConfigValidate(t *testing.T) {
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					PublicKeyPath         string `yaml:"public-key-path"`
-					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
+					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 				}{
 					PublicKeyPath: "path",
 				},
 			},
 			expectedError: "inputs.issuer-certificate is required",
 		},
+		{
+			name: "inputs.issuer-bundle-path without inputs.trust-anchor-certificate-path",
+			config: intermediateConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
+					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
+				}{
+					PublicKeyPath:         "path",
+					IssuerCertificatePath: "path",
+					IssuerBundlePath:      "path",
+				},
+			},
+			expectedError: "inputs.issuer-bundle-path requires inputs.trust-anchor-certificate-path",
+		},
 		{
 			name: "no outputs.certificate-path",
 			config: intermediateConfig{
@@ -328,8 +581,11 @@ func TestIntermediateConfigValidate(t *testing.T) {
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					PublicKeyPath         string `yaml:"public-key-path"`
-					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
+					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 				}{
 					PublicKeyPath:         "path",
 					IssuerCertificatePath: "path",
@@ -345,14 +601,19 @@ func TestIntermediateConfigValidate(t *testing.T) {
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					PublicKeyPath         string `yaml:"public-key-path"`
-					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
+					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 				}{
 					PublicKeyPath:         "path",
 					IssuerCertificatePath: "path",
 				},
 				Outputs: struct {
 					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
 				}{
 					CertificatePath: "path",
 				},
@@ -367,20 +628,25 @@ func TestIntermediateConfigValidate(t *testing.T) {
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					PublicKeyPath         string `yaml:"public-key-path"`
-					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
+					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 				}{
 					PublicKeyPath:         "path",
 					IssuerCertificatePath: "path",
 				},
 				Outputs: struct {
 					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
 				}{
 					CertificatePath: "path",
 				},
 				CertProfile: certProfile{
-					NotBefore:          "a",
-					NotAfter:           "b",
+					NotBefore:          "2020-01-01 00:00:00",
+					NotAfter:           "2025-01-01 00:00:00",
 					SignatureAlgorithm: "c",
 					CommonName:         "d",
 					Organization:       "e",
@@ -402,20 +668,25 @@ func TestIntermediateConfigValidate(t *testing.T) {
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					PublicKeyPath         string `yaml:"public-key-path"`
-					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
+					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 				}{
 					PublicKeyPath:         "path",
 					IssuerCertificatePath: "path",
 				},
 				Outputs: struct {
 					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
 				}{
 					CertificatePath: "path",
 				},
 				CertProfile: certProfile{
-					NotBefore:          "a",
-					NotAfter:           "b",
+					NotBefore:          "2020-01-01 00:00:00",
+					NotAfter:           "2025-01-01 00:00:00",
 					SignatureAlgorithm: "c",
 					CommonName:         "d",
 					Organization:       "e",
@@ -429,6 +700,47 @@ func TestIntermediateConfigValidate(t *testing.T) {
 			},
 			expectedError: "policy should be exactly BRs domain-validated for subordinate CAs",
 		},
+		{
+			name: "aki-form issuer-serial without skipping AKI lint",
+			config: intermediateConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
+					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
+				}{
+					PublicKeyPath:         "path",
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
+				}{
+					CertificatePath: "path",
+				},
+				CertProfile: certProfile{
+					NotBefore:          "2020-01-01 00:00:00",
+					NotAfter:           "2025-01-01 00:00:00",
+					SignatureAlgorithm: "c",
+					CommonName:         "d",
+					Organization:       "e",
+					Country:            "f",
+					OCSPURL:            "g",
+					CRLURL:             "h",
+					IssuerURL:          "i",
+					Policies:           []policyInfoConfig{{OID: "2.23.140.1.2.1"}},
+					AKIForm:            "issuer-serial",
+				},
+				SkipLints: []string{},
+			},
+			expectedError: "aki-form of \"issuer-serial\" requires skip-lints to contain \"e_ext_authority_key_identifier_no_key_identifier\"",
+		},
 		{
 			name: "good config",
 			config: intermediateConfig{
@@ -437,20 +749,25 @@ func TestIntermediateConfigValidate(t *testing.T) {
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					PublicKeyPath         string `yaml:"public-key-path"`
-					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
+					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 				}{
 					PublicKeyPath:         "path",
 					IssuerCertificatePath: "path",
 				},
 				Outputs: struct {
 					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
 				}{
 					CertificatePath: "path",
 				},
 				CertProfile: certProfile{
-					NotBefore:          "a",
-					NotAfter:           "b",
+					NotBefore:          "2020-01-01 00:00:00",
+					NotAfter:           "2025-01-01 00:00:00",
 					SignatureAlgorithm: "c",
 					CommonName:         "d",
 					Organization:       "e",
@@ -504,7 +821,7 @@ func TestCrossCertConfigValidate(t *testing.T) {
 					SigningLabel: "label",
 				},
 			},
-			expectedError: "inputs.public-key-path is required",
+			expectedError: "one of inputs.public-key-path or inputs.public-key-label is required",
 		},
 		{
 			name: "no inputs.issuer-certificate-path",
@@ -515,7 +832,10 @@ func TestCrossCertConfigValidate(t *testing.T) {
 				},
 				Inputs: struct {
 					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
 					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 					CertificateToCrossSignPath string `yaml:"certificate-to-cross-sign-path"`
 				}{
 					PublicKeyPath:              "path",
@@ -533,7 +853,10 @@ func TestCrossCertConfigValidate(t *testing.T) {
 				},
 				Inputs: struct {
 					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
 					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 					CertificateToCrossSignPath string `yaml:"certificate-to-cross-sign-path"`
 				}{
 					PublicKeyPath:         "path",
@@ -551,7 +874,10 @@ func TestCrossCertConfigValidate(t *testing.T) {
 				},
 				Inputs: struct {
 					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
 					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 					CertificateToCrossSignPath string `yaml:"certificate-to-cross-sign-path"`
 				}{
 					PublicKeyPath:              "path",
@@ -570,7 +896,10 @@ func TestCrossCertConfigValidate(t *testing.T) {
 				},
 				Inputs: struct {
 					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
 					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 					CertificateToCrossSignPath string `yaml:"certificate-to-cross-sign-path"`
 				}{
 					PublicKeyPath:              "path",
@@ -579,6 +908,8 @@ func TestCrossCertConfigValidate(t *testing.T) {
 				},
 				Outputs: struct {
 					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
 				}{
 					CertificatePath: "path",
 				},
@@ -594,7 +925,10 @@ func TestCrossCertConfigValidate(t *testing.T) {
 				},
 				Inputs: struct {
 					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
 					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 					CertificateToCrossSignPath string `yaml:"certificate-to-cross-sign-path"`
 				}{
 					PublicKeyPath:              "path",
@@ -603,12 +937,14 @@ func TestCrossCertConfigValidate(t *testing.T) {
 				},
 				Outputs: struct {
 					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
 				}{
 					CertificatePath: "path",
 				},
 				CertProfile: certProfile{
-					NotBefore:          "a",
-					NotAfter:           "b",
+					NotBefore:          "2020-01-01 00:00:00",
+					NotAfter:           "2025-01-01 00:00:00",
 					SignatureAlgorithm: "c",
 					CommonName:         "d",
 					Organization:       "e",
@@ -631,7 +967,10 @@ func TestCrossCertConfigValidate(t *testing.T) {
 				},
 				Inputs: struct {
 					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
 					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 					CertificateToCrossSignPath string `yaml:"certificate-to-cross-sign-path"`
 				}{
 					PublicKeyPath:              "path",
@@ -640,12 +979,14 @@ func TestCrossCertConfigValidate(t *testing.T) {
 				},
 				Outputs: struct {
 					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
 				}{
 					CertificatePath: "path",
 				},
 				CertProfile: certProfile{
-					NotBefore:          "a",
-					NotAfter:           "b",
+					NotBefore:          "2020-01-01 00:00:00",
+					NotAfter:           "2025-01-01 00:00:00",
 					SignatureAlgorithm: "c",
 					CommonName:         "d",
 					Organization:       "e",
@@ -668,7 +1009,10 @@ func TestCrossCertConfigValidate(t *testing.T) {
 				},
 				Inputs: struct {
 					PublicKeyPath              string `yaml:"public-key-path"`
+					PublicKeyLabel             string `yaml:"public-key-label"`
 					IssuerCertificatePath      string `yaml:"issuer-certificate-path"`
+					TrustAnchorCertificatePath string `yaml:"trust-anchor-certificate-path"`
+					IssuerBundlePath           string `yaml:"issuer-bundle-path"`
 					CertificateToCrossSignPath string `yaml:"certificate-to-cross-sign-path"`
 				}{
 					PublicKeyPath:              "path",
@@ -677,12 +1021,14 @@ func TestCrossCertConfigValidate(t *testing.T) {
 				},
 				Outputs: struct {
 					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+					ReportPath      string `yaml:"report-path"`
 				}{
 					CertificatePath: "path",
 				},
 				CertProfile: certProfile{
-					NotBefore:          "a",
-					NotAfter:           "b",
+					NotBefore:          "2020-01-01 00:00:00",
+					NotAfter:           "2025-01-01 00:00:00",
 					SignatureAlgorithm: "c",
 					CommonName:         "d",
 					Organization:       "e",
@@ -766,7 +1112,8 @@ func TestCSRConfigValidate(t *testing.T) {
 					PublicKeyPath: "path",
 				},
 				Outputs: struct {
-					CSRPath string `yaml:"csr-path"`
+					CSRPath    string `yaml:"csr-path"`
+					CSRDERPath string `yaml:"csr-der-path"`
 				}{
 					CSRPath: "path",
 				},
@@ -786,7 +1133,8 @@ func TestCSRConfigValidate(t *testing.T) {
 					PublicKeyPath: "path",
 				},
 				Outputs: struct {
-					CSRPath string `yaml:"csr-path"`
+					CSRPath    string `yaml:"csr-path"`
+					CSRDERPath string `yaml:"csr-der-path"`
 				}{
 					CSRPath: "path",
 				},
@@ -854,6 +1202,28 @@ func TestKeyConfigValidate(t *testing.T) {
 			},
 			expectedError: "outputs.public-key-path is required",
 		},
+		{
+			name: "outputs.pkcs1-public-key-path for an ECDSA key",
+			config: keyConfig{
+				PKCS11: PKCS11KeyGenConfig{
+					Module:     "module",
+					StoreLabel: "label",
+				},
+				Key: keyGenConfig{
+					Type:       "ecdsa",
+					ECDSACurve: "P-256",
+				},
+				Outputs: struct {
+					PublicKeyPath      string `yaml:"public-key-path"`
+					PKCS1PublicKeyPath string `yaml:"pkcs1-public-key-path"`
+					PKCS11ConfigPath   string `yaml:"pkcs11-config-path"`
+				}{
+					PublicKeyPath:      "path",
+					PKCS1PublicKeyPath: "path.pkcs1",
+				},
+			},
+			expectedError: "outputs.pkcs1-public-key-path can only be set if key.type = 'rsa'",
+		},
 		{
 			name: "good config",
 			config: keyConfig{
@@ -866,11 +1236,13 @@ func TestKeyConfigValidate(t *testing.T) {
 					RSAModLength: 2048,
 				},
 				Outputs: struct {
-					PublicKeyPath    string `yaml:"public-key-path"`
-					PKCS11ConfigPath string `yaml:"pkcs11-config-path"`
+					PublicKeyPath      string `yaml:"public-key-path"`
+					PKCS1PublicKeyPath string `yaml:"pkcs1-public-key-path"`
+					PKCS11ConfigPath   string `yaml:"pkcs11-config-path"`
 				}{
-					PublicKeyPath:    "path",
-					PKCS11ConfigPath: "path.json",
+					PublicKeyPath:      "path",
+					PKCS1PublicKeyPath: "path.pkcs1",
+					PKCS11ConfigPath:   "path.json",
 				},
 			},
 		},
@@ -887,6 +1259,133 @@ func TestKeyConfigValidate(t *testing.T) {
 	}
 }
 
+func TestKeyConfigValidateKeys(t *testing.T) {
+	tmp := t.TempDir()
+	existingPath := path.Join(tmp, "existing.pubkey.pem")
+	test.AssertNotError(t, os.WriteFile(existingPath, []byte("key"), 0644), "failed to write existing public key")
+
+	cases := []struct {
+		name string
+		// label is a top-level pkcs11.store-key-with-label, if any.
+		label         string
+		config        string
+		expectedError string
+	}{
+		{
+			name: "valid",
+			config: `
+keys:
+    - store-key-with-label: a
+      outputs:
+          public-key-path: {tmp}/a.pubkey.pem
+    - store-key-with-label: b
+      outputs:
+          public-key-path: {tmp}/b.pubkey.pem
+          pkcs11-config-path: {tmp}/b.pkcs11.json
+`,
+		},
+		{
+			name:  "top-level label",
+			label: "a",
+			config: `
+keys:
+    - store-key-with-label: b
+      outputs:
+          public-key-path: {tmp}/b.pubkey.pem
+`,
+			expectedError: "pkcs11.store-key-with-label cannot be set with keys, each entry has its own label",
+		},
+		{
+			name: "top-level outputs",
+			config: `
+outputs:
+    public-key-path: {tmp}/a.pubkey.pem
+keys:
+    - store-key-with-label: b
+      outputs:
+          public-key-path: {tmp}/b.pubkey.pem
+`,
+			expectedError: "outputs cannot be set with keys, each entry has its own outputs",
+		},
+		{
+			name: "missing label",
+			config: `
+keys:
+    - outputs:
+          public-key-path: {tmp}/a.pubkey.pem
+`,
+			expectedError: "keys[0]: pkcs11.store-key-with-label is required",
+		},
+		{
+			name: "duplicate label",
+			config: `
+keys:
+    - store-key-with-label: a
+      outputs:
+          public-key-path: {tmp}/a.pubkey.pem
+    - store-key-with-label: a
+      outputs:
+          public-key-path: {tmp}/b.pubkey.pem
+`,
+			expectedError: `keys[1]: store-key-with-label "a" is already used by keys[0]`,
+		},
+		{
+			name: "duplicate output path",
+			config: `
+keys:
+    - store-key-with-label: a
+      outputs:
+          public-key-path: {tmp}/a.pubkey.pem
+    - store-key-with-label: b
+      outputs:
+          public-key-path: {tmp}/b.pubkey.pem
+          pkcs11-config-path: {tmp}/a.pubkey.pem
+`,
+			expectedError: `keys[1]: outputs.pkcs11-config-path "{tmp}/a.pubkey.pem" is already written by keys[0]`,
+		},
+		{
+			name: "existing output path",
+			config: `
+keys:
+    - store-key-with-label: a
+      outputs:
+          public-key-path: {tmp}/a.pubkey.pem
+    - store-key-with-label: b
+      outputs:
+          public-key-path: {tmp}/existing.pubkey.pem
+`,
+			expectedError: `keys[1]: outputs.public-key-path is "{tmp}/existing.pubkey.pem", which already exists`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			label := ""
+			if tc.label != "" {
+				label = "    store-key-with-label: " + tc.label + "\n"
+			}
+			config := `ceremony-type: key
+pkcs11:
+    module: module
+    store-key-in-slot: 0
+` + label + `key:
+    type: ecdsa
+    ecdsa-curve: P-256
+` + strings.TrimPrefix(tc.config, "\n")
+			config = strings.ReplaceAll(config, "{tmp}", tmp)
+			var kc keyConfig
+			err := strictyaml.Unmarshal([]byte(config), &kc)
+			test.AssertNotError(t, err, "failed to parse config")
+			err = kc.validate()
+			if tc.expectedError == "" {
+				test.AssertNotError(t, err, "validate failed")
+			} else {
+				test.AssertError(t, err, "validate didn't fail")
+				test.AssertEquals(t, err.Error(), strings.ReplaceAll(tc.expectedError, "{tmp}", tmp))
+			}
+		})
+	}
+}
+
 func TestOCSPRespConfig(t *testing.T) {
 	cases := []struct {
 		name          string
@@ -925,9 +1424,11 @@ func TestOCSPRespConfig(t *testing.T) {
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					CertificatePath                string `yaml:"certificate-path"`
-					IssuerCertificatePath          string `yaml:"issuer-certificate-path"`
-					DelegatedIssuerCertificatePath string `yaml:"delegated-issuer-certificate-path"`
+					CertificatePath                string                  `yaml:"certificate-path"`
+					IssuerCertificatePath          string                  `yaml:"issuer-certificate-path"`
+					DelegatedIssuerCertificatePath string                  `yaml:"delegated-issuer-certificate-path"`
+					DelegatedIssuerBundlePath      string                  `yaml:"delegated-issuer-bundle-path"`
+					Certificates                   []ocspCertificateConfig `yaml:"certificates"`
 				}{
 					CertificatePath: "path",
 				},
@@ -942,9 +1443,11 @@ func TestOCSPRespConfig(t *testing.T) {
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					CertificatePath                string `yaml:"certificate-path"`
-					IssuerCertificatePath          string `yaml:"issuer-certificate-path"`
-					DelegatedIssuerCertificatePath string `yaml:"delegated-issuer-certificate-path"`
+					CertificatePath                string                  `yaml:"certificate-path"`
+					IssuerCertificatePath          string                  `yaml:"issuer-certificate-path"`
+					DelegatedIssuerCertificatePath string                  `yaml:"delegated-issuer-certificate-path"`
+					DelegatedIssuerBundlePath      string                  `yaml:"delegated-issuer-bundle-path"`
+					Certificates                   []ocspCertificateConfig `yaml:"certificates"`
 				}{
 					CertificatePath:       "path",
 					IssuerCertificatePath: "path",
@@ -960,15 +1463,18 @@ func TestOCSPRespConfig(t *testing.T) {
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					CertificatePath                string `yaml:"certificate-path"`
-					IssuerCertificatePath          string `yaml:"issuer-certificate-path"`
-					DelegatedIssuerCertificatePath string `yaml:"delegated-issuer-certificate-path"`
+					CertificatePath                string                  `yaml:"certificate-path"`
+					IssuerCertificatePath          string                  `yaml:"issuer-certificate-path"`
+					DelegatedIssuerCertificatePath string                  `yaml:"delegated-issuer-certificate-path"`
+					DelegatedIssuerBundlePath      string                  `yaml:"delegated-issuer-bundle-path"`
+					Certificates                   []ocspCertificateConfig `yaml:"certificates"`
 				}{
 					CertificatePath:       "path",
 					IssuerCertificatePath: "path",
 				},
 				Outputs: struct {
-					ResponsePath string `yaml:"response-path"`
+					ResponsePath    string `yaml:"response-path"`
+					ResponseDERPath string `yaml:"response-der-path"`
 				}{
 					ResponsePath: "path",
 				},
@@ -983,22 +1489,30 @@ func TestOCSPRespConfig(t *testing.T) {
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					CertificatePath                string `yaml:"certificate-path"`
-					IssuerCertificatePath          string `yaml:"issuer-certificate-path"`
-					DelegatedIssuerCertificatePath string `yaml:"delegated-issuer-certificate-path"`
+					CertificatePath                string                  `yaml:"certificate-path"`
+					IssuerCertificatePath          string                  `yaml:"issuer-certificate-path"`
+					DelegatedIssuerCertificatePath string                  `yaml:"delegated-issuer-certificate-path"`
+					DelegatedIssuerBundlePath      string                  `yaml:"delegated-issuer-bundle-path"`
+					Certificates                   []ocspCertificateConfig `yaml:"certificates"`
 				}{
 					CertificatePath:       "path",
 					IssuerCertificatePath: "path",
 				},
 				Outputs: struct {
-					ResponsePath string `yaml:"response-path"`
+					ResponsePath    string `yaml:"response-path"`
+					ResponseDERPath string `yaml:"response-der-path"`
 				}{
 					ResponsePath: "path",
 				},
 				OCSPProfile: struct {
-					ThisUpdate string `yaml:"this-update"`
-					NextUpdate string `yaml:"next-update"`
-					Status     string `yaml:"status"`
+					ThisUpdate    string `yaml:"this-update"`
+					NextUpdate    string `yaml:"next-update"`
+					Status        string `yaml:"status"`
+					ResponderID   string `yaml:"responder-id"`
+					ArchiveCutoff string `yaml:"archive-cutoff"`
+					IncludeChain  bool   `yaml:"include-chain"`
+					HashAlgorithm string `yaml:"hash-algorithm"`
+					Nonce         string `yaml:"nonce"`
 				}{
 					ThisUpdate: "this-update",
 				},
@@ -1013,22 +1527,30 @@ func TestOCSPRespConfig(t *testing.T) {
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					CertificatePath                string `yaml:"certificate-path"`
-					IssuerCertificatePath          string `yaml:"issuer-certificate-path"`
-					DelegatedIssuerCertificatePath string `yaml:"delegated-issuer-certificate-path"`
+					CertificatePath                string                  `yaml:"certificate-path"`
+					IssuerCertificatePath          string                  `yaml:"issuer-certificate-path"`
+					DelegatedIssuerCertificatePath string                  `yaml:"delegated-issuer-certificate-path"`
+					DelegatedIssuerBundlePath      string                  `yaml:"delegated-issuer-bundle-path"`
+					Certificates                   []ocspCertificateConfig `yaml:"certificates"`
 				}{
 					CertificatePath:       "path",
 					IssuerCertificatePath: "path",
 				},
 				Outputs: struct {
-					ResponsePath string `yaml:"response-path"`
+					ResponsePath    string `yaml:"response-path"`
+					ResponseDERPath string `yaml:"response-der-path"`
 				}{
 					ResponsePath: "path",
 				},
 				OCSPProfile: struct {
-					ThisUpdate string `yaml:"this-update"`
-					NextUpdate string `yaml:"next-update"`
-					Status     string `yaml:"status"`
+					ThisUpdate    string `yaml:"this-update"`
+					NextUpdate    string `yaml:"next-update"`
+					Status        string `yaml:"status"`
+					ResponderID   string `yaml:"responder-id"`
+					ArchiveCutoff string `yaml:"archive-cutoff"`
+					IncludeChain  bool   `yaml:"include-chain"`
+					HashAlgorithm string `yaml:"hash-algorithm"`
+					Nonce         string `yaml:"nonce"`
 				}{
 					ThisUpdate: "this-update",
 					NextUpdate: "next-update",
@@ -1037,72 +1559,244 @@ func TestOCSPRespConfig(t *testing.T) {
 			expectedError: "ocsp-profile.status must be either \"good\" or \"revoked\"",
 		},
 		{
-			name: "good config",
+			name: "bad ocsp-profile.responder-id",
 			config: ocspRespConfig{
 				PKCS11: PKCS11SigningConfig{
 					Module:       "module",
 					SigningLabel: "label",
 				},
 				Inputs: struct {
-					CertificatePath                string `yaml:"certificate-path"`
-					IssuerCertificatePath          string `yaml:"issuer-certificate-path"`
-					DelegatedIssuerCertificatePath string `yaml:"delegated-issuer-certificate-path"`
+					CertificatePath                string                  `yaml:"certificate-path"`
+					IssuerCertificatePath          string                  `yaml:"issuer-certificate-path"`
+					DelegatedIssuerCertificatePath string                  `yaml:"delegated-issuer-certificate-path"`
+					DelegatedIssuerBundlePath      string                  `yaml:"delegated-issuer-bundle-path"`
+					Certificates                   []ocspCertificateConfig `yaml:"certificates"`
 				}{
 					CertificatePath:       "path",
 					IssuerCertificatePath: "path",
 				},
 				Outputs: struct {
-					ResponsePath string `yaml:"response-path"`
+					ResponsePath    string `yaml:"response-path"`
+					ResponseDERPath string `yaml:"response-der-path"`
 				}{
 					ResponsePath: "path",
 				},
 				OCSPProfile: struct {
-					ThisUpdate string `yaml:"this-update"`
-					NextUpdate string `yaml:"next-update"`
-					Status     string `yaml:"status"`
-				}{
-					ThisUpdate: "this-update",
-					NextUpdate: "next-update",
-					Status:     "good",
+					ThisUpdate    string `yaml:"this-update"`
+					NextUpdate    string `yaml:"next-update"`
+					Status        string `yaml:"status"`
+					ResponderID   string `yaml:"responder-id"`
+					ArchiveCutoff string `yaml:"archive-cutoff"`
+					IncludeChain  bool   `yaml:"include-chain"`
+					HashAlgorithm string `yaml:"hash-algorithm"`
+					Nonce         string `yaml:"nonce"`
+				}{
+					ThisUpdate:  "this-update",
+					NextUpdate:  "next-update",
+					Status:      "good",
+					ResponderID: "by-hash",
 				},
 			},
-		},
-	}
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			err := tc.config.validate()
-			if err != nil && err.Error() != tc.expectedError {
-				t.Fatalf("Unexpected error, wanted: %q, got: %q", tc.expectedError, err)
-			} else if err == nil && tc.expectedError != "" {
-				t.Fatalf("validate didn't fail, wanted: %q", err)
-			}
-		})
-	}
-}
-
-func TestCRLConfig(t *testing.T) {
-	cases := []struct {
-		name          string
-		config        crlConfig
-		expectedError string
-	}{
-		{
-			name:          "no pkcs11.module",
-			config:        crlConfig{},
-			expectedError: "pkcs11.module is required",
+			expectedError: "ocsp-profile.responder-id must be either \"by-name\" or \"by-key\"",
 		},
 		{
-			name: "no pkcs11.signing-key-label",
-			config: crlConfig{
+			name: "bad ocsp-profile.hash-algorithm",
+			config: ocspRespConfig{
 				PKCS11: PKCS11SigningConfig{
-					Module: "module",
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					CertificatePath                string                  `yaml:"certificate-path"`
+					IssuerCertificatePath          string                  `yaml:"issuer-certificate-path"`
+					DelegatedIssuerCertificatePath string                  `yaml:"delegated-issuer-certificate-path"`
+					DelegatedIssuerBundlePath      string                  `yaml:"delegated-issuer-bundle-path"`
+					Certificates                   []ocspCertificateConfig `yaml:"certificates"`
+				}{
+					CertificatePath:       "path",
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					ResponsePath    string `yaml:"response-path"`
+					ResponseDERPath string `yaml:"response-der-path"`
+				}{
+					ResponsePath: "path",
+				},
+				OCSPProfile: struct {
+					ThisUpdate    string `yaml:"this-update"`
+					NextUpdate    string `yaml:"next-update"`
+					Status        string `yaml:"status"`
+					ResponderID   string `yaml:"responder-id"`
+					ArchiveCutoff string `yaml:"archive-cutoff"`
+					IncludeChain  bool   `yaml:"include-chain"`
+					HashAlgorithm string `yaml:"hash-algorithm"`
+					Nonce         string `yaml:"nonce"`
+				}{
+					ThisUpdate:    "this-update",
+					NextUpdate:    "next-update",
+					Status:        "good",
+					HashAlgorithm: "md5",
 				},
 			},
-			expectedError: "pkcs11.signing-key-label is required",
+			expectedError: "ocsp-profile.hash-algorithm must be one of \"sha1\", \"sha256\", \"sha384\" or \"sha512\"",
 		},
 		{
-			name: "no inputs.issuer-certificate-path",
-			config: crlConfig{
+			name: "bad ocsp-profile.nonce hex",
+			config: ocspRespConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					CertificatePath                string                  `yaml:"certificate-path"`
+					IssuerCertificatePath          string                  `yaml:"issuer-certificate-path"`
+					DelegatedIssuerCertificatePath string                  `yaml:"delegated-issuer-certificate-path"`
+					DelegatedIssuerBundlePath      string                  `yaml:"delegated-issuer-bundle-path"`
+					Certificates                   []ocspCertificateConfig `yaml:"certificates"`
+				}{
+					CertificatePath:       "path",
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					ResponsePath    string `yaml:"response-path"`
+					ResponseDERPath string `yaml:"response-der-path"`
+				}{
+					ResponsePath: "path",
+				},
+				OCSPProfile: struct {
+					ThisUpdate    string `yaml:"this-update"`
+					NextUpdate    string `yaml:"next-update"`
+					Status        string `yaml:"status"`
+					ResponderID   string `yaml:"responder-id"`
+					ArchiveCutoff string `yaml:"archive-cutoff"`
+					IncludeChain  bool   `yaml:"include-chain"`
+					HashAlgorithm string `yaml:"hash-algorithm"`
+					Nonce         string `yaml:"nonce"`
+				}{
+					ThisUpdate: "this-update",
+					NextUpdate: "next-update",
+					Status:     "good",
+					Nonce:      "abcdefgh",
+				},
+			},
+			expectedError: "ocsp-profile.nonce is not valid hex: encoding/hex: invalid byte: U+0067 'g'",
+		},
+		{
+			name: "ocsp-profile.nonce too long",
+			config: ocspRespConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					CertificatePath                string                  `yaml:"certificate-path"`
+					IssuerCertificatePath          string                  `yaml:"issuer-certificate-path"`
+					DelegatedIssuerCertificatePath string                  `yaml:"delegated-issuer-certificate-path"`
+					DelegatedIssuerBundlePath      string                  `yaml:"delegated-issuer-bundle-path"`
+					Certificates                   []ocspCertificateConfig `yaml:"certificates"`
+				}{
+					CertificatePath:       "path",
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					ResponsePath    string `yaml:"response-path"`
+					ResponseDERPath string `yaml:"response-der-path"`
+				}{
+					ResponsePath: "path",
+				},
+				OCSPProfile: struct {
+					ThisUpdate    string `yaml:"this-update"`
+					NextUpdate    string `yaml:"next-update"`
+					Status        string `yaml:"status"`
+					ResponderID   string `yaml:"responder-id"`
+					ArchiveCutoff string `yaml:"archive-cutoff"`
+					IncludeChain  bool   `yaml:"include-chain"`
+					HashAlgorithm string `yaml:"hash-algorithm"`
+					Nonce         string `yaml:"nonce"`
+				}{
+					ThisUpdate: "this-update",
+					NextUpdate: "next-update",
+					Status:     "good",
+					Nonce:      strings.Repeat("ab", 33),
+				},
+			},
+			expectedError: "ocsp-profile.nonce is 33 bytes, which is not between 1 and 32",
+		},
+		{
+			name: "good config",
+			config: ocspRespConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					CertificatePath                string                  `yaml:"certificate-path"`
+					IssuerCertificatePath          string                  `yaml:"issuer-certificate-path"`
+					DelegatedIssuerCertificatePath string                  `yaml:"delegated-issuer-certificate-path"`
+					DelegatedIssuerBundlePath      string                  `yaml:"delegated-issuer-bundle-path"`
+					Certificates                   []ocspCertificateConfig `yaml:"certificates"`
+				}{
+					CertificatePath:       "path",
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					ResponsePath    string `yaml:"response-path"`
+					ResponseDERPath string `yaml:"response-der-path"`
+				}{
+					ResponsePath: "path",
+				},
+				OCSPProfile: struct {
+					ThisUpdate    string `yaml:"this-update"`
+					NextUpdate    string `yaml:"next-update"`
+					Status        string `yaml:"status"`
+					ResponderID   string `yaml:"responder-id"`
+					ArchiveCutoff string `yaml:"archive-cutoff"`
+					IncludeChain  bool   `yaml:"include-chain"`
+					HashAlgorithm string `yaml:"hash-algorithm"`
+					Nonce         string `yaml:"nonce"`
+				}{
+					ThisUpdate: "this-update",
+					NextUpdate: "next-update",
+					Status:     "good",
+				},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.validate()
+			if err != nil && err.Error() != tc.expectedError {
+				t.Fatalf("Unexpected error, wanted: %q, got: %q", tc.expectedError, err)
+			} else if err == nil && tc.expectedError != "" {
+				t.Fatalf("validate didn't fail, wanted: %q", err)
+			}
+		})
+	}
+}
+
+func TestCRLConfig(t *testing.T) {
+	cases := []struct {
+		name          string
+		config        crlConfig
+		expectedError string
+	}{
+		{
+			name:          "no pkcs11.module",
+			config:        crlConfig{},
+			expectedError: "pkcs11.module is required",
+		},
+		{
+			name: "no pkcs11.signing-key-label",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module: "module",
+				},
+			},
+			expectedError: "pkcs11.signing-key-label is required",
+		},
+		{
+			name: "no inputs.issuer-certificate-path",
+			config: crlConfig{
 				PKCS11: PKCS11SigningConfig{
 					Module:       "module",
 					SigningLabel: "label",
@@ -1166,11 +1860,14 @@ func TestCRLConfig(t *testing.T) {
 					ThisUpdate          string `yaml:"this-update"`
 					NextUpdate          string `yaml:"next-update"`
 					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
 					RevokedCertificates []struct {
 						CertificatePath  string `yaml:"certificate-path"`
 						RevocationDate   string `yaml:"revocation-date"`
 						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
 					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
 				}{
 					ThisUpdate: "this-update",
 				},
@@ -1198,11 +1895,14 @@ func TestCRLConfig(t *testing.T) {
 					ThisUpdate          string `yaml:"this-update"`
 					NextUpdate          string `yaml:"next-update"`
 					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
 					RevokedCertificates []struct {
 						CertificatePath  string `yaml:"certificate-path"`
 						RevocationDate   string `yaml:"revocation-date"`
 						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
 					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
 				}{
 					ThisUpdate: "this-update",
 					NextUpdate: "next-update",
@@ -1231,11 +1931,14 @@ func TestCRLConfig(t *testing.T) {
 					ThisUpdate          string `yaml:"this-update"`
 					NextUpdate          string `yaml:"next-update"`
 					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
 					RevokedCertificates []struct {
 						CertificatePath  string `yaml:"certificate-path"`
 						RevocationDate   string `yaml:"revocation-date"`
 						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
 					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
 				}{
 					ThisUpdate: "this-update",
 					NextUpdate: "next-update",
@@ -1244,6 +1947,7 @@ func TestCRLConfig(t *testing.T) {
 						CertificatePath  string `yaml:"certificate-path"`
 						RevocationDate   string `yaml:"revocation-date"`
 						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
 					}{{}},
 				},
 			},
@@ -1270,11 +1974,14 @@ func TestCRLConfig(t *testing.T) {
 					ThisUpdate          string `yaml:"this-update"`
 					NextUpdate          string `yaml:"next-update"`
 					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
 					RevokedCertificates []struct {
 						CertificatePath  string `yaml:"certificate-path"`
 						RevocationDate   string `yaml:"revocation-date"`
 						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
 					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
 				}{
 					ThisUpdate: "this-update",
 					NextUpdate: "next-update",
@@ -1283,6 +1990,7 @@ func TestCRLConfig(t *testing.T) {
 						CertificatePath  string `yaml:"certificate-path"`
 						RevocationDate   string `yaml:"revocation-date"`
 						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
 					}{{
 						CertificatePath: "path",
 					}},
@@ -1311,11 +2019,14 @@ func TestCRLConfig(t *testing.T) {
 					ThisUpdate          string `yaml:"this-update"`
 					NextUpdate          string `yaml:"next-update"`
 					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
 					RevokedCertificates []struct {
 						CertificatePath  string `yaml:"certificate-path"`
 						RevocationDate   string `yaml:"revocation-date"`
 						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
 					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
 				}{
 					ThisUpdate: "this-update",
 					NextUpdate: "next-update",
@@ -1324,6 +2035,7 @@ func TestCRLConfig(t *testing.T) {
 						CertificatePath  string `yaml:"certificate-path"`
 						RevocationDate   string `yaml:"revocation-date"`
 						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
 					}{{
 						CertificatePath: "path",
 						RevocationDate:  "date",
@@ -1333,7 +2045,7 @@ func TestCRLConfig(t *testing.T) {
 			expectedError: "crl-profile.revoked-certificates.revocation-reason is required",
 		},
 		{
-			name: "good",
+			name: "no crl-profile.revoked-certificates-dir.path",
 			config: crlConfig{
 				PKCS11: PKCS11SigningConfig{
 					Module:       "module",
@@ -1353,42 +2065,1096 @@ func TestCRLConfig(t *testing.T) {
 					ThisUpdate          string `yaml:"this-update"`
 					NextUpdate          string `yaml:"next-update"`
 					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
 					RevokedCertificates []struct {
 						CertificatePath  string `yaml:"certificate-path"`
 						RevocationDate   string `yaml:"revocation-date"`
 						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
 					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
 				}{
-					ThisUpdate: "this-update",
-					NextUpdate: "next-update",
-					Number:     1,
-					RevokedCertificates: []struct {
+					ThisUpdate:             "this-update",
+					NextUpdate:             "next-update",
+					Number:                 1,
+					RevokedCertificatesDir: &revokedCertificatesDirConfig{},
+				},
+			},
+			expectedError: "crl-profile.revoked-certificates-dir.path is required",
+		},
+		{
+			name: "crl-profile.revoked-certificates-dir.path is not a directory",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CRLPath string `yaml:"crl-path"`
+				}{
+					CRLPath: "path",
+				},
+				CRLProfile: struct {
+					ThisUpdate          string `yaml:"this-update"`
+					NextUpdate          string `yaml:"next-update"`
+					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
+					RevokedCertificates []struct {
 						CertificatePath  string `yaml:"certificate-path"`
 						RevocationDate   string `yaml:"revocation-date"`
 						RevocationReason int    `yaml:"revocation-reason"`
-					}{{
-						CertificatePath:  "path",
-						RevocationDate:   "date",
-						RevocationReason: 1,
-					}},
+						InvalidityDate   string `yaml:"invalidity-date"`
+					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+				}{
+					ThisUpdate:             "this-update",
+					NextUpdate:             "next-update",
+					Number:                 1,
+					RevokedCertificatesDir: &revokedCertificatesDirConfig{Path: "main.go"},
 				},
 			},
+			expectedError: "crl-profile.revoked-certificates-dir.path is \"main.go\", which is not a directory",
 		},
-	}
-	for _, tc := range cases {
-		t.Run(tc.name, func(t *testing.T) {
-			err := tc.config.validate()
-			if err != nil && err.Error() != tc.expectedError {
-				t.Fatalf("Unexpected error, wanted: %q, got: %q", tc.expectedError, err)
-			} else if err == nil && tc.expectedError != "" {
-				t.Fatalf("validate didn't fail, wanted: %q", err)
-			}
-		})
-	}
-}
-
-func TestSignAndWriteNoLintCert(t *testing.T) {
-	_, err := signAndWriteCert(nil, nil, nil, nil, nil, "")
-	test.AssertError(t, err, "should have failed because no lintCert was provided")
-	test.AssertDeepEquals(t, err, fmt.Errorf("linting was not performed prior to issuance"))
+		{
+			name: "good with crl-profile.revoked-certificates-dir",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CRLPath string `yaml:"crl-path"`
+				}{
+					CRLPath: "path",
+				},
+				CRLProfile: struct {
+					ThisUpdate          string `yaml:"this-update"`
+					NextUpdate          string `yaml:"next-update"`
+					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
+					RevokedCertificates []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+				}{
+					ThisUpdate:             "this-update",
+					NextUpdate:             "next-update",
+					Number:                 1,
+					RevokedCertificatesDir: &revokedCertificatesDirConfig{Path: t.TempDir(), RevocationReason: 1},
+				},
+			},
+		},
+		{
+			name: "base-crl-number equal to number",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CRLPath string `yaml:"crl-path"`
+				}{
+					CRLPath: "path",
+				},
+				CRLProfile: struct {
+					ThisUpdate          string `yaml:"this-update"`
+					NextUpdate          string `yaml:"next-update"`
+					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
+					RevokedCertificates []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+				}{
+					ThisUpdate:    "this-update",
+					NextUpdate:    "next-update",
+					Number:        5,
+					BaseCRLNumber: 5,
+				},
+			},
+			expectedError: "crl-profile.number must be greater than crl-profile.base-crl-number",
+		},
+		{
+			name: "base-crl-number greater than number",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CRLPath string `yaml:"crl-path"`
+				}{
+					CRLPath: "path",
+				},
+				CRLProfile: struct {
+					ThisUpdate          string `yaml:"this-update"`
+					NextUpdate          string `yaml:"next-update"`
+					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
+					RevokedCertificates []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+				}{
+					ThisUpdate:    "this-update",
+					NextUpdate:    "next-update",
+					Number:        5,
+					BaseCRLNumber: 6,
+				},
+			},
+			expectedError: "crl-profile.number must be greater than crl-profile.base-crl-number",
+		},
+		{
+			name: "negative base-crl-number",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CRLPath string `yaml:"crl-path"`
+				}{
+					CRLPath: "path",
+				},
+				CRLProfile: struct {
+					ThisUpdate          string `yaml:"this-update"`
+					NextUpdate          string `yaml:"next-update"`
+					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
+					RevokedCertificates []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+				}{
+					ThisUpdate:    "this-update",
+					NextUpdate:    "next-update",
+					Number:        5,
+					BaseCRLNumber: -1,
+				},
+			},
+			expectedError: "crl-profile.base-crl-number must be positive",
+		},
+		{
+			name: "good delta",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CRLPath string `yaml:"crl-path"`
+				}{
+					CRLPath: "path",
+				},
+				CRLProfile: struct {
+					ThisUpdate          string `yaml:"this-update"`
+					NextUpdate          string `yaml:"next-update"`
+					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
+					RevokedCertificates []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+				}{
+					ThisUpdate:    "this-update",
+					NextUpdate:    "next-update",
+					Number:        6,
+					BaseCRLNumber: 5,
+				},
+			},
+		},
+		{
+			name: "unparseable invalidity date",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CRLPath string `yaml:"crl-path"`
+				}{
+					CRLPath: "path",
+				},
+				CRLProfile: struct {
+					ThisUpdate          string `yaml:"this-update"`
+					NextUpdate          string `yaml:"next-update"`
+					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
+					RevokedCertificates []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+				}{
+					ThisUpdate: "this-update",
+					NextUpdate: "next-update",
+					Number:     1,
+					RevokedCertificates: []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					}{{
+						CertificatePath:  "path",
+						RevocationDate:   "2020-01-01 00:00:00",
+						RevocationReason: 1,
+						InvalidityDate:   "2019-12-31",
+					}},
+				},
+			},
+			expectedError: "unable to parse crl-profile.revoked-certificates.invalidity-date: parsing time \"2019-12-31\" as \"2006-01-02 15:04:05\": cannot parse \"\" as \"15\"",
+		},
+		{
+			name: "invalidity date with unparseable revocation date",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CRLPath string `yaml:"crl-path"`
+				}{
+					CRLPath: "path",
+				},
+				CRLProfile: struct {
+					ThisUpdate          string `yaml:"this-update"`
+					NextUpdate          string `yaml:"next-update"`
+					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
+					RevokedCertificates []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+				}{
+					ThisUpdate: "this-update",
+					NextUpdate: "next-update",
+					Number:     1,
+					RevokedCertificates: []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					}{{
+						CertificatePath:  "path",
+						RevocationDate:   "date",
+						RevocationReason: 1,
+						InvalidityDate:   "2019-12-31 00:00:00",
+					}},
+				},
+			},
+			expectedError: "unable to parse crl-profile.revoked-certificates.revocation-date: parsing time \"date\" as \"2006-01-02 15:04:05\": cannot parse \"date\" as \"2006\"",
+		},
+		{
+			name: "invalidity date after revocation date",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CRLPath string `yaml:"crl-path"`
+				}{
+					CRLPath: "path",
+				},
+				CRLProfile: struct {
+					ThisUpdate          string `yaml:"this-update"`
+					NextUpdate          string `yaml:"next-update"`
+					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
+					RevokedCertificates []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+				}{
+					ThisUpdate: "this-update",
+					NextUpdate: "next-update",
+					Number:     1,
+					RevokedCertificates: []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					}{{
+						CertificatePath:  "path",
+						RevocationDate:   "2020-01-01 00:00:00",
+						RevocationReason: 1,
+						InvalidityDate:   "2020-01-01 00:00:01",
+					}},
+				},
+			},
+			expectedError: "crl-profile.revoked-certificates.invalidity-date must not be after revocation-date",
+		},
+		{
+			name: "good invalidity date",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CRLPath string `yaml:"crl-path"`
+				}{
+					CRLPath: "path",
+				},
+				CRLProfile: struct {
+					ThisUpdate          string `yaml:"this-update"`
+					NextUpdate          string `yaml:"next-update"`
+					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
+					RevokedCertificates []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+				}{
+					ThisUpdate: "this-update",
+					NextUpdate: "next-update",
+					Number:     1,
+					RevokedCertificates: []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					}{{
+						CertificatePath:  "path",
+						RevocationDate:   "2020-01-01 00:00:00",
+						RevocationReason: 1,
+						InvalidityDate:   "2019-12-31 00:00:00",
+					}},
+				},
+			},
+		},
+		{
+			name: "good",
+			config: crlConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CRLPath string `yaml:"crl-path"`
+				}{
+					CRLPath: "path",
+				},
+				CRLProfile: struct {
+					ThisUpdate          string `yaml:"this-update"`
+					NextUpdate          string `yaml:"next-update"`
+					Number              int64  `yaml:"number"`
+					BaseCRLNumber       int64  `yaml:"base-crl-number"`
+					RevokedCertificates []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					} `yaml:"revoked-certificates"`
+					RevokedCertificatesDir *revokedCertificatesDirConfig `yaml:"revoked-certificates-dir"`
+				}{
+					ThisUpdate: "this-update",
+					NextUpdate: "next-update",
+					Number:     1,
+					RevokedCertificates: []struct {
+						CertificatePath  string `yaml:"certificate-path"`
+						RevocationDate   string `yaml:"revocation-date"`
+						RevocationReason int    `yaml:"revocation-reason"`
+						InvalidityDate   string `yaml:"invalidity-date"`
+					}{{
+						CertificatePath:  "path",
+						RevocationDate:   "date",
+						RevocationReason: 1,
+					}},
+				},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.validate()
+			if err != nil && err.Error() != tc.expectedError {
+				t.Fatalf("Unexpected error, wanted: %q, got: %q", tc.expectedError, err)
+			} else if err == nil && tc.expectedError != "" {
+				t.Fatalf("validate didn't fail, wanted: %q", err)
+			}
+		})
+	}
+}
+
+func TestCRLConfigRevocationReason(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name          string
+		reason        int
+		expectedError string
+	}{
+		{
+			name:   "keyCompromise",
+			reason: 1,
+		},
+		{
+			name:   "cACompromise",
+			reason: 2,
+		},
+		{
+			name:   "aACompromise",
+			reason: 10,
+		},
+		{
+			name:          "unused",
+			reason:        7,
+			expectedError: "crl-profile.revoked-certificates[1].revocation-reason is 7, which is not a CRLReason code defined by RFC 5280",
+		},
+		{
+			name:          "undefined",
+			reason:        11,
+			expectedError: "crl-profile.revoked-certificates[1].revocation-reason is 11, which is not a CRLReason code defined by RFC 5280",
+		},
+		{
+			name:          "negative",
+			reason:        -1,
+			expectedError: "crl-profile.revoked-certificates[1].revocation-reason is -1, which is not a CRLReason code defined by RFC 5280",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The first entry is valid, so that errors must name the second.
+			configBytes := []byte(fmt.Sprintf(`pkcs11:
+    module: module
+    signing-key-label: label
+inputs:
+    issuer-certificate-path: path
+outputs:
+    crl-path: path
+crl-profile:
+    this-update: this-update
+    next-update: next-update
+    number: 1
+    revoked-certificates:
+        - certificate-path: a.cert.pem
+          revocation-date: 2020-01-01 00:00:00
+          revocation-reason: 1
+        - certificate-path: b.cert.pem
+          revocation-date: 2020-01-01 00:00:00
+          revocation-reason: %d
+`, tc.reason))
+			var config crlConfig
+			err := strictyaml.Unmarshal(configBytes, &config)
+			test.AssertNotError(t, err, "failed to parse config")
+			err = config.validate()
+			if tc.expectedError == "" {
+				test.AssertNotError(t, err, "validate failed with a valid revocation reason")
+			} else {
+				test.AssertError(t, err, "validate didn't fail with an invalid revocation reason")
+				test.AssertEquals(t, err.Error(), tc.expectedError)
+			}
+
+			// The same codes are accepted for revoked-certificates-dir.
+			config.CRLProfile.RevokedCertificates = nil
+			config.CRLProfile.RevokedCertificatesDir = &revokedCertificatesDirConfig{Path: dir, RevocationReason: tc.reason}
+			err = config.validate()
+			if tc.expectedError == "" {
+				test.AssertNotError(t, err, "validate failed with a valid revoked-certificates-dir revocation reason")
+			} else {
+				test.AssertError(t, err, "validate didn't fail with an invalid revoked-certificates-dir revocation reason")
+				test.AssertEquals(t, err.Error(), fmt.Sprintf("crl-profile.revoked-certificates-dir.revocation-reason is %d, which is not a CRLReason code defined by RFC 5280", tc.reason))
+			}
+		})
+	}
+}
+
+func TestRenewConfigValidate(t *testing.T) {
+	cases := []struct {
+		name          string
+		config        renewConfig
+		expectedError string
+	}{
+		{
+			name:          "no pkcs11.module",
+			config:        renewConfig{},
+			expectedError: "pkcs11.module is required",
+		},
+		{
+			name: "no inputs.certificate-path",
+			config: renewConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+			},
+			expectedError: "inputs.certificate-path is required",
+		},
+		{
+			name: "no inputs.issuer-certificate-path",
+			config: renewConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					CertificatePath       string `yaml:"certificate-path"`
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					CertificatePath: "path",
+				},
+			},
+			expectedError: "inputs.issuer-certificate-path is required",
+		},
+		{
+			name: "no outputs.certificate-path",
+			config: renewConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					CertificatePath       string `yaml:"certificate-path"`
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					CertificatePath:       "path",
+					IssuerCertificatePath: "path",
+				},
+			},
+			expectedError: "outputs.certificate-path is required",
+		},
+		{
+			name: "no validity.not-before",
+			config: renewConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					CertificatePath       string `yaml:"certificate-path"`
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					CertificatePath:       "path",
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+				}{
+					CertificatePath: "path",
+				},
+			},
+			expectedError: "validity.not-before is required",
+		},
+		{
+			name: "no validity.not-after",
+			config: renewConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					CertificatePath       string `yaml:"certificate-path"`
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					CertificatePath:       "path",
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+				}{
+					CertificatePath: "path",
+				},
+				Validity: struct {
+					NotBefore string `yaml:"not-before"`
+					NotAfter  string `yaml:"not-after"`
+				}{
+					NotBefore: "2020-01-01 00:00:00",
+				},
+			},
+			expectedError: "validity.not-after is required",
+		},
+		{
+			name: "good config",
+			config: renewConfig{
+				PKCS11: PKCS11SigningConfig{
+					Module:       "module",
+					SigningLabel: "label",
+				},
+				Inputs: struct {
+					CertificatePath       string `yaml:"certificate-path"`
+					IssuerCertificatePath string `yaml:"issuer-certificate-path"`
+				}{
+					CertificatePath:       "path",
+					IssuerCertificatePath: "path",
+				},
+				Outputs: struct {
+					CertificatePath string `yaml:"certificate-path"`
+					LintReportPath  string `yaml:"lint-report-path"`
+				}{
+					CertificatePath: "path",
+				},
+				Validity: struct {
+					NotBefore string `yaml:"not-before"`
+					NotAfter  string `yaml:"not-after"`
+				}{
+					NotBefore: "2020-01-01 00:00:00",
+					NotAfter:  "2040-01-01 00:00:00",
+				},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.validate()
+			if err != nil && err.Error() != tc.expectedError {
+				t.Fatalf("Unexpected error, wanted: %q, got: %q", tc.expectedError, err)
+			} else if err == nil && tc.expectedError != "" {
+				t.Fatalf("validate didn't fail, wanted: %q", err)
+			}
+		})
+	}
+}
+
+func TestSignAndWriteNoLintCert(t *testing.T) {
+	_, err := signAndWriteCert(nil, nil, nil, nil, nil, certUniqueIDs{}, nil, "")
+	test.AssertError(t, err, "should have failed because no lintCert was provided")
+	test.AssertDeepEquals(t, err, fmt.Errorf("linting was not performed prior to issuance"))
+}
+
+func TestSignAndWriteCertLogsSubjectSPKIHash(t *testing.T) {
+	pub, _, err := loadPubKey("../../test/test-root.pubkey.pem")
+	test.AssertNotError(t, err, "failed to load test public key")
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	issuerTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "issuer"},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	issuerDER, err := x509.CreateCertificate(rand.Reader, issuerTemplate, issuerTemplate, k.Public(), k)
+	test.AssertNotError(t, err, "failed to create issuer certificate")
+	issuer, err := x509.ParseCertificate(issuerDER)
+	test.AssertNotError(t, err, "failed to parse issuer certificate")
+	tbs := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "subject"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	_, err = signAndWriteCert(tbs, issuer, lintCert(issuer), pub, &wrappedSigner{k}, certUniqueIDs{}, nil, t.TempDir()+"/cert.pem")
+	test.AssertNotError(t, err, "signAndWriteCert failed")
+
+	// The SHA-256 of the DER SubjectPublicKeyInfo in test-root.pubkey.pem,
+	// rather than of the issuer's signing key.
+	test.AssertContains(t, logs.String(), "Subject public key info SHA-256: 272e470e506d52f2a42ed12c19b769d28f4bbd5271d65606dd1fa7e46fca808a\n")
+}
+
+func TestCheckNotBefore(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	configured := time.Date(2020, 1, 1, 0, 0, 40, 0, time.UTC)
+	issue := func(notBefore time.Time) *x509.Certificate {
+		t.Helper()
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			NotBefore:    notBefore,
+			NotAfter:     configured.AddDate(1, 0, 0),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+		test.AssertNotError(t, err, "failed to create certificate")
+		cert, err := x509.ParseCertificate(der)
+		test.AssertNotError(t, err, "failed to parse certificate")
+		return cert
+	}
+
+	test.AssertNotError(t, checkNotBefore(issue(configured), configured), "checkNotBefore failed for an identical NotBefore")
+
+	// Fractional seconds can't be encoded, so they're tolerated.
+	err = checkNotBefore(issue(configured), configured.Add(500*time.Millisecond))
+	test.AssertNotError(t, err, "checkNotBefore failed for a sub-second difference")
+
+	// A builder which rounds the NotBefore to the minute drifts by 20 seconds.
+	err = checkNotBefore(issue(configured.Round(time.Minute)), configured)
+	test.AssertError(t, err, "checkNotBefore didn't detect rounding")
+	test.AssertEquals(t, err.Error(), "signed certificate's NotBefore 2020-01-01 00:01:00 differs from the configured 2020-01-01 00:00:40 by 20s")
+}
+
+func TestKeyAndRootConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	goodConfig := func() keyAndRootConfig {
+		var config keyAndRootConfig
+		config.PKCS11 = PKCS11KeyGenConfig{
+			Module:     "module",
+			StoreLabel: "label",
+		}
+		config.Key = keyGenConfig{
+			Type:       "ecdsa",
+			ECDSACurve: "P-256",
+		}
+		config.Outputs.PublicKeyPath = dir + "/root.pubkey.pem"
+		config.Outputs.PKCS11ConfigPath = dir + "/root.json"
+		config.Outputs.CertificatePath = dir + "/root.cert.pem"
+		config.CertProfile = certProfile{
+			SignatureAlgorithm: "ECDSAWithSHA256",
+			CommonName:         "d",
+			Organization:       "e",
+			Country:            "f",
+			NotBefore:          "2020-01-01 00:00:00",
+			NotAfter:           "2040-01-01 00:00:00",
+			KeyUsages:          []string{"Cert Sign", "CRL Sign"},
+		}
+		return config
+	}
+	test.AssertNotError(t, goodConfig().validate(), "validate failed for a good config")
+
+	// The key generation portion is validated.
+	config := goodConfig()
+	config.Key.Type = ""
+	err := config.validate()
+	test.AssertError(t, err, "validate didn't fail")
+	test.AssertEquals(t, err.Error(), "key.type is required")
+
+	// The root portion is validated.
+	config = goodConfig()
+	config.Outputs.CertificatePath = ""
+	err = config.validate()
+	test.AssertError(t, err, "validate didn't fail")
+	test.AssertEquals(t, err.Error(), "outputs.certificate-path is required")
+
+	config = goodConfig()
+	config.CertProfile.NotAfter = ""
+	err = config.validate()
+	test.AssertError(t, err, "validate didn't fail")
+	test.AssertEquals(t, err.Error(), "not-after is required")
+}
+
+func TestExplainLints(t *testing.T) {
+	var out strings.Builder
+	err := explainLints(&out, "root")
+	test.AssertNotError(t, err, "explainLints failed for root")
+	test.AssertContains(t, out.String(), "e_ca_crl_sign_not_set")
+	test.AssertNotContains(t, out.String(), "e_subordinate_ca_cert_asserts_ev_policy")
+
+	out.Reset()
+	err = explainLints(&out, "intermediate")
+	test.AssertNotError(t, err, "explainLints failed for intermediate")
+	test.AssertContains(t, out.String(), "e_subordinate_ca_cert_asserts_ev_policy")
+
+	err = explainLints(&out, "crl")
+	test.AssertError(t, err, "explainLints didn't fail for an unknown type")
+}
+
+func TestLintExcludeSources(t *testing.T) {
+	exclude := lintExcludeSources("intermediate", false)
+	test.Assert(t, slices.Contains(exclude, lint.EtsiEsi), "ETSI lints aren't excluded without qcStatements")
+	test.Assert(t, slices.Contains(exclude, lint.CABFEVGuidelines), "EV lints aren't excluded")
+	test.Assert(t, slices.Contains(exclude, lints.LetsEncryptCPSRoot), "root lints aren't excluded from an intermediate")
+
+	exclude = lintExcludeSources("intermediate", true)
+	test.Assert(t, !slices.Contains(exclude, lint.EtsiEsi), "ETSI lints are excluded with qcStatements")
+	test.Assert(t, slices.Contains(exclude, lint.CABFEVGuidelines), "EV lints aren't excluded with qcStatements")
+
+	ext, err := qcStatementsExtension([]qcStatementConfig{{OID: "0.4.0.1862.1.1"}})
+	test.AssertNotError(t, err, "qcStatementsExtension failed")
+	test.Assert(t, !hasQCStatements(&x509.Certificate{}), "hasQCStatements true without the extension")
+	test.Assert(t, hasQCStatements(&x509.Certificate{ExtraExtensions: []pkix.Extension{ext}}), "hasQCStatements false with the extension")
+}
+
+func TestExplainLintsMatchesIssuance(t *testing.T) {
+	s, ctx := pkcs11helpers.NewSessionWithMock()
+	ctx.GenerateRandomFunc = realRand
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	pubBytes, err := x509.MarshalPKIXPublicKey(k.Public())
+	test.AssertNotError(t, err, "failed to marshal test key")
+	profile := &certProfile{
+		SignatureAlgorithm: "ECDSAWithSHA256",
+		CommonName:         "common name",
+		Organization:       "organization",
+		Country:            "US",
+		NotBefore:          "2020-01-01 00:00:00",
+		NotAfter:           "2040-01-01 00:00:00",
+		KeyUsages:          []string{"Cert Sign", "CRL Sign"},
+	}
+	template, err := makeTemplate(newRandReader(s), profile, pubBytes, nil, rootCert)
+	test.AssertNotError(t, err, "makeTemplate failed")
+	test.AssertEquals(t, lintProfile(template, template), "root")
+
+	_, report, err := issueLintCertWithReport(template, template, k.Public(), &wrappedSigner{k}, certUniqueIDs{}, []string{"n_ca_digital_signature_not_set"}, "")
+	test.AssertNotError(t, err, "linting failed")
+	var linted []string
+	for _, entry := range report {
+		linted = append(linted, entry.Name)
+	}
+	slices.Sort(linted)
+
+	var out strings.Builder
+	err = explainLints(&out, "root")
+	test.AssertNotError(t, err, "explainLints failed for root")
+	var explained []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		explained = append(explained, strings.Fields(line)[0])
+	}
+	test.AssertDeepEquals(t, linted, explained)
+}
+
+func TestPKCS11ConfigConfigValidate(t *testing.T) {
+	config := pkcs11ConfigConfig{
+		PKCS11: PKCS11KeyGenConfig{
+			Module:     "module",
+			StoreLabel: "label",
+		},
+	}
+	err := config.validate()
+	test.AssertError(t, err, "validate didn't fail without outputs")
+	test.AssertEquals(t, err.Error(), "outputs.public-key-path is required")
+
+	config.Outputs.PublicKeyPath = "path"
+	err = config.validate()
+	test.AssertError(t, err, "validate didn't fail without outputs.pkcs11-config-path")
+	test.AssertEquals(t, err.Error(), "outputs.pkcs11-config-path is required")
+
+	config.Outputs.PKCS11ConfigPath = "path.json"
+	test.AssertNotError(t, config.validate(), "validate failed for a good config")
+
+	config.PKCS11.InitToken = &initTokenConfig{SOPINEnvVar: "SO_PIN", TokenLabel: "token"}
+	err = config.validate()
+	test.AssertError(t, err, "validate didn't fail with pkcs11.init-token")
+	test.AssertEquals(t, err.Error(), "pkcs11.init-token cannot be set, as the key must already exist")
+}
+
+func TestWrappingKeyConfigValidate(t *testing.T) {
+	config := wrappingKeyConfig{
+		PKCS11: PKCS11KeyGenConfig{
+			Module: "module",
+		},
+	}
+	err := config.validate()
+	test.AssertError(t, err, "validate didn't fail without pkcs11.store-key-with-label")
+	test.AssertEquals(t, err.Error(), "pkcs11.store-key-with-label is required")
+
+	config.PKCS11.StoreLabel = "wrapping key"
+	for _, keyLen := range []uint{0, 64, 192, 512} {
+		config.Key.AESKeyLength = keyLen
+		err = config.validate()
+		test.AssertError(t, err, fmt.Sprintf("validate didn't fail with an unsupported key.aes-key-length %d", keyLen))
+		test.AssertEquals(t, err.Error(), "key.aes-key-length can only be 128 or 256")
+	}
+
+	for _, keyLen := range []uint{128, 256} {
+		config.Key.AESKeyLength = keyLen
+		test.AssertNotError(t, config.validate(), fmt.Sprintf("validate failed with key.aes-key-length %d", keyLen))
+	}
+}
+
+func TestPKCS11UserType(t *testing.T) {
+	err := PKCS11KeyGenConfig{Module: "module", StoreLabel: "label", UserType: "admin"}.validate()
+	test.AssertError(t, err, "validate didn't fail with an unknown pkcs11.user-type")
+	test.AssertEquals(t, err.Error(), "pkcs11.user-type is \"admin\", which is not one of \"user\" or \"so\"")
+	err = PKCS11SigningConfig{Module: "module", SigningLabel: "label", UserType: "admin"}.validate()
+	test.AssertError(t, err, "validate didn't fail with an unknown pkcs11.user-type")
+	test.AssertEquals(t, err.Error(), "pkcs11.user-type is \"admin\", which is not one of \"user\" or \"so\"")
+
+	var gotUserType uint
+	initializeSession = func(module string, slot uint, userType uint, pin string) (*pkcs11helpers.Session, error) {
+		gotUserType = userType
+		return nil, errors.New("fake backend")
+	}
+	t.Cleanup(func() { initializeSession = pkcs11helpers.Initialize })
+
+	for _, tc := range []struct {
+		userType string
+		want     uint
+	}{
+		{"", pkcs11.CKU_USER},
+		{"user", pkcs11.CKU_USER},
+		{"so", pkcs11.CKU_SO},
+	} {
+		_, _, err = openSigner(PKCS11SigningConfig{Module: "module", SigningLabel: "label", UserType: tc.userType}, nil)
+		test.AssertError(t, err, "openSigner didn't fail with the fake backend")
+		test.AssertEquals(t, gotUserType, tc.want)
+	}
+
+	// Key generation after token initialization is done by the normal user.
+	config := PKCS11KeyGenConfig{UserType: "so"}
+	test.AssertEquals(t, config.sessionUserType(), uint(pkcs11.CKU_SO))
+	config.InitToken = &initTokenConfig{}
+	test.AssertEquals(t, config.sessionUserType(), uint(pkcs11.CKU_USER))
+}
+
+func TestPKCS11KeygenMechanism(t *testing.T) {
+	err := PKCS11KeyGenConfig{Module: "module", StoreLabel: "label", KeygenMechanism: "CKM_DSA_KEY_PAIR_GEN"}.validate()
+	test.AssertError(t, err, "validate didn't fail with an unknown pkcs11.keygen-mechanism")
+	test.AssertEquals(t, err.Error(), `pkcs11.keygen-mechanism is "CKM_DSA_KEY_PAIR_GEN", which is not one of "CKM_RSA_PKCS_KEY_PAIR_GEN", "CKM_RSA_X9_31_KEY_PAIR_GEN", "CKM_EC_KEY_PAIR_GEN", or "CKM_ML_DSA_KEY_PAIR_GEN"`)
+
+	kc := keyConfig{
+		PKCS11: PKCS11KeyGenConfig{Module: "module", StoreLabel: "label", KeygenMechanism: "CKM_RSA_X9_31_KEY_PAIR_GEN"},
+		Key:    keyGenConfig{Type: "ecdsa", ECDSACurve: "P-256"},
+	}
+	kc.Outputs.PublicKeyPath = "path"
+	err = kc.validate()
+	test.AssertError(t, err, "validate didn't fail with an RSA pkcs11.keygen-mechanism for an ECDSA key")
+	test.AssertEquals(t, err.Error(), "pkcs11.keygen-mechanism CKM_RSA_X9_31_KEY_PAIR_GEN can't be used if key.type = 'ecdsa'")
+
+	wkc := wrappingKeyConfig{PKCS11: PKCS11KeyGenConfig{Module: "module", StoreLabel: "label", KeygenMechanism: "CKM_EC_KEY_PAIR_GEN"}}
+	wkc.Key.AESKeyLength = 256
+	err = wkc.validate()
+	test.AssertError(t, err, "validate didn't fail with pkcs11.keygen-mechanism for a wrapping key")
+	test.AssertEquals(t, err.Error(), "pkcs11.keygen-mechanism cannot be set, as no key pair is generated")
+
+	var gotMechanism []*pkcs11.Mechanism
+	initializeSession = func(module string, slot uint, userType uint, pin string) (*pkcs11helpers.Session, error) {
+		ctx := setupCtx()
+		ctx.GenerateKeyPairFunc = func(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, _, _ []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error) {
+			gotMechanism = m
+			return 0, 0, errors.New("fake backend")
+		}
+		return &pkcs11helpers.Session{Module: &ctx}, nil
+	}
+	t.Cleanup(func() { initializeSession = pkcs11helpers.Initialize })
+
+	for _, tc := range []struct {
+		name      string
+		mechanism string
+		key       string
+		want      uint
+	}{
+		{"default rsa", "", "type: rsa\n    rsa-mod-length: 2048", pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN},
+		{"rsa x9.31", "CKM_RSA_X9_31_KEY_PAIR_GEN", "type: rsa\n    rsa-mod-length: 2048", pkcs11.CKM_RSA_X9_31_KEY_PAIR_GEN},
+		{"default ecdsa", "", "type: ecdsa\n    ecdsa-curve: P-256", pkcs11.CKM_EC_KEY_PAIR_GEN},
+		{"default mldsa", "", "type: mldsa\n    mldsa-param-set: \"65\"", ckmMLDSAKeyPairGen},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gotMechanism = nil
+			config := fmt.Sprintf(`ceremony-type: key
+pkcs11:
+    module: module
+    store-key-with-label: label
+    keygen-mechanism: %q
+key:
+    %s
+outputs:
+    public-key-path: %s
+`, tc.mechanism, tc.key, t.TempDir()+"/pubkey.pem")
+			if tc.mechanism == "" {
+				config = strings.Replace(config, "    keygen-mechanism: \"\"\n", "", 1)
+			}
+			err := keyCeremony([]byte(config), false, "")
+			test.AssertError(t, err, "key ceremony didn't fail with the fake backend")
+			test.AssertContains(t, err.Error(), "fake backend")
+			test.AssertEquals(t, len(gotMechanism), 1)
+			test.AssertEquals(t, gotMechanism[0].Mechanism, tc.want)
+		})
+	}
+}
+
+func TestOCSPRespConfigIncludeChain(t *testing.T) {
+	var config ocspRespConfig
+	config.PKCS11 = PKCS11SigningConfig{Module: "module", SigningLabel: "label"}
+	config.Inputs.CertificatePath = "path"
+	config.Inputs.IssuerCertificatePath = "path"
+	config.Outputs.ResponsePath = "path"
+	config.OCSPProfile.ThisUpdate = "this-update"
+	config.OCSPProfile.NextUpdate = "next-update"
+	config.OCSPProfile.Status = "good"
+
+	config.Inputs.DelegatedIssuerBundlePath = "bundle"
+	err := config.validate()
+	test.AssertError(t, err, "validate didn't fail with a bundle but without include-chain")
+	test.AssertEquals(t, err.Error(), "inputs.delegated-issuer-bundle-path can only be set if ocsp-profile.include-chain is true")
+
+	config.OCSPProfile.IncludeChain = true
+	err = config.validate()
+	test.AssertError(t, err, "validate didn't fail with include-chain but without a delegated issuer")
+	test.AssertEquals(t, err.Error(), "ocsp-profile.include-chain requires inputs.delegated-issuer-certificate-path")
+
+	config.Inputs.DelegatedIssuerCertificatePath = "path"
+	config.Inputs.DelegatedIssuerBundlePath = ""
+	err = config.validate()
+	test.AssertError(t, err, "validate didn't fail with include-chain but without a bundle")
+	test.AssertEquals(t, err.Error(), "ocsp-profile.include-chain requires inputs.delegated-issuer-bundle-path")
+
+	config.Inputs.DelegatedIssuerBundlePath = "bundle"
+	test.AssertNotError(t, config.validate(), "validate failed with include-chain, a delegated issuer and a bundle")
 }
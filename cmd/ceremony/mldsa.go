@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/letsencrypt/boulder/pkcs11helpers"
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS#11 v3.2 constants for ML-DSA, which the pkcs11 package doesn't define
+// yet.
+const (
+	ckmMLDSAKeyPairGen      = 0x1c
+	ckaParameterSet         = 0x61d
+	ckpMLDSA44         uint = 0x1
+	ckpMLDSA65         uint = 0x2
+	ckpMLDSA87         uint = 0x3
+)
+
+// mldsaParameterSet describes one of the ML-DSA parameter sets from FIPS 204.
+type mldsaParameterSet struct {
+	// name is the key.mldsa-param-set value which selects the parameter set.
+	name string
+	// ckp is the CKA_PARAMETER_SET value which selects it on the device.
+	ckp uint
+	// oid identifies it in a SubjectPublicKeyInfo.
+	oid asn1.ObjectIdentifier
+	// publicKeyLen is the length of an encoded public key, in bytes.
+	publicKeyLen int
+}
+
+var mldsaParameterSets = map[string]mldsaParameterSet{
+	"44": {"44", ckpMLDSA44, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 17}, 1312},
+	"65": {"65", ckpMLDSA65, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 18}, 1952},
+	"87": {"87", ckpMLDSA87, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 3, 19}, 2592},
+}
+
+// mldsaPublicKey is an encoded ML-DSA public key. crypto/x509 can't yet marshal
+// or parse ML-DSA keys, so the ceremony tool does so itself.
+type mldsaPublicKey struct {
+	params mldsaParameterSet
+	key    []byte
+}
+
+// Equal reports whether x is the same ML-DSA public key as k.
+func (k *mldsaPublicKey) Equal(x crypto.PublicKey) bool {
+	xk, ok := x.(*mldsaPublicKey)
+	return ok && k.params.name == xk.params.name && bytes.Equal(k.key, xk.key)
+}
+
+// subjectPublicKeyInfo is the ASN.1 structure of a public key, from RFC 5280
+// Section 4.1.
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// marshalPKIX returns the DER encoding of k's SubjectPublicKeyInfo. The
+// algorithm identifier has no parameters.
+func (k *mldsaPublicKey) marshalPKIX() ([]byte, error) {
+	return asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: k.params.oid},
+		PublicKey: asn1.BitString{Bytes: k.key, BitLength: 8 * len(k.key)},
+	})
+}
+
+// parseMLDSAPublicKey parses the DER encoded SubjectPublicKeyInfo der. If it
+// doesn't contain an ML-DSA public key it returns nil, and no error, so that
+// the caller can fall back to x509.ParsePKIXPublicKey.
+func parseMLDSAPublicKey(der []byte) (*mldsaPublicKey, error) {
+	var spki subjectPublicKeyInfo
+	rest, err := asn1.Unmarshal(der, &spki)
+	if err != nil || len(rest) != 0 {
+		return nil, nil
+	}
+	for _, params := range mldsaParameterSets {
+		if !spki.Algorithm.Algorithm.Equal(params.oid) {
+			continue
+		}
+		if len(spki.Algorithm.Parameters.FullBytes) != 0 {
+			return nil, fmt.Errorf("ML-DSA-%s public key algorithm identifier has parameters", params.name)
+		}
+		if spki.PublicKey.BitLength != 8*params.publicKeyLen || len(spki.PublicKey.Bytes) != params.publicKeyLen {
+			return nil, fmt.Errorf("ML-DSA-%s public key is %d bits, expected %d", params.name, spki.PublicKey.BitLength, 8*params.publicKeyLen)
+		}
+		return &mldsaPublicKey{params: params, key: spki.PublicKey.Bytes}, nil
+	}
+	return nil, nil
+}
+
+// marshalPKIXPublicKey is like x509.MarshalPKIXPublicKey, but also supports
+// ML-DSA public keys.
+func marshalPKIXPublicKey(pubKey crypto.PublicKey) ([]byte, error) {
+	if k, ok := pubKey.(*mldsaPublicKey); ok {
+		return k.marshalPKIX()
+	}
+	return x509.MarshalPKIXPublicKey(pubKey)
+}
+
+// mldsaArgs constructs the private and public key template attributes sent to
+// the device and specifies that mechanism should be used. params specifies the
+// parameter set of the key pair to be generated on the device.
+func mldsaArgs(label string, params mldsaParameterSet, keyID []byte, mechanism uint) generateArgs {
+	return generateArgs{
+		mechanism: []*pkcs11.Mechanism{
+			pkcs11.NewMechanism(mechanism, nil),
+		},
+		publicAttrs: []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			// Allow the key to verify signatures
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			// Set requested parameter set
+			pkcs11.NewAttribute(ckaParameterSet, params.ckp),
+		},
+		privateAttrs: []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			// Prevent attributes being retrieved
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			// Prevent the key being extracted from the device
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+			// Allow the key to create signatures
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		},
+	}
+}
+
+// mldsaPub extracts the generated public key, specified by the provided object
+// handle, and checks that it has the requested parameter set and the length
+// that parameter set requires.
+func mldsaPub(session *pkcs11helpers.Session, object pkcs11.ObjectHandle, params mldsaParameterSet) (*mldsaPublicKey, error) {
+	attrs, err := session.GetAttributeValue(object, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(ckaParameterSet, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve key attributes: %w", err)
+	}
+
+	var paramSet, value []byte
+	for _, a := range attrs {
+		switch a.Type {
+		case ckaParameterSet:
+			paramSet = a.Value
+		case pkcs11.CKA_VALUE:
+			value = a.Value
+		}
+	}
+	if paramSet == nil || value == nil {
+		return nil, errors.New("Couldn't retrieve CKA_PARAMETER_SET and CKA_VALUE")
+	}
+	if !bytes.Equal(paramSet, pkcs11.NewAttribute(ckaParameterSet, params.ckp).Value) {
+		return nil, errors.New("returned CKA_PARAMETER_SET doesn't match expected parameter set")
+	}
+	if len(value) != params.publicKeyLen {
+		return nil, fmt.Errorf("returned CKA_VALUE is %d bytes, expected %d", len(value), params.publicKeyLen)
+	}
+	log.Printf("\tParameter set: ML-DSA-%s\n", params.name)
+	log.Printf("\tPublic key: %X\n", value)
+	return &mldsaPublicKey{params: params, key: value}, nil
+}
+
+// mldsaGenerate is used to generate an ML-DSA key pair with the parameter set
+// specified by paramSet, using the key pair generation mechanism specified by
+// mechanism. It returns the public part of the generated key pair
+// and the random key ID that the HSM uses to identify the key pair. Unlike the
+// RSA and ECDSA key pairs, the key pair isn't checked by signing a test
+// message, since the tool can't yet verify ML-DSA signatures.
+func mldsaGenerate(session *pkcs11helpers.Session, label, paramSet string, mechanism uint) (*mldsaPublicKey, []byte, error) {
+	params, present := mldsaParameterSets[paramSet]
+	if !present {
+		return nil, nil, fmt.Errorf("ML-DSA parameter set %q not supported", paramSet)
+	}
+	keyID := make([]byte, 4)
+	_, err := newRandReader(session).Read(keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Generating ML-DSA-%s key with ID %x\n", params.name, keyID)
+	args := mldsaArgs(label, params, keyID, mechanism)
+	pub, _, err := session.GenerateKeyPair(args.mechanism, args.publicAttrs, args.privateAttrs)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Println("Key generated")
+	log.Println("Extracting public key")
+	pk, err := mldsaPub(session, pub, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Println("Extracted public key")
+	return pk, keyID, nil
+}
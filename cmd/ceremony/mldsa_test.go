@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/letsencrypt/boulder/pkcs11helpers"
+	"github.com/letsencrypt/boulder/test"
+	"github.com/miekg/pkcs11"
+)
+
+// setMLDSAGenerateFuncs makes ctx return a random public key of the given
+// parameter set, which is also returned, for any object.
+func setMLDSAGenerateFuncs(ctx *pkcs11helpers.MockCtx, params mldsaParameterSet) []byte {
+	key := make([]byte, params.publicKeyLen)
+	_, _ = rand.Read(key)
+	ctx.GetAttributeValueFunc = func(pkcs11.SessionHandle, pkcs11.ObjectHandle, []*pkcs11.Attribute) ([]*pkcs11.Attribute, error) {
+		return []*pkcs11.Attribute{
+			pkcs11.NewAttribute(ckaParameterSet, params.ckp),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, key),
+		}, nil
+	}
+	return key
+}
+
+func TestMLDSAPub(t *testing.T) {
+	s, ctx := pkcs11helpers.NewSessionWithMock()
+	params := mldsaParameterSets["65"]
+
+	// test we fail when the attributes can't be retrieved
+	ctx.GetAttributeValueFunc = func(pkcs11.SessionHandle, pkcs11.ObjectHandle, []*pkcs11.Attribute) ([]*pkcs11.Attribute, error) {
+		return nil, errors.New("bad")
+	}
+	_, err := mldsaPub(s, 0, params)
+	test.AssertError(t, err, "mldsaPub didn't fail when GetAttributeValue failed")
+
+	// test we fail to construct key with a non-matching parameter set
+	setMLDSAGenerateFuncs(ctx, mldsaParameterSets["44"])
+	_, err = mldsaPub(s, 0, params)
+	test.AssertError(t, err, "mldsaPub didn't fail with non-matching parameter set")
+	test.AssertEquals(t, err.Error(), "returned CKA_PARAMETER_SET doesn't match expected parameter set")
+
+	// test we fail to construct key with the wrong length
+	ctx.GetAttributeValueFunc = func(pkcs11.SessionHandle, pkcs11.ObjectHandle, []*pkcs11.Attribute) ([]*pkcs11.Attribute, error) {
+		return []*pkcs11.Attribute{
+			pkcs11.NewAttribute(ckaParameterSet, params.ckp),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, make([]byte, 1312)),
+		}, nil
+	}
+	_, err = mldsaPub(s, 0, params)
+	test.AssertError(t, err, "mldsaPub didn't fail with the wrong key length")
+	test.AssertEquals(t, err.Error(), "returned CKA_VALUE is 1312 bytes, expected 1952")
+
+	// test we don't fail with the correct attributes
+	key := setMLDSAGenerateFuncs(ctx, params)
+	pk, err := mldsaPub(s, 0, params)
+	test.AssertNotError(t, err, "mldsaPub failed with valid attributes")
+	test.AssertByteEquals(t, pk.key, key)
+}
+
+func TestMLDSAGenerate(t *testing.T) {
+	ctx := setupCtx()
+	s := &pkcs11helpers.Session{Module: &ctx, Session: 0}
+
+	_, _, err := mldsaGenerate(s, "", "50", ckmMLDSAKeyPairGen)
+	test.AssertError(t, err, "mldsaGenerate didn't fail with an unknown parameter set")
+
+	// Test mldsaGenerate fails when GenerateKeyPair fails
+	ctx.GenerateKeyPairFunc = func(pkcs11.SessionHandle, []*pkcs11.Mechanism, []*pkcs11.Attribute, []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error) {
+		return 0, 0, errors.New("bad")
+	}
+	_, _, err = mldsaGenerate(s, "", "87", ckmMLDSAKeyPairGen)
+	test.AssertError(t, err, "mldsaGenerate didn't fail on GenerateKeyPair error")
+
+	// Test mldsaGenerate requests the parameter set with the ML-DSA key pair
+	// generation mechanism
+	var mechanism []*pkcs11.Mechanism
+	var publicAttrs []*pkcs11.Attribute
+	ctx.GenerateKeyPairFunc = func(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, pub []*pkcs11.Attribute, _ []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error) {
+		mechanism, publicAttrs = m, pub
+		return 0, 0, nil
+	}
+	params := mldsaParameterSets["87"]
+	key := setMLDSAGenerateFuncs(&ctx, params)
+	pk, keyID, err := mldsaGenerate(s, "", "87", ckmMLDSAKeyPairGen)
+	test.AssertNotError(t, err, "mldsaGenerate failed")
+	test.AssertByteEquals(t, pk.key, key)
+	test.AssertByteEquals(t, keyID, []byte{1, 2, 3, 0})
+	test.AssertEquals(t, len(mechanism), 1)
+	test.AssertEquals(t, mechanism[0].Mechanism, uint(ckmMLDSAKeyPairGen))
+	var paramSet []byte
+	for _, attr := range publicAttrs {
+		if attr.Type == ckaParameterSet {
+			paramSet = attr.Value
+		}
+	}
+	test.AssertByteEquals(t, paramSet, pkcs11.NewAttribute(ckaParameterSet, ckpMLDSA87).Value)
+}
+
+func TestGenerateKeyMLDSA(t *testing.T) {
+	tmp := t.TempDir()
+
+	ctx := setupCtx()
+	setMLDSAGenerateFuncs(&ctx, mldsaParameterSets["65"])
+	keyPath := path.Join(tmp, "test-mldsa-key.pem")
+	s := &pkcs11helpers.Session{Module: &ctx, Session: 0}
+	keyInfo, err := generateKey(s, "", keyPath, keyGenConfig{
+		Type:          "mldsa",
+		MLDSAParamSet: "65",
+	}, ckmMLDSAKeyPairGen)
+	test.AssertNotError(t, err, "Failed to generate ML-DSA key")
+
+	diskKeyBytes, err := os.ReadFile(keyPath)
+	test.AssertNotError(t, err, "Failed to load key from disk")
+	block, _ := pem.Decode(diskKeyBytes)
+	test.AssertEquals(t, block.Type, "PUBLIC KEY")
+	test.AssertByteEquals(t, block.Bytes, keyInfo.der)
+
+	diskKey, der, err := loadPubKey(keyPath)
+	test.AssertNotError(t, err, "Failed to load ML-DSA public key")
+	test.AssertByteEquals(t, der, keyInfo.der)
+	test.Assert(t, keyInfo.key.(*mldsaPublicKey).Equal(diskKey), "loaded key doesn't match the generated key")
+}
+
+func TestMLDSAPublicKeyPKIX(t *testing.T) {
+	for name, params := range mldsaParameterSets {
+		t.Run(name, func(t *testing.T) {
+			key := &mldsaPublicKey{params: params, key: bytes.Repeat([]byte{0xaa}, params.publicKeyLen)}
+			der, err := marshalPKIXPublicKey(key)
+			test.AssertNotError(t, err, "failed to marshal ML-DSA public key")
+
+			// The algorithm identifier has no parameters, and the key is
+			// the whole of the subject public key.
+			var spki struct {
+				Algorithm struct{ Algorithm asn1.ObjectIdentifier }
+				PublicKey asn1.BitString
+			}
+			rest, err := asn1.Unmarshal(der, &spki)
+			test.AssertNotError(t, err, "failed to unmarshal SubjectPublicKeyInfo")
+			test.AssertEquals(t, len(rest), 0)
+			test.Assert(t, spki.Algorithm.Algorithm.Equal(params.oid), "wrong algorithm OID")
+			test.AssertByteEquals(t, spki.PublicKey.RightAlign(), key.key)
+
+			parsed, err := parseMLDSAPublicKey(der)
+			test.AssertNotError(t, err, "failed to parse ML-DSA public key")
+			test.Assert(t, key.Equal(parsed), "parsed key doesn't match")
+		})
+	}
+
+	// A key of the wrong length for its parameter set is rejected.
+	short, err := asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: mldsaParameterSets["87"].oid},
+		PublicKey: asn1.BitString{Bytes: make([]byte, 1952), BitLength: 8 * 1952},
+	})
+	test.AssertNotError(t, err, "failed to marshal short key")
+	_, err = parseMLDSAPublicKey(short)
+	test.AssertError(t, err, "parsed an ML-DSA-87 key of the wrong length")
+
+	// Other public keys are left to crypto/x509.
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate ECDSA key")
+	ecDER, err := x509.MarshalPKIXPublicKey(k.Public())
+	test.AssertNotError(t, err, "failed to marshal ECDSA key")
+	parsed, err := parseMLDSAPublicKey(ecDER)
+	test.AssertNotError(t, err, "parseMLDSAPublicKey failed for an ECDSA key")
+	test.Assert(t, parsed == nil, "parseMLDSAPublicKey returned a key for an ECDSA key")
+}
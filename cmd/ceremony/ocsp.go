@@ -1,20 +1,46 @@
 package main
 
 import (
+	"bytes"
 	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	"math/big"
 	"time"
 
+	"github.com/jmhodges/clock"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
 	"golang.org/x/crypto/ocsp"
 )
 
-func generateOCSPResponse(signer crypto.Signer, issuer, delegatedIssuer, cert *x509.Certificate, thisUpdate, nextUpdate time.Time, status int) ([]byte, error) {
+// oidOCSPArchiveCutoff is the id-pkix-ocsp-archive-cutoff extension OID from
+// RFC 6960 Section 4.4.4.
+var oidOCSPArchiveCutoff = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 6}
+
+// ocspClock is the clock which signed OCSP responses' thisUpdate and
+// archiveCutoff are checked against. It is replaced by a fake clock in tests.
+var ocspClock = clock.New()
+
+// generateOCSPResponse creates and signs an OCSP response for cert. The
+// response identifies its responder by name, unless responderIDByKey is true,
+// in which case it identifies the responder by the hash of its public key. If
+// archiveCutoff is non-zero the response includes an archive cutoff extension.
+// revokedAt is the revocation time of a response whose status is revoked.
+// issuerHash is the hash used for the issuer name and key hashes in the
+// response's CertID, and defaults to SHA-1 if zero. If nonce is non-empty the
+// response includes a nonce extension containing it.
+func generateOCSPResponse(signer crypto.Signer, issuer, delegatedIssuer, cert *x509.Certificate, thisUpdate, nextUpdate, archiveCutoff time.Time, status int, revokedAt time.Time, issuerHash crypto.Hash, responderIDByKey bool, nonce []byte) ([]byte, error) {
 	err := cert.CheckSignatureFrom(issuer)
 	if err != nil {
-		return nil, fmt.Errorf("invalid signature on certificate from issuer: %s", err)
+		return nil, fmt.Errorf("invalid signature on certificate from issuer: %w", err)
 	}
 
 	signingCert := issuer
@@ -22,7 +48,7 @@ func generateOCSPResponse(signer crypto.Signer, issuer, delegatedIssuer, cert *x
 		signingCert = delegatedIssuer
 		err := delegatedIssuer.CheckSignatureFrom(issuer)
 		if err != nil {
-			return nil, fmt.Errorf("invalid signature on delegated issuer from issuer: %s", err)
+			return nil, fmt.Errorf("invalid signature on delegated issuer from issuer: %w", err)
 		}
 
 		gotOCSPEKU := false
@@ -51,19 +77,459 @@ func generateOCSPResponse(signer crypto.Signer, issuer, delegatedIssuer, cert *x
 		ThisUpdate:   thisUpdate,
 		NextUpdate:   nextUpdate,
 		Status:       status,
+		RevokedAt:    revokedAt,
+		IssuerHash:   issuerHash,
 	}
 	if delegatedIssuer != nil {
 		template.Certificate = delegatedIssuer
 	}
+	if !archiveCutoff.IsZero() {
+		// ocsp.CreateResponse sets producedAt to the current time truncated to
+		// the minute, so a cutoff which isn't after that can't be after it.
+		if archiveCutoff.After(ocspClock.Now().Truncate(time.Minute)) {
+			return nil, errors.New("archiveCutoff must not be after the response's producedAt")
+		}
+		cutoffDER, err := asn1.MarshalWithParams(archiveCutoff.UTC(), "generalized")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode archiveCutoff: %w", err)
+		}
+		// ocsp.CreateResponse places ExtraExtensions in the singleExtensions of
+		// the response, where RFC 6960 Section 4.4.4 requires this extension.
+		template.ExtraExtensions = []pkix.Extension{{Id: oidOCSPArchiveCutoff, Value: cutoffDER}}
+	}
+
+	// ocsp.CreateResponse can't identify the responder by key or add response
+	// extensions, so if either is needed the response it creates is left
+	// unsigned, and is only signed once it has been modified.
+	modify := responderIDByKey || len(nonce) != 0
+	createSigner := signer
+	if modify {
+		createSigner = unsignedSigner{signer.Public()}
+	}
+	resp, err := ocsp.CreateResponse(issuer, signingCert, template, createSigner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create response: %w", err)
+	}
+	if modify {
+		var responderCert *x509.Certificate
+		if responderIDByKey {
+			responderCert = signingCert
+		}
+		resp, err = signModifiedOCSPResponse(resp, responderCert, nonce, signer)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	resp, err := ocsp.CreateResponse(issuer, signingCert, template, signer)
+	err = checkOCSPResponseCertID(resp, cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	err = checkOCSPResponseCertificates(resp, delegatedIssuer)
+	if err != nil {
+		return nil, err
+	}
+	err = checkOCSPResponseThisUpdateNotInFuture(resp, ocspClock)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create response: %s", err)
+		return nil, err
 	}
 
+	return resp, nil
+}
+
+// encodeOCSPResponse returns the standard base64 encoding of the provided DER
+// encoded OCSP response, followed by a trailing newline.
+func encodeOCSPResponse(resp []byte) []byte {
 	encodedResp := make([]byte, base64.StdEncoding.EncodedLen(len(resp))+1)
 	base64.StdEncoding.Encode(encodedResp, resp)
 	encodedResp[len(encodedResp)-1] = '\n'
+	return encodedResp
+}
+
+// ocspCertID and the structures containing it mirror the ASN.1 structures
+// from RFC 6960 Section 4.2.1, omitting the trailing fields we don't inspect.
+type ocspCertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	CertStatus asn1.RawValue
+	ThisUpdate time.Time `asn1:"generalized"`
+}
+
+type ocspResponseData struct {
+	Version     int `asn1:"optional,default:0,explicit,tag:0"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []ocspSingleResponse
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData ocspResponseData
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspResponseASN1 struct {
+	Status        asn1.Enumerated
+	ResponseBytes ocspResponseBytes `asn1:"explicit,tag:0"`
+}
+
+// ocspIssuerHashes maps the values of ocsp-profile.hash-algorithm to the hash
+// used for the CertID of the response.
+var ocspIssuerHashes = map[string]crypto.Hash{
+	"sha1":   crypto.SHA1,
+	"sha256": crypto.SHA256,
+	"sha384": crypto.SHA384,
+	"sha512": crypto.SHA512,
+}
+
+var ocspHashOIDs = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+// checkOCSPResponseCertID parses the provided DER encoded OCSP response and
+// verifies that the CertID of its single response identifies cert as issued by
+// issuer, as described in RFC 6960 Section 4.1.1.
+func checkOCSPResponseCertID(resp []byte, cert, issuer *x509.Certificate) error {
+	var outer ocspResponseASN1
+	_, err := asn1.Unmarshal(resp, &outer)
+	if err != nil {
+		return fmt.Errorf("failed to parse OCSP response: %w", err)
+	}
+	var basic ocspBasicResponse
+	_, err = asn1.Unmarshal(outer.ResponseBytes.Response, &basic)
+	if err != nil {
+		return fmt.Errorf("failed to parse basic OCSP response: %w", err)
+	}
+	if len(basic.TBSResponseData.Responses) != 1 {
+		return fmt.Errorf("OCSP response contains %d responses, expected 1", len(basic.TBSResponseData.Responses))
+	}
+	certID := basic.TBSResponseData.Responses[0].CertID
+
+	if certID.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+		return fmt.Errorf("OCSP response CertID serial %x doesn't match certificate serial %x", certID.SerialNumber, cert.SerialNumber)
+	}
+
+	hash, ok := ocspHashOIDs[certID.HashAlgorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("OCSP response CertID uses unsupported hash algorithm %s", certID.HashAlgorithm.Algorithm)
+	}
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	_, err = asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki)
+	if err != nil {
+		return fmt.Errorf("failed to parse issuer public key: %w", err)
+	}
+	h := hash.New()
+	h.Write(issuer.RawSubject)
+	if !bytes.Equal(certID.IssuerNameHash, h.Sum(nil)) {
+		return errors.New("OCSP response CertID issuerNameHash doesn't match issuer certificate")
+	}
+	h.Reset()
+	h.Write(spki.PublicKey.RightAlign())
+	if !bytes.Equal(certID.IssuerKeyHash, h.Sum(nil)) {
+		return errors.New("OCSP response CertID issuerKeyHash doesn't match issuer certificate")
+	}
+
+	return nil
+}
+
+// checkOCSPResponseThisUpdateNotInFuture parses the provided DER encoded OCSP
+// response and verifies that the thisUpdate of its single response is not after
+// the current time of clk, since clients treat a response which isn't valid yet
+// as invalid.
+func checkOCSPResponseThisUpdateNotInFuture(resp []byte, clk clock.Clock) error {
+	var outer ocspResponseASN1
+	_, err := asn1.Unmarshal(resp, &outer)
+	if err != nil {
+		return fmt.Errorf("failed to parse OCSP response: %w", err)
+	}
+	var basic ocspBasicResponse
+	_, err = asn1.Unmarshal(outer.ResponseBytes.Response, &basic)
+	if err != nil {
+		return fmt.Errorf("failed to parse basic OCSP response: %w", err)
+	}
+	if len(basic.TBSResponseData.Responses) != 1 {
+		return fmt.Errorf("OCSP response contains %d responses, expected 1", len(basic.TBSResponseData.Responses))
+	}
+	thisUpdate := basic.TBSResponseData.Responses[0].ThisUpdate
+	now := clk.Now()
+	if thisUpdate.After(now) {
+		return fmt.Errorf("signed OCSP response thisUpdate (%s) is after the current time (%s)", thisUpdate, now)
+	}
+	return nil
+}
+
+// ocspBasicResponseRaw mirrors the BasicOCSPResponse structure from RFC 6960
+// Section 4.2.1, leaving the tbsResponseData undecoded so that it can be
+// modified and re-signed.
+type ocspBasicResponseRaw struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+// checkOCSPResponseCertificates parses the provided DER encoded OCSP response
+// and verifies its certs field. A response signed by a delegated responder must
+// include the responder's certificate, so that relying parties can validate it,
+// as described in RFC 6960 Section 4.2.2.2. A response signed directly by the
+// issuer, indicated by a nil delegatedIssuer, must not include any
+// certificates.
+func checkOCSPResponseCertificates(resp []byte, delegatedIssuer *x509.Certificate) error {
+	var outer ocspResponseASN1
+	_, err := asn1.Unmarshal(resp, &outer)
+	if err != nil {
+		return fmt.Errorf("failed to parse OCSP response: %w", err)
+	}
+	var basic ocspBasicResponseRaw
+	_, err = asn1.Unmarshal(outer.ResponseBytes.Response, &basic)
+	if err != nil {
+		return fmt.Errorf("failed to parse basic OCSP response: %w", err)
+	}
+
+	if delegatedIssuer == nil {
+		if len(basic.Certificates) != 0 {
+			return fmt.Errorf("OCSP response signed by the issuer contains %d certificates, expected none", len(basic.Certificates))
+		}
+		return nil
+	}
+	for _, cert := range basic.Certificates {
+		if bytes.Equal(cert.FullBytes, delegatedIssuer.Raw) {
+			return nil
+		}
+	}
+	return errors.New("OCSP response signed by a delegated responder doesn't include the responder's certificate")
+}
+
+// checkOCSPResponderChain verifies that chain is a well-formed chain for the
+// delegated responder certificate responder: responder must be signed by the
+// first certificate in chain, and each certificate in chain by the next.
+func checkOCSPResponderChain(responder *x509.Certificate, chain []*x509.Certificate) error {
+	if len(chain) == 0 {
+		return errors.New("delegated responder chain is empty")
+	}
+	child := responder
+	for i, parent := range chain {
+		err := child.CheckSignatureFrom(parent)
+		if err != nil {
+			return fmt.Errorf("delegated responder chain certificate %d didn't sign the certificate before it: %w", i, err)
+		}
+		child = parent
+	}
+	return nil
+}
+
+// includeOCSPResponderChain replaces the certs field of the provided DER
+// encoded OCSP response, which ocsp.CreateResponse fills with only the
+// delegated responder's certificate, with the responder's certificate followed
+// by chain. The certs field isn't covered by the response's signature, so the
+// response doesn't need to be re-signed.
+func includeOCSPResponderChain(resp []byte, responder *x509.Certificate, chain []*x509.Certificate) ([]byte, error) {
+	err := checkOCSPResponderChain(responder, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	var outer ocspResponseASN1
+	_, err = asn1.Unmarshal(resp, &outer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OCSP response: %w", err)
+	}
+	var basic ocspBasicResponseRaw
+	_, err = asn1.Unmarshal(outer.ResponseBytes.Response, &basic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse basic OCSP response: %w", err)
+	}
+
+	basic.Certificates = []asn1.RawValue{{FullBytes: responder.Raw}}
+	for _, cert := range chain {
+		basic.Certificates = append(basic.Certificates, asn1.RawValue{FullBytes: cert.Raw})
+	}
+
+	outer.ResponseBytes.Response, err = asn1.Marshal(basic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode basic OCSP response: %w", err)
+	}
+	return asn1.Marshal(outer)
+}
+
+// ocspSigAlgHashes maps the signature algorithm OIDs which ocsp.CreateResponse
+// may use to the hash function used to compute the digest which is signed.
+var ocspSigAlgHashes = map[string]crypto.Hash{
+	"1.2.840.113549.1.1.11": crypto.SHA256, // sha256WithRSAEncryption
+	"1.2.840.113549.1.1.12": crypto.SHA384, // sha384WithRSAEncryption
+	"1.2.840.113549.1.1.13": crypto.SHA512, // sha512WithRSAEncryption
+	"1.2.840.10045.4.3.2":   crypto.SHA256, // ecdsa-with-SHA256
+	"1.2.840.10045.4.3.3":   crypto.SHA384, // ecdsa-with-SHA384
+	"1.2.840.10045.4.3.4":   crypto.SHA512, // ecdsa-with-SHA512
+}
+
+// unsignedSigner is a crypto.Signer which returns an empty signature, for
+// creating a response which is modified and signed by signModifiedOCSPResponse
+// without signing it twice.
+type unsignedSigner struct {
+	pub crypto.PublicKey
+}
+
+func (us unsignedSigner) Public() crypto.PublicKey {
+	return us.pub
+}
+
+func (us unsignedSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, nil
+}
+
+// resignOCSPResponse replaces the tbsResponseData of basic, the response
+// contained in outer, with tbsDER, signs it with signer using the response's
+// existing signature algorithm, and returns the DER encoded response.
+func resignOCSPResponse(outer ocspResponseASN1, basic ocspBasicResponseRaw, tbsDER []byte, signer crypto.Signer) ([]byte, error) {
+	hashFunc, ok := ocspSigAlgHashes[basic.SignatureAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, fmt.Errorf("OCSP response uses unsupported signature algorithm %s", basic.SignatureAlgorithm.Algorithm)
+	}
+	h := hashFunc.New()
+	h.Write(tbsDER)
+	// As in ocsp.CreateResponse, rand.Reader is passed for signers which need a
+	// source of randomness. HSM based signers generate their own.
+	signature, err := signer.Sign(rand.Reader, h.Sum(nil), hashFunc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign OCSP response: %w", err)
+	}
+	basic.TBSResponseData = asn1.RawValue{FullBytes: tbsDER}
+	basic.Signature = asn1.BitString{Bytes: signature, BitLength: len(signature) * 8}
+
+	outer.ResponseBytes.Response, err = asn1.Marshal(basic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode basic OCSP response: %w", err)
+	}
+	return asn1.Marshal(outer)
+}
+
+// oidOCSPNonce is the id-pkix-ocsp-nonce extension OID from RFC 8954 Section
+// 2.1.
+var oidOCSPNonce = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
+// RFC 8954 Section 2.1: "Nonce ::= OCTET STRING(SIZE(1..32))"
+const (
+	minOCSPNonceLen = 1
+	maxOCSPNonceLen = 32
+)
+
+// signModifiedOCSPResponse makes the changes to the tbsResponseData of the
+// provided DER encoded OCSP response which ocsp.CreateResponse can't, and signs
+// the result with signer, discarding the response's existing signature. If
+// responderCert is non-nil the byName ResponderID which ocsp.CreateResponse
+// always emits is replaced with the byKey form, containing the SHA-1 hash of
+// responderCert's public key as described in RFC 6960 Section 4.2.1. If nonce
+// is non-empty a nonce extension containing it is added to the
+// responseExtensions, which ocsp.CreateResponse never emits.
+func signModifiedOCSPResponse(resp []byte, responderCert *x509.Certificate, nonce []byte, signer crypto.Signer) ([]byte, error) {
+	var outer ocspResponseASN1
+	_, err := asn1.Unmarshal(resp, &outer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OCSP response: %w", err)
+	}
+	var basic ocspBasicResponseRaw
+	_, err = asn1.Unmarshal(outer.ResponseBytes.Response, &basic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse basic OCSP response: %w", err)
+	}
+
+	// The tbsResponseData is the optional version, the ResponderID,
+	// producedAt, responses, and the optional responseExtensions. All but
+	// the ResponderID and responseExtensions are copied verbatim.
+	input := cryptobyte.String(basic.TBSResponseData.FullBytes)
+	var tbs cryptobyte.String
+	if !input.ReadASN1(&tbs, cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("failed to parse tbsResponseData")
+	}
+	var version cryptobyte.String
+	versionTag := cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()
+	hasVersion := tbs.PeekASN1Tag(versionTag)
+	if hasVersion && !tbs.ReadASN1Element(&version, versionTag) {
+		return nil, errors.New("failed to parse tbsResponseData version")
+	}
+	var responderID cryptobyte.String
+	var responderIDTag cryptobyte_asn1.Tag
+	if !tbs.ReadAnyASN1Element(&responderID, &responderIDTag) {
+		return nil, errors.New("failed to parse tbsResponseData ResponderID")
+	}
+	fields := tbs
+	if !tbs.SkipASN1(cryptobyte_asn1.GeneralizedTime) || !tbs.SkipASN1(cryptobyte_asn1.SEQUENCE) {
+		return nil, errors.New("failed to parse tbsResponseData")
+	}
+	if len(nonce) != 0 && !tbs.Empty() {
+		return nil, errors.New("OCSP response already contains responseExtensions")
+	}
+
+	if responderCert != nil {
+		if responderIDTag != cryptobyte_asn1.Tag(1).Constructed().ContextSpecific() {
+			return nil, errors.New("OCSP response ResponderID isn't byName")
+		}
+		var spki struct {
+			Algorithm pkix.AlgorithmIdentifier
+			PublicKey asn1.BitString
+		}
+		_, err = asn1.Unmarshal(responderCert.RawSubjectPublicKeyInfo, &spki)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse responder public key: %w", err)
+		}
+		keyHash := sha1.Sum(spki.PublicKey.RightAlign())
+		var b cryptobyte.Builder
+		b.AddASN1(cryptobyte_asn1.Tag(2).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+			b.AddASN1OctetString(keyHash[:])
+		})
+		responderID, err = b.Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode ResponderID: %w", err)
+		}
+	}
+
+	var extDER []byte
+	if len(nonce) != 0 {
+		nonceDER, err := asn1.Marshal(nonce)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode nonce: %w", err)
+		}
+		extDER, err = asn1.Marshal(pkix.Extension{Id: oidOCSPNonce, Value: nonceDER})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode nonce extension: %w", err)
+		}
+	}
+
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		if hasVersion {
+			b.AddBytes(version)
+		}
+		b.AddBytes(responderID)
+		b.AddBytes(fields)
+		if extDER != nil {
+			b.AddASN1(cryptobyte_asn1.Tag(1).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddBytes(extDER)
+				})
+			})
+		}
+	})
+	tbsDER, err := b.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tbsResponseData: %w", err)
+	}
 
-	return encodedResp, nil
+	return resignOCSPResponse(outer, basic, tbsDER, signer)
 }
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha1"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
 	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/jmhodges/clock"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/strictyaml"
 	"github.com/letsencrypt/boulder/test"
 )
 
@@ -123,7 +135,7 @@ func TestGenerateOCSPResponse(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := generateOCSPResponse(kA, tc.issuer, tc.delegatedIssuer, tc.cert, tc.thisUpdate, tc.nextUpdate, 0)
+			_, err := generateOCSPResponse(kA, tc.issuer, tc.delegatedIssuer, tc.cert, tc.thisUpdate, tc.nextUpdate, time.Time{}, 0, time.Time{}, 0, false, nil)
 			if err != nil {
 				if tc.expectedError != "" && tc.expectedError != err.Error() {
 					t.Errorf("unexpected error: got %q, want %q", err.Error(), tc.expectedError)
@@ -136,3 +148,759 @@ func TestGenerateOCSPResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeOCSPResponse(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(9),
+		Subject: pkix.Name{
+			CommonName: "cn",
+		},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             time.Time{}.Add(time.Hour * 10),
+		NotAfter:              time.Time{}.Add(time.Hour * 20),
+	}
+	issuerBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to create test issuer")
+	issuer, err := x509.ParseCertificate(issuerBytes)
+	test.AssertNotError(t, err, "failed to parse test issuer")
+
+	resp, err := generateOCSPResponse(k, issuer, nil, issuer, time.Time{}.Add(time.Hour*11), time.Time{}.Add(time.Hour*12), time.Time{}, 0, time.Time{}, 0, false, nil)
+	test.AssertNotError(t, err, "failed to generate OCSP response")
+
+	encoded := encodeOCSPResponse(resp)
+	test.AssertEquals(t, encoded[len(encoded)-1], byte('\n'))
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSuffix(encoded, []byte("\n"))))
+	test.AssertNotError(t, err, "failed to decode base64 OCSP response")
+	test.AssertByteEquals(t, decoded, resp)
+}
+
+func TestGenerateOCSPResponseArchiveCutoff(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(9),
+		Subject: pkix.Name{
+			CommonName: "cn",
+		},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             time.Time{}.Add(time.Hour * 10),
+		NotAfter:              time.Time{}.Add(time.Hour * 20),
+	}
+	issuerBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to create test issuer")
+	issuer, err := x509.ParseCertificate(issuerBytes)
+	test.AssertNotError(t, err, "failed to parse test issuer")
+	thisUpdate := time.Time{}.Add(time.Hour * 11)
+	nextUpdate := time.Time{}.Add(time.Hour * 12)
+
+	// Without an archive cutoff the response has no single extensions.
+	resp, err := generateOCSPResponse(k, issuer, nil, issuer, thisUpdate, nextUpdate, time.Time{}, 0, time.Time{}, 0, false, nil)
+	test.AssertNotError(t, err, "failed to generate OCSP response")
+	parsed, err := ocsp.ParseResponse(resp, issuer)
+	test.AssertNotError(t, err, "failed to parse OCSP response")
+	test.AssertEquals(t, len(parsed.Extensions), 0)
+
+	archiveCutoff := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	fc := clock.NewFake()
+	fc.Set(archiveCutoff.Add(time.Minute))
+	defer func(clk clock.Clock) { ocspClock = clk }(ocspClock)
+	ocspClock = fc
+	resp, err = generateOCSPResponse(k, issuer, nil, issuer, thisUpdate, nextUpdate, archiveCutoff, 0, time.Time{}, 0, false, nil)
+	test.AssertNotError(t, err, "failed to generate OCSP response")
+	parsed, err = ocsp.ParseResponse(resp, issuer)
+	test.AssertNotError(t, err, "failed to parse OCSP response")
+	test.AssertEquals(t, len(parsed.Extensions), 1)
+	test.AssertDeepEquals(t, parsed.Extensions[0].Id, oidOCSPArchiveCutoff)
+	test.Assert(t, !parsed.Extensions[0].Critical, "archive cutoff extension should not be critical")
+	// GeneralizedTime "20200102030405Z"
+	test.AssertByteEquals(t, parsed.Extensions[0].Value, append([]byte{0x18, 0x0f}, "20200102030405Z"...))
+
+	// producedAt is truncated to the minute, so a cutoff later in the same
+	// minute is after it.
+	fc.Set(archiveCutoff)
+	_, err = generateOCSPResponse(k, issuer, nil, issuer, thisUpdate, nextUpdate, archiveCutoff, 0, time.Time{}, 0, false, nil)
+	test.AssertError(t, err, "generateOCSPResponse didn't fail with an archive cutoff after producedAt")
+	test.AssertEquals(t, err.Error(), "archiveCutoff must not be after the response's producedAt")
+}
+
+func TestGenerateOCSPResponseNonce(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(9),
+		Subject:               pkix.Name{CommonName: "issuer"},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             time.Time{}.Add(time.Hour * 10),
+		NotAfter:              time.Time{}.Add(time.Hour * 20),
+	}
+	issuerBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to create test issuer")
+	issuer, err := x509.ParseCertificate(issuerBytes)
+	test.AssertNotError(t, err, "failed to parse test issuer")
+	thisUpdate := time.Time{}.Add(time.Hour * 11)
+	nextUpdate := time.Time{}.Add(time.Hour * 12)
+
+	// responseExtensions returns the responseExtensions of the provided DER
+	// encoded OCSP response, which ocsp.ParseResponse doesn't expose.
+	responseExtensions := func(resp []byte) []pkix.Extension {
+		var outer ocspResponseASN1
+		_, err := asn1.Unmarshal(resp, &outer)
+		test.AssertNotError(t, err, "failed to parse OCSP response")
+		var basic struct {
+			TBSResponseData struct {
+				Version            int `asn1:"optional,default:0,explicit,tag:0"`
+				ResponderID        asn1.RawValue
+				ProducedAt         time.Time `asn1:"generalized"`
+				Responses          []asn1.RawValue
+				ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+			}
+		}
+		_, err = asn1.Unmarshal(outer.ResponseBytes.Response, &basic)
+		test.AssertNotError(t, err, "failed to parse basic OCSP response")
+		return basic.TBSResponseData.ResponseExtensions
+	}
+
+	// Without a nonce the response has no response extensions.
+	resp, err := generateOCSPResponse(k, issuer, nil, issuer, thisUpdate, nextUpdate, time.Time{}, 0, time.Time{}, 0, false, nil)
+	test.AssertNotError(t, err, "failed to generate OCSP response")
+	test.AssertEquals(t, len(responseExtensions(resp)), 0)
+
+	for _, tc := range []struct {
+		name  string
+		nonce []byte
+		byKey bool
+	}{
+		{"one byte", []byte{0x01}, false},
+		{"maximum length", bytes.Repeat([]byte{0xab}, maxOCSPNonceLen), false},
+		{"by key", []byte("nonce"), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			signer := &countingSigner{wrappedSigner: wrappedSigner{k}}
+			resp, err := generateOCSPResponse(signer, issuer, nil, issuer, thisUpdate, nextUpdate, time.Time{}, 0, time.Time{}, 0, tc.byKey, tc.nonce)
+			test.AssertNotError(t, err, "failed to generate OCSP response")
+			test.AssertEquals(t, signer.calls, 1)
+
+			// ocsp.ParseResponse verifies the signature on the response.
+			parsed, err := ocsp.ParseResponse(resp, issuer)
+			test.AssertNotError(t, err, "failed to parse OCSP response")
+			test.AssertEquals(t, len(parsed.Extensions), 0)
+			if tc.byKey {
+				test.Assert(t, len(parsed.ResponderKeyHash) != 0, "response isn't identified by key")
+			}
+
+			exts := responseExtensions(resp)
+			test.AssertEquals(t, len(exts), 1)
+			test.AssertDeepEquals(t, exts[0].Id, oidOCSPNonce)
+			test.Assert(t, !exts[0].Critical, "nonce extension should not be critical")
+			var nonce []byte
+			rest, err := asn1.Unmarshal(exts[0].Value, &nonce)
+			test.AssertNotError(t, err, "failed to parse nonce")
+			test.AssertEquals(t, len(rest), 0)
+			test.AssertByteEquals(t, nonce, tc.nonce)
+		})
+	}
+
+	// A response which already has response extensions isn't given another.
+	resp, err = signModifiedOCSPResponse(resp, nil, []byte{0x02}, k)
+	test.AssertNotError(t, err, "failed to add nonce")
+	_, err = signModifiedOCSPResponse(resp, nil, []byte{0x03}, k)
+	test.AssertError(t, err, "signModifiedOCSPResponse didn't fail for a response with response extensions")
+}
+
+func TestCheckOCSPResponseThisUpdateNotInFuture(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(9),
+		Subject:               pkix.Name{CommonName: "issuer"},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	issuerBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to create test issuer")
+	issuer, err := x509.ParseCertificate(issuerBytes)
+	test.AssertNotError(t, err, "failed to parse test issuer")
+	thisUpdate := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	nextUpdate := thisUpdate.Add(time.Hour)
+
+	fc := clock.NewFake()
+	fc.Set(thisUpdate)
+	defer func(clk clock.Clock) { ocspClock = clk }(ocspClock)
+	ocspClock = fc
+	resp, err := generateOCSPResponse(k, issuer, nil, issuer, thisUpdate, nextUpdate, time.Time{}, 0, time.Time{}, 0, false, nil)
+	test.AssertNotError(t, err, "generateOCSPResponse failed with thisUpdate equal to now")
+
+	test.AssertNotError(t, checkOCSPResponseThisUpdateNotInFuture(resp, fc), "checkOCSPResponseThisUpdateNotInFuture failed with thisUpdate equal to now")
+	fc.Add(time.Hour)
+	test.AssertNotError(t, checkOCSPResponseThisUpdateNotInFuture(resp, fc), "checkOCSPResponseThisUpdateNotInFuture failed with thisUpdate in the past")
+	fc.Set(thisUpdate.Add(-time.Second))
+	err = checkOCSPResponseThisUpdateNotInFuture(resp, fc)
+	test.AssertError(t, err, "checkOCSPResponseThisUpdateNotInFuture didn't fail with thisUpdate in the future")
+	test.AssertContains(t, err.Error(), "is after the current time")
+
+	// generateOCSPResponse refuses to produce a future-dated response.
+	_, err = generateOCSPResponse(k, issuer, nil, issuer, thisUpdate, nextUpdate, time.Time{}, 0, time.Time{}, 0, false, nil)
+	test.AssertError(t, err, "generateOCSPResponse didn't fail with thisUpdate in the future")
+	test.AssertContains(t, err.Error(), "is after the current time")
+}
+
+func TestGenerateOCSPResponseIssuerHash(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(9),
+		Subject: pkix.Name{
+			CommonName: "cn",
+		},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             time.Time{}.Add(time.Hour * 10),
+		NotAfter:              time.Time{}.Add(time.Hour * 20),
+	}
+	issuerBytes, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	test.AssertNotError(t, err, "failed to create test issuer")
+	issuer, err := x509.ParseCertificate(issuerBytes)
+	test.AssertNotError(t, err, "failed to parse test issuer")
+
+	cases := []struct {
+		hashAlgorithm string
+		expectedHash  crypto.Hash
+	}{
+		{"", crypto.SHA1},
+		{"sha1", crypto.SHA1},
+		{"sha256", crypto.SHA256},
+		{"sha384", crypto.SHA384},
+		{"sha512", crypto.SHA512},
+	}
+	for _, tc := range cases {
+		t.Run(tc.hashAlgorithm, func(t *testing.T) {
+			resp, err := generateOCSPResponse(k, issuer, nil, issuer, time.Time{}.Add(time.Hour*11), time.Time{}.Add(time.Hour*12), time.Time{}, 0, time.Time{}, ocspIssuerHashes[tc.hashAlgorithm], false, nil)
+			test.AssertNotError(t, err, "failed to generate OCSP response")
+			parsed, err := ocsp.ParseResponse(resp, issuer)
+			test.AssertNotError(t, err, "failed to parse OCSP response")
+			test.AssertEquals(t, parsed.IssuerHash, tc.expectedHash)
+		})
+	}
+}
+
+func TestCheckOCSPResponseCertID(t *testing.T) {
+	kA, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	kB, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(9),
+		Subject: pkix.Name{
+			CommonName: "cn",
+		},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             time.Time{}.Add(time.Hour * 10),
+		NotAfter:              time.Time{}.Add(time.Hour * 20),
+	}
+	issuerBytes, err := x509.CreateCertificate(rand.Reader, template, template, kA.Public(), kA)
+	test.AssertNotError(t, err, "failed to create test issuer")
+	issuer, err := x509.ParseCertificate(issuerBytes)
+	test.AssertNotError(t, err, "failed to parse test issuer")
+	template.Subject.CommonName = "other cn"
+	otherIssuerBytes, err := x509.CreateCertificate(rand.Reader, template, template, kB.Public(), kB)
+	test.AssertNotError(t, err, "failed to create other test issuer")
+	otherIssuer, err := x509.ParseCertificate(otherIssuerBytes)
+	test.AssertNotError(t, err, "failed to parse other test issuer")
+	template.BasicConstraintsValid, template.IsCA = false, false
+	template.SerialNumber = big.NewInt(10)
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, issuer, kB.Public(), kA)
+	test.AssertNotError(t, err, "failed to create test cert")
+	cert, err := x509.ParseCertificate(certBytes)
+	test.AssertNotError(t, err, "failed to parse test cert")
+
+	resp, err := generateOCSPResponse(kA, issuer, nil, cert, time.Time{}.Add(time.Hour*11), time.Time{}.Add(time.Hour*12), time.Time{}, 0, time.Time{}, 0, false, nil)
+	test.AssertNotError(t, err, "failed to generate OCSP response")
+
+	err = checkOCSPResponseCertID(resp, cert, issuer)
+	test.AssertNotError(t, err, "CertID check failed for corresponding certificate and issuer")
+
+	err = checkOCSPResponseCertID(resp, cert, otherIssuer)
+	test.AssertError(t, err, "CertID check didn't fail for a different issuer")
+	test.AssertContains(t, err.Error(), "issuerNameHash doesn't match")
+
+	err = checkOCSPResponseCertID(resp, issuer, issuer)
+	test.AssertError(t, err, "CertID check didn't fail for a different certificate")
+	test.AssertContains(t, err.Error(), "doesn't match certificate serial")
+}
+
+func TestGenerateOCSPResponseResponderID(t *testing.T) {
+	kA, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	kB, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(9),
+		Subject: pkix.Name{
+			CommonName: "cn",
+		},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             time.Time{}.Add(time.Hour * 10),
+		NotAfter:              time.Time{}.Add(time.Hour * 20),
+	}
+	issuerBytes, err := x509.CreateCertificate(rand.Reader, template, template, kA.Public(), kA)
+	test.AssertNotError(t, err, "failed to create test issuer")
+	issuer, err := x509.ParseCertificate(issuerBytes)
+	test.AssertNotError(t, err, "failed to parse test issuer")
+	template.Subject.CommonName = "delegated cn"
+	template.BasicConstraintsValid, template.IsCA = false, false
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}
+	delegatedIssuerBytes, err := x509.CreateCertificate(rand.Reader, template, issuer, kB.Public(), kA)
+	test.AssertNotError(t, err, "failed to create test delegated issuer")
+	delegatedIssuer, err := x509.ParseCertificate(delegatedIssuerBytes)
+	test.AssertNotError(t, err, "failed to parse test delegated issuer")
+
+	keyHash := func(cert *x509.Certificate) []byte {
+		var spki struct {
+			Algorithm pkix.AlgorithmIdentifier
+			PublicKey asn1.BitString
+		}
+		_, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki)
+		test.AssertNotError(t, err, "failed to parse public key")
+		h := sha1.Sum(spki.PublicKey.RightAlign())
+		return h[:]
+	}
+
+	cases := []struct {
+		name            string
+		signer          *ecdsa.PrivateKey
+		delegatedIssuer *x509.Certificate
+		responder       *x509.Certificate
+		byKey           bool
+	}{
+		{
+			name:      "by name",
+			signer:    kA,
+			responder: issuer,
+		},
+		{
+			name:      "by key",
+			signer:    kA,
+			responder: issuer,
+			byKey:     true,
+		},
+		{
+			name:            "delegated by name",
+			signer:          kB,
+			delegatedIssuer: delegatedIssuer,
+			responder:       delegatedIssuer,
+		},
+		{
+			name:            "delegated by key",
+			signer:          kB,
+			delegatedIssuer: delegatedIssuer,
+			responder:       delegatedIssuer,
+			byKey:           true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			signer := &countingSigner{wrappedSigner: wrappedSigner{tc.signer}}
+			resp, err := generateOCSPResponse(signer, issuer, tc.delegatedIssuer, issuer, time.Time{}.Add(time.Hour*11), time.Time{}.Add(time.Hour*12), time.Time{}, 0, time.Time{}, 0, tc.byKey, nil)
+			test.AssertNotError(t, err, "failed to generate OCSP response")
+			test.AssertEquals(t, signer.calls, 1)
+
+			// ocsp.ParseResponse verifies the signature on the response.
+			parsed, err := ocsp.ParseResponse(resp, issuer)
+			test.AssertNotError(t, err, "failed to parse OCSP response")
+			if tc.byKey {
+				test.AssertByteEquals(t, parsed.ResponderKeyHash, keyHash(tc.responder))
+				test.AssertEquals(t, len(parsed.RawResponderName), 0)
+			} else {
+				test.AssertByteEquals(t, parsed.RawResponderName, tc.responder.RawSubject)
+				test.AssertEquals(t, len(parsed.ResponderKeyHash), 0)
+			}
+			test.AssertEquals(t, parsed.SerialNumber.Cmp(issuer.SerialNumber), 0)
+		})
+	}
+}
+
+func TestCheckOCSPResponseCertificates(t *testing.T) {
+	kA, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	kB, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(9),
+		Subject: pkix.Name{
+			CommonName: "cn",
+		},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             time.Time{}.Add(time.Hour * 10),
+		NotAfter:              time.Time{}.Add(time.Hour * 20),
+	}
+	issuerBytes, err := x509.CreateCertificate(rand.Reader, template, template, kA.Public(), kA)
+	test.AssertNotError(t, err, "failed to create test issuer")
+	issuer, err := x509.ParseCertificate(issuerBytes)
+	test.AssertNotError(t, err, "failed to parse test issuer")
+	template.Subject.CommonName = "delegated cn"
+	template.BasicConstraintsValid, template.IsCA = false, false
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}
+	delegatedIssuerBytes, err := x509.CreateCertificate(rand.Reader, template, issuer, kB.Public(), kA)
+	test.AssertNotError(t, err, "failed to create test delegated issuer")
+	delegatedIssuer, err := x509.ParseCertificate(delegatedIssuerBytes)
+	test.AssertNotError(t, err, "failed to parse test delegated issuer")
+
+	cases := []struct {
+		name            string
+		signer          *ecdsa.PrivateKey
+		responder       *x509.Certificate
+		includedCert    *x509.Certificate
+		delegatedIssuer *x509.Certificate
+		expectedError   string
+	}{
+		{
+			name:      "issuer signed without certificates",
+			signer:    kA,
+			responder: issuer,
+		},
+		{
+			name:          "issuer signed with the issuer's certificate",
+			signer:        kA,
+			responder:     issuer,
+			includedCert:  issuer,
+			expectedError: "OCSP response signed by the issuer contains 1 certificates, expected none",
+		},
+		{
+			name:            "delegated with the responder's certificate",
+			signer:          kB,
+			responder:       delegatedIssuer,
+			includedCert:    delegatedIssuer,
+			delegatedIssuer: delegatedIssuer,
+		},
+		{
+			name:            "delegated without certificates",
+			signer:          kB,
+			responder:       delegatedIssuer,
+			delegatedIssuer: delegatedIssuer,
+			expectedError:   "OCSP response signed by a delegated responder doesn't include the responder's certificate",
+		},
+		{
+			name:            "delegated with the issuer's certificate",
+			signer:          kB,
+			responder:       delegatedIssuer,
+			includedCert:    issuer,
+			delegatedIssuer: delegatedIssuer,
+			expectedError:   "OCSP response signed by a delegated responder doesn't include the responder's certificate",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := ocsp.CreateResponse(issuer, tc.responder, ocsp.Response{
+				SerialNumber: big.NewInt(10),
+				ThisUpdate:   time.Time{}.Add(time.Hour * 11),
+				NextUpdate:   time.Time{}.Add(time.Hour * 12),
+				Certificate:  tc.includedCert,
+			}, tc.signer)
+			test.AssertNotError(t, err, "failed to create OCSP response")
+
+			err = checkOCSPResponseCertificates(resp, tc.delegatedIssuer)
+			if tc.expectedError == "" {
+				test.AssertNotError(t, err, "checkOCSPResponseCertificates failed")
+			} else {
+				test.AssertError(t, err, "checkOCSPResponseCertificates didn't fail")
+				test.AssertEquals(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestIncludeOCSPResponderChain(t *testing.T) {
+	kRoot, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	kA, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	kB, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(9),
+		Subject: pkix.Name{
+			CommonName: "root cn",
+		},
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		NotBefore:             time.Time{}.Add(time.Hour * 10),
+		NotAfter:              time.Time{}.Add(time.Hour * 20),
+	}
+	rootBytes, err := x509.CreateCertificate(rand.Reader, template, template, kRoot.Public(), kRoot)
+	test.AssertNotError(t, err, "failed to create test root")
+	root, err := x509.ParseCertificate(rootBytes)
+	test.AssertNotError(t, err, "failed to parse test root")
+	template.Subject.CommonName = "cn"
+	issuerBytes, err := x509.CreateCertificate(rand.Reader, template, root, kA.Public(), kRoot)
+	test.AssertNotError(t, err, "failed to create test issuer")
+	issuer, err := x509.ParseCertificate(issuerBytes)
+	test.AssertNotError(t, err, "failed to parse test issuer")
+	template.Subject.CommonName = "delegated cn"
+	template.BasicConstraintsValid, template.IsCA = false, false
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}
+	delegatedIssuerBytes, err := x509.CreateCertificate(rand.Reader, template, issuer, kB.Public(), kA)
+	test.AssertNotError(t, err, "failed to create test delegated issuer")
+	delegatedIssuer, err := x509.ParseCertificate(delegatedIssuerBytes)
+	test.AssertNotError(t, err, "failed to parse test delegated issuer")
+
+	resp, err := generateOCSPResponse(kB, issuer, delegatedIssuer, delegatedIssuer, time.Time{}.Add(time.Hour*11), time.Time{}.Add(time.Hour*12), time.Time{}, 0, time.Time{}, 0, false, nil)
+	test.AssertNotError(t, err, "failed to generate OCSP response")
+
+	chainResp, err := includeOCSPResponderChain(resp, delegatedIssuer, []*x509.Certificate{issuer, root})
+	test.AssertNotError(t, err, "failed to include responder chain")
+	test.AssertNotError(t, checkOCSPResponseCertificates(chainResp, delegatedIssuer), "response with chain doesn't include the responder's certificate")
+
+	var outer ocspResponseASN1
+	_, err = asn1.Unmarshal(chainResp, &outer)
+	test.AssertNotError(t, err, "failed to parse OCSP response")
+	var basic ocspBasicResponseRaw
+	_, err = asn1.Unmarshal(outer.ResponseBytes.Response, &basic)
+	test.AssertNotError(t, err, "failed to parse basic OCSP response")
+	test.AssertEquals(t, len(basic.Certificates), 3)
+	test.AssertByteEquals(t, basic.Certificates[0].FullBytes, delegatedIssuer.Raw)
+	test.AssertByteEquals(t, basic.Certificates[1].FullBytes, issuer.Raw)
+	test.AssertByteEquals(t, basic.Certificates[2].FullBytes, root.Raw)
+
+	// The signature doesn't cover the certs field, so it is still valid.
+	_, err = ocsp.ParseResponse(chainResp, issuer)
+	test.AssertNotError(t, err, "failed to parse OCSP response with chain")
+
+	_, err = includeOCSPResponderChain(resp, delegatedIssuer, nil)
+	test.AssertError(t, err, "includeOCSPResponderChain didn't fail with an empty chain")
+	test.AssertEquals(t, err.Error(), "delegated responder chain is empty")
+
+	_, err = includeOCSPResponderChain(resp, delegatedIssuer, []*x509.Certificate{root, issuer})
+	test.AssertError(t, err, "includeOCSPResponderChain didn't fail with a misordered chain")
+	test.AssertContains(t, err.Error(), "delegated responder chain certificate 0 didn't sign the certificate before it")
+}
+
+func TestWriteOCSPResponses(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// makeIssuer writes an issuer certificate and its private key to dir,
+	// returning the certificate, its key, and the paths of both files.
+	makeIssuer := func(name string) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		test.AssertNotError(t, err, "failed to generate test key")
+		template := &x509.Certificate{
+			Subject:               pkix.Name{CommonName: name},
+			SerialNumber:          big.NewInt(7),
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(365 * 24 * time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+		certDER, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+		test.AssertNotError(t, err, "failed to generate test cert")
+		cert, err := x509.ParseCertificate(certDER)
+		test.AssertNotError(t, err, "failed to parse test cert")
+		certPath := filepath.Join(dir, name+".cert.pem")
+		writePEMFile(t, certPath, "CERTIFICATE", certDER)
+		keyDER, err := x509.MarshalPKCS8PrivateKey(k)
+		test.AssertNotError(t, err, "failed to marshal test key")
+		keyPath := filepath.Join(dir, name+".key.pem")
+		writePEMFile(t, keyPath, "PRIVATE KEY", keyDER)
+		return cert, k, certPath, keyPath
+	}
+	// makeCert writes a certificate issued by issuer to filename.
+	makeCert := func(filename string, serial int64, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		test.AssertNotError(t, err, "failed to generate test key")
+		template := &x509.Certificate{
+			Subject:      pkix.Name{CommonName: filepath.Base(filename)},
+			SerialNumber: big.NewInt(serial),
+			NotBefore:    now.Add(-time.Hour),
+			NotAfter:     now.Add(24 * time.Hour),
+		}
+		certDER, err := x509.CreateCertificate(rand.Reader, template, issuer, k.Public(), issuerKey)
+		test.AssertNotError(t, err, "failed to generate test cert")
+		writePEMFile(t, filename, "CERTIFICATE", certDER)
+	}
+	issuer, issuerKey, issuerPath, keyPath := makeIssuer("issuer")
+	other, otherKey, _, _ := makeIssuer("other")
+	goodPath := filepath.Join(dir, "int-a.cert.pem")
+	makeCert(goodPath, 10, issuer, issuerKey)
+	revokedPath := filepath.Join(dir, "int-b.cert.pem")
+	makeCert(revokedPath, 11, issuer, issuerKey)
+	test.AssertNotError(t, os.Mkdir(filepath.Join(dir, "dup"), 0700), "failed to create directory")
+	duplicatePath := filepath.Join(dir, "dup", "int-a.cert.pem")
+	makeCert(duplicatePath, 12, issuer, issuerKey)
+	otherPath := filepath.Join(dir, "int-other.cert.pem")
+	makeCert(otherPath, 13, other, otherKey)
+
+	revocationTime := now.Add(-time.Minute)
+	configFor := func(certificates string) ocspRespConfig {
+		t.Helper()
+		configBytes := []byte(fmt.Sprintf(`ceremony-type: ocsp-response
+inputs:
+    issuer-certificate-path: %s
+    certificates:
+%s
+outputs:
+    response-path: %s
+    response-der-path: %s
+ocsp-profile:
+    this-update: %s
+    next-update: %s
+`, issuerPath, certificates, filepath.Join(dir, "{name}.resp.b64"), filepath.Join(dir, "{name}.resp.der"),
+			now.Add(-time.Minute).Format(time.DateTime), now.Add(time.Hour).Format(time.DateTime)))
+		var config ocspRespConfig
+		err := strictyaml.Unmarshal(configBytes, &config)
+		test.AssertNotError(t, err, "failed to parse config")
+		config.PKCS11.softwareKeyPath = keyPath
+		return config
+	}
+	mixed := fmt.Sprintf(`        - certificate-path: %s
+          status: good
+        - certificate-path: %s
+          status: revoked
+          revocation-time: %s`, goodPath, revokedPath, revocationTime.Format(time.DateTime))
+
+	// A good and a revoked response are signed with the same key.
+	config := configFor(mixed)
+	test.AssertNotError(t, config.validate(), "validate failed")
+	test.AssertNotError(t, writeOCSPResponses(config), "writeOCSPResponses failed")
+	for _, tc := range []struct {
+		name      string
+		serial    int64
+		status    int
+		revokedAt time.Time
+	}{
+		{"int-a", 10, ocsp.Good, time.Time{}},
+		{"int-b", 11, ocsp.Revoked, revocationTime},
+	} {
+		respDER, err := os.ReadFile(filepath.Join(dir, tc.name+".resp.der"))
+		test.AssertNotError(t, err, "failed to read OCSP response")
+		resp, err := ocsp.ParseResponse(respDER, issuer)
+		test.AssertNotError(t, err, "failed to parse OCSP response")
+		test.AssertEquals(t, resp.SerialNumber.Int64(), tc.serial)
+		test.AssertEquals(t, resp.Status, tc.status)
+		test.Assert(t, resp.RevokedAt.Equal(tc.revokedAt), "wrong revocation time")
+		respB64, err := os.ReadFile(filepath.Join(dir, tc.name+".resp.b64"))
+		test.AssertNotError(t, err, "failed to read base64 encoded OCSP response")
+		test.AssertByteEquals(t, respB64, encodeOCSPResponse(respDER))
+	}
+
+	// Certificates with the same name would be written to the same files.
+	config = configFor(fmt.Sprintf(`        - certificate-path: %s
+          status: good
+        - certificate-path: %s
+          status: good`, filepath.Join(dir, "dup", "int-c.cert.pem"), filepath.Join(dir, "int-c.cert.pem")))
+	err := config.validate()
+	test.AssertError(t, err, "validate didn't fail for colliding output paths")
+	test.AssertEquals(t, err.Error(), fmt.Sprintf("inputs.certificates[1]: outputs.response-path %q is already written for inputs.certificates[0]", filepath.Join(dir, "int-c.resp.b64")))
+
+	// As would a certificate whose response was already written.
+	config = configFor(fmt.Sprintf(`        - certificate-path: %s
+          status: good`, duplicatePath))
+	err = config.validate()
+	test.AssertError(t, err, "validate didn't fail for an existing output path")
+	test.AssertContains(t, err.Error(), "already exists")
+
+	// A certificate from another issuer is rejected before any response is
+	// signed.
+	config = configFor(fmt.Sprintf(`        - certificate-path: %s
+          status: good
+        - certificate-path: %s
+          status: good`, filepath.Join(dir, "dup", "int-a.cert.pem"), otherPath))
+	config.Outputs.ResponsePath = filepath.Join(dir, "other-{name}.resp.b64")
+	config.Outputs.ResponseDERPath = ""
+	test.AssertNotError(t, config.validate(), "validate failed")
+	err = writeOCSPResponses(config)
+	test.AssertError(t, err, "writeOCSPResponses didn't fail for a certificate from another issuer")
+	test.AssertContains(t, err.Error(), fmt.Sprintf("certificate %q was not issued by inputs.issuer-certificate-path", otherPath))
+	test.AssertEquals(t, exitCodeFor(err), exitConfig)
+	_, err = os.Stat(filepath.Join(dir, "other-int-a.resp.b64"))
+	test.Assert(t, os.IsNotExist(err), "a response was written despite a certificate from another issuer")
+}
+
+func TestOCSPRespConfigValidateCertificates(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		modify        func(*ocspRespConfig)
+		expectedError string
+	}{
+		{
+			name:   "valid",
+			modify: func(*ocspRespConfig) {},
+		},
+		{
+			name:          "certificate-path also set",
+			modify:        func(c *ocspRespConfig) { c.Inputs.CertificatePath = "cert.pem" },
+			expectedError: "inputs.certificate-path and inputs.certificates cannot both be set",
+		},
+		{
+			name:          "ocsp-profile.status set",
+			modify:        func(c *ocspRespConfig) { c.OCSPProfile.Status = "good" },
+			expectedError: "ocsp-profile.status cannot be set with inputs.certificates, each entry has its own status",
+		},
+		{
+			name:          "response-path not templated",
+			modify:        func(c *ocspRespConfig) { c.Outputs.ResponsePath = "resp.b64" },
+			expectedError: `outputs.response-path must contain "{name}" when inputs.certificates is set`,
+		},
+		{
+			name:          "bad status",
+			modify:        func(c *ocspRespConfig) { c.Inputs.Certificates[1].Status = "unknown" },
+			expectedError: `inputs.certificates[1].status must be either "good" or "revoked"`,
+		},
+		{
+			name:          "revoked without revocation-time",
+			modify:        func(c *ocspRespConfig) { c.Inputs.Certificates[1].RevocationTime = "" },
+			expectedError: `inputs.certificates[1].revocation-time is required if status is "revoked"`,
+		},
+		{
+			name:          "good with revocation-time",
+			modify:        func(c *ocspRespConfig) { c.Inputs.Certificates[0].RevocationTime = "2020-01-01 00:00:00" },
+			expectedError: `inputs.certificates[0].revocation-time can only be set if status is "revoked"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var config ocspRespConfig
+			config.PKCS11 = PKCS11SigningConfig{Module: "module", SigningLabel: "label"}
+			config.Inputs.IssuerCertificatePath = "issuer.cert.pem"
+			config.Inputs.Certificates = []ocspCertificateConfig{
+				{CertificatePath: "a.cert.pem", Status: "good"},
+				{CertificatePath: "b.cert.pem", Status: "revoked", RevocationTime: "2020-01-01 00:00:00"},
+			}
+			config.Outputs.ResponsePath = filepath.Join(t.TempDir(), "{name}.resp.b64")
+			config.OCSPProfile.ThisUpdate = "2020-01-01 00:00:00"
+			config.OCSPProfile.NextUpdate = "2020-01-02 00:00:00"
+			tc.modify(&config)
+			err := config.validate()
+			if tc.expectedError == "" {
+				test.AssertNotError(t, err, "validate failed")
+			} else {
+				test.AssertError(t, err, "validate didn't fail")
+				test.AssertEquals(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// readPIN writes a prompt to out and reads a PIN terminated by a newline from
+// in. If in is a terminal the PIN is read without being echoed.
+func readPIN(in io.Reader, out io.Writer) ([]byte, error) {
+	fmt.Fprint(out, "PKCS#11 PIN: ")
+	var pin []byte
+	var err error
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		pin, err = term.ReadPassword(int(f.Fd()))
+		// The newline typed by the user isn't echoed either.
+		fmt.Fprintln(out)
+	} else {
+		pin, err = readLine(in)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PIN: %w", err)
+	}
+	if len(pin) == 0 {
+		return nil, errors.New("PIN must not be empty")
+	}
+	return pin, nil
+}
+
+// openPINFD returns a file for the open file descriptor fd, as passed by
+// --pin-fd or --so-pin-fd, from which a PIN can be read with readPIN. It
+// returns an error if fd isn't open.
+func openPINFD(fd int) (*os.File, error) {
+	if fd < 0 {
+		return nil, fmt.Errorf("file descriptor %d is invalid", fd)
+	}
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+	_, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("file descriptor %d is not open: %w", fd, err)
+	}
+	return f, nil
+}
+
+// readLine reads from in until a newline or the end of input, one byte at a
+// time so that nothing past the newline is consumed. The newline, and a
+// preceding carriage return, are not included in the returned line.
+func readLine(in io.Reader) ([]byte, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := in.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			clear(line)
+			return nil, err
+		}
+	}
+	return bytes.TrimSuffix(line, []byte("\r")), nil
+}
+
+// setConfigPIN returns a copy of configBytes with pkcs11.pin set to the PIN
+// returned by readPIN, which reads it from the terminal or a file descriptor.
+// The config must contain a pkcs11 object which doesn't already set a PIN, so
+// that the PIN comes from exactly one source, and this is checked before
+// readPIN is called. The PIN returned by readPIN is zeroed once it has been
+// copied into the config.
+func setConfigPIN(configBytes []byte, readPIN func() ([]byte, error)) ([]byte, error) {
+	var doc yaml.Node
+	err := yaml.Unmarshal(configBytes, &doc)
+	if err != nil {
+		return nil, configErrorf("failed to parse config: %w", err)
+	}
+	if len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, configErrorf("config is not a YAML mapping")
+	}
+	pkcs11Node := mappingValue(doc.Content[0], "pkcs11")
+	if pkcs11Node == nil || pkcs11Node.Kind != yaml.MappingNode {
+		return nil, configErrorf("config doesn't contain a pkcs11 object")
+	}
+	pinNode := mappingValue(pkcs11Node, "pin")
+	if pinNode != nil && pinNode.Value != "" {
+		return nil, configErrorf("pkcs11.pin cannot be set in the config when the PIN is read from --prompt-pin or --pin-fd")
+	}
+	if pinNode == nil {
+		pinNode = &yaml.Node{Kind: yaml.ScalarNode}
+		pkcs11Node.Content = append(pkcs11Node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "pin"}, pinNode)
+	}
+
+	pin, err := readPIN()
+	if err != nil {
+		return nil, err
+	}
+	pinNode.Kind = yaml.ScalarNode
+	pinNode.Tag = "!!str"
+	pinNode.Style = yaml.DoubleQuotedStyle
+	pinNode.Value = string(pin)
+	clear(pin)
+	return yaml.Marshal(&doc)
+}
+
+// mappingValue returns the value node for key in the YAML mapping node m, or
+// nil if m doesn't contain key.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
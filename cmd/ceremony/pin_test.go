@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/letsencrypt/boulder/strictyaml"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestReadPIN(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		input       string
+		expectedPIN string
+		expectedErr string
+	}{
+		{
+			name:        "newline terminated",
+			input:       "1234\nremaining",
+			expectedPIN: "1234",
+		},
+		{
+			name:        "carriage return and newline terminated",
+			input:       "1234\r\n",
+			expectedPIN: "1234",
+		},
+		{
+			name:        "end of input",
+			input:       "1234",
+			expectedPIN: "1234",
+		},
+		{
+			name:        "empty",
+			input:       "\n",
+			expectedErr: "PIN must not be empty",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			in := strings.NewReader(tc.input)
+			var out bytes.Buffer
+			pin, err := readPIN(in, &out)
+			test.AssertEquals(t, out.String(), "PKCS#11 PIN: ")
+			if tc.expectedErr != "" {
+				test.AssertError(t, err, "readPIN didn't fail")
+				test.AssertEquals(t, err.Error(), tc.expectedErr)
+				return
+			}
+			test.AssertNotError(t, err, "readPIN failed")
+			test.AssertEquals(t, string(pin), tc.expectedPIN)
+		})
+	}
+
+	// Nothing past the newline is consumed.
+	in := strings.NewReader("1234\nremaining")
+	_, err := readPIN(in, &bytes.Buffer{})
+	test.AssertNotError(t, err, "readPIN failed")
+	test.AssertEquals(t, in.Len(), len("remaining"))
+}
+
+func TestSetConfigPIN(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		config      string
+		expectedErr string
+	}{
+		{
+			name:   "no pin",
+			config: "ceremony-type: key\npkcs11:\n    module: module\n",
+		},
+		{
+			name:   "empty pin",
+			config: "ceremony-type: key\npkcs11:\n    module: module\n    pin:\n",
+		},
+		{
+			name:        "config pin",
+			config:      "ceremony-type: key\npkcs11:\n    module: module\n    pin: 5678\n",
+			expectedErr: "pkcs11.pin cannot be set in the config when the PIN is read from --prompt-pin or --pin-fd",
+		},
+		{
+			name:        "no pkcs11",
+			config:      "ceremony-type: key\n",
+			expectedErr: "config doesn't contain a pkcs11 object",
+		},
+		{
+			name:        "not a mapping",
+			config:      "- key\n",
+			expectedErr: "config is not a YAML mapping",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var pin []byte
+			configBytes, err := setConfigPIN([]byte(tc.config), func() ([]byte, error) {
+				pin = []byte("0123")
+				return pin, nil
+			})
+			if tc.expectedErr != "" {
+				test.AssertError(t, err, "setConfigPIN didn't fail")
+				test.AssertEquals(t, err.Error(), tc.expectedErr)
+				test.AssertEquals(t, exitCodeFor(err), exitConfig)
+				// The PIN is only read once the config has been checked.
+				test.AssertEquals(t, len(pin), 0)
+				return
+			}
+			test.AssertNotError(t, err, "setConfigPIN failed")
+			test.AssertByteEquals(t, pin, []byte{0, 0, 0, 0})
+
+			var config struct {
+				CeremonyType string `yaml:"ceremony-type"`
+				PKCS11       struct {
+					Module string `yaml:"module"`
+					PIN    string `yaml:"pin"`
+				} `yaml:"pkcs11"`
+			}
+			err = strictyaml.Unmarshal(configBytes, &config)
+			test.AssertNotError(t, err, "failed to parse config")
+			test.AssertEquals(t, config.CeremonyType, "key")
+			test.AssertEquals(t, config.PKCS11.Module, "module")
+			// The PIN is quoted so that it isn't parsed as a number.
+			test.AssertEquals(t, config.PKCS11.PIN, "0123")
+		})
+	}
+
+	_, err := setConfigPIN([]byte("pkcs11:\n    module: module\n"), func() ([]byte, error) {
+		return nil, errors.New("oops")
+	})
+	test.AssertError(t, err, "setConfigPIN didn't fail")
+	test.AssertEquals(t, err.Error(), "oops")
+}
+
+func TestReadPINFromFD(t *testing.T) {
+	r, w, err := os.Pipe()
+	test.AssertNotError(t, err, "failed to create pipe")
+	defer r.Close()
+	_, err = w.WriteString("1234\n5678\n")
+	test.AssertNotError(t, err, "failed to write PINs to pipe")
+	test.AssertNotError(t, w.Close(), "failed to close pipe")
+
+	// The PIN and SO PIN can be delivered on the same file descriptor.
+	f, err := openPINFD(int(r.Fd()))
+	test.AssertNotError(t, err, "openPINFD failed")
+	pin, err := readPIN(f, io.Discard)
+	test.AssertNotError(t, err, "readPIN failed")
+	test.AssertEquals(t, string(pin), "1234")
+	soPIN, err := readPIN(f, io.Discard)
+	test.AssertNotError(t, err, "readPIN failed")
+	test.AssertEquals(t, string(soPIN), "5678")
+
+	_, err = readPIN(f, io.Discard)
+	test.AssertError(t, err, "readPIN didn't fail at the end of input")
+
+	_, err = openPINFD(-2)
+	test.AssertError(t, err, "openPINFD didn't fail with a negative file descriptor")
+	_, err = openPINFD(int(w.Fd()))
+	test.AssertError(t, err, "openPINFD didn't fail with a closed file descriptor")
+}
+
+func TestSetSOPIN(t *testing.T) {
+	config := PKCS11KeyGenConfig{Module: "module", PIN: "1234", StoreLabel: "label", UserType: "so"}
+	test.AssertNotError(t, config.setSOPIN(""), "setSOPIN failed without an SO PIN")
+	err := config.setSOPIN("5678")
+	test.AssertError(t, err, "setSOPIN didn't fail without pkcs11.init-token")
+	test.AssertEquals(t, err.Error(), "--so-pin-fd can only be used when pkcs11.init-token is set")
+
+	config.InitToken = &initTokenConfig{TokenLabel: "token"}
+	test.AssertNotError(t, config.setSOPIN("5678"), "setSOPIN failed with pkcs11.init-token")
+	test.AssertNotError(t, config.validate(), "validate failed with an SO PIN from --so-pin-fd")
+	test.AssertEquals(t, config.InitToken.getSOPIN(), "5678")
+
+	config.InitToken.SOPINEnvVar = "CEREMONY_TEST_SO_PIN"
+	err = config.validate()
+	test.AssertError(t, err, "validate didn't fail with an SO PIN from both --so-pin-fd and the environment")
+	test.AssertEquals(t, err.Error(), "pkcs11.init-token.so-pin-env-var cannot be set when --so-pin-fd is used")
+}
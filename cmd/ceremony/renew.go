@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/letsencrypt/boulder/linter"
+)
+
+// validityASN1 mirrors the RFC 5280 Section 4.1.2.5 Validity structure.
+// encoding/asn1 encodes times between 1950 and 2049 as UTCTime and all other
+// times as GeneralizedTime, as the RFC requires.
+type validityASN1 struct {
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// renewTemplate returns a template which reproduces existing apart from its
+// serial number and validity period. It is only the starting point of the
+// linting certificate, whose TBSCertificate is then replaced by renewModifier.
+// Every extension of existing is carried over via ExtraExtensions, which
+// prevents x509.CreateCertificate from generating any of its own.
+func renewTemplate(existing *x509.Certificate, serial *big.Int, notBefore, notAfter time.Time) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          serial,
+		SignatureAlgorithm:    existing.SignatureAlgorithm,
+		RawSubject:            existing.RawSubject,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		BasicConstraintsValid: existing.BasicConstraintsValid,
+		IsCA:                  existing.IsCA,
+		SubjectKeyId:          existing.SubjectKeyId,
+		ExtraExtensions:       existing.Extensions,
+	}
+}
+
+// renewTBS returns the TBSCertificate of the DER encoded certificate
+// existingDER with only its serial number and validity period replaced, along
+// with the certificate's outer signature algorithm identifier. All other
+// TBSCertificate fields, including the issuer, subject, subject public key
+// info, and extensions, are copied verbatim.
+func renewTBS(existingDER []byte, serial *big.Int, notBefore, notAfter time.Time) (*tbsCertificateASN1, pkix.AlgorithmIdentifier, error) {
+	tbs, sigAlgID, err := parseTBSCertificate(existingDER)
+	if err != nil {
+		return nil, pkix.AlgorithmIdentifier{}, err
+	}
+	validity, err := asn1.Marshal(validityASN1{NotBefore: notBefore.UTC(), NotAfter: notAfter.UTC()})
+	if err != nil {
+		return nil, pkix.AlgorithmIdentifier{}, fmt.Errorf("failed to encode validity: %w", err)
+	}
+	tbs.SerialNumber = serial
+	tbs.Validity = asn1.RawValue{FullBytes: validity}
+	return tbs, sigAlgID, nil
+}
+
+// renewModifier returns a linter.Modifier which replaces the TBSCertificate of
+// the linting certificate with tbs, so that exactly the TBSCertificate which
+// will be signed is linted. The linter replaces the subject public key of a
+// self-signed certificate with its own, so if selfSigned is true the linting
+// certificate's subject public key is kept.
+func renewModifier(tbs *tbsCertificateASN1, sigAlg x509.SignatureAlgorithm, sigAlgID pkix.AlgorithmIdentifier, selfSigned bool) linter.Modifier {
+	return func(certDER []byte, signer crypto.Signer) ([]byte, error) {
+		lintTBS := *tbs
+		if selfSigned {
+			generated, _, err := parseTBSCertificate(certDER)
+			if err != nil {
+				return nil, err
+			}
+			lintTBS.PublicKey = generated.PublicKey
+		}
+		// The linting certificate is signed by a software key, which needs a
+		// source of randomness.
+		return signTBSCertificate(rand.Reader, &lintTBS, sigAlg, sigAlgID, signer)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestRenewCertificate(t *testing.T) {
+	issuerKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate issuer key")
+	issuerTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "issuer"},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	issuerDER, err := x509.CreateCertificate(rand.Reader, issuerTmpl, issuerTmpl, issuerKey.Public(), issuerKey)
+	test.AssertNotError(t, err, "failed to create issuer certificate")
+	issuer, err := x509.ParseCertificate(issuerDER)
+	test.AssertNotError(t, err, "failed to parse issuer certificate")
+
+	subjectKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate subject key")
+	existingTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		SignatureAlgorithm:    x509.ECDSAWithSHA384,
+		Subject:               pkix.Name{CommonName: "subject", Organization: []string{"organization"}, Country: []string{"US"}},
+		NotBefore:             time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IssuingCertificateURL: []string{"http://issuer"},
+		CRLDistributionPoints: []string{"http://crl"},
+		PolicyIdentifiers:     []asn1.ObjectIdentifier{{2, 23, 140, 1, 2, 1}},
+	}
+	existingDER, err := x509.CreateCertificate(rand.Reader, existingTmpl, issuer, subjectKey.Public(), issuerKey)
+	test.AssertNotError(t, err, "failed to create existing certificate")
+
+	existing, err := x509.ParseCertificate(existingDER)
+	test.AssertNotError(t, err, "failed to parse existing certificate")
+
+	serial := big.NewInt(0xdeadbeef)
+	notBefore := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2027, 12, 31, 23, 59, 59, 0, time.UTC)
+	tbs, sigAlgID, err := renewTBS(existingDER, serial, notBefore, notAfter)
+	test.AssertNotError(t, err, "renewTBS failed")
+	template := renewTemplate(existing, serial, notBefore, notAfter)
+	modify := renewModifier(tbs, existing.SignatureAlgorithm, sigAlgID, false)
+	lintCert, _, err := issueModifiedLintCert(template, issuer, existing.PublicKey, &wrappedSigner{issuerKey}, modify, []string{"n_ca_digital_signature_not_set", "n_mp_allowed_eku"}, "")
+	test.AssertNotError(t, err, "linting failed")
+	renewedDER, err := signLintedTBSCertificate(lintCert.Raw, existing.RawSubjectPublicKeyInfo, existing.SignatureAlgorithm, &wrappedSigner{issuerKey})
+	test.AssertNotError(t, err, "signing the renewal failed")
+
+	renewed, err := x509.ParseCertificate(renewedDER)
+	test.AssertNotError(t, err, "failed to parse renewed certificate")
+	test.AssertNotError(t, renewed.CheckSignatureFrom(issuer), "renewed certificate has an invalid signature")
+	test.AssertEquals(t, renewed.SerialNumber.Cmp(serial), 0)
+	test.Assert(t, renewed.NotBefore.Equal(notBefore), "renewed certificate has the wrong notBefore")
+	test.Assert(t, renewed.NotAfter.Equal(notAfter), "renewed certificate has the wrong notAfter")
+
+	// The linted TBSCertificate is exactly the one which was signed.
+	test.AssertByteEquals(t, lintCert.RawTBSCertificate, renewed.RawTBSCertificate)
+	tbsDER, err := asn1.Marshal(*tbs)
+	test.AssertNotError(t, err, "failed to encode renewed tbsCertificate")
+	test.AssertByteEquals(t, renewed.RawTBSCertificate, tbsDER)
+
+	// Other than the serial number and validity period every field of the
+	// TBSCertificate must be identical.
+	existingTBS, existingSigAlg, err := parseTBSCertificate(existingDER)
+	test.AssertNotError(t, err, "failed to parse existing tbsCertificate")
+	renewedTBS, renewedSigAlg, err := parseTBSCertificate(renewedDER)
+	test.AssertNotError(t, err, "failed to parse renewed tbsCertificate")
+	test.AssertDeepEquals(t, renewedSigAlg, existingSigAlg)
+	test.AssertNotEquals(t, renewedTBS.SerialNumber.Cmp(existingTBS.SerialNumber), 0)
+	test.Assert(t, !bytes.Equal(renewedTBS.Validity.FullBytes, existingTBS.Validity.FullBytes), "validity was not replaced")
+	renewedTBS.SerialNumber = existingTBS.SerialNumber
+	renewedTBS.Validity = existingTBS.Validity
+	test.AssertDeepEquals(t, renewedTBS, existingTBS)
+}
+
+func TestRenewSelfSigned(t *testing.T) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate root key")
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		SignatureAlgorithm:    x509.ECDSAWithSHA384,
+		Subject:               pkix.Name{CommonName: "root", Organization: []string{"organization"}, Country: []string{"US"}},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	existingDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootKey.Public(), rootKey)
+	test.AssertNotError(t, err, "failed to create existing root")
+	existing, err := x509.ParseCertificate(existingDER)
+	test.AssertNotError(t, err, "failed to parse existing root")
+
+	serial := big.NewInt(0xdeadbeef)
+	notBefore := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2044, 12, 31, 23, 59, 59, 0, time.UTC)
+	tbs, sigAlgID, err := renewTBS(existingDER, serial, notBefore, notAfter)
+	test.AssertNotError(t, err, "renewTBS failed")
+	template := renewTemplate(existing, serial, notBefore, notAfter)
+	modify := renewModifier(tbs, existing.SignatureAlgorithm, sigAlgID, true)
+	lintCert, _, err := issueModifiedLintCert(template, existing, existing.PublicKey, &wrappedSigner{rootKey}, modify, []string{"n_ca_digital_signature_not_set"}, "")
+	test.AssertNotError(t, err, "linting failed")
+	// The linting certificate verifies with the linter's own key.
+	lc := (*x509.Certificate)(lintCert)
+	test.AssertNotError(t, lc.CheckSignatureFrom(lc), "linting certificate isn't self-signed")
+
+	renewedDER, err := signLintedTBSCertificate(lintCert.Raw, existing.RawSubjectPublicKeyInfo, existing.SignatureAlgorithm, &wrappedSigner{rootKey})
+	test.AssertNotError(t, err, "signing the renewal failed")
+	renewed, err := x509.ParseCertificate(renewedDER)
+	test.AssertNotError(t, err, "failed to parse renewed root")
+	test.AssertNotError(t, renewed.CheckSignatureFrom(renewed), "renewed root isn't self-signed")
+	tbsDER, err := asn1.Marshal(*tbs)
+	test.AssertNotError(t, err, "failed to encode renewed tbsCertificate")
+	test.AssertByteEquals(t, renewed.RawTBSCertificate, tbsDER)
+}
+
+func TestRenewCeremonyInvalidValidity(t *testing.T) {
+	dir := t.TempDir()
+	config := fmt.Sprintf(`ceremony-type: renew
+pkcs11:
+    module: module
+    signing-key-slot: 0
+    signing-key-label: label
+inputs:
+    certificate-path: cert.pem
+    issuer-certificate-path: issuer.pem
+outputs:
+    certificate-path: %s
+validity:
+    not-before: %%s
+    not-after: %%s
+`, path.Join(dir, "renewed.cert.pem"))
+	for _, tc := range []struct{ notBefore, notAfter, expectedError string }{
+		{"2020-13-01 00:00:00", "2030-01-01 00:00:00", "unable to parse validity.not-before"},
+		{"2020-01-01 00:00:00", "2030-13-01 00:00:00", "unable to parse validity.not-after"},
+		{"2030-01-01 00:00:00", "2020-01-01 00:00:00", "validity.not-after must be after validity.not-before"},
+	} {
+		err := renewCeremony([]byte(fmt.Sprintf(config, tc.notBefore, tc.notAfter)))
+		test.AssertError(t, err, "renew ceremony accepted an invalid validity period")
+		test.AssertContains(t, err.Error(), tc.expectedError)
+		test.AssertEquals(t, exitCodeFor(err), exitConfig)
+	}
+}
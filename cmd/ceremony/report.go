@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/letsencrypt/boulder/linter"
+)
+
+// ceremonyReport is a machine readable record of a completed ceremony, for
+// auditors.
+type ceremonyReport struct {
+	CeremonyType string `json:"ceremony-type"`
+	// PKCS11Module and KeyLabel identify the signing key. When --software-key
+	// is used they are empty and SoftwareKey is set instead.
+	PKCS11Module string       `json:"pkcs11-module,omitempty"`
+	KeyLabel     string       `json:"key-label,omitempty"`
+	SoftwareKey  bool         `json:"software-key,omitempty"`
+	Inputs       []reportFile `json:"inputs"`
+	Outputs      []reportFile `json:"outputs"`
+	// SubjectPublicKeySHA256 is the hex encoded SHA-256 digest of the issued
+	// certificate's DER encoded SubjectPublicKeyInfo.
+	SubjectPublicKeySHA256 string                   `json:"subject-public-key-sha256"`
+	NotBefore              time.Time                `json:"not-before"`
+	NotAfter               time.Time                `json:"not-after"`
+	Lints                  []linter.LintReportEntry `json:"lints"`
+}
+
+// reportFile records the SHA-256 digest of a file named by a ceremony config.
+type reportFile struct {
+	Field  string `json:"field"`
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// hashReportFiles returns a reportFile for each of paths, skipping the path
+// skip, which is the report itself.
+func hashReportFiles(paths []dryRunPath, skip string) ([]reportFile, error) {
+	files := []reportFile{}
+	for _, p := range paths {
+		if p.path == skip {
+			continue
+		}
+		contents, err := os.ReadFile(p.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", p.field, err)
+		}
+		digest := sha256.Sum256(contents)
+		files = append(files, reportFile{Field: p.field, Path: p.path, SHA256: hex.EncodeToString(digest[:])})
+	}
+	return files, nil
+}
+
+// writeCeremonyReport writes a JSON report of a ceremony which issued cert to
+// filename. config must be a pointer to the ceremony's config, and every file
+// it names, other than the report and any checkpoint, is hashed. The signing
+// key is identified by module and label, or by softwareKey if --software-key
+// was used.
+func writeCeremonyReport(filename string, config any, ceremonyType, module, label string, softwareKey bool, cert *x509.Certificate, lints []linter.LintReportEntry) error {
+	inputPaths, outputPaths := collectConfigPaths(reflect.ValueOf(config), "", false)
+	// The checkpoint is rewritten once the report has been written, so it
+	// isn't hashed.
+	outputPaths = slices.DeleteFunc(outputPaths, func(p dryRunPath) bool {
+		return p.field == "outputs.checkpoint-path"
+	})
+	inputs, err := hashReportFiles(inputPaths, filename)
+	if err != nil {
+		return err
+	}
+	outputs, err := hashReportFiles(outputPaths, filename)
+	if err != nil {
+		return err
+	}
+	spkiDigest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	report := ceremonyReport{
+		CeremonyType:           ceremonyType,
+		PKCS11Module:           module,
+		KeyLabel:               label,
+		SoftwareKey:            softwareKey,
+		Inputs:                 inputs,
+		Outputs:                outputs,
+		SubjectPublicKeySHA256: hex.EncodeToString(spkiDigest[:]),
+		NotBefore:              cert.NotBefore.UTC(),
+		NotAfter:               cert.NotAfter.UTC(),
+		Lints:                  lints,
+	}
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ceremony report: %w", err)
+	}
+	err = writeFile(filename, append(reportJSON, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write ceremony report to %q: %w", filename, err)
+	}
+	log.Printf("Ceremony report written to %q\n", filename)
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/linter"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func fileSHA256(t *testing.T, filename string) string {
+	t.Helper()
+	contents, err := os.ReadFile(filename)
+	test.AssertNotError(t, err, "failed to read file")
+	digest := sha256.Sum256(contents)
+	return hex.EncodeToString(digest[:])
+}
+
+func TestIntermediateCeremonyReport(t *testing.T) {
+	dir := t.TempDir()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate root key")
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root", Organization: []string{"organization"}, Country: []string{"US"}},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootKey.Public(), rootKey)
+	test.AssertNotError(t, err, "failed to create root certificate")
+	rootPath := path.Join(dir, "root.cert.pem")
+	writePEMFile(t, rootPath, "CERTIFICATE", rootDER)
+	rootKeyDER, err := x509.MarshalPKCS8PrivateKey(rootKey)
+	test.AssertNotError(t, err, "failed to marshal root key")
+	rootKeyPath := path.Join(dir, "root.key.pem")
+	writePEMFile(t, rootKeyPath, "PRIVATE KEY", rootKeyDER)
+
+	intKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate intermediate key")
+	intPubDER, err := x509.MarshalPKIXPublicKey(intKey.Public())
+	test.AssertNotError(t, err, "failed to marshal intermediate public key")
+	intPubPath := path.Join(dir, "int.pubkey.pem")
+	writePEMFile(t, intPubPath, "PUBLIC KEY", intPubDER)
+	intCertPath := path.Join(dir, "int.cert.pem")
+	lintReportPath := path.Join(dir, "int.lints.json")
+	reportPath := path.Join(dir, "int.report.json")
+
+	config := fmt.Sprintf(`ceremony-type: intermediate
+inputs:
+    public-key-path: %s
+    issuer-certificate-path: %s
+outputs:
+    certificate-path: %s
+    lint-report-path: %s
+    report-path: %s
+certificate-profile:
+    signature-algorithm: ECDSAWithSHA384
+    common-name: intermediate
+    organization: organization
+    country: US
+    not-before: 2020-01-01 00:00:00
+    not-after: 2027-01-01 00:00:00
+    crl-url: http://crl.example.org/crl
+    issuer-url: http://issuer.example.org/root
+    policies:
+        - oid: 2.23.140.1.2.1
+    key-usages:
+        - Digital Signature
+        - Cert Sign
+        - CRL Sign
+skip-lints:
+    - n_ca_digital_signature_not_set
+`, intPubPath, rootPath, intCertPath, lintReportPath, reportPath)
+
+	err = intermediateCeremony([]byte(config), intermediateCert, false, rootKeyPath, "")
+	test.AssertNotError(t, err, "intermediate ceremony failed")
+
+	reportJSON, err := os.ReadFile(reportPath)
+	test.AssertNotError(t, err, "failed to read ceremony report")
+	var report ceremonyReport
+	err = json.Unmarshal(reportJSON, &report)
+	test.AssertNotError(t, err, "failed to parse ceremony report")
+
+	test.AssertEquals(t, report.CeremonyType, "intermediate")
+	test.Assert(t, report.SoftwareKey, "report doesn't record the software key")
+	test.AssertEquals(t, report.PKCS11Module, "")
+	test.AssertDeepEquals(t, report.Inputs, []reportFile{
+		{Field: "inputs.public-key-path", Path: intPubPath, SHA256: fileSHA256(t, intPubPath)},
+		{Field: "inputs.issuer-certificate-path", Path: rootPath, SHA256: fileSHA256(t, rootPath)},
+	})
+	test.AssertDeepEquals(t, report.Outputs, []reportFile{
+		{Field: "outputs.certificate-path", Path: intCertPath, SHA256: fileSHA256(t, intCertPath)},
+		{Field: "outputs.lint-report-path", Path: lintReportPath, SHA256: fileSHA256(t, lintReportPath)},
+	})
+	intPubDigest := sha256.Sum256(intPubDER)
+	test.AssertEquals(t, report.SubjectPublicKeySHA256, hex.EncodeToString(intPubDigest[:]))
+	test.AssertEquals(t, report.NotBefore, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	test.AssertEquals(t, report.NotAfter, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var lintReport []linter.LintReportEntry
+	lintReportJSON, err := os.ReadFile(lintReportPath)
+	test.AssertNotError(t, err, "failed to read lint report")
+	err = json.Unmarshal(lintReportJSON, &lintReport)
+	test.AssertNotError(t, err, "failed to parse lint report")
+	test.AssertDeepEquals(t, report.Lints, lintReport)
+	var skipped bool
+	for _, l := range report.Lints {
+		if l.Name == "n_ca_digital_signature_not_set" {
+			skipped = l.Status == linter.LintStatusSkipped
+		}
+	}
+	test.Assert(t, skipped, "report doesn't record the skipped lint")
+}
+
+func TestCeremonyReportPathValidation(t *testing.T) {
+	dir := t.TempDir()
+	existing := path.Join(dir, "existing.json")
+	err := os.WriteFile(existing, []byte("{}"), 0600)
+	test.AssertNotError(t, err, "failed to write file")
+
+	var rc rootConfig
+	rc.PKCS11.Module = "module"
+	rc.PKCS11.StoreLabel = "label"
+	rc.Key = keyGenConfig{Type: "ecdsa", ECDSACurve: "P-384"}
+	rc.Outputs.PublicKeyPath = path.Join(dir, "pub.pem")
+	rc.Outputs.CertificatePath = path.Join(dir, "cert.pem")
+	rc.Outputs.ReportPath = existing
+	err = rc.validate()
+	test.AssertError(t, err, "validate didn't fail for an existing report-path")
+	test.AssertEquals(t, err.Error(), fmt.Sprintf("outputs.report-path is %q, which already exists", existing))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/miekg/pkcs11"
+)
+
+// maxSignRetryDelay bounds the delay before the first retry. The delay doubles
+// after each retry.
+const maxSignRetryDelay = time.Minute
+
+// transientPKCS11Errors are the PKCS#11 errors which may be returned by an HSM
+// which is temporarily unable to sign, and after which signing is retried.
+var transientPKCS11Errors = []pkcs11.Error{
+	pkcs11.CKR_FUNCTION_FAILED,
+	pkcs11.CKR_DEVICE_ERROR,
+	pkcs11.CKR_DEVICE_MEMORY,
+}
+
+// signRetryConfig configures the retrying of signing operations which fail
+// with a transient PKCS#11 error.
+type signRetryConfig struct {
+	// Attempts is the maximum number of times a signing operation is
+	// attempted, including the first attempt.
+	Attempts int `yaml:"attempts"`
+	// Delay is the delay before the first retry, as a Go duration string. It
+	// doubles after each retry.
+	Delay string `yaml:"delay"`
+}
+
+func (src *signRetryConfig) validate() error {
+	if src == nil {
+		return nil
+	}
+	if src.Attempts < 1 || src.Attempts > 10 {
+		return errors.New("pkcs11.sign-retry.attempts must be between 1 and 10")
+	}
+	delay, err := time.ParseDuration(src.Delay)
+	if err != nil {
+		return fmt.Errorf("pkcs11.sign-retry.delay is invalid: %w", err)
+	}
+	if delay <= 0 || delay > maxSignRetryDelay {
+		return fmt.Errorf("pkcs11.sign-retry.delay must be greater than 0 and at most %s", maxSignRetryDelay)
+	}
+	return nil
+}
+
+// retrySigner wraps a crypto.Signer, retrying signing operations which fail
+// with one of transientPKCS11Errors with an exponential backoff. Any other
+// error is returned immediately.
+type retrySigner struct {
+	crypto.Signer
+	attempts int
+	delay    time.Duration
+	sleep    func(time.Duration)
+}
+
+// newRetrySigner returns signer wrapped in a retrySigner configured by config,
+// or signer itself if config is nil.
+func newRetrySigner(signer crypto.Signer, config *signRetryConfig) (crypto.Signer, error) {
+	if config == nil {
+		return signer, nil
+	}
+	err := config.validate()
+	if err != nil {
+		return nil, err
+	}
+	// validate has already checked that the delay parses.
+	delay, _ := time.ParseDuration(config.Delay)
+	return &retrySigner{
+		Signer:   signer,
+		attempts: config.Attempts,
+		delay:    delay,
+		sleep:    time.Sleep,
+	}, nil
+}
+
+func (rs *retrySigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	delay := rs.delay
+	for attempt := 1; ; attempt++ {
+		signature, err := rs.Signer.Sign(rand, digest, opts)
+		if err == nil {
+			return signature, nil
+		}
+		var pkcs11Err pkcs11.Error
+		if !errors.As(err, &pkcs11Err) || !slices.Contains(transientPKCS11Errors, pkcs11Err) {
+			return nil, err
+		}
+		if attempt >= rs.attempts {
+			return nil, fmt.Errorf("signing failed after %d attempts: %w", attempt, err)
+		}
+		log.Printf("Signing attempt %d of %d failed with a transient error, retrying in %s: %s\n", attempt, rs.attempts, delay, err)
+		rs.sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/miekg/pkcs11"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+// flakySigner fails with each of errs in turn before signing with key.
+type flakySigner struct {
+	key   *ecdsa.PrivateKey
+	errs  []error
+	calls int
+}
+
+func (fs *flakySigner) Public() crypto.PublicKey {
+	return fs.key.Public()
+}
+
+func (fs *flakySigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	fs.calls++
+	if len(fs.errs) > 0 {
+		err := fs.errs[0]
+		fs.errs = fs.errs[1:]
+		return nil, err
+	}
+	return fs.key.Sign(rand, digest, opts)
+}
+
+func TestSignRetryConfigValidate(t *testing.T) {
+	for _, tc := range []struct {
+		config      *signRetryConfig
+		expectedErr string
+	}{
+		{
+			config: nil,
+		},
+		{
+			config: &signRetryConfig{Attempts: 3, Delay: "1s"},
+		},
+		{
+			config:      &signRetryConfig{Attempts: 0, Delay: "1s"},
+			expectedErr: "pkcs11.sign-retry.attempts must be between 1 and 10",
+		},
+		{
+			config:      &signRetryConfig{Attempts: 11, Delay: "1s"},
+			expectedErr: "pkcs11.sign-retry.attempts must be between 1 and 10",
+		},
+		{
+			config:      &signRetryConfig{Attempts: 3, Delay: "soon"},
+			expectedErr: "pkcs11.sign-retry.delay is invalid: time: invalid duration \"soon\"",
+		},
+		{
+			config:      &signRetryConfig{Attempts: 3, Delay: "2m"},
+			expectedErr: "pkcs11.sign-retry.delay must be greater than 0 and at most 1m0s",
+		},
+	} {
+		err := tc.config.validate()
+		if tc.expectedErr == "" {
+			test.AssertNotError(t, err, "validate failed")
+		} else {
+			test.AssertError(t, err, "validate didn't fail")
+			test.AssertEquals(t, err.Error(), tc.expectedErr)
+		}
+	}
+}
+
+func TestRetrySigner(t *testing.T) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	digest := sha256.Sum256([]byte("data"))
+	// The PKCS#11 error is wrapped, as it is by pkcs11helpers.
+	deviceErr := fmt.Errorf("failed to sign data: %w", pkcs11.Error(pkcs11.CKR_DEVICE_ERROR))
+
+	newSigner := func(errs ...error) (*flakySigner, *retrySigner, *[]time.Duration) {
+		backend := &flakySigner{key: k, errs: errs}
+		signer, err := newRetrySigner(backend, &signRetryConfig{Attempts: 3, Delay: "1s"})
+		test.AssertNotError(t, err, "newRetrySigner failed")
+		rs := signer.(*retrySigner)
+		var delays []time.Duration
+		rs.sleep = func(d time.Duration) { delays = append(delays, d) }
+		return backend, rs, &delays
+	}
+
+	// Two transient failures are retried with a backoff before succeeding.
+	backend, rs, delays := newSigner(deviceErr, deviceErr)
+	sig, err := rs.Sign(rand.Reader, digest[:], crypto.SHA256)
+	test.AssertNotError(t, err, "Sign failed")
+	test.Assert(t, ecdsa.VerifyASN1(&k.PublicKey, digest[:], sig), "signature doesn't verify")
+	test.AssertEquals(t, backend.calls, 3)
+	test.AssertDeepEquals(t, *delays, []time.Duration{time.Second, 2 * time.Second})
+
+	// Transient failures stop being retried once the attempts are exhausted.
+	backend, rs, _ = newSigner(deviceErr, deviceErr, deviceErr)
+	_, err = rs.Sign(rand.Reader, digest[:], crypto.SHA256)
+	test.AssertError(t, err, "Sign didn't fail")
+	test.AssertEquals(t, err.Error(), "signing failed after 3 attempts: failed to sign data: pkcs11: 0x30: CKR_DEVICE_ERROR")
+	test.AssertEquals(t, backend.calls, 3)
+
+	// Other errors fail immediately.
+	for _, nonTransient := range []error{
+		fmt.Errorf("failed to sign data: %w", pkcs11.Error(pkcs11.CKR_KEY_HANDLE_INVALID)),
+		errors.New("digest length doesn't match hash length"),
+	} {
+		backend, rs, delays = newSigner(nonTransient)
+		_, err = rs.Sign(rand.Reader, digest[:], crypto.SHA256)
+		test.AssertErrorIs(t, err, nonTransient)
+		test.AssertEquals(t, backend.calls, 1)
+		test.AssertEquals(t, len(*delays), 0)
+	}
+
+	// Without a config the signer isn't wrapped.
+	signer, err := newRetrySigner(backend, nil)
+	test.AssertNotError(t, err, "newRetrySigner failed")
+	test.AssertEquals(t, signer, crypto.Signer(backend))
+}
@@ -15,16 +15,16 @@ const (
 )
 
 // rsaArgs constructs the private and public key template attributes sent to the
-// device and specifies which mechanism should be used. modulusLen specifies the
+// device and specifies that mechanism should be used. modulusLen specifies the
 // length of the modulus to be generated on the device in bits and exponent
 // specifies the public exponent that should be used.
-func rsaArgs(label string, modulusLen, exponent uint, keyID []byte) generateArgs {
+func rsaArgs(label string, modulusLen, exponent uint, keyID []byte, mechanism uint) generateArgs {
 	// Encode as unpadded big endian encoded byte slice
 	expSlice := big.NewInt(int64(exponent)).Bytes()
 	log.Printf("\tEncoded public exponent (%d) as: %0X\n", exponent, expSlice)
 	return generateArgs{
 		mechanism: []*pkcs11.Mechanism{
-			pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil),
+			pkcs11.NewMechanism(mechanism, nil),
 		},
 		publicAttrs: []*pkcs11.Attribute{
 			pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
@@ -72,17 +72,18 @@ func rsaPub(session *pkcs11helpers.Session, object pkcs11.ObjectHandle, modulusL
 }
 
 // rsaGenerate is used to generate and verify a RSA key pair of the size
-// specified by modulusLen and with the exponent 65537.
+// specified by modulusLen and with the exponent 65537, using the key pair
+// generation mechanism specified by mechanism.
 // It returns the public part of the generated key pair as a rsa.PublicKey
 // and the random key ID that the HSM uses to identify the key pair.
-func rsaGenerate(session *pkcs11helpers.Session, label string, modulusLen uint) (*rsa.PublicKey, []byte, error) {
+func rsaGenerate(session *pkcs11helpers.Session, label string, modulusLen, mechanism uint) (*rsa.PublicKey, []byte, error) {
 	keyID := make([]byte, 4)
 	_, err := newRandReader(session).Read(keyID)
 	if err != nil {
 		return nil, nil, err
 	}
 	log.Printf("Generating RSA key with %d bit modulus and public exponent %d and ID %x\n", modulusLen, rsaExp, keyID)
-	args := rsaArgs(label, modulusLen, rsaExp, keyID)
+	args := rsaArgs(label, modulusLen, rsaExp, keyID, mechanism)
 	pub, _, err := session.GenerateKeyPair(args.mechanism, args.publicAttrs, args.privateAttrs)
 	if err != nil {
 		return nil, nil, err
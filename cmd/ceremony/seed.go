@@ -0,0 +1,331 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/letsencrypt/boulder/strictyaml"
+)
+
+// seedKeyConfig describes a key which is generated on a token, and the slot
+// and label it is stored under.
+type seedKeyConfig struct {
+	Slot  uint         `yaml:"slot"`
+	Label string       `yaml:"label"`
+	Key   keyGenConfig `yaml:"key"`
+}
+
+type seedRootConfig struct {
+	Name        string        `yaml:"name"`
+	Key         seedKeyConfig `yaml:"key"`
+	CertProfile certProfile   `yaml:"certificate-profile"`
+	SkipLints   []string      `yaml:"skip-lints"`
+}
+
+type seedIntermediateConfig struct {
+	Name        string        `yaml:"name"`
+	Issuer      string        `yaml:"issuer"`
+	Key         seedKeyConfig `yaml:"key"`
+	CertProfile certProfile   `yaml:"certificate-profile"`
+	SkipLints   []string      `yaml:"skip-lints"`
+}
+
+type seedCrossCertConfig struct {
+	Name        string      `yaml:"name"`
+	Issuer      string      `yaml:"issuer"`
+	Subject     string      `yaml:"subject"`
+	CertProfile certProfile `yaml:"certificate-profile"`
+	SkipLints   []string    `yaml:"skip-lints"`
+}
+
+// seedHierarchyConfig describes a complete hierarchy of roots, intermediates,
+// and cross-certificates for a test environment. Each entry is issued by a
+// component ceremony, and its outputs are written to the output directory
+// using the entry's name, where they are used as the inputs of later steps.
+type seedHierarchyConfig struct {
+	CeremonyType string `yaml:"ceremony-type"`
+	PKCS11       struct {
+		Module string `yaml:"module"`
+		PIN    string `yaml:"pin"`
+	} `yaml:"pkcs11"`
+	OutputDirectory   string                   `yaml:"output-directory"`
+	Roots             []seedRootConfig         `yaml:"roots"`
+	Intermediates     []seedIntermediateConfig `yaml:"intermediates"`
+	CrossCertificates []seedCrossCertConfig    `yaml:"cross-certificates"`
+}
+
+func (shc seedHierarchyConfig) publicKeyPath(name string) string {
+	return filepath.Join(shc.OutputDirectory, name+".pubkey.pem")
+}
+
+func (shc seedHierarchyConfig) certificatePath(name string) string {
+	return filepath.Join(shc.OutputDirectory, name+".cert.pem")
+}
+
+// validate checks the whole hierarchy before any ceremony is run, so that a
+// mistake in a later step doesn't leave a partially seeded hierarchy behind.
+func (shc seedHierarchyConfig) validate() error {
+	if shc.PKCS11.Module == "" {
+		return errors.New("pkcs11.module is required")
+	}
+	if shc.OutputDirectory == "" {
+		return errors.New("output-directory is required")
+	}
+	if len(shc.Roots) == 0 {
+		return errors.New("at least one root is required")
+	}
+
+	// names maps each entry name to the field which defined it, and paths and
+	// keys map each output path and key slot and label to the field which
+	// uses it, so that collisions between any two steps are detected.
+	names := make(map[string]string)
+	paths := make(map[string]string)
+	keys := make(map[string]string)
+	addName := func(field, name string) error {
+		if name == "" {
+			return fmt.Errorf("%s.name is required", field)
+		}
+		// Names are used as file names in the output directory.
+		if filepath.Base(name) != name {
+			return fmt.Errorf("%s.name %q must not contain a path separator", field, name)
+		}
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("%s.name %q is already used by %s", field, name, other)
+		}
+		names[name] = field
+		return nil
+	}
+	addPath := func(field, path string) error {
+		if other, ok := paths[path]; ok {
+			return fmt.Errorf("%s output %q is already written by %s", field, path, other)
+		}
+		paths[path] = field
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			return fmt.Errorf("%s output %q already exists", field, path)
+		}
+		return nil
+	}
+	addKey := func(field string, key seedKeyConfig) error {
+		if key.Label == "" {
+			return fmt.Errorf("%s.key.label is required", field)
+		}
+		id := fmt.Sprintf("%q in slot %d", key.Label, key.Slot)
+		if other, ok := keys[id]; ok {
+			return fmt.Errorf("%s.key label %s is already used by %s", field, id, other)
+		}
+		keys[id] = field
+		if key.Key.Type == "mldsa" {
+			return fmt.Errorf("%s.key.type 'mldsa' is only supported by the key ceremony", field)
+		}
+		return key.Key.validate()
+	}
+
+	// roots and subjects contain the names of entries which may issue and be
+	// cross-signed, respectively.
+	roots := make(map[string]bool)
+	subjects := make(map[string]bool)
+	for i, root := range shc.Roots {
+		field := fmt.Sprintf("roots[%d]", i)
+		err := addName(field, root.Name)
+		if err != nil {
+			return err
+		}
+		err = addKey(field, root.Key)
+		if err != nil {
+			return err
+		}
+		err = addPath(field, shc.publicKeyPath(root.Name))
+		if err != nil {
+			return err
+		}
+		err = addPath(field, shc.certificatePath(root.Name))
+		if err != nil {
+			return err
+		}
+		err = root.CertProfile.verifyProfile(rootCert)
+		if err != nil {
+			return fmt.Errorf("%s.certificate-profile: %w", field, err)
+		}
+		roots[root.Name] = true
+		subjects[root.Name] = true
+	}
+
+	for i, intermediate := range shc.Intermediates {
+		field := fmt.Sprintf("intermediates[%d]", i)
+		err := addName(field, intermediate.Name)
+		if err != nil {
+			return err
+		}
+		// Intermediates are issued with a path length of zero, so only roots
+		// can issue them.
+		if !roots[intermediate.Issuer] {
+			return fmt.Errorf("%s.issuer %q is not the name of a root", field, intermediate.Issuer)
+		}
+		err = addKey(field, intermediate.Key)
+		if err != nil {
+			return err
+		}
+		err = addPath(field, shc.publicKeyPath(intermediate.Name))
+		if err != nil {
+			return err
+		}
+		err = addPath(field, shc.certificatePath(intermediate.Name))
+		if err != nil {
+			return err
+		}
+		err = intermediate.CertProfile.verifyProfile(intermediateCert)
+		if err != nil {
+			return fmt.Errorf("%s.certificate-profile: %w", field, err)
+		}
+		subjects[intermediate.Name] = true
+	}
+
+	for i, cross := range shc.CrossCertificates {
+		field := fmt.Sprintf("cross-certificates[%d]", i)
+		err := addName(field, cross.Name)
+		if err != nil {
+			return err
+		}
+		if !subjects[cross.Subject] {
+			return fmt.Errorf("%s.subject %q is not the name of a root or intermediate", field, cross.Subject)
+		}
+		if !roots[cross.Issuer] {
+			return fmt.Errorf("%s.issuer %q is not the name of a root", field, cross.Issuer)
+		}
+		if cross.Issuer == cross.Subject {
+			return fmt.Errorf("%s.issuer and %s.subject are both %q", field, field, cross.Issuer)
+		}
+		err = addPath(field, shc.certificatePath(cross.Name))
+		if err != nil {
+			return err
+		}
+		err = cross.CertProfile.verifyProfile(crossCert)
+		if err != nil {
+			return fmt.Errorf("%s.certificate-profile: %w", field, err)
+		}
+	}
+
+	return nil
+}
+
+// seedHierarchyCeremony runs the root, key, intermediate, and cross-certificate
+// ceremonies required to create the hierarchy described by the config, in
+// that order. The config of each step is generated from the hierarchy config
+// and passed to the same function which would handle it as a standalone
+// ceremony.
+func seedHierarchyCeremony(configBytes []byte) error {
+	var config seedHierarchyConfig
+	err := strictyaml.Unmarshal(configBytes, &config)
+	if err != nil {
+		return configErrorf("failed to parse config: %w", err)
+	}
+	log.Printf("Preparing seed-hierarchy ceremony for %s\n", config.OutputDirectory)
+	err = config.validate()
+	if err != nil {
+		return configErrorf("failed to validate config: %w", err)
+	}
+
+	keys := make(map[string]seedKeyConfig)
+	for _, root := range config.Roots {
+		var rc rootConfig
+		rc.CeremonyType = "root"
+		rc.PKCS11 = PKCS11KeyGenConfig{
+			Module:     config.PKCS11.Module,
+			PIN:        config.PKCS11.PIN,
+			StoreSlot:  root.Key.Slot,
+			StoreLabel: root.Key.Label,
+		}
+		rc.Key = root.Key.Key
+		rc.Outputs.PublicKeyPath = config.publicKeyPath(root.Name)
+		rc.Outputs.CertificatePath = config.certificatePath(root.Name)
+		rc.CertProfile = root.CertProfile
+		rc.SkipLints = root.SkipLints
+		err = runSeedStep(root.Name, rc.CeremonyType, rc, func(b []byte) error { return rootCeremony(b, b, false, false, "") })
+		if err != nil {
+			return err
+		}
+		keys[root.Name] = root.Key
+	}
+
+	for _, intermediate := range config.Intermediates {
+		var kc keyConfig
+		kc.CeremonyType = "key"
+		kc.PKCS11 = PKCS11KeyGenConfig{
+			Module:     config.PKCS11.Module,
+			PIN:        config.PKCS11.PIN,
+			StoreSlot:  intermediate.Key.Slot,
+			StoreLabel: intermediate.Key.Label,
+		}
+		kc.Key = intermediate.Key.Key
+		kc.Outputs.PublicKeyPath = config.publicKeyPath(intermediate.Name)
+		err = runSeedStep(intermediate.Name, kc.CeremonyType, kc, func(b []byte) error { return keyCeremony(b, false, "") })
+		if err != nil {
+			return err
+		}
+		keys[intermediate.Name] = intermediate.Key
+
+		var ic intermediateConfig
+		ic.CeremonyType = "intermediate"
+		ic.PKCS11 = PKCS11SigningConfig{
+			Module:       config.PKCS11.Module,
+			PIN:          config.PKCS11.PIN,
+			SigningSlot:  keys[intermediate.Issuer].Slot,
+			SigningLabel: keys[intermediate.Issuer].Label,
+		}
+		ic.Inputs.PublicKeyPath = config.publicKeyPath(intermediate.Name)
+		ic.Inputs.IssuerCertificatePath = config.certificatePath(intermediate.Issuer)
+		ic.Inputs.TrustAnchorCertificatePath = config.certificatePath(intermediate.Issuer)
+		ic.Outputs.CertificatePath = config.certificatePath(intermediate.Name)
+		ic.CertProfile = intermediate.CertProfile
+		ic.SkipLints = intermediate.SkipLints
+		err = runSeedStep(intermediate.Name, ic.CeremonyType, ic, func(b []byte) error { return intermediateCeremony(b, intermediateCert, false, "", "") })
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, cross := range config.CrossCertificates {
+		var csc crossCertConfig
+		csc.CeremonyType = "cross-certificate"
+		csc.PKCS11 = PKCS11SigningConfig{
+			Module:       config.PKCS11.Module,
+			PIN:          config.PKCS11.PIN,
+			SigningSlot:  keys[cross.Issuer].Slot,
+			SigningLabel: keys[cross.Issuer].Label,
+		}
+		csc.Inputs.PublicKeyPath = config.publicKeyPath(cross.Subject)
+		csc.Inputs.IssuerCertificatePath = config.certificatePath(cross.Issuer)
+		csc.Inputs.TrustAnchorCertificatePath = config.certificatePath(cross.Issuer)
+		csc.Inputs.CertificateToCrossSignPath = config.certificatePath(cross.Subject)
+		csc.Outputs.CertificatePath = config.certificatePath(cross.Name)
+		csc.CertProfile = cross.CertProfile
+		csc.SkipLints = cross.SkipLints
+		err = runSeedStep(cross.Name, csc.CeremonyType, csc, func(b []byte) error { return crossCertCeremony(b, crossCert, false, "") })
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runSeedStep encodes the config of a single step of a seed-hierarchy
+// ceremony and passes it to the ceremony function for that step.
+func runSeedStep(name, ceremonyType string, config any, ceremony func([]byte) error) error {
+	configBytes, err := yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s config for %q: %w", ceremonyType, name, err)
+	}
+	// The config isn't logged, since it contains the PKCS#11 PIN.
+	log.Printf("Running %s ceremony for %q\n", ceremonyType, name)
+	err = ceremony(configBytes)
+	if err != nil {
+		return fmt.Errorf("%s ceremony for %q failed: %w", ceremonyType, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+
+	"github.com/letsencrypt/boulder/pkcs11helpers"
+	"github.com/letsencrypt/boulder/strictyaml"
+	"github.com/letsencrypt/boulder/test"
+)
+
+// softToken is an in-memory PKCS#11 token which supports the ECDSA operations
+// used by the root, key, intermediate, and cross-certificate ceremonies, and
+// the AES key generation used by the wrapping-key ceremony.
+type softToken struct {
+	objects [][]*pkcs11.Attribute
+	keys    map[pkcs11.ObjectHandle]*ecdsa.PrivateKey
+	found   []pkcs11.ObjectHandle
+	signer  *ecdsa.PrivateKey
+}
+
+func (st *softToken) add(attrs []*pkcs11.Attribute) pkcs11.ObjectHandle {
+	st.objects = append(st.objects, attrs)
+	return pkcs11.ObjectHandle(len(st.objects) - 1)
+}
+
+func (st *softToken) GenerateKeyPair(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, pubAttrs []*pkcs11.Attribute, privAttrs []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error) {
+	if len(m) != 1 || m[0].Mechanism != pkcs11.CKM_EC_KEY_PAIR_GEN {
+		return 0, 0, errors.New("unsupported mechanism")
+	}
+	var params []byte
+	for _, a := range pubAttrs {
+		if a.Type == pkcs11.CKA_EC_PARAMS {
+			params = a.Value
+		}
+	}
+	var key *ecdsa.PrivateKey
+	for name, oid := range curveToOIDDER {
+		if bytes.Equal(oid, params) {
+			var err error
+			key, err = ecdsa.GenerateKey(stringToCurve[name], rand.Reader)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	if key == nil {
+		return 0, 0, errors.New("unsupported curve")
+	}
+	point, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagOctetString, Bytes: elliptic.Marshal(key.Curve, key.X, key.Y)})
+	if err != nil {
+		return 0, 0, err
+	}
+	pub := st.add(append(pubAttrs,
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, point),
+	))
+	priv := st.add(append(privAttrs,
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+	))
+	st.keys[priv] = key
+	return pub, priv, nil
+}
+
+func (st *softToken) GenerateKey(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, attrs []*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+	if len(m) != 1 || m[0].Mechanism != pkcs11.CKM_AES_KEY_GEN {
+		return 0, errors.New("unsupported mechanism")
+	}
+	return st.add(attrs), nil
+}
+
+func (st *softToken) GetAttributeValue(_ pkcs11.SessionHandle, o pkcs11.ObjectHandle, attrs []*pkcs11.Attribute) ([]*pkcs11.Attribute, error) {
+	var values []*pkcs11.Attribute
+	for _, want := range attrs {
+		for _, have := range st.objects[o] {
+			if have.Type == want.Type {
+				values = append(values, pkcs11.NewAttribute(have.Type, have.Value))
+			}
+		}
+	}
+	if len(values) != len(attrs) {
+		return nil, errors.New("attribute not found")
+	}
+	return values, nil
+}
+
+func (st *softToken) SignInit(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, o pkcs11.ObjectHandle) error {
+	key, ok := st.keys[o]
+	if !ok || len(m) != 1 || m[0].Mechanism != pkcs11.CKM_ECDSA {
+		return errors.New("unsupported signing operation")
+	}
+	st.signer = key
+	return nil
+}
+
+func (st *softToken) Sign(_ pkcs11.SessionHandle, digest []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, st.signer, digest)
+	if err != nil {
+		return nil, err
+	}
+	// PKCS#11 ECDSA signatures are the fixed length concatenation of r and s.
+	size := (st.signer.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+	return signature, nil
+}
+
+func (st *softToken) GenerateRandom(_ pkcs11.SessionHandle, c int) ([]byte, error) {
+	b := make([]byte, c)
+	_, err := rand.Read(b)
+	return b, err
+}
+
+func (st *softToken) FindObjectsInit(_ pkcs11.SessionHandle, tmpl []*pkcs11.Attribute) error {
+	st.found = nil
+	for o, attrs := range st.objects {
+		matches := true
+		for _, want := range tmpl {
+			match := false
+			for _, have := range attrs {
+				if have.Type == want.Type && bytes.Equal(have.Value, want.Value) {
+					match = true
+				}
+			}
+			matches = matches && match
+		}
+		if matches {
+			st.found = append(st.found, pkcs11.ObjectHandle(o))
+		}
+	}
+	return nil
+}
+
+func (st *softToken) FindObjects(_ pkcs11.SessionHandle, max int) ([]pkcs11.ObjectHandle, bool, error) {
+	return st.found[:min(max, len(st.found))], false, nil
+}
+
+func (st *softToken) FindObjectsFinal(_ pkcs11.SessionHandle) error {
+	st.found = nil
+	return nil
+}
+
+// useSoftTokens replaces initializeSession for the duration of the test with
+// one which opens sessions with a softToken per slot.
+func useSoftTokens(t *testing.T) {
+	tokens := make(map[uint]*softToken)
+	initializeSession = func(module string, slot uint, userType uint, pin string) (*pkcs11helpers.Session, error) {
+		token, ok := tokens[slot]
+		if !ok {
+			token = &softToken{keys: make(map[pkcs11.ObjectHandle]*ecdsa.PrivateKey)}
+			tokens[slot] = token
+		}
+		return &pkcs11helpers.Session{Module: token}, nil
+	}
+	t.Cleanup(func() { initializeSession = pkcs11helpers.Initialize })
+}
+
+// seedHierarchyTestConfig describes two roots, an intermediate issued by each,
+// and a cross-sign of the second root by the first.
+const seedHierarchyTestConfig = `
+ceremony-type: seed-hierarchy
+pkcs11:
+    module: soft
+    pin: seed-hierarchy-pin
+output-directory: %s
+roots:
+    - name: root-x1
+      key:
+          slot: 0
+          label: root x1
+          key:
+              type: ecdsa
+              ecdsa-curve: P-384
+      certificate-profile:
+          signature-algorithm: ECDSAWithSHA384
+          common-name: root x1
+          organization: good guys
+          country: US
+          not-before: 2020-01-01 12:00:00
+          not-after: 2040-01-01 12:00:00
+          key-usages:
+              - Cert Sign
+              - CRL Sign
+      skip-lints:
+          - n_ca_digital_signature_not_set
+    - name: root-x2
+      key:
+          slot: 1
+          label: root x2
+          key:
+              type: ecdsa
+              ecdsa-curve: P-256
+      certificate-profile:
+          signature-algorithm: ECDSAWithSHA256
+          common-name: root x2
+          organization: good guys
+          country: US
+          not-before: 2020-01-01 12:00:00
+          not-after: 2040-01-01 12:00:00
+          key-usages:
+              - Cert Sign
+              - CRL Sign
+      skip-lints:
+          - n_ca_digital_signature_not_set
+intermediates:
+    - name: int-r1
+      issuer: root-x1
+      key:
+          slot: 2
+          label: int r1
+          key:
+              type: ecdsa
+              ecdsa-curve: P-256
+      certificate-profile:
+          signature-algorithm: ECDSAWithSHA384
+          common-name: int r1
+          organization: good guys
+          country: US
+          not-before: 2020-01-01 12:00:00
+          not-after: 2025-01-01 11:59:59
+          crl-url: http://x1.example.com/crl
+          issuer-url: http://x1.example.com/cert
+          policies:
+              - oid: 2.23.140.1.2.1
+          key-usages:
+              - Digital Signature
+              - Cert Sign
+              - CRL Sign
+    - name: int-e1
+      issuer: root-x2
+      key:
+          slot: 2
+          label: int e1
+          key:
+              type: ecdsa
+              ecdsa-curve: P-256
+      certificate-profile:
+          signature-algorithm: ECDSAWithSHA256
+          common-name: int e1
+          organization: good guys
+          country: US
+          not-before: 2020-01-01 12:00:00
+          not-after: 2025-01-01 11:59:59
+          crl-url: http://x2.example.com/crl
+          issuer-url: http://x2.example.com/cert
+          policies:
+              - oid: 2.23.140.1.2.1
+          key-usages:
+              - Digital Signature
+              - Cert Sign
+              - CRL Sign
+cross-certificates:
+    - name: root-x2-cross
+      issuer: root-x1
+      subject: root-x2
+      certificate-profile:
+          signature-algorithm: ECDSAWithSHA384
+          common-name: root x2
+          organization: good guys
+          country: US
+          not-before: 2020-01-01 12:00:00
+          not-after: 2025-01-01 11:59:59
+          crl-url: http://x1.example.com/crl
+          issuer-url: http://x1.example.com/cert
+          policies:
+              - oid: 2.23.140.1.2.1
+          key-usages:
+              - Cert Sign
+              - CRL Sign
+      skip-lints:
+          - n_ca_digital_signature_not_set
+          - n_mp_allowed_eku
+          - n_sub_ca_eku_missing
+`
+
+func TestSeedHierarchyCeremony(t *testing.T) {
+	useSoftTokens(t)
+	dir := t.TempDir()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	err := seedHierarchyCeremony([]byte(fmt.Sprintf(seedHierarchyTestConfig, dir)))
+	test.AssertNotError(t, err, "seedHierarchyCeremony failed")
+	test.AssertContains(t, logs.String(), `Running root ceremony for "root-x1"`)
+	test.AssertNotContains(t, logs.String(), "seed-hierarchy-pin")
+
+	rootX1, err := loadCert(filepath.Join(dir, "root-x1.cert.pem"))
+	test.AssertNotError(t, err, "failed to load root-x1")
+	rootX2, err := loadCert(filepath.Join(dir, "root-x2.cert.pem"))
+	test.AssertNotError(t, err, "failed to load root-x2")
+	rootX2Cross, err := loadCert(filepath.Join(dir, "root-x2-cross.cert.pem"))
+	test.AssertNotError(t, err, "failed to load root-x2-cross")
+	intR1, err := loadCert(filepath.Join(dir, "int-r1.cert.pem"))
+	test.AssertNotError(t, err, "failed to load int-r1")
+	intE1, err := loadCert(filepath.Join(dir, "int-e1.cert.pem"))
+	test.AssertNotError(t, err, "failed to load int-e1")
+
+	test.AssertNotError(t, verifyChain(intR1, nil, rootX1), "int-r1 doesn't chain to root-x1")
+	test.AssertNotError(t, verifyChain(intE1, nil, rootX2), "int-e1 doesn't chain to root-x2")
+	test.AssertNotError(t, verifyChain(rootX2Cross, nil, rootX1), "root-x2-cross doesn't chain to root-x1")
+
+	// int-e1 must chain to root-x1 through the cross-sign of root-x2, making a
+	// complete three level hierarchy.
+	roots := x509.NewCertPool()
+	roots.AddCert(rootX1)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(rootX2Cross)
+	chains, err := intE1.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   intE1.NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	test.AssertNotError(t, err, "int-e1 doesn't chain to root-x1")
+	test.AssertEquals(t, len(chains), 1)
+	test.AssertEquals(t, len(chains[0]), 3)
+	test.AssertByteEquals(t, chains[0][1].Raw, rootX2Cross.Raw)
+}
+
+func TestSeedHierarchyConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "existing.cert.pem"), nil, 0644)
+	test.AssertNotError(t, err, "failed to write existing output")
+
+	cases := []struct {
+		name          string
+		modify        func(*seedHierarchyConfig)
+		expectedError string
+	}{
+		{
+			name:   "good config",
+			modify: func(*seedHierarchyConfig) {},
+		},
+		{
+			name:          "no pkcs11.module",
+			modify:        func(c *seedHierarchyConfig) { c.PKCS11.Module = "" },
+			expectedError: "pkcs11.module is required",
+		},
+		{
+			name:          "no output-directory",
+			modify:        func(c *seedHierarchyConfig) { c.OutputDirectory = "" },
+			expectedError: "output-directory is required",
+		},
+		{
+			name:          "no roots",
+			modify:        func(c *seedHierarchyConfig) { c.Roots = nil },
+			expectedError: "at least one root is required",
+		},
+		{
+			name:          "duplicate name",
+			modify:        func(c *seedHierarchyConfig) { c.Intermediates[1].Name = "root-x2" },
+			expectedError: `intermediates[1].name "root-x2" is already used by roots[1]`,
+		},
+		{
+			name:          "name with path separator",
+			modify:        func(c *seedHierarchyConfig) { c.Roots[0].Name = "../root-x1" },
+			expectedError: `roots[0].name "../root-x1" must not contain a path separator`,
+		},
+		{
+			name:          "duplicate key label",
+			modify:        func(c *seedHierarchyConfig) { c.Intermediates[1].Key.Label = "int r1" },
+			expectedError: `intermediates[1].key label "int r1" in slot 2 is already used by intermediates[0]`,
+		},
+		{
+			name:          "existing output",
+			modify:        func(c *seedHierarchyConfig) { c.CrossCertificates[0].Name = "existing" },
+			expectedError: fmt.Sprintf("cross-certificates[0] output %q already exists", filepath.Join(dir, "existing.cert.pem")),
+		},
+		{
+			name:          "intermediate issuer is not a root",
+			modify:        func(c *seedHierarchyConfig) { c.Intermediates[1].Issuer = "int-r1" },
+			expectedError: `intermediates[1].issuer "int-r1" is not the name of a root`,
+		},
+		{
+			name:          "cross-certificate subject is unknown",
+			modify:        func(c *seedHierarchyConfig) { c.CrossCertificates[0].Subject = "root-x3" },
+			expectedError: `cross-certificates[0].subject "root-x3" is not the name of a root or intermediate`,
+		},
+		{
+			name:          "cross-certificate issuer is its subject",
+			modify:        func(c *seedHierarchyConfig) { c.CrossCertificates[0].Issuer = "root-x2" },
+			expectedError: `cross-certificates[0].issuer and cross-certificates[0].subject are both "root-x2"`,
+		},
+		{
+			name:          "bad intermediate profile",
+			modify:        func(c *seedHierarchyConfig) { c.Intermediates[0].CertProfile.CRLURL = "" },
+			expectedError: "intermediates[0].certificate-profile: crl-url is required for subordinate CAs",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var config seedHierarchyConfig
+			err := strictyaml.Unmarshal([]byte(fmt.Sprintf(seedHierarchyTestConfig, dir)), &config)
+			test.AssertNotError(t, err, "failed to parse config")
+			tc.modify(&config)
+			err = config.validate()
+			if tc.expectedError == "" {
+				test.AssertNotError(t, err, "validate failed")
+			} else {
+				test.AssertError(t, err, "validate didn't fail")
+				test.AssertEquals(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
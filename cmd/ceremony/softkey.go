@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// softwareSigner signs with a private key held in memory rather than on an
+// HSM. It is only used when --software-key is passed, for local testing.
+type softwareSigner struct {
+	crypto.Signer
+}
+
+// Sign ignores the provided io.Reader, as x509.CreateCertificate is passed a
+// failReader because HSMs generate their own randomness.
+func (ss softwareSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return ss.Signer.Sign(rand.Reader, digest, opts)
+}
+
+// loadSoftwareKey loads a PEM encoded PKCS#8, SEC 1, or PKCS#1 private key from
+// filename.
+func loadSoftwareKey(filename string) (crypto.Signer, error) {
+	keyPEM, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in software key file %s", filename)
+	}
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("software key file %s contains a %q PEM block, not a private key", filename, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse software key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("software key of type %T can't sign", key)
+	}
+	return softwareSigner{signer}, nil
+}
+
+// openSoftwareSigner loads the software key from filename and checks that it
+// matches pubKey. It returns crypto/rand.Reader as the source of randomness for
+// serial numbers.
+func openSoftwareSigner(filename string, pubKey crypto.PublicKey) (crypto.Signer, io.Reader, error) {
+	log.Printf("WARNING: signing with the software key %q instead of a PKCS#11 token, this must only be used for testing\n", filename)
+	signer, err := loadSoftwareKey(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	ok, err := publicKeysEqual(signer.Public(), pubKey)
+	if !ok {
+		if err == nil {
+			err = errors.New("software key doesn't match the issuer's public key")
+		}
+		return nil, nil, err
+	}
+	return signer, rand.Reader, nil
+}
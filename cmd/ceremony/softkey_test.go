@@ -0,0 +1,304 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+func writePEMFile(t *testing.T, filename, blockType string, der []byte) {
+	t.Helper()
+	err := os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	test.AssertNotError(t, err, "failed to write PEM file")
+}
+
+func TestLoadSoftwareKey(t *testing.T) {
+	dir := t.TempDir()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(k)
+	test.AssertNotError(t, err, "failed to marshal test key")
+	writePEMFile(t, path.Join(dir, "pkcs8.pem"), "PRIVATE KEY", pkcs8)
+	signer, err := loadSoftwareKey(path.Join(dir, "pkcs8.pem"))
+	test.AssertNotError(t, err, "failed to load PKCS#8 key")
+	test.Assert(t, k.PublicKey.Equal(signer.Public()), "loaded key doesn't match")
+
+	sec1, err := x509.MarshalECPrivateKey(k)
+	test.AssertNotError(t, err, "failed to marshal test key")
+	writePEMFile(t, path.Join(dir, "sec1.pem"), "EC PRIVATE KEY", sec1)
+	signer, err = loadSoftwareKey(path.Join(dir, "sec1.pem"))
+	test.AssertNotError(t, err, "failed to load SEC 1 key")
+	test.Assert(t, k.PublicKey.Equal(signer.Public()), "loaded key doesn't match")
+
+	_, err = loadSoftwareKey("../../test/test-root.pubkey.pem")
+	test.AssertError(t, err, "loaded a public key")
+	test.AssertContains(t, err.Error(), "not a private key")
+
+	// The key must match the issuer.
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	_, _, err = openSoftwareSigner(path.Join(dir, "pkcs8.pem"), other.Public())
+	test.AssertError(t, err, "openSoftwareSigner didn't fail with a mismatched key")
+	test.AssertEquals(t, err.Error(), "software key doesn't match the issuer's public key")
+}
+
+func TestSoftwareKeyExclusiveWithPKCS11(t *testing.T) {
+	psc := PKCS11SigningConfig{softwareKeyPath: "key.pem"}
+	test.AssertNotError(t, psc.validate(), "validate failed without a pkcs11 block")
+
+	psc.SigningLabel = "label"
+	err := psc.validate()
+	test.AssertError(t, err, "validate didn't fail with a pkcs11 block")
+	test.AssertEquals(t, err.Error(), "pkcs11 cannot be set when --software-key is used")
+}
+
+func TestIntermediateCeremonySoftwareKey(t *testing.T) {
+	dir := t.TempDir()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate root key")
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root", Organization: []string{"organization"}, Country: []string{"US"}},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootKey.Public(), rootKey)
+	test.AssertNotError(t, err, "failed to create root certificate")
+	rootPath := path.Join(dir, "root.cert.pem")
+	writePEMFile(t, rootPath, "CERTIFICATE", rootDER)
+	rootKeyDER, err := x509.MarshalPKCS8PrivateKey(rootKey)
+	test.AssertNotError(t, err, "failed to marshal root key")
+	rootKeyPath := path.Join(dir, "root.key.pem")
+	writePEMFile(t, rootKeyPath, "PRIVATE KEY", rootKeyDER)
+
+	intKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate intermediate key")
+	intPubDER, err := x509.MarshalPKIXPublicKey(intKey.Public())
+	test.AssertNotError(t, err, "failed to marshal intermediate public key")
+	intPubPath := path.Join(dir, "int.pubkey.pem")
+	writePEMFile(t, intPubPath, "PUBLIC KEY", intPubDER)
+	intCertPath := path.Join(dir, "int.cert.pem")
+
+	config := fmt.Sprintf(`ceremony-type: intermediate
+inputs:
+    public-key-path: %s
+    issuer-certificate-path: %s
+    trust-anchor-certificate-path: %s
+outputs:
+    certificate-path: %s
+certificate-profile:
+    signature-algorithm: ECDSAWithSHA384
+    common-name: intermediate
+    organization: organization
+    country: US
+    not-before: 2020-01-01 00:00:00
+    not-after: 2027-01-01 00:00:00
+    crl-url: http://crl.example.org/crl
+    issuer-url: http://issuer.example.org/root
+    policies:
+        - oid: 2.23.140.1.2.1
+    key-usages:
+        - Digital Signature
+        - Cert Sign
+        - CRL Sign
+`, intPubPath, rootPath, rootPath, intCertPath)
+
+	err = intermediateCeremony([]byte(config), intermediateCert, false, rootKeyPath, "")
+	test.AssertNotError(t, err, "intermediate ceremony with a software key failed")
+
+	intCert, err := loadCert(intCertPath)
+	test.AssertNotError(t, err, "failed to load intermediate certificate")
+	root, err := x509.ParseCertificate(rootDER)
+	test.AssertNotError(t, err, "failed to parse root certificate")
+	test.AssertNotError(t, intCert.CheckSignatureFrom(root), "intermediate isn't signed by the root")
+	test.Assert(t, intKey.PublicKey.Equal(intCert.PublicKey), "intermediate certifies the wrong key")
+
+	// A config containing a pkcs11 block is rejected.
+	withPKCS11 := "pkcs11:\n    module: module\n    signing-key-label: label\n" + config
+	err = intermediateCeremony([]byte(withPKCS11), intermediateCert, false, rootKeyPath, "")
+	test.AssertError(t, err, "intermediate ceremony didn't fail")
+	test.AssertEquals(t, err.Error(), "failed to validate config: pkcs11 cannot be set when --software-key is used")
+}
+
+func TestIntermediateCeremonyFromCSR(t *testing.T) {
+	dir := t.TempDir()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate root key")
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root", Organization: []string{"organization"}, Country: []string{"US"}},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootKey.Public(), rootKey)
+	test.AssertNotError(t, err, "failed to create root certificate")
+	rootPath := path.Join(dir, "root.cert.pem")
+	writePEMFile(t, rootPath, "CERTIFICATE", rootDER)
+	rootKeyDER, err := x509.MarshalPKCS8PrivateKey(rootKey)
+	test.AssertNotError(t, err, "failed to marshal root key")
+	rootKeyPath := path.Join(dir, "root.key.pem")
+	writePEMFile(t, rootKeyPath, "PRIVATE KEY", rootKeyDER)
+
+	intKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate intermediate key")
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "intermediate", Organization: []string{"organization"}, Country: []string{"US"}},
+		DNSNames: []string{"intermediate.example.org"},
+	}, intKey)
+	test.AssertNotError(t, err, "failed to create CSR")
+	csrPath := path.Join(dir, "int.csr.pem")
+	writePEMFile(t, csrPath, "CERTIFICATE REQUEST", csrDER)
+	intCertPath := path.Join(dir, "int.cert.pem")
+
+	// The subject and public key are omitted, since they come from the CSR.
+	config := fmt.Sprintf(`ceremony-type: intermediate
+inputs:
+    issuer-certificate-path: %s
+outputs:
+    certificate-path: %s
+certificate-profile:
+    signature-algorithm: ECDSAWithSHA384
+    not-before: 2020-01-01 00:00:00
+    not-after: 2027-01-01 00:00:00
+    crl-url: http://crl.example.org/crl
+    issuer-url: http://issuer.example.org/root
+    policies:
+        - oid: 2.23.140.1.2.1
+    key-usages:
+        - Digital Signature
+        - Cert Sign
+        - CRL Sign
+`, rootPath, intCertPath)
+
+	err = intermediateCeremony([]byte(config), intermediateCert, false, rootKeyPath, csrPath)
+	test.AssertNotError(t, err, "intermediate ceremony from a CSR failed")
+
+	intCert, err := loadCert(intCertPath)
+	test.AssertNotError(t, err, "failed to load intermediate certificate")
+	test.AssertEquals(t, intCert.Subject.String(), "CN=intermediate,O=organization,C=US")
+	test.AssertDeepEquals(t, intCert.DNSNames, []string{"intermediate.example.org"})
+	test.Assert(t, intKey.PublicKey.Equal(intCert.PublicKey), "intermediate certifies the wrong key")
+
+	// A public key in the config is rejected, since it would conflict with
+	// the CSR's.
+	intPubDER, err := x509.MarshalPKIXPublicKey(intKey.Public())
+	test.AssertNotError(t, err, "failed to marshal intermediate public key")
+	intPubPath := path.Join(dir, "int.pubkey.pem")
+	writePEMFile(t, intPubPath, "PUBLIC KEY", intPubDER)
+	withPubKey := strings.Replace(config, "inputs:\n", "inputs:\n    public-key-path: "+intPubPath+"\n", 1)
+	withPubKey = strings.Replace(withPubKey, intCertPath, path.Join(dir, "int2.cert.pem"), 1)
+	err = intermediateCeremony([]byte(withPubKey), intermediateCert, false, rootKeyPath, csrPath)
+	test.AssertError(t, err, "intermediate ceremony didn't fail")
+	test.AssertEquals(t, err.Error(), "failed to validate config: inputs.public-key-path and inputs.public-key-label cannot be set when --from-csr is used")
+
+	// As is a CSR with an invalid signature.
+	csrDER[len(csrDER)-1] ^= 1
+	badCSRPath := path.Join(dir, "bad.csr.pem")
+	writePEMFile(t, badCSRPath, "CERTIFICATE REQUEST", csrDER)
+	err = intermediateCeremony([]byte(config), intermediateCert, false, rootKeyPath, badCSRPath)
+	test.AssertError(t, err, "intermediate ceremony didn't fail")
+	test.AssertContains(t, err.Error(), "CSR signature is invalid")
+}
+
+func TestIntermediateCeremonyNameConstraints(t *testing.T) {
+	dir := t.TempDir()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate root key")
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root", Organization: []string{"organization"}, Country: []string{"US"}},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootKey.Public(), rootKey)
+	test.AssertNotError(t, err, "failed to create root certificate")
+	rootPath := path.Join(dir, "root.cert.pem")
+	writePEMFile(t, rootPath, "CERTIFICATE", rootDER)
+	rootKeyDER, err := x509.MarshalPKCS8PrivateKey(rootKey)
+	test.AssertNotError(t, err, "failed to marshal root key")
+	rootKeyPath := path.Join(dir, "root.key.pem")
+	writePEMFile(t, rootKeyPath, "PRIVATE KEY", rootKeyDER)
+
+	intKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate intermediate key")
+	intPubDER, err := x509.MarshalPKIXPublicKey(intKey.Public())
+	test.AssertNotError(t, err, "failed to marshal intermediate public key")
+	intPubPath := path.Join(dir, "int.pubkey.pem")
+	writePEMFile(t, intPubPath, "PUBLIC KEY", intPubDER)
+	intCertPath := path.Join(dir, "int.cert.pem")
+
+	config := fmt.Sprintf(`ceremony-type: intermediate
+inputs:
+    public-key-path: %s
+    issuer-certificate-path: %s
+outputs:
+    certificate-path: %s
+certificate-profile:
+    signature-algorithm: ECDSAWithSHA384
+    common-name: intermediate
+    organization: organization
+    country: US
+    not-before: 2020-01-01 00:00:00
+    not-after: 2027-01-01 00:00:00
+    crl-url: http://crl.example.org/crl
+    issuer-url: http://issuer.example.org/root
+    policies:
+        - oid: 2.23.140.1.2.1
+    permitted-dns-domains:
+        - example.org
+    excluded-dns-domains:
+        - internal.example.org
+    permitted-ip-ranges:
+        - 8.8.8.0/24
+    excluded-ip-ranges:
+        - 8.8.8.128/25
+        - ::/0
+`, intPubPath, rootPath, intCertPath)
+	err = intermediateCeremony([]byte(config), intermediateCert, false, rootKeyPath, "")
+	test.AssertNotError(t, err, "intermediate ceremony with name constraints failed")
+
+	intCert, err := loadCert(intCertPath)
+	test.AssertNotError(t, err, "failed to load intermediate certificate")
+	var found bool
+	for _, ext := range intCert.Extensions {
+		if ext.Id.Equal(asn1.ObjectIdentifier{2, 5, 29, 30}) {
+			found = true
+			test.Assert(t, ext.Critical, "name constraints extension isn't critical")
+		}
+	}
+	test.Assert(t, found, "certificate doesn't contain a name constraints extension")
+	test.Assert(t, intCert.PermittedDNSDomainsCritical, "name constraints aren't critical")
+	test.AssertDeepEquals(t, intCert.PermittedDNSDomains, []string{"example.org"})
+	test.AssertDeepEquals(t, intCert.ExcludedDNSDomains, []string{"internal.example.org"})
+	test.AssertEquals(t, len(intCert.PermittedIPRanges), 1)
+	test.AssertEquals(t, intCert.PermittedIPRanges[0].String(), "8.8.8.0/24")
+	test.AssertEquals(t, len(intCert.ExcludedIPRanges), 2)
+	test.AssertEquals(t, intCert.ExcludedIPRanges[0].String(), "8.8.8.128/25")
+	test.AssertEquals(t, intCert.ExcludedIPRanges[1].String(), "::/0")
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/letsencrypt/boulder/linter"
+)
+
+// certificateASN1 and tbsCertificateASN1 mirror the RFC 5280 Section 4.1
+// Certificate and TBSCertificate structures. They are used to alter parts of a
+// certificate which x509.CreateCertificate doesn't allow callers to control,
+// before re-signing it.
+type certificateASN1 struct {
+	TBSCertificate     asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+type tbsCertificateASN1 struct {
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Issuer             asn1.RawValue
+	Validity           asn1.RawValue
+	Subject            asn1.RawValue
+	PublicKey          asn1.RawValue
+	IssuerUniqueID     asn1.BitString   `asn1:"optional,tag:1"`
+	SubjectUniqueID    asn1.BitString   `asn1:"optional,tag:2"`
+	Extensions         []pkix.Extension `asn1:"omitempty,optional,explicit,tag:3"`
+}
+
+// sigAlgHashes maps the signature algorithms in AllowedSigAlgs to the hash
+// function used to compute the digest which is signed.
+var sigAlgHashes = map[x509.SignatureAlgorithm]crypto.Hash{
+	x509.SHA256WithRSA:   crypto.SHA256,
+	x509.SHA384WithRSA:   crypto.SHA384,
+	x509.SHA512WithRSA:   crypto.SHA512,
+	x509.ECDSAWithSHA256: crypto.SHA256,
+	x509.ECDSAWithSHA384: crypto.SHA384,
+	x509.ECDSAWithSHA512: crypto.SHA512,
+}
+
+// parseTBSCertificate returns the decoded TBSCertificate and outer signature
+// algorithm of a DER encoded certificate.
+func parseTBSCertificate(certDER []byte) (*tbsCertificateASN1, pkix.AlgorithmIdentifier, error) {
+	var cert certificateASN1
+	rest, err := asn1.Unmarshal(certDER, &cert)
+	if err != nil {
+		return nil, pkix.AlgorithmIdentifier{}, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, pkix.AlgorithmIdentifier{}, errors.New("trailing data after certificate")
+	}
+	var tbs tbsCertificateASN1
+	rest, err = asn1.Unmarshal(cert.TBSCertificate.FullBytes, &tbs)
+	if err != nil {
+		return nil, pkix.AlgorithmIdentifier{}, fmt.Errorf("failed to parse tbsCertificate: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, pkix.AlgorithmIdentifier{}, errors.New("trailing data after tbsCertificate")
+	}
+	return &tbs, cert.SignatureAlgorithm, nil
+}
+
+// signTBSCertificate encodes tbs, signs it with signer using sigAlg and
+// returns the resulting DER encoded certificate. A PKCS#11 signer generates
+// any randomness it needs at the HSM, so for one rand should be a failReader.
+func signTBSCertificate(rand io.Reader, tbs *tbsCertificateASN1, sigAlg x509.SignatureAlgorithm, sigAlgID pkix.AlgorithmIdentifier, signer crypto.Signer) ([]byte, error) {
+	hashFunc, ok := sigAlgHashes[sigAlg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signature algorithm %q", sigAlg)
+	}
+	tbsDER, err := asn1.Marshal(*tbs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tbsCertificate: %w", err)
+	}
+	h := hashFunc.New()
+	h.Write(tbsDER)
+	signature, err := signer.Sign(rand, h.Sum(nil), hashFunc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign tbsCertificate: %w", err)
+	}
+	return asn1.Marshal(certificateASN1{
+		TBSCertificate:     asn1.RawValue{FullBytes: tbsDER},
+		SignatureAlgorithm: sigAlgID,
+		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	})
+}
+
+// removeExtension removes the extension identified by oid from tbs. It returns
+// an error if tbs doesn't contain the extension.
+func (tbs *tbsCertificateASN1) removeExtension(oid asn1.ObjectIdentifier) error {
+	var exts []pkix.Extension
+	for _, ext := range tbs.Extensions {
+		if !ext.Id.Equal(oid) {
+			exts = append(exts, ext)
+		}
+	}
+	if len(exts) == len(tbs.Extensions) {
+		return fmt.Errorf("certificate does not contain extension %s", oid)
+	}
+	tbs.Extensions = exts
+	return nil
+}
+
+// setUniqueIDs sets the issuerUniqueID and subjectUniqueID fields of tbs.
+// Either ID may be empty, in which case that field is omitted.
+func (tbs *tbsCertificateASN1) setUniqueIDs(uniqueIDs certUniqueIDs) {
+	// Fields are only omitted by asn1.Marshal if they're the zero value, so an
+	// empty ID must be left as one.
+	if len(uniqueIDs.issuer) != 0 {
+		tbs.IssuerUniqueID = asn1.BitString{Bytes: uniqueIDs.issuer, BitLength: len(uniqueIDs.issuer) * 8}
+	}
+	if len(uniqueIDs.subject) != 0 {
+		tbs.SubjectUniqueID = asn1.BitString{Bytes: uniqueIDs.subject, BitLength: len(uniqueIDs.subject) * 8}
+	}
+}
+
+// certModifier returns a linter.Modifier which makes the changes to a
+// certificate created from tmpl by x509.CreateCertificate which it can't make
+// itself, or nil if there are none. x509.CreateCertificate always generates a
+// subject key identifier for CA certificates if the template doesn't contain
+// one, so when the profile requested that it be omitted it is removed. It also
+// can't produce the nonstandard unique ID fields, so they're set afterwards.
+func certModifier(tmpl *x509.Certificate, uniqueIDs certUniqueIDs) linter.Modifier {
+	omitSKI := tmpl.IsCA && len(tmpl.SubjectKeyId) == 0
+	if !omitSKI && !uniqueIDs.present() {
+		return nil
+	}
+	return func(certDER []byte, signer crypto.Signer) ([]byte, error) {
+		tbs, sigAlgID, err := parseTBSCertificate(certDER)
+		if err != nil {
+			return nil, err
+		}
+		if omitSKI {
+			err = tbs.removeExtension(oidSubjectKeyIdentifier)
+			if err != nil {
+				return nil, fmt.Errorf("failed to omit subject key identifier: %w", err)
+			}
+		}
+		tbs.setUniqueIDs(uniqueIDs)
+		// The linting certificate is signed by a software key, which needs a
+		// source of randomness.
+		return signTBSCertificate(rand.Reader, tbs, tmpl.SignatureAlgorithm, sigAlgID, signer)
+	}
+}
+
+// signLintedTBSCertificate signs the TBSCertificate of a DER encoded linting
+// certificate with signer, so that the issued certificate is exactly the one
+// which was linted. The linting certificate of a self-signed certificate
+// contains the linter's throwaway public key, so the subject public key is
+// replaced with subjectSPKI.
+func signLintedTBSCertificate(lintCertDER, subjectSPKI []byte, sigAlg x509.SignatureAlgorithm, signer crypto.Signer) ([]byte, error) {
+	tbs, sigAlgID, err := parseTBSCertificate(lintCertDER)
+	if err != nil {
+		return nil, err
+	}
+	tbs.PublicKey = asn1.RawValue{FullBytes: subjectSPKI}
+	return signTBSCertificate(&failReader{}, tbs, sigAlg, sigAlgID, signer)
+}
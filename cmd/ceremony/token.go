@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/miekg/pkcs11"
+)
+
+// initTokenConfig contains the fields required to initialize a blank token
+// and set its user PIN before a key is generated on it.
+type initTokenConfig struct {
+	// SOPINEnvVar is the name of the environment variable which contains the
+	// security officer PIN. The SO PIN is never read from the config file.
+	SOPINEnvVar string `yaml:"so-pin-env-var"`
+	// TokenLabel is the label which the token is initialized with.
+	TokenLabel string `yaml:"token-label"`
+
+	// soPIN is set from --so-pin-fd rather than the config. If set it is used
+	// instead of SOPINEnvVar, which must then be empty.
+	soPIN string
+}
+
+func (itc initTokenConfig) validate() error {
+	if itc.soPIN != "" {
+		if itc.SOPINEnvVar != "" {
+			return errors.New("pkcs11.init-token.so-pin-env-var cannot be set when --so-pin-fd is used")
+		}
+	} else {
+		if itc.SOPINEnvVar == "" {
+			return errors.New("pkcs11.init-token.so-pin-env-var is required")
+		}
+		if os.Getenv(itc.SOPINEnvVar) == "" {
+			return fmt.Errorf("pkcs11.init-token.so-pin-env-var is %q, which is not set", itc.SOPINEnvVar)
+		}
+	}
+	if itc.TokenLabel == "" {
+		return errors.New("pkcs11.init-token.token-label is required")
+	}
+	// PKCS#11 token labels are a fixed 32 byte, space padded, field.
+	if len(itc.TokenLabel) > 32 {
+		return fmt.Errorf("pkcs11.init-token.token-label is %d bytes, which is longer than 32 bytes", len(itc.TokenLabel))
+	}
+	return nil
+}
+
+// getSOPIN returns the security officer PIN, read either from --so-pin-fd or
+// from the environment variable named by SOPINEnvVar.
+func (itc initTokenConfig) getSOPIN() string {
+	if itc.soPIN != "" {
+		return itc.soPIN
+	}
+	return os.Getenv(itc.SOPINEnvVar)
+}
+
+// initToken initializes the token in slot with the configured label and sets
+// its user PIN to userPIN. If the token is already initialized and contains
+// any objects it refuses to re-initialize it, unless force is true.
+func initToken(module string, slot uint, userPIN string, config initTokenConfig, force bool) error {
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		return hsmErrorf("failed to load module")
+	}
+	err := ctx.Initialize()
+	if err != nil {
+		return fmt.Errorf("couldn't initialize context: %w", err)
+	}
+	// The context is finalized so that pkcs11helpers.Initialize can initialize
+	// the module again once the token is ready.
+	defer func() {
+		_ = ctx.Finalize()
+		ctx.Destroy()
+	}()
+
+	info, err := ctx.GetTokenInfo(slot)
+	if err != nil {
+		return fmt.Errorf("couldn't get token info for slot %d: %w", slot, err)
+	}
+	if info.Flags&pkcs11.CKF_TOKEN_INITIALIZED != 0 && !force {
+		hasObjects, err := tokenHasObjects(ctx, slot, userPIN, info.Flags&pkcs11.CKF_USER_PIN_INITIALIZED != 0)
+		if err != nil {
+			return err
+		}
+		if hasObjects {
+			return fmt.Errorf("token %q in slot %d already contains objects, refusing to re-initialize it without --force-init", info.Label, slot)
+		}
+	}
+
+	err = ctx.InitToken(slot, config.getSOPIN(), config.TokenLabel)
+	if err != nil {
+		return fmt.Errorf("couldn't initialize token in slot %d: %w", slot, err)
+	}
+	log.Printf("Initialized token in slot %d with label %q\n", slot, config.TokenLabel)
+
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return fmt.Errorf("couldn't open session: %w", err)
+	}
+	defer func() { _ = ctx.CloseSession(session) }()
+	err = ctx.Login(session, pkcs11.CKU_SO, config.getSOPIN())
+	if err != nil {
+		return fmt.Errorf("couldn't login as security officer: %w", err)
+	}
+	err = ctx.InitPIN(session, userPIN)
+	if err != nil {
+		return fmt.Errorf("couldn't set user PIN: %w", err)
+	}
+	err = ctx.Logout(session)
+	if err != nil {
+		return fmt.Errorf("couldn't logout: %w", err)
+	}
+	log.Printf("Set user PIN for token in slot %d\n", slot)
+
+	return nil
+}
+
+// tokenHasObjects returns true if the token in slot contains any objects. If
+// the user PIN has been initialized the session is logged in with userPIN so
+// that private objects are also found.
+func tokenHasObjects(ctx *pkcs11.Ctx, slot uint, userPIN string, userPINInitialized bool) (bool, error) {
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return false, fmt.Errorf("couldn't open session: %w", err)
+	}
+	defer func() { _ = ctx.CloseSession(session) }()
+	if userPINInitialized {
+		err = ctx.Login(session, pkcs11.CKU_USER, userPIN)
+		if err != nil {
+			return false, fmt.Errorf("couldn't login to check token in slot %d for objects: %w", slot, err)
+		}
+		defer func() { _ = ctx.Logout(session) }()
+	}
+
+	err = ctx.FindObjectsInit(session, nil)
+	if err != nil {
+		return false, fmt.Errorf("couldn't search token for objects: %w", err)
+	}
+	handles, _, err := ctx.FindObjects(session, 1)
+	if err != nil {
+		return false, fmt.Errorf("couldn't search token for objects: %w", err)
+	}
+	err = ctx.FindObjectsFinal(session)
+	if err != nil {
+		return false, fmt.Errorf("couldn't search token for objects: %w", err)
+	}
+	return len(handles) > 0, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInitTokenConfigValidate(t *testing.T) {
+	t.Setenv("CEREMONY_TEST_SO_PIN", "1234")
+
+	cases := []struct {
+		name          string
+		config        PKCS11KeyGenConfig
+		expectedError string
+	}{
+		{
+			name: "no pkcs11.pin",
+			config: PKCS11KeyGenConfig{
+				Module:     "module",
+				StoreLabel: "label",
+				UserType:   "so",
+				InitToken: &initTokenConfig{
+					SOPINEnvVar: "CEREMONY_TEST_SO_PIN",
+					TokenLabel:  "token",
+				},
+			},
+			expectedError: "pkcs11.pin is required when pkcs11.init-token is set",
+		},
+		{
+			name: "pkcs11.init-token without pkcs11.user-type so",
+			config: PKCS11KeyGenConfig{
+				Module:     "module",
+				PIN:        "5678",
+				StoreLabel: "label",
+				UserType:   "user",
+				InitToken: &initTokenConfig{
+					SOPINEnvVar: "CEREMONY_TEST_SO_PIN",
+					TokenLabel:  "token",
+				},
+			},
+			expectedError: "pkcs11.user-type must be \"so\" when pkcs11.init-token is set",
+		},
+		{
+			name: "no pkcs11.init-token.so-pin-env-var",
+			config: PKCS11KeyGenConfig{
+				Module:     "module",
+				PIN:        "5678",
+				StoreLabel: "label",
+				UserType:   "so",
+				InitToken: &initTokenConfig{
+					TokenLabel: "token",
+				},
+			},
+			expectedError: "pkcs11.init-token.so-pin-env-var is required",
+		},
+		{
+			name: "pkcs11.init-token.so-pin-env-var not set",
+			config: PKCS11KeyGenConfig{
+				Module:     "module",
+				PIN:        "5678",
+				StoreLabel: "label",
+				UserType:   "so",
+				InitToken: &initTokenConfig{
+					SOPINEnvVar: "CEREMONY_TEST_UNSET_SO_PIN",
+					TokenLabel:  "token",
+				},
+			},
+			expectedError: "pkcs11.init-token.so-pin-env-var is \"CEREMONY_TEST_UNSET_SO_PIN\", which is not set",
+		},
+		{
+			name: "no pkcs11.init-token.token-label",
+			config: PKCS11KeyGenConfig{
+				Module:     "module",
+				PIN:        "5678",
+				StoreLabel: "label",
+				UserType:   "so",
+				InitToken: &initTokenConfig{
+					SOPINEnvVar: "CEREMONY_TEST_SO_PIN",
+				},
+			},
+			expectedError: "pkcs11.init-token.token-label is required",
+		},
+		{
+			name: "pkcs11.init-token.token-label too long",
+			config: PKCS11KeyGenConfig{
+				Module:     "module",
+				PIN:        "5678",
+				StoreLabel: "label",
+				UserType:   "so",
+				InitToken: &initTokenConfig{
+					SOPINEnvVar: "CEREMONY_TEST_SO_PIN",
+					TokenLabel:  strings.Repeat("a", 33),
+				},
+			},
+			expectedError: "pkcs11.init-token.token-label is 33 bytes, which is longer than 32 bytes",
+		},
+		{
+			name: "good config",
+			config: PKCS11KeyGenConfig{
+				Module:     "module",
+				PIN:        "5678",
+				StoreLabel: "label",
+				UserType:   "so",
+				InitToken: &initTokenConfig{
+					SOPINEnvVar: "CEREMONY_TEST_SO_PIN",
+					TokenLabel:  strings.Repeat("a", 32),
+				},
+			},
+		},
+		{
+			name: "good config without init-token",
+			config: PKCS11KeyGenConfig{
+				Module:     "module",
+				StoreLabel: "label",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.validate()
+			if err != nil && err.Error() != tc.expectedError {
+				t.Fatalf("Unexpected error, wanted: %q, got: %q", tc.expectedError, err)
+			} else if err == nil && tc.expectedError != "" {
+				t.Fatalf("validate didn't fail, wanted: %q", tc.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/boulder/strictyaml"
+)
+
+// verifyCertificate compares cert, which was issued by a ceremony, with the
+// certificate profile it was issued from and checks that it is signed by
+// issuer. It returns one line describing each mismatch, or nil if there are
+// none. Subject fields which the profile leaves unset, because they were taken
+// from a CSR, aren't compared.
+func verifyCertificate(cert, issuer *x509.Certificate, profile *certProfile) []string {
+	var diffs []string
+	// compare records a mismatch between the formatted values want and got.
+	compare := func(field, want, got string) {
+		if want != got {
+			diffs = append(diffs, fmt.Sprintf("%s: config has %s, certificate has %s", field, want, got))
+		}
+	}
+	// optional returns the single element list which makeTemplate creates
+	// from an optional profile field, or an empty list if it is unset.
+	optional := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return []string{s}
+	}
+
+	if profile.CommonName != "" {
+		compare("subject commonName", fmt.Sprintf("%q", profile.CommonName), fmt.Sprintf("%q", cert.Subject.CommonName))
+	}
+	if len(profile.organizations()) != 0 {
+		compare("subject organization", fmt.Sprintf("%q", profile.organizations()), fmt.Sprintf("%q", cert.Subject.Organization))
+	}
+	compare("subject organizationalUnit", fmt.Sprintf("%q", profile.OrganizationalUnits), fmt.Sprintf("%q", cert.Subject.OrganizationalUnit))
+	if profile.Country != "" {
+		compare("subject country", fmt.Sprintf("%q", []string{profile.Country}), fmt.Sprintf("%q", cert.Subject.Country))
+	}
+
+	notBefore, err := time.Parse(time.DateTime, profile.NotBefore)
+	if err != nil {
+		diffs = append(diffs, fmt.Sprintf("notBefore: failed to parse not-before: %s", err))
+	} else {
+		compare("notBefore", notBefore.UTC().Format(time.DateTime), cert.NotBefore.UTC().Format(time.DateTime))
+	}
+	notAfter, err := parseNotAfter(profile.NotAfter)
+	if err != nil {
+		diffs = append(diffs, fmt.Sprintf("notAfter: failed to parse not-after: %s", err))
+	} else {
+		compare("notAfter", notAfter.UTC().Format(time.DateTime), cert.NotAfter.UTC().Format(time.DateTime))
+	}
+
+	var wantPolicies, gotPolicies []string
+	for _, policy := range profile.Policies {
+		wantPolicies = append(wantPolicies, policy.OID)
+	}
+	for _, oid := range cert.PolicyIdentifiers {
+		gotPolicies = append(gotPolicies, oid.String())
+	}
+	compare("certificate policies", fmt.Sprintf("%q", wantPolicies), fmt.Sprintf("%q", gotPolicies))
+
+	compare("OCSP URLs", fmt.Sprintf("%q", optional(profile.OCSPURL)), fmt.Sprintf("%q", cert.OCSPServer))
+	compare("CA issuers URLs", fmt.Sprintf("%q", optional(profile.IssuerURL)), fmt.Sprintf("%q", cert.IssuingCertificateURL))
+	compare("CRL distribution points", fmt.Sprintf("%q", optional(profile.CRLURL)), fmt.Sprintf("%q", cert.CRLDistributionPoints))
+
+	sigAlg, ok := AllowedSigAlgs[profile.SignatureAlgorithm]
+	if !ok {
+		diffs = append(diffs, fmt.Sprintf("signature algorithm: unsupported signature-algorithm %q", profile.SignatureAlgorithm))
+	} else {
+		compare("signature algorithm", sigAlg.String(), cert.SignatureAlgorithm.String())
+	}
+
+	if !bytes.Equal(cert.RawIssuer, issuer.RawSubject) {
+		diffs = append(diffs, fmt.Sprintf("issuer: issuer certificate has subject %q, certificate has issuer %q", issuer.Subject, cert.Issuer))
+	}
+	err = cert.CheckSignatureFrom(issuer)
+	if err != nil {
+		diffs = append(diffs, fmt.Sprintf("signature: not signed by the issuer certificate: %s", err))
+	}
+	return diffs
+}
+
+// verifyCeremony checks the certificate written by the ceremony configured by
+// configBytes against the config, as described by verifyCertificate, and writes
+// a summary to w. It returns an error listing every mismatch if there are any.
+// The config is parsed but not validated, since validation requires that the
+// ceremony's outputs don't exist yet.
+func verifyCeremony(w io.Writer, configBytes []byte, ceremonyType string) error {
+	var certPath, issuerPath string
+	var profile *certProfile
+	switch ceremonyType {
+	case "root":
+		var c rootConfig
+		err := strictyaml.Unmarshal(configBytes, &c)
+		if err != nil {
+			return configErrorf("failed to parse config: %w", err)
+		}
+		certPath, issuerPath, profile = c.Outputs.CertificatePath, c.Outputs.CertificatePath, &c.CertProfile
+	case "key-and-root":
+		var c keyAndRootConfig
+		err := strictyaml.Unmarshal(configBytes, &c)
+		if err != nil {
+			return configErrorf("failed to parse config: %w", err)
+		}
+		certPath, issuerPath, profile = c.Outputs.CertificatePath, c.Outputs.CertificatePath, &c.CertProfile
+	case "intermediate", "ocsp-signer", "crl-signer":
+		var c intermediateConfig
+		err := strictyaml.Unmarshal(configBytes, &c)
+		if err != nil {
+			return configErrorf("failed to parse config: %w", err)
+		}
+		certPath, issuerPath, profile = c.Outputs.CertificatePath, c.Inputs.IssuerCertificatePath, &c.CertProfile
+	case "cross-certificate":
+		var c crossCertConfig
+		err := strictyaml.Unmarshal(configBytes, &c)
+		if err != nil {
+			return configErrorf("failed to parse config: %w", err)
+		}
+		certPath, issuerPath, profile = c.Outputs.CertificatePath, c.Inputs.IssuerCertificatePath, &c.CertProfile
+	default:
+		return fmt.Errorf("--verify is not supported by the %s ceremony", ceremonyType)
+	}
+	if certPath == "" {
+		return errors.New("outputs.certificate-path is required")
+	}
+	if issuerPath == "" {
+		return errors.New("inputs.issuer-certificate-path is required")
+	}
+
+	cert, err := loadCert(certPath)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate %q: %w", certPath, err)
+	}
+	issuer, err := loadCert(issuerPath)
+	if err != nil {
+		return fmt.Errorf("failed to load issuer certificate %q: %w", issuerPath, err)
+	}
+	diffs := verifyCertificate(cert, issuer, profile)
+	if len(diffs) != 0 {
+		return fmt.Errorf("certificate %q doesn't match config:\n  %s", certPath, strings.Join(diffs, "\n  "))
+	}
+	fmt.Fprintf(w, "Certificate %q matches the %s ceremony config and is signed by %q.\n", certPath, ceremonyType, issuerPath)
+	return nil
+}
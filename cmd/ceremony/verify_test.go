@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestVerifyCeremony(t *testing.T) {
+	dir := t.TempDir()
+
+	rootKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate root key")
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root", Organization: []string{"organization"}, Country: []string{"US"}},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootKey.Public(), rootKey)
+	test.AssertNotError(t, err, "failed to create root certificate")
+	rootPath := path.Join(dir, "root.cert.pem")
+	writePEMFile(t, rootPath, "CERTIFICATE", rootDER)
+	rootKeyDER, err := x509.MarshalPKCS8PrivateKey(rootKey)
+	test.AssertNotError(t, err, "failed to marshal root key")
+	rootKeyPath := path.Join(dir, "root.key.pem")
+	writePEMFile(t, rootKeyPath, "PRIVATE KEY", rootKeyDER)
+
+	intKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate intermediate key")
+	intPubDER, err := x509.MarshalPKIXPublicKey(intKey.Public())
+	test.AssertNotError(t, err, "failed to marshal intermediate public key")
+	intPubPath := path.Join(dir, "int.pubkey.pem")
+	writePEMFile(t, intPubPath, "PUBLIC KEY", intPubDER)
+	intCertPath := path.Join(dir, "int.cert.pem")
+
+	config := fmt.Sprintf(`ceremony-type: intermediate
+inputs:
+    public-key-path: %s
+    issuer-certificate-path: %s
+outputs:
+    certificate-path: %s
+certificate-profile:
+    signature-algorithm: ECDSAWithSHA384
+    common-name: intermediate
+    organization: organization
+    country: US
+    not-before: 2020-01-01 00:00:00
+    not-after: 2027-01-01 00:00:00
+    crl-url: http://crl.example.org/crl
+    issuer-url: http://issuer.example.org/root
+    policies:
+        - oid: 2.23.140.1.2.1
+    key-usages:
+        - Digital Signature
+        - Cert Sign
+        - CRL Sign
+`, intPubPath, rootPath, intCertPath)
+	err = intermediateCeremony([]byte(config), intermediateCert, false, rootKeyPath, "")
+	test.AssertNotError(t, err, "intermediate ceremony failed")
+
+	var summary bytes.Buffer
+	err = verifyCeremony(&summary, []byte(config), "intermediate")
+	test.AssertNotError(t, err, "verify failed for the certificate issued from the config")
+	test.AssertContains(t, summary.String(), "matches the intermediate ceremony config")
+
+	// Every field which differs from the certificate is reported.
+	changed := strings.NewReplacer(
+		"common-name: intermediate", "common-name: other",
+		"not-after: 2027-01-01 00:00:00", "not-after: 2026-01-01 00:00:00",
+		"oid: 2.23.140.1.2.1", "oid: 2.23.140.1.2.2",
+		"crl-url: http://crl.example.org/crl", "crl-url: http://crl.example.org/other",
+		"signature-algorithm: ECDSAWithSHA384", "signature-algorithm: ECDSAWithSHA256",
+	).Replace(config)
+	err = verifyCeremony(&summary, []byte(changed), "intermediate")
+	test.AssertError(t, err, "verify didn't fail for a changed config")
+	test.AssertEquals(t, err.Error(), fmt.Sprintf(`certificate %q doesn't match config:
+  subject commonName: config has "other", certificate has "intermediate"
+  notAfter: config has 2026-01-01 00:00:00, certificate has 2027-01-01 00:00:00
+  certificate policies: config has ["2.23.140.1.2.2"], certificate has ["2.23.140.1.2.1"]
+  CRL distribution points: config has ["http://crl.example.org/other"], certificate has ["http://crl.example.org/crl"]
+  signature algorithm: config has ECDSA-SHA256, certificate has ECDSA-SHA384`, intCertPath))
+
+	// A certificate which isn't signed by the configured issuer is reported.
+	otherKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate other root key")
+	otherDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, otherKey.Public(), otherKey)
+	test.AssertNotError(t, err, "failed to create other root certificate")
+	otherPath := path.Join(dir, "other.cert.pem")
+	writePEMFile(t, otherPath, "CERTIFICATE", otherDER)
+	err = verifyCeremony(&summary, []byte(strings.Replace(config, rootPath, otherPath, 1)), "intermediate")
+	test.AssertError(t, err, "verify didn't fail for the wrong issuer")
+	test.AssertContains(t, err.Error(), "signature: not signed by the issuer certificate")
+
+	err = verifyCeremony(&summary, []byte(config), "crl")
+	test.AssertError(t, err, "verify didn't fail for a crl ceremony")
+	test.AssertEquals(t, err.Error(), "--verify is not supported by the crl ceremony")
+}
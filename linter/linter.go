@@ -7,6 +7,8 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
 
 	zlintx509 "github.com/zmap/zcrypto/x509"
@@ -45,6 +47,33 @@ func Check(tbs *x509.Certificate, subjectPubKey crypto.PublicKey, realIssuer *x5
 	return lintCertBytes, nil
 }
 
+// CheckWithReport is like Check, but it also returns a LintReportEntry for
+// every lint which was considered, including those which were skipped. The
+// report is returned even if linting fails, so that it can be used to
+// understand the failure.
+func CheckWithReport(tbs *x509.Certificate, subjectPubKey crypto.PublicKey, realIssuer *x509.Certificate, realSigner crypto.Signer, skipLints []string) ([]byte, []LintReportEntry, error) {
+	linter, err := New(realIssuer, realSigner, skipLints)
+	if err != nil {
+		return nil, nil, err
+	}
+	return linter.CheckWithReport(tbs, subjectPubKey)
+}
+
+// CheckModifiedWithReport is like CheckWithReport, but the linting certificate
+// is passed to modify before it is linted. This allows fields which
+// x509.CreateCertificate can't produce to be linted, as long as modify makes
+// the same change to the linting certificate as is made to the real one. Lints
+// which are disabled by default are also run if they're named in enableLints,
+// and lints from excludeSources, rather than from DefaultExcludeSources, aren't
+// run.
+func CheckModifiedWithReport(tbs *x509.Certificate, subjectPubKey crypto.PublicKey, realIssuer *x509.Certificate, realSigner crypto.Signer, skipLints, enableLints []string, excludeSources []lint.LintSource, modify Modifier) ([]byte, []LintReportEntry, error) {
+	linter, err := newLinter(realIssuer, realSigner, skipLints, enableLints, excludeSources)
+	if err != nil {
+		return nil, nil, err
+	}
+	return linter.CheckModifiedWithReport(tbs, subjectPubKey, modify)
+}
+
 // CheckCRL is like Check, but for CRLs.
 func CheckCRL(tbs *x509.RevocationList, realIssuer *x509.Certificate, realSigner crypto.Signer, skipLints []string) error {
 	linter, err := New(realIssuer, realSigner, skipLints)
@@ -54,6 +83,26 @@ func CheckCRL(tbs *x509.RevocationList, realIssuer *x509.Certificate, realSigner
 	return linter.CheckCRL(tbs)
 }
 
+// ReportIssued runs an already issued DER encoded certificate through all lints
+// except those named in skipLints. Rather than failing it returns a
+// LintReportEntry for every lint run against certDER and for every lint named
+// in skipLints. It only returns an error if the certificate can't be linted.
+func ReportIssued(certDER []byte, skipLints []string) ([]LintReportEntry, error) {
+	cert, err := zlintx509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	reg, err := makeRegistry(skipLints, nil, DefaultExcludeSources)
+	if err != nil {
+		return nil, err
+	}
+	return makeLintReport(zlint.LintCertificateEx(cert, reg), skipLints), nil
+}
+
+// Modifier alters a DER encoded certificate and re-signs it with signer,
+// returning the DER encoded result.
+type Modifier func(certDER []byte, signer crypto.Signer) ([]byte, error)
+
 // Linter is capable of linting a to-be-signed (TBS) certificate. It does so by
 // signing that certificate with a throwaway private key and a fake issuer whose
 // public key matches the throwaway private key, and then running the resulting
@@ -63,6 +112,7 @@ type Linter struct {
 	signer     crypto.Signer
 	registry   lint.Registry
 	realPubKey crypto.PublicKey
+	skipLints  []string
 }
 
 // New constructs a Linter. It uses the provided real certificate and signer
@@ -71,6 +121,13 @@ type Linter struct {
 // to skip to filter the zlint global registry to only those lints which should
 // be run.
 func New(realIssuer *x509.Certificate, realSigner crypto.Signer, skipLints []string) (*Linter, error) {
+	return newLinter(realIssuer, realSigner, skipLints, nil, DefaultExcludeSources)
+}
+
+// newLinter is like New, but lints which are disabled by default are also run
+// if they're named in enableLints, and lints from excludeSources, rather than
+// from DefaultExcludeSources, aren't run.
+func newLinter(realIssuer *x509.Certificate, realSigner crypto.Signer, skipLints, enableLints []string, excludeSources []lint.LintSource) (*Linter, error) {
 	lintSigner, err := makeSigner(realSigner)
 	if err != nil {
 		return nil, err
@@ -79,11 +136,11 @@ func New(realIssuer *x509.Certificate, realSigner crypto.Signer, skipLints []str
 	if err != nil {
 		return nil, err
 	}
-	reg, err := makeRegistry(skipLints)
+	reg, err := makeRegistry(skipLints, enableLints, excludeSources)
 	if err != nil {
 		return nil, err
 	}
-	return &Linter{lintIssuer, lintSigner, reg, realSigner.Public()}, nil
+	return &Linter{lintIssuer, lintSigner, reg, realSigner.Public(), skipLints}, nil
 }
 
 // Check signs the given TBS certificate using the Linter's fake issuer cert and
@@ -94,27 +151,59 @@ func New(realIssuer *x509.Certificate, realSigner crypto.Signer, skipLints []str
 // an error if any lint fails. On success it also returns the DER bytes of the
 // linting certificate.
 func (l Linter) Check(tbs *x509.Certificate, subjectPubKey crypto.PublicKey) ([]byte, error) {
-	lintPubKey := subjectPubKey
-	selfSigned, err := core.PublicKeysEqual(subjectPubKey, l.realPubKey)
+	lintCertBytes, lintRes, err := l.lintCert(tbs, subjectPubKey, nil)
 	if err != nil {
 		return nil, err
 	}
-	if selfSigned {
-		lintPubKey = l.signer.Public()
-	}
-
-	lintCertBytes, cert, err := makeLintCert(tbs, lintPubKey, l.issuer, l.signer)
+	err = ProcessResultSet(lintRes)
 	if err != nil {
 		return nil, err
 	}
+	return lintCertBytes, nil
+}
 
-	lintRes := zlint.LintCertificateEx(cert, l.registry)
+// CheckWithReport is like Check, but it also returns a LintReportEntry for
+// every lint which was run or skipped. The report is returned alongside any
+// lint failure.
+func (l Linter) CheckWithReport(tbs *x509.Certificate, subjectPubKey crypto.PublicKey) ([]byte, []LintReportEntry, error) {
+	return l.CheckModifiedWithReport(tbs, subjectPubKey, nil)
+}
+
+// CheckModifiedWithReport is like CheckWithReport, but if modify is non-nil
+// the linting certificate is passed to it, along with the Linter's private
+// key, and the certificate it returns is linted instead.
+func (l Linter) CheckModifiedWithReport(tbs *x509.Certificate, subjectPubKey crypto.PublicKey, modify Modifier) ([]byte, []LintReportEntry, error) {
+	lintCertBytes, lintRes, err := l.lintCert(tbs, subjectPubKey, modify)
+	if err != nil {
+		return nil, nil, err
+	}
+	report := makeLintReport(lintRes, l.skipLints)
 	err = ProcessResultSet(lintRes)
 	if err != nil {
-		return nil, err
+		return nil, report, err
 	}
+	return lintCertBytes, report, nil
+}
 
-	return lintCertBytes, nil
+// lintCert creates the linting certificate for the given TBS certificate,
+// modified by modify if it is non-nil, and runs it through all non-filtered
+// lints, returning the linting certificate's DER bytes and the lint results.
+func (l Linter) lintCert(tbs *x509.Certificate, subjectPubKey crypto.PublicKey, modify Modifier) ([]byte, *zlint.ResultSet, error) {
+	lintPubKey := subjectPubKey
+	selfSigned, err := core.PublicKeysEqual(subjectPubKey, l.realPubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if selfSigned {
+		lintPubKey = l.signer.Public()
+	}
+
+	lintCertBytes, cert, err := makeLintCert(tbs, lintPubKey, l.issuer, l.signer, modify)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return lintCertBytes, zlint.LintCertificateEx(cert, l.registry), nil
 }
 
 // CheckCRL signs the given RevocationList template using the Linter's fake
@@ -205,16 +294,49 @@ func makeIssuer(realIssuer *x509.Certificate, lintSigner crypto.Signer) (*x509.C
 	return lintIssuer, nil
 }
 
-func makeRegistry(skipLints []string) (lint.Registry, error) {
+// defaultDisabledLints are registered lints which only hold for certificates
+// whose issuer has opted in to them, such as with the ceremony tool's
+// --enable-lints flag, and so are only run when named in enableLints.
+var defaultDisabledLints = []string{
+	"e_cert_extensions_not_canonical_order",
+}
+
+// DefaultExcludeSources are the lint sources which aren't run unless a caller
+// provides its own list of sources to exclude.
+var DefaultExcludeSources = []lint.LintSource{
+	// Excluded because Boulder does not issue EV certs.
+	lint.CABFEVGuidelines,
+	// Excluded because Boulder does not use the
+	// ETSI EN 319 412-5 qcStatements extension.
+	lint.EtsiEsi,
+}
+
+// CheckEnableLints returns an error if any of enableLints isn't a lint which is
+// disabled by default, and so can't be enabled.
+func CheckEnableLints(enableLints []string) error {
+	for _, name := range enableLints {
+		if !slices.Contains(defaultDisabledLints, name) {
+			return fmt.Errorf("lint %q can't be enabled, because it isn't disabled by default", name)
+		}
+	}
+	return nil
+}
+
+func makeRegistry(skipLints, enableLints []string, excludeSources []lint.LintSource) (lint.Registry, error) {
+	err := CheckEnableLints(enableLints)
+	if err != nil {
+		return nil, err
+	}
+	excludeNames := make([]string, 0, len(skipLints)+len(defaultDisabledLints))
+	excludeNames = append(excludeNames, skipLints...)
+	for _, name := range defaultDisabledLints {
+		if !slices.Contains(enableLints, name) {
+			excludeNames = append(excludeNames, name)
+		}
+	}
 	reg, err := lint.GlobalRegistry().Filter(lint.FilterOptions{
-		ExcludeNames: skipLints,
-		ExcludeSources: []lint.LintSource{
-			// Excluded because Boulder does not issue EV certs.
-			lint.CABFEVGuidelines,
-			// Excluded because Boulder does not use the
-			// ETSI EN 319 412-5 qcStatements extension.
-			lint.EtsiEsi,
-		},
+		ExcludeNames:   excludeNames,
+		ExcludeSources: excludeSources,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create lint registry: %w", err)
@@ -222,11 +344,17 @@ func makeRegistry(skipLints []string) (lint.Registry, error) {
 	return reg, nil
 }
 
-func makeLintCert(tbs *x509.Certificate, subjectPubKey crypto.PublicKey, issuer *x509.Certificate, signer crypto.Signer) ([]byte, *zlintx509.Certificate, error) {
+func makeLintCert(tbs *x509.Certificate, subjectPubKey crypto.PublicKey, issuer *x509.Certificate, signer crypto.Signer, modify Modifier) ([]byte, *zlintx509.Certificate, error) {
 	lintCertBytes, err := x509.CreateCertificate(rand.Reader, tbs, issuer, subjectPubKey, signer)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create lint certificate: %w", err)
 	}
+	if modify != nil {
+		lintCertBytes, err = modify(lintCertBytes, signer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to modify lint certificate: %w", err)
+		}
+	}
 	lintCert, err := zlintx509.ParseCertificate(lintCertBytes)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to parse lint certificate: %w", err)
@@ -234,6 +362,9 @@ func makeLintCert(tbs *x509.Certificate, subjectPubKey crypto.PublicKey, issuer
 	return lintCertBytes, lintCert, nil
 }
 
+// ProcessResultSet returns an error naming every lint in lintRes whose result
+// was worse than Pass. Notices and warnings fail just like errors do, so a
+// warning can only be tolerated by skipping the lint which produced it.
 func ProcessResultSet(lintRes *zlint.ResultSet) error {
 	if lintRes.NoticesPresent || lintRes.WarningsPresent || lintRes.ErrorsPresent || lintRes.FatalsPresent {
 		var failedLints []string
@@ -247,6 +378,98 @@ func ProcessResultSet(lintRes *zlint.ResultSet) error {
 	return nil
 }
 
+// LintStatusSkipped is the LintReportEntry status of a lint which was skipped
+// because it was named in skipLints.
+const LintStatusSkipped = "skipped"
+
+// LintReportEntry describes the outcome of a single lint.
+type LintReportEntry struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Status string `json:"status"`
+}
+
+// reportStatus returns a human readable LintReportEntry status for s.
+func reportStatus(s lint.LintStatus) string {
+	switch s {
+	case lint.Pass:
+		return "pass"
+	case lint.NA:
+		return "not applicable"
+	case lint.NE:
+		return "not effective"
+	default:
+		return s.String()
+	}
+}
+
+// makeLintReport returns a LintReportEntry for every lint in lintRes and for
+// every certificate lint named in skipLints, sorted by lint name.
+func makeLintReport(lintRes *zlint.ResultSet, skipLints []string) []LintReportEntry {
+	lints := lint.GlobalRegistry().CertificateLints()
+	source := func(name string) string {
+		l := lints.ByName(name)
+		if l == nil {
+			return string(lint.UnknownLintSource)
+		}
+		return string(l.Source)
+	}
+
+	var report []LintReportEntry
+	for name, result := range lintRes.Results {
+		report = append(report, LintReportEntry{
+			Name:   name,
+			Source: source(name),
+			Status: reportStatus(result.Status),
+		})
+	}
+	for _, name := range skipLints {
+		if lints.ByName(name) == nil {
+			// CRL lints share skipLints with certificate lints, but are
+			// never considered when linting a certificate.
+			continue
+		}
+		report = append(report, LintReportEntry{
+			Name:   name,
+			Source: source(name),
+			Status: LintStatusSkipped,
+		})
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Name < report[j].Name
+	})
+	return report
+}
+
+// LintDescription describes a single certificate lint.
+type LintDescription struct {
+	Name        string
+	Source      string
+	Description string
+}
+
+// DescribeLints returns a LintDescription for every certificate lint which is
+// run by CheckModifiedWithReport with the same enableLints and excludeSources,
+// sorted by lint name.
+func DescribeLints(enableLints []string, excludeSources []lint.LintSource) ([]LintDescription, error) {
+	reg, err := makeRegistry(nil, enableLints, excludeSources)
+	if err != nil {
+		return nil, err
+	}
+	var descriptions []LintDescription
+	for _, l := range reg.CertificateLints().Lints() {
+		descriptions = append(descriptions, LintDescription{
+			Name:        l.Name,
+			Source:      string(l.Source),
+			Description: l.Description,
+		})
+	}
+	sort.Slice(descriptions, func(i, j int) bool {
+		return descriptions[i].Name < descriptions[j].Name
+	})
+	return descriptions, nil
+}
+
 func makeLintCRL(tbs *x509.RevocationList, issuer *x509.Certificate, signer crypto.Signer) (*zlintx509.RevocationList, error) {
 	lintCRLBytes, err := x509.CreateRevocationList(rand.Reader, tbs, issuer, signer)
 	if err != nil {
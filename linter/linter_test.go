@@ -4,9 +4,20 @@ import (
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
 	"math/big"
+	"os"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/zmap/zlint/v3"
+	"github.com/zmap/zlint/v3/lint"
 
 	"github.com/letsencrypt/boulder/test"
 )
@@ -46,3 +57,123 @@ func TestMakeSigner_Unsupported(t *testing.T) {
 func TestMakeIssuer(t *testing.T) {
 
 }
+
+func TestMakeLintReport(t *testing.T) {
+	lintRes := &zlint.ResultSet{
+		Results: map[string]*lint.LintResult{
+			"e_sub_cert_aia_does_not_contain_ocsp_url": {Status: lint.NA},
+			"e_ext_subject_key_identifier_missing_ca":  {Status: lint.Pass},
+		},
+	}
+	report := makeLintReport(lintRes, []string{"n_ca_digital_signature_not_set", "e_crl_validity_period"})
+	test.AssertDeepEquals(t, report, []LintReportEntry{
+		{Name: "e_ext_subject_key_identifier_missing_ca", Source: string(lint.RFC5280), Status: "pass"},
+		{Name: "e_sub_cert_aia_does_not_contain_ocsp_url", Source: string(lint.CABFBaselineRequirements), Status: "not applicable"},
+		{Name: "n_ca_digital_signature_not_set", Source: string(lint.CABFBaselineRequirements), Status: LintStatusSkipped},
+	})
+}
+
+func TestMakeRegistryEnableLints(t *testing.T) {
+	reg, err := makeRegistry(nil, nil, DefaultExcludeSources)
+	test.AssertNotError(t, err, "makeRegistry failed")
+	test.Assert(t, !slices.Contains(reg.Names(), "e_cert_extensions_not_canonical_order"), "a lint disabled by default is registered")
+
+	reg, err = makeRegistry(nil, []string{"e_cert_extensions_not_canonical_order"}, DefaultExcludeSources)
+	test.AssertNotError(t, err, "makeRegistry failed with a lint enabled")
+	test.Assert(t, slices.Contains(reg.Names(), "e_cert_extensions_not_canonical_order"), "an enabled lint isn't registered")
+
+	_, err = makeRegistry(nil, []string{"n_ca_digital_signature_not_set"}, DefaultExcludeSources)
+	test.AssertError(t, err, "makeRegistry didn't fail when enabling a lint which isn't disabled by default")
+}
+
+func TestMakeRegistryExcludeSources(t *testing.T) {
+	reg, err := makeRegistry(nil, nil, DefaultExcludeSources)
+	test.AssertNotError(t, err, "makeRegistry failed")
+	test.Assert(t, !slices.Contains(reg.Names(), "e_qcstatem_mandatory_etsi_statems"), "ETSI lints are registered by default")
+	test.Assert(t, !slices.Contains(reg.Names(), "e_ev_valid_time_too_long"), "EV lints are registered by default")
+
+	// The ceremony tool runs the ETSI EN 319 412-5 lints against certificates
+	// with a qcStatements extension by excluding only the EV lints.
+	reg, err = makeRegistry(nil, nil, []lint.LintSource{lint.CABFEVGuidelines})
+	test.AssertNotError(t, err, "makeRegistry failed")
+	test.Assert(t, slices.Contains(reg.Names(), "e_qcstatem_mandatory_etsi_statems"), "ETSI lints aren't registered")
+	test.Assert(t, !slices.Contains(reg.Names(), "e_ev_valid_time_too_long"), "EV lints are registered")
+}
+
+func TestCheckModifiedWithReportEnableLints(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate key")
+	// x509.CreateCertificate appends ExtraExtensions after the extensions it
+	// builds itself, so this puts keyUsage out of canonical order.
+	keyUsage, err := asn1.Marshal(asn1.BitString{Bytes: []byte{0x06}, BitLength: 7})
+	test.AssertNotError(t, err, "failed to marshal keyUsage")
+	tbs := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{2, 5, 29, 15}, Critical: true, Value: keyUsage},
+		},
+	}
+	issuer := &x509.Certificate{Subject: tbs.Subject, PublicKey: key.Public()}
+
+	statusOf := func(report []LintReportEntry, name string) string {
+		for _, entry := range report {
+			if entry.Name == name {
+				return entry.Status
+			}
+		}
+		return ""
+	}
+
+	_, report, _ := CheckModifiedWithReport(tbs, key.Public(), issuer, key, nil, nil, DefaultExcludeSources, nil)
+	test.AssertEquals(t, statusOf(report, "e_cert_extensions_not_canonical_order"), "")
+
+	_, report, err = CheckModifiedWithReport(tbs, key.Public(), issuer, key, nil, []string{"e_cert_extensions_not_canonical_order"}, DefaultExcludeSources, nil)
+	test.AssertErrorIs(t, err, ErrLinting)
+	test.AssertContains(t, err.Error(), "e_cert_extensions_not_canonical_order")
+	test.AssertEquals(t, statusOf(report, "e_cert_extensions_not_canonical_order"), lint.Error.String())
+}
+
+func TestProcessResultSetFailsOnWarnings(t *testing.T) {
+	err := ProcessResultSet(&zlint.ResultSet{
+		Results: map[string]*lint.LintResult{
+			"w_some_lint": {Status: lint.Warn, Details: "something looks odd"},
+			"e_pass_lint": {Status: lint.Pass},
+		},
+		WarningsPresent: true,
+	})
+	test.AssertErrorIs(t, err, ErrLinting)
+	test.AssertContains(t, err.Error(), "w_some_lint (something looks odd)")
+
+	err = ProcessResultSet(&zlint.ResultSet{
+		Results: map[string]*lint.LintResult{
+			"e_pass_lint": {Status: lint.Pass},
+		},
+	})
+	test.AssertNotError(t, err, "ProcessResultSet failed without warnings")
+}
+
+func TestReportIssued(t *testing.T) {
+	_, err := ReportIssued([]byte{0x30, 0x00}, nil)
+	test.AssertError(t, err, "ReportIssued didn't fail for a malformed certificate")
+
+	certPEM, err := os.ReadFile("../test/hierarchy/int-e1.cert.pem")
+	test.AssertNotError(t, err, "failed to read certificate")
+	block, _ := pem.Decode(certPEM)
+	report, err := ReportIssued(block.Bytes, []string{"n_ca_digital_signature_not_set"})
+	test.AssertNotError(t, err, "ReportIssued failed")
+	var skipped []LintReportEntry
+	for _, entry := range report {
+		test.AssertNotEquals(t, entry.Status, lint.Error.String())
+		if entry.Status == LintStatusSkipped {
+			skipped = append(skipped, entry)
+		}
+	}
+	test.AssertDeepEquals(t, skipped, []LintReportEntry{
+		{Name: "n_ca_digital_signature_not_set", Source: string(lint.CABFBaselineRequirements), Status: LintStatusSkipped},
+	})
+}
@@ -0,0 +1,52 @@
+package chrome
+
+import (
+	"time"
+
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+	"github.com/zmap/zlint/v3/util"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type certHasPoisonAndSCTList struct{}
+
+/************************************************
+RFC 6962: 3.1
+A precertificate is identified by the critical poison extension, and SCTs for
+it are embedded in the final certificate, which doesn't contain the poison.
+A certificate carrying both is neither a precertificate nor a final
+certificate.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_cert_has_poison_and_sct_list",
+		Description:   "Let's Encrypt Certificates must not contain both the CT poison extension and an SCT list extension",
+		Citation:      "RFC 6962: 3.1",
+		Source:        lints.ChromeCTPolicy,
+		EffectiveDate: time.Date(2022, time.April, 15, 0, 0, 0, 0, time.UTC),
+		Lint:          NewCertHasPoisonAndSCTList,
+	})
+}
+
+func NewCertHasPoisonAndSCTList() lint.LintInterface {
+	return &certHasPoisonAndSCTList{}
+}
+
+func (l *certHasPoisonAndSCTList) CheckApplies(c *x509.Certificate) bool {
+	return true
+}
+
+func (l *certHasPoisonAndSCTList) Execute(c *x509.Certificate) *lint.LintResult {
+	poison := lints.GetExtWithOID(c.Extensions, util.CtPoisonOID)
+	sctList := lints.GetExtWithOID(c.Extensions, util.TimestampOID)
+	if poison != nil && sctList != nil {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "Certificate contains both the CT poison extension and an SCT list extension",
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
@@ -0,0 +1,53 @@
+package chrome
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints/test"
+)
+
+func TestCertHasPoisonAndSCTList(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		want       lint.LintStatus
+		wantSubStr string
+	}{
+		{
+			name: "poison_only",
+			want: lint.Pass,
+		},
+		{
+			name: "sct_list_only",
+			want: lint.Pass,
+		},
+		{
+			name:       "poison_and_sct_list",
+			want:       lint.Error,
+			wantSubStr: "both the CT poison extension and an SCT list extension",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewCertHasPoisonAndSCTList()
+			c := test.LoadPEMCert(t, fmt.Sprintf("testdata/cert_%s.pem", tc.name))
+			if !l.CheckApplies(c) {
+				t.Fatalf("expected lint to apply to %s", tc.name)
+			}
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
@@ -16,13 +16,22 @@ const (
 	BRDay time.Duration = 86400 * time.Second
 
 	// Declare our own Sources for use in zlint registry filtering.
-	LetsEncryptCPS lint.LintSource = "LECPS"
-	ChromeCTPolicy lint.LintSource = "ChromeCT"
+	LetsEncryptCPS             lint.LintSource = "LECPS"
+	LetsEncryptCPSAll          lint.LintSource = "LECPSAll"
+	LetsEncryptCPSIntermediate lint.LintSource = "LECPSIntermediate"
+	LetsEncryptCPSRoot         lint.LintSource = "LECPSRoot"
+	LetsEncryptCPSSubscriber   lint.LintSource = "LECPSSubscriber"
+	ChromeCTPolicy             lint.LintSource = "ChromeCT"
 )
 
 var (
 	CPSV33Date           = time.Date(2021, time.June, 8, 0, 0, 0, 0, time.UTC)
 	MozillaPolicy281Date = time.Date(2023, time.February, 15, 0, 0, 0, 0, time.UTC)
+
+	// RFC 5280 4.1.2.5: "To indicate that a certificate has no well-defined
+	// expiration date, the notAfter SHOULD be assigned the GeneralizedTime
+	// value of 99991231235959Z."
+	NoWellDefinedExpiration = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
 )
 
 // GetExtWithOID is a helper for several of our custom lints. It returns the
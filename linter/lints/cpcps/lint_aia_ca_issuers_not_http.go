@@ -0,0 +1,60 @@
+package cpcps
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+	"github.com/zmap/zlint/v3/util"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type aiaCAIssuersNotHTTP struct{}
+
+/************************************************
+RFC 5280: 4.2.2.1
+Relying parties fetch the caIssuers accessLocation while building a chain.
+Fetching it over https requires validating another chain, which may need the
+very certificate being fetched, so Let's Encrypt caIssuers URLs must use the
+http scheme.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_aia_ca_issuers_not_http",
+		Description:   "Let's Encrypt Certificates must only have http caIssuers URLs in their authorityInformationAccess extension",
+		Citation:      "RFC 5280: 4.2.2.1",
+		Source:        lints.LetsEncryptCPSAll,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewAIACAIssuersNotHTTP,
+	})
+}
+
+func NewAIACAIssuersNotHTTP() lint.LintInterface {
+	return &aiaCAIssuersNotHTTP{}
+}
+
+func (l *aiaCAIssuersNotHTTP) CheckApplies(c *x509.Certificate) bool {
+	return util.IsExtInCert(c, util.AiaOID) && len(c.IssuingCertificateURL) > 0
+}
+
+func (l *aiaCAIssuersNotHTTP) Execute(c *x509.Certificate) *lint.LintResult {
+	for _, u := range c.IssuingCertificateURL {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return &lint.LintResult{
+				Status:  lint.Error,
+				Details: fmt.Sprintf("Failed to parse caIssuers URL %q: %s", u, err),
+			}
+		}
+		if parsed.Scheme != "http" {
+			return &lint.LintResult{
+				Status:  lint.Error,
+				Details: fmt.Sprintf("caIssuers URL %q uses the %q scheme, not http", u, parsed.Scheme),
+			}
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
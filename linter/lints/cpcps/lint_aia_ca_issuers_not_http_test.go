@@ -0,0 +1,49 @@
+package cpcps
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints/test"
+)
+
+func TestAIACAIssuersNotHTTP(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		want       lint.LintStatus
+		wantSubStr string
+	}{
+		{
+			name: "aia_ca_issuers_http",
+			want: lint.Pass,
+		},
+		{
+			name:       "aia_ca_issuers_https",
+			want:       lint.Error,
+			wantSubStr: "uses the \"https\" scheme, not http",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewAIACAIssuersNotHTTP()
+			c := test.LoadPEMCert(t, fmt.Sprintf("testdata/cert_%s.pem", tc.name))
+			if !l.CheckApplies(c) {
+				t.Fatalf("expected lint to apply to %s", tc.name)
+			}
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package cpcps
+
+import (
+	"github.com/zmap/zcrypto/encoding/asn1"
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type certExtensionsNotCanonicalOrder struct{}
+
+/************************************************
+CPS: 7.1
+A ceremony which must be reproducible must emit certificate extensions in a
+single canonical order, so that re-running it from the same config produces
+byte-identical output. The canonical order is the order in which Go's
+crypto/x509 builds its extensions, with any extensions it doesn't know about
+following all of the ones it does.
+
+This lint is disabled by default (see linter.defaultDisabledLints) because
+other certificates are not held to it, for instance those whose authority key
+identifier is supplied as an extra extension. The ceremony tool runs it when it
+is named by --enable-lints.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_cert_extensions_not_canonical_order",
+		Description:   "Let's Encrypt Certificates from reproducible ceremonies must have their extensions in canonical order",
+		Citation:      "CPS: 7.1",
+		Source:        lints.LetsEncryptCPSAll,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewCertExtensionsNotCanonicalOrder,
+	})
+}
+
+// canonicalExtensionOrder lists the extensions the ceremony tool can emit, in
+// the order crypto/x509 writes them.
+var canonicalExtensionOrder = []asn1.ObjectIdentifier{
+	{2, 5, 29, 15},              // id-ce-keyUsage
+	{2, 5, 29, 37},              // id-ce-extKeyUsage
+	{2, 5, 29, 19},              // id-ce-basicConstraints
+	{2, 5, 29, 14},              // id-ce-subjectKeyIdentifier
+	{2, 5, 29, 35},              // id-ce-authorityKeyIdentifier
+	{1, 3, 6, 1, 5, 5, 7, 1, 1}, // id-pe-authorityInfoAccess
+	{2, 5, 29, 17},              // id-ce-subjectAltName
+	{2, 5, 29, 32},              // id-ce-certificatePolicies
+	{2, 5, 29, 30},              // id-ce-nameConstraints
+	{2, 5, 29, 31},              // id-ce-cRLDistributionPoints
+}
+
+func NewCertExtensionsNotCanonicalOrder() lint.LintInterface {
+	return &certExtensionsNotCanonicalOrder{}
+}
+
+func (l *certExtensionsNotCanonicalOrder) CheckApplies(c *x509.Certificate) bool {
+	return true
+}
+
+func (l *certExtensionsNotCanonicalOrder) Execute(c *x509.Certificate) *lint.LintResult {
+	// Unknown extensions rank after every known one.
+	rank := func(oid asn1.ObjectIdentifier) int {
+		for i, known := range canonicalExtensionOrder {
+			if oid.Equal(known) {
+				return i
+			}
+		}
+		return len(canonicalExtensionOrder)
+	}
+
+	last := 0
+	for _, ext := range c.Extensions {
+		r := rank(ext.Id)
+		if r < last {
+			return &lint.LintResult{
+				Status:  lint.Error,
+				Details: "Certificate extension " + ext.Id.String() + " is out of canonical order",
+			}
+		}
+		last = r
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
@@ -0,0 +1,49 @@
+package cpcps
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints/test"
+)
+
+func TestCertExtensionsNotCanonicalOrder(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		want       lint.LintStatus
+		wantSubStr string
+	}{
+		{
+			name: "extensions_canonical_order",
+			want: lint.Pass,
+		},
+		{
+			name:       "extensions_noncanonical_order",
+			want:       lint.Error,
+			wantSubStr: "is out of canonical order",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewCertExtensionsNotCanonicalOrder()
+			c := test.LoadPEMCert(t, fmt.Sprintf("testdata/cert_%s.pem", tc.name))
+			if !l.CheckApplies(c) {
+				t.Fatalf("expected lint to apply to %s", tc.name)
+			}
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
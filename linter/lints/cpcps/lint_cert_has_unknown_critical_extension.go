@@ -0,0 +1,64 @@
+package cpcps
+
+import (
+	"fmt"
+
+	"github.com/zmap/zcrypto/encoding/asn1"
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+	"github.com/zmap/zlint/v3/util"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type certHasUnknownCriticalExtension struct{}
+
+/************************************************
+RFC 5280: 4.2
+A certificate-using system MUST reject the certificate if it encounters a
+critical extension it does not recognize or a critical extension that contains
+information that it cannot process.
+************************************************/
+
+// knownCriticalExtensions contains the OIDs of the extensions which our
+// certificate profiles may mark critical.
+var knownCriticalExtensions = []asn1.ObjectIdentifier{
+	util.BasicConstOID,
+	util.KeyUsageOID,
+	util.NameConstOID,
+	// Go marks the subjectAltName extension critical when the subject is empty.
+	util.SubjectAlternateNameOID,
+	// RFC 6962 Section 3.1: the precertificate poison extension is critical.
+	util.CtPoisonOID,
+}
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_cert_has_unknown_critical_extension",
+		Description:   "Let's Encrypt Certificates must not contain critical extensions which our profiles do not emit",
+		Citation:      "RFC 5280: 4.2",
+		Source:        lints.LetsEncryptCPSAll,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewCertHasUnknownCriticalExtension,
+	})
+}
+
+func NewCertHasUnknownCriticalExtension() lint.LintInterface {
+	return &certHasUnknownCriticalExtension{}
+}
+
+func (l *certHasUnknownCriticalExtension) CheckApplies(c *x509.Certificate) bool {
+	return true
+}
+
+func (l *certHasUnknownCriticalExtension) Execute(c *x509.Certificate) *lint.LintResult {
+	for _, ext := range c.Extensions {
+		if ext.Critical && !util.SliceContainsOID(knownCriticalExtensions, ext.Id) {
+			return &lint.LintResult{
+				Status:  lint.Error,
+				Details: fmt.Sprintf("Certificate contains unknown critical extension %s", ext.Id),
+			}
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
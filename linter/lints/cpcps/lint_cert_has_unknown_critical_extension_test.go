@@ -0,0 +1,46 @@
+package cpcps
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints/test"
+)
+
+func TestCertHasUnknownCriticalExtension(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		want       lint.LintStatus
+		wantSubStr string
+	}{
+		{
+			name: "known_critical_exts",
+			want: lint.Pass,
+		},
+		{
+			name:       "unknown_critical_ext",
+			want:       lint.Error,
+			wantSubStr: "unknown critical extension 1.2.3.4",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewCertHasUnknownCriticalExtension()
+			c := test.LoadPEMCert(t, fmt.Sprintf("testdata/cert_%s.pem", tc.name))
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package cpcps
+
+import (
+	"fmt"
+
+	"github.com/zmap/zcrypto/encoding/asn1"
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+	"github.com/zmap/zlint/v3/util"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type certPoliciesHasReservedOID struct{}
+
+/************************************************
+RFC 5280: 4.2.1.4
+The anyPolicy identifier only has meaning in CA certificates, where it
+indicates that the CA doesn't limit the policies of the certificates it
+issues. Subscriber certificates must assert the specific policies they were
+issued under instead.
+
+Policy identifiers under the ITU-T X.660 example arc (2.999) are only ever
+placeholders, and must never appear in an issued certificate.
+************************************************/
+
+var (
+	// oidAnyPolicy is the anyPolicy identifier from RFC 5280 Section 4.2.1.4.
+	oidAnyPolicy = asn1.ObjectIdentifier{2, 5, 29, 32, 0}
+	// oidExampleArc is the X.660 arc reserved for examples in documentation.
+	oidExampleArc = asn1.ObjectIdentifier{2, 999}
+)
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_cert_policies_has_reserved_oid",
+		Description:   "Let's Encrypt Certificates must not assert anyPolicy, unless they are CA certificates, or a placeholder policy identifier",
+		Citation:      "RFC 5280: 4.2.1.4",
+		Source:        lints.LetsEncryptCPSAll,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewCertPoliciesHasReservedOID,
+	})
+}
+
+func NewCertPoliciesHasReservedOID() lint.LintInterface {
+	return &certPoliciesHasReservedOID{}
+}
+
+func (l *certPoliciesHasReservedOID) CheckApplies(c *x509.Certificate) bool {
+	return util.IsExtInCert(c, util.CertPolicyOID)
+}
+
+func (l *certPoliciesHasReservedOID) Execute(c *x509.Certificate) *lint.LintResult {
+	for _, oid := range c.PolicyIdentifiers {
+		if oid.Equal(oidAnyPolicy) && !util.IsCACert(c) {
+			return &lint.LintResult{
+				Status:  lint.Error,
+				Details: "certificatePolicies of a non-CA certificate contains anyPolicy",
+			}
+		}
+		if len(oid) >= len(oidExampleArc) && oid[:len(oidExampleArc)].Equal(oidExampleArc) {
+			return &lint.LintResult{
+				Status:  lint.Error,
+				Details: fmt.Sprintf("certificatePolicies contains policy OID %s under the example arc", oid),
+			}
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
@@ -0,0 +1,54 @@
+package cpcps
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints/test"
+)
+
+func TestCertPoliciesHasReservedOID(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		want       lint.LintStatus
+		wantSubStr string
+	}{
+		{
+			name: "policy_domain_validated",
+			want: lint.Pass,
+		},
+		{
+			name:       "policy_any_policy_on_leaf",
+			want:       lint.Error,
+			wantSubStr: "non-CA certificate contains anyPolicy",
+		},
+		{
+			name:       "policy_example_arc",
+			want:       lint.Error,
+			wantSubStr: "policy OID 2.999.1 under the example arc",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewCertPoliciesHasReservedOID()
+			c := test.LoadPEMCert(t, fmt.Sprintf("testdata/cert_%s.pem", tc.name))
+			if !l.CheckApplies(c) {
+				t.Fatalf("expected lint to apply to %s", tc.name)
+			}
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
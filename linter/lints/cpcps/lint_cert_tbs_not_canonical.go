@@ -0,0 +1,82 @@
+package cpcps
+
+import (
+	"bytes"
+
+	"github.com/zmap/zcrypto/encoding/asn1"
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type certTBSNotCanonical struct{}
+
+/************************************************
+RFC 5280: 4.1
+The certificate is encoded using the ASN.1 Distinguished Encoding Rules (DER).
+DER permits exactly one encoding of each value, so decoding and re-encoding the
+tbsCertificate must reproduce the original bytes. A difference indicates a
+non-canonical encoding, or data following the last field of the
+tbsCertificate, which a lenient parser would silently ignore.
+************************************************/
+
+// tbsCertificate is the RFC 5280 Section 4.1 TBSCertificate structure. Fields
+// which don't need to be inspected are left as raw values, which re-encode to
+// their original bytes.
+type tbsCertificate struct {
+	Version         int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber    asn1.RawValue
+	Signature       asn1.RawValue
+	Issuer          asn1.RawValue
+	Validity        asn1.RawValue
+	Subject         asn1.RawValue
+	PublicKey       asn1.RawValue
+	IssuerUniqueID  asn1.BitString `asn1:"optional,tag:1"`
+	SubjectUniqueID asn1.BitString `asn1:"optional,tag:2"`
+	Extensions      asn1.RawValue  `asn1:"optional,explicit,tag:3"`
+}
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_cert_tbs_not_canonical",
+		Description:   "Let's Encrypt Certificates must have a tbsCertificate which re-encodes to identical DER",
+		Citation:      "RFC 5280: 4.1",
+		Source:        lints.LetsEncryptCPSAll,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewCertTBSNotCanonical,
+	})
+}
+
+func NewCertTBSNotCanonical() lint.LintInterface {
+	return &certTBSNotCanonical{}
+}
+
+func (l *certTBSNotCanonical) CheckApplies(c *x509.Certificate) bool {
+	return true
+}
+
+func (l *certTBSNotCanonical) Execute(c *x509.Certificate) *lint.LintResult {
+	var tbs tbsCertificate
+	_, err := asn1.Unmarshal(c.RawTBSCertificate, &tbs)
+	if err != nil {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "failed to parse tbsCertificate",
+		}
+	}
+	reencoded, err := asn1.Marshal(tbs)
+	if err != nil {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "failed to re-encode tbsCertificate",
+		}
+	}
+	if !bytes.Equal(reencoded, c.RawTBSCertificate) {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "tbsCertificate doesn't re-encode to identical DER, it contains trailing data or a non-canonical encoding",
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
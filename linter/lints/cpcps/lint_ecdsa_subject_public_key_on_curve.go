@@ -0,0 +1,113 @@
+package cpcps
+
+import (
+	"crypto/elliptic"
+	"math/big"
+
+	"github.com/zmap/zcrypto/encoding/asn1"
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zcrypto/x509/pkix"
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type ecdsaSubjectPublicKeyNotOnCurve struct{}
+
+/************************************************
+Subject public keys are frequently taken from CSRs submitted by third parties.
+An ECDSA public key whose point does not lie on its declared curve, or which is
+the point at infinity, is malformed and must never be certified.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_ecdsa_subject_public_key_not_on_curve",
+		Description:   "Let's Encrypt Certificates with ECDSA subject public keys must contain a valid point on the declared curve",
+		Citation:      "CPS: 6.1.6",
+		Source:        lints.LetsEncryptCPSAll,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewECDSASubjectPublicKeyNotOnCurve,
+	})
+}
+
+func NewECDSASubjectPublicKeyNotOnCurve() lint.LintInterface {
+	return &ecdsaSubjectPublicKeyNotOnCurve{}
+}
+
+func (l *ecdsaSubjectPublicKeyNotOnCurve) CheckApplies(c *x509.Certificate) bool {
+	return c.PublicKeyAlgorithm == x509.ECDSA
+}
+
+var ecdsaNamedCurves = map[string]elliptic.Curve{
+	asn1.ObjectIdentifier{1, 3, 132, 0, 33}.String():          elliptic.P224(),
+	asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}.String(): elliptic.P256(),
+	asn1.ObjectIdentifier{1, 3, 132, 0, 34}.String():          elliptic.P384(),
+	asn1.ObjectIdentifier{1, 3, 132, 0, 35}.String():          elliptic.P521(),
+}
+
+func (l *ecdsaSubjectPublicKeyNotOnCurve) Execute(c *x509.Certificate) *lint.LintResult {
+	// The point is checked from the raw SubjectPublicKeyInfo, rather than from
+	// the parsed public key, so that this lint does not depend on the parser
+	// having already rejected malformed points.
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	_, err := asn1.Unmarshal(c.RawSubjectPublicKeyInfo, &spki)
+	if err != nil {
+		return &lint.LintResult{
+			Status:  lint.Fatal,
+			Details: "failed to parse SubjectPublicKeyInfo",
+		}
+	}
+
+	var curveOID asn1.ObjectIdentifier
+	_, err = asn1.Unmarshal(spki.Algorithm.Parameters.FullBytes, &curveOID)
+	if err != nil {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "ECDSA public key parameters are not a named curve",
+		}
+	}
+	curve, ok := ecdsaNamedCurves[curveOID.String()]
+	if !ok {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "ECDSA public key uses an unsupported curve",
+		}
+	}
+
+	// SEC 1 Section 2.3.3: the point at infinity is encoded as a single zero
+	// octet, and all other points we accept use the uncompressed form.
+	point := spki.PublicKey.RightAlign()
+	byteLen := (curve.Params().BitSize + 7) / 8
+	if len(point) == 1 && point[0] == 0 {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "ECDSA public key is the point at infinity",
+		}
+	}
+	if len(point) != 1+2*byteLen || point[0] != 4 {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "ECDSA public key is not an uncompressed point",
+		}
+	}
+	x := new(big.Int).SetBytes(point[1 : 1+byteLen])
+	y := new(big.Int).SetBytes(point[1+byteLen:])
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "ECDSA public key is the point at infinity",
+		}
+	}
+	if x.Cmp(curve.Params().P) >= 0 || y.Cmp(curve.Params().P) >= 0 || !curve.IsOnCurve(x, y) {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "ECDSA public key point is not on the declared curve",
+		}
+	}
+
+	return &lint.LintResult{Status: lint.Pass}
+}
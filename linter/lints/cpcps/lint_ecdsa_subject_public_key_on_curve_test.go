@@ -0,0 +1,70 @@
+package cpcps
+
+import (
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+// loadPEMSPKICert returns a certificate containing only the raw
+// SubjectPublicKeyInfo from the given PEM file. Malformed ECDSA keys are
+// rejected by the certificate parser, so they can't be loaded from a full
+// certificate fixture.
+func loadPEMSPKICert(t *testing.T, filename string) *x509.Certificate {
+	t.Helper()
+	file, err := os.ReadFile(filename)
+	test.AssertNotError(t, err, "reading public key file")
+	block, _ := pem.Decode(file)
+	test.AssertEquals(t, block.Type, "PUBLIC KEY")
+	return &x509.Certificate{
+		PublicKeyAlgorithm:      x509.ECDSA,
+		RawSubjectPublicKeyInfo: block.Bytes,
+	}
+}
+
+func TestECDSASubjectPublicKeyNotOnCurve(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		want       lint.LintStatus
+		wantSubStr string
+	}{
+		{
+			name: "on_curve",
+			want: lint.Pass,
+		},
+		{
+			name:       "off_curve",
+			want:       lint.Error,
+			wantSubStr: "not on the declared curve",
+		},
+		{
+			name:       "infinity",
+			want:       lint.Error,
+			wantSubStr: "point at infinity",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewECDSASubjectPublicKeyNotOnCurve()
+			c := loadPEMSPKICert(t, fmt.Sprintf("testdata/spki_ecdsa_%s.pem", tc.name))
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
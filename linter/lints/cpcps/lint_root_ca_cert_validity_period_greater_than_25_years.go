@@ -35,6 +35,12 @@ func (l *rootCACertValidityTooLong) Execute(c *x509.Certificate) *lint.LintResul
 	// CPS 7.1: "Root CA Certificate Validity Period: Up to 25 years."
 	maxValidity := 25 * 365 * lints.BRDay
 
+	// Roots with no well-defined expiration date would overflow the validity
+	// calculation below, and are exempt from the maximum validity period.
+	if c.NotAfter.Equal(lints.NoWellDefinedExpiration) {
+		return &lint.LintResult{Status: lint.Pass}
+	}
+
 	// RFC 5280 4.1.2.5: "The validity period for a certificate is the period
 	// of time from notBefore through notAfter, inclusive."
 	certValidity := c.NotAfter.Add(time.Second).Sub(c.NotBefore)
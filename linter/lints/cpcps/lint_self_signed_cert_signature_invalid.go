@@ -0,0 +1,62 @@
+package cpcps
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+	"github.com/zmap/zlint/v3/util"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type selfSignedCertSignatureInvalid struct{}
+
+/************************************************
+RFC 5280: 3.2
+Self-signed certificates are self-issued certificates where the digital
+signature may be verified by the public key bound into the certificate.
+
+A Let's Encrypt Root CA Certificate whose signature doesn't verify with its own
+public key was signed with the wrong key, and can't serve as a trust anchor.
+
+zlint only considers a certificate to be self-signed once its signature has
+verified, so this lint instead applies to every self-issued CA certificate
+whose authorityKeyIdentifier, if present, matches its subjectKeyIdentifier.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_self_signed_cert_signature_invalid",
+		Description:   "Let's Encrypt Root CA Certificates must have a signature which verifies with their own public key",
+		Citation:      "RFC 5280: 3.2",
+		Source:        lints.LetsEncryptCPSRoot,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewSelfSignedCertSignatureInvalid,
+	})
+}
+
+func NewSelfSignedCertSignatureInvalid() lint.LintInterface {
+	return &selfSignedCertSignatureInvalid{}
+}
+
+func (l *selfSignedCertSignatureInvalid) CheckApplies(c *x509.Certificate) bool {
+	if !util.IsCACert(c) || !bytes.Equal(c.RawSubject, c.RawIssuer) {
+		return false
+	}
+	// A self-issued certificate naming a different key as its issuer, such as
+	// one produced by a key rollover, isn't self-signed.
+	return len(c.AuthorityKeyId) == 0 || bytes.Equal(c.AuthorityKeyId, c.SubjectKeyId)
+}
+
+func (l *selfSignedCertSignatureInvalid) Execute(c *x509.Certificate) *lint.LintResult {
+	err := c.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature)
+	if err != nil {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: fmt.Sprintf("Self-signed certificate's signature does not verify with its own public key: %s", err),
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
@@ -0,0 +1,60 @@
+package cpcps
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints/test"
+)
+
+func TestSelfSignedCertSignatureInvalid(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		want       lint.LintStatus
+		wantSubStr string
+	}{
+		{
+			name: "root_self_signed",
+			want: lint.Pass,
+		},
+		{
+			name:       "root_signed_with_wrong_key",
+			want:       lint.Error,
+			wantSubStr: "does not verify with its own public key",
+		},
+		{
+			name: "intermediate_crl_sign",
+			want: lint.NA,
+		},
+		{
+			name: "subscriber_standard_extensions",
+			want: lint.NA,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewSelfSignedCertSignatureInvalid()
+			c := test.LoadPEMCert(t, fmt.Sprintf("testdata/cert_%s.pem", tc.name))
+			if !l.CheckApplies(c) {
+				if tc.want != lint.NA {
+					t.Fatalf("expected lint to apply to %s", tc.name)
+				}
+				return
+			}
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
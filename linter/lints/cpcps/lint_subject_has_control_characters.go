@@ -0,0 +1,59 @@
+package cpcps
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type subjectHasControlCharacters struct{}
+
+/************************************************
+RFC 5280: 4.1.2.6
+Subject attribute values are compared and displayed by relying parties as
+strings. An ASCII control character, and in particular an embedded NUL, can
+cause a value to be truncated or misinterpreted, so no subject attribute
+value in a Let's Encrypt certificate may contain one.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_subject_has_control_characters",
+		Description:   "Let's Encrypt Certificates must not have subject attribute values containing ASCII control characters",
+		Citation:      "RFC 5280: 4.1.2.6",
+		Source:        lints.LetsEncryptCPSAll,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewSubjectHasControlCharacters,
+	})
+}
+
+func NewSubjectHasControlCharacters() lint.LintInterface {
+	return &subjectHasControlCharacters{}
+}
+
+func (l *subjectHasControlCharacters) CheckApplies(c *x509.Certificate) bool {
+	return true
+}
+
+func (l *subjectHasControlCharacters) Execute(c *x509.Certificate) *lint.LintResult {
+	isControl := func(r rune) bool {
+		return r < 0x20 || r == 0x7f
+	}
+	for _, name := range c.Subject.Names {
+		value, ok := name.Value.(string)
+		if !ok {
+			continue
+		}
+		if strings.IndexFunc(value, isControl) != -1 {
+			return &lint.LintResult{
+				Status:  lint.Error,
+				Details: fmt.Sprintf("Subject attribute %s contains an ASCII control character", name.Type),
+			}
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
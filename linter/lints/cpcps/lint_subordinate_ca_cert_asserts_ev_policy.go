@@ -0,0 +1,60 @@
+package cpcps
+
+import (
+	"github.com/zmap/zcrypto/encoding/asn1"
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+	"github.com/zmap/zlint/v3/util"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type subordinateCACertAssertsEVPolicy struct{}
+
+/************************************************
+Let's Encrypt only issues Domain Validated certificates, so our Intermediate CA
+certificates assert the CA/Browser Forum domain-validated reserved policy
+identifier and must not assert the extended-validation policy identifier.
+************************************************/
+
+// PermitSubordinateCAEVPolicy, if true, allows Intermediate CA certificates to
+// assert the extended-validation policy identifier.
+var PermitSubordinateCAEVPolicy bool
+
+// oidExtendedValidationPolicy is the CA/Browser Forum extended-validation
+// reserved policy identifier.
+var oidExtendedValidationPolicy = asn1.ObjectIdentifier{2, 23, 140, 1, 1}
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_subordinate_ca_cert_asserts_ev_policy",
+		Description:   "Let's Encrypt Intermediate CA Certificates must not assert the extended-validation policy identifier",
+		Citation:      "CPS: 7.1.6",
+		Source:        lints.LetsEncryptCPSIntermediate,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewSubordinateCACertAssertsEVPolicy,
+	})
+}
+
+func NewSubordinateCACertAssertsEVPolicy() lint.LintInterface {
+	return &subordinateCACertAssertsEVPolicy{}
+}
+
+func (l *subordinateCACertAssertsEVPolicy) CheckApplies(c *x509.Certificate) bool {
+	return util.IsSubCA(c) && util.IsExtInCert(c, util.CertPolicyOID)
+}
+
+func (l *subordinateCACertAssertsEVPolicy) Execute(c *x509.Certificate) *lint.LintResult {
+	if PermitSubordinateCAEVPolicy {
+		return &lint.LintResult{Status: lint.Pass}
+	}
+	for _, policy := range c.PolicyIdentifiers {
+		if policy.Equal(oidExtendedValidationPolicy) {
+			return &lint.LintResult{
+				Status:  lint.Error,
+				Details: "CA certificate asserts the extended-validation policy identifier",
+			}
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
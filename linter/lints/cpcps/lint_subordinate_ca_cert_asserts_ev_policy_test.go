@@ -0,0 +1,63 @@
+package cpcps
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints/test"
+)
+
+func TestSubordinateCACertAssertsEVPolicy(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		want       lint.LintStatus
+		wantSubStr string
+	}{
+		{
+			name: "intermediate_dv_policy",
+			want: lint.Pass,
+		},
+		{
+			name:       "intermediate_ev_policy",
+			want:       lint.Error,
+			wantSubStr: "asserts the extended-validation policy identifier",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewSubordinateCACertAssertsEVPolicy()
+			c := test.LoadPEMCert(t, fmt.Sprintf("testdata/cert_%s.pem", tc.name))
+			if !l.CheckApplies(c) {
+				t.Fatalf("expected lint to apply to %s", tc.name)
+			}
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
+
+// TestSubordinateCACertAssertsEVPolicyPermitted isn't parallel, as it modifies
+// PermitSubordinateCAEVPolicy.
+func TestSubordinateCACertAssertsEVPolicyPermitted(t *testing.T) {
+	PermitSubordinateCAEVPolicy = true
+	defer func() { PermitSubordinateCAEVPolicy = false }()
+
+	l := NewSubordinateCACertAssertsEVPolicy()
+	c := test.LoadPEMCert(t, "testdata/cert_intermediate_ev_policy.pem")
+	r := l.Execute(c)
+	if r.Status != lint.Pass {
+		t.Errorf("expected %q, got %q", lint.Pass, r.Status)
+	}
+}
@@ -0,0 +1,50 @@
+package cpcps
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+	"github.com/zmap/zlint/v3/util"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type subordinateCACertCommonNameIsIPAddress struct{}
+
+/************************************************
+The commonName of a Let's Encrypt Intermediate CA certificate is a short
+descriptive name, such as "R3" or "E5". A commonName which is an IP address
+indicates that a subscriber certificate profile was used by mistake.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_subordinate_ca_cert_common_name_is_ip_address",
+		Description:   "Let's Encrypt Intermediate CA Certificates must not have an IP address as their subject commonName",
+		Citation:      "CPS: 7.1.4",
+		Source:        lints.LetsEncryptCPSIntermediate,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewSubordinateCACertCommonNameIsIPAddress,
+	})
+}
+
+func NewSubordinateCACertCommonNameIsIPAddress() lint.LintInterface {
+	return &subordinateCACertCommonNameIsIPAddress{}
+}
+
+func (l *subordinateCACertCommonNameIsIPAddress) CheckApplies(c *x509.Certificate) bool {
+	return util.IsSubCA(c)
+}
+
+func (l *subordinateCACertCommonNameIsIPAddress) Execute(c *x509.Certificate) *lint.LintResult {
+	_, err := netip.ParseAddr(c.Subject.CommonName)
+	if err == nil {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: fmt.Sprintf("CA certificate subject commonName %q is an IP address", c.Subject.CommonName),
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
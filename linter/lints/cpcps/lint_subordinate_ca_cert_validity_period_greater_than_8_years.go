@@ -1,6 +1,7 @@
 package cpcps
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/zmap/zcrypto/x509"
@@ -12,12 +13,25 @@ import (
 
 type subordinateCACertValidityTooLong struct{}
 
+// MaxSubordinateCAValidity is the longest validity period permitted for an
+// Intermediate CA certificate. The ceremony tool checks profiles against it
+// before issuance. It is a variable so that tests can exercise the boundary
+// with short-lived fixtures.
+var MaxSubordinateCAValidity = 8 * 365 * lints.BRDay
+
+/************************************************
+CPS 7.1: "Intermediate CA Certificate Validity Period: Up to 8 years."
+
+The validity period is measured in BR days, inclusive of both notBefore and
+notAfter, as described in RFC 5280 Section 4.1.2.5.
+************************************************/
+
 func init() {
 	lint.RegisterLint(&lint.Lint{
 		Name:          "e_validity_period_greater_than_8_years",
 		Description:   "Let's Encrypt Intermediate CA Certificates have Validity Periods of up to 8 years",
 		Citation:      "CPS: 7.1",
-		Source:        lints.LetsEncryptCPS,
+		Source:        lints.LetsEncryptCPSIntermediate,
 		EffectiveDate: lints.CPSV33Date,
 		Lint:          NewSubordinateCACertValidityTooLong,
 	})
@@ -32,15 +46,15 @@ func (l *subordinateCACertValidityTooLong) CheckApplies(c *x509.Certificate) boo
 }
 
 func (l *subordinateCACertValidityTooLong) Execute(c *x509.Certificate) *lint.LintResult {
-	// CPS 7.1: "Intermediate CA Certificate Validity Period: Up to 8 years."
-	maxValidity := 8 * 365 * lints.BRDay
-
 	// RFC 5280 4.1.2.5: "The validity period for a certificate is the period
 	// of time from notBefore through notAfter, inclusive."
 	certValidity := c.NotAfter.Add(time.Second).Sub(c.NotBefore)
 
-	if certValidity > maxValidity {
-		return &lint.LintResult{Status: lint.Error}
+	if certValidity > MaxSubordinateCAValidity {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: fmt.Sprintf("Intermediate CA certificate validity period of %s is longer than the maximum of %s", certValidity, MaxSubordinateCAValidity),
+		}
 	}
 
 	return &lint.LintResult{Status: lint.Pass}
@@ -0,0 +1,74 @@
+package cpcps
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+	"github.com/letsencrypt/boulder/linter/lints/test"
+)
+
+func TestSubordinateCACertValidityTooLong(t *testing.T) {
+	// Not parallel, since a case overrides MaxSubordinateCAValidity.
+	testCases := []struct {
+		name        string
+		maxValidity time.Duration
+		want        lint.LintStatus
+		wantSubStr  string
+	}{
+		{
+			name: "intermediate_validity_8_years",
+			want: lint.Pass,
+		},
+		{
+			name:       "intermediate_validity_8_years_1_second",
+			want:       lint.Error,
+			wantSubStr: "longer than the maximum of 70080h0m0s",
+		},
+		{
+			name:        "intermediate_validity_8_years",
+			maxValidity: 365 * lints.BRDay,
+			want:        lint.Error,
+			wantSubStr:  "longer than the maximum of 8760h0m0s",
+		},
+		{
+			name: "root_crl_sign",
+			want: lint.NA,
+		},
+		{
+			// Certificates without basicConstraints cA=true aren't CA
+			// certificates, whatever their validity period.
+			name: "subscriber_standard_extensions",
+			want: lint.NA,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.maxValidity != 0 {
+				defer func(orig time.Duration) { MaxSubordinateCAValidity = orig }(MaxSubordinateCAValidity)
+				MaxSubordinateCAValidity = tc.maxValidity
+			}
+			l := NewSubordinateCACertValidityTooLong()
+			c := test.LoadPEMCert(t, fmt.Sprintf("testdata/cert_%s.pem", tc.name))
+			if !l.CheckApplies(c) {
+				if tc.want != lint.NA {
+					t.Fatalf("expected lint to apply to %s", tc.name)
+				}
+				return
+			}
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package cpcps
+
+import (
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+	"github.com/zmap/zlint/v3/util"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type subscriberCertEVSubjectSerialNumberMissing struct{}
+
+/************************************************
+EV Guidelines: 7.1.4.2.5
+Subject Registration Number Field: Required. The subject:serialNumber attribute
+of an Extended Validation Certificate contains the Registration (or similar)
+Number assigned to the Subject by the Incorporating or Registration Agency.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_subscriber_cert_ev_subject_serial_number_missing",
+		Description:   "Let's Encrypt Subscriber Certificates asserting the extended-validation policy identifier must contain a subject serialNumber attribute",
+		Citation:      "EV Guidelines: 7.1.4.2.5",
+		Source:        lints.LetsEncryptCPSSubscriber,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewSubscriberCertEVSubjectSerialNumberMissing,
+	})
+}
+
+func NewSubscriberCertEVSubjectSerialNumberMissing() lint.LintInterface {
+	return &subscriberCertEVSubjectSerialNumberMissing{}
+}
+
+func (l *subscriberCertEVSubjectSerialNumberMissing) CheckApplies(c *x509.Certificate) bool {
+	if !util.IsSubscriberCert(c) {
+		return false
+	}
+	for _, policy := range c.PolicyIdentifiers {
+		if policy.Equal(oidExtendedValidationPolicy) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *subscriberCertEVSubjectSerialNumberMissing) Execute(c *x509.Certificate) *lint.LintResult {
+	if len(c.Subject.SerialNumbers) == 0 {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "Extended Validation Subscriber Certificate does not contain a subject serialNumber attribute",
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
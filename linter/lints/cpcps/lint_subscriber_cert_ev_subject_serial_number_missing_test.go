@@ -0,0 +1,59 @@
+package cpcps
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints/test"
+)
+
+func TestSubscriberCertEVSubjectSerialNumberMissing(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		want       lint.LintStatus
+		wantSubStr string
+	}{
+		{
+			name: "subscriber_ev_serial_number_present",
+			want: lint.Pass,
+		},
+		{
+			name:       "subscriber_ev_serial_number_missing",
+			want:       lint.Error,
+			wantSubStr: "does not contain a subject serialNumber attribute",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewSubscriberCertEVSubjectSerialNumberMissing()
+			c := test.LoadPEMCert(t, fmt.Sprintf("testdata/cert_%s.pem", tc.name))
+			if !l.CheckApplies(c) {
+				t.Fatalf("expected lint to apply to %s", tc.name)
+			}
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
+
+func TestSubscriberCertEVSubjectSerialNumberMissingNotEV(t *testing.T) {
+	t.Parallel()
+
+	l := NewSubscriberCertEVSubjectSerialNumberMissing()
+	c := test.LoadPEMCert(t, "testdata/cert_subscriber_dv_policy.pem")
+	if l.CheckApplies(c) {
+		t.Fatalf("expected lint not to apply to a certificate without the extended-validation policy")
+	}
+}
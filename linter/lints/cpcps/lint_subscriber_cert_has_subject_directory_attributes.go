@@ -0,0 +1,49 @@
+package cpcps
+
+import (
+	"github.com/zmap/zcrypto/encoding/asn1"
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+	"github.com/zmap/zlint/v3/util"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type subscriberCertHasSubjectDirectoryAttributes struct{}
+
+/************************************************
+Baseline Requirements: 7.1.2.7.6
+The subjectDirectoryAttributes extension isn't among the extensions permitted
+in a Subscriber Certificate. It carries identity attributes, such as a date of
+birth, which don't belong in a TLS certificate.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_subscriber_cert_has_subject_directory_attributes",
+		Description:   "Let's Encrypt TLS Subscriber Certificates must not contain the subjectDirectoryAttributes extension",
+		Citation:      "BRs: 7.1.2.7.6",
+		Source:        lints.LetsEncryptCPSSubscriber,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewSubscriberCertHasSubjectDirectoryAttributes,
+	})
+}
+
+func NewSubscriberCertHasSubjectDirectoryAttributes() lint.LintInterface {
+	return &subscriberCertHasSubjectDirectoryAttributes{}
+}
+
+func (l *subscriberCertHasSubjectDirectoryAttributes) CheckApplies(c *x509.Certificate) bool {
+	return util.IsSubscriberCert(c) && util.IsServerAuthCert(c)
+}
+
+func (l *subscriberCertHasSubjectDirectoryAttributes) Execute(c *x509.Certificate) *lint.LintResult {
+	subjectDirectoryAttributesOID := asn1.ObjectIdentifier{2, 5, 29, 9} // id-ce-subjectDirectoryAttributes
+	if lints.GetExtWithOID(c.Extensions, subjectDirectoryAttributesOID) != nil {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "TLS subscriber certificate contains a subjectDirectoryAttributes extension",
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
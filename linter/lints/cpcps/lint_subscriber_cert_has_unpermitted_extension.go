@@ -0,0 +1,87 @@
+package cpcps
+
+import (
+	"strings"
+
+	"github.com/zmap/zcrypto/encoding/asn1"
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+	"github.com/zmap/zlint/v3/util"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type subscriberCertHasUnpermittedExtension struct{}
+
+/************************************************
+Baseline Requirements: 7.1.2.7.6
+A Subscriber Certificate may only contain the extensions listed in the
+Subscriber Certificate profile. Our TLS Subscriber Certificates are held to
+the closed set of extensions which our issuance profiles emit, so that an
+unexpected extension can't slip into a certificate unnoticed.
+
+The signedCertificateTimestampList and precertificate poison extensions are
+permitted here; their presence and criticality are checked by the CT lints.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_subscriber_cert_has_unpermitted_extension",
+		Description:   "Let's Encrypt TLS Subscriber Certificates must only contain extensions from the permitted set",
+		Citation:      "BRs: 7.1.2.7.6",
+		Source:        lints.LetsEncryptCPSSubscriber,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewSubscriberCertHasUnpermittedExtension,
+	})
+}
+
+// permittedSubscriberExtensions contains the OIDs of the extensions which may
+// appear in a TLS Subscriber Certificate.
+var permittedSubscriberExtensions = []asn1.ObjectIdentifier{
+	util.AuthkeyOID,
+	util.SubjectKeyIdentityOID,
+	util.KeyUsageOID,
+	util.EkuSynOid,
+	util.BasicConstOID,
+	util.SubjectAlternateNameOID,
+	util.CertPolicyOID,
+	util.CrlDistOID,
+	util.AiaOID,
+	// Handled by the CT lints.
+	util.CtPoisonOID,
+	util.TimestampOID,
+	// RFC 7633: the TLS Feature extension is included when must-staple is
+	// requested and the issuance profile allows it.
+	{1, 3, 6, 1, 5, 5, 7, 1, 24},
+}
+
+func NewSubscriberCertHasUnpermittedExtension() lint.LintInterface {
+	return &subscriberCertHasUnpermittedExtension{}
+}
+
+func (l *subscriberCertHasUnpermittedExtension) CheckApplies(c *x509.Certificate) bool {
+	return util.IsSubscriberCert(c) && util.IsServerAuthCert(c)
+}
+
+func (l *subscriberCertHasUnpermittedExtension) Execute(c *x509.Certificate) *lint.LintResult {
+	var unpermitted []string
+	for _, ext := range c.Extensions {
+		permitted := false
+		for _, oid := range permittedSubscriberExtensions {
+			if ext.Id.Equal(oid) {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			unpermitted = append(unpermitted, ext.Id.String())
+		}
+	}
+	if len(unpermitted) > 0 {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "TLS subscriber certificate contains unpermitted extensions: " + strings.Join(unpermitted, ", "),
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
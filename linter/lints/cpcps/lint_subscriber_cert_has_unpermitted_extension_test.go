@@ -0,0 +1,56 @@
+package cpcps
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints/test"
+)
+
+func TestSubscriberCertHasUnpermittedExtension(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		want       lint.LintStatus
+		wantSubStr string
+	}{
+		{
+			name: "subscriber_standard_extensions",
+			want: lint.Pass,
+		},
+		{
+			name:       "subscriber_unpermitted_extension",
+			want:       lint.Error,
+			wantSubStr: "contains unpermitted extensions: 1.3.6.1.4.1.44947.1.99",
+		},
+		{
+			name: "root_crl_sign",
+			want: lint.NA,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewSubscriberCertHasUnpermittedExtension()
+			c := test.LoadPEMCert(t, fmt.Sprintf("testdata/cert_%s.pem", tc.name))
+			if !l.CheckApplies(c) {
+				if tc.want != lint.NA {
+					t.Fatalf("expected lint to apply to %s", tc.name)
+				}
+				return
+			}
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
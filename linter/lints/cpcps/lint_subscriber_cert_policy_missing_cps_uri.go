@@ -0,0 +1,105 @@
+package cpcps
+
+import (
+	"fmt"
+
+	"github.com/zmap/zcrypto/encoding/asn1"
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+	"github.com/zmap/zlint/v3/util"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type subscriberCertPolicyMissingCPSURI struct{}
+
+/************************************************
+CPS: 7.1
+Subscriber Certificates which assert the ISRG Domain Validated policy
+(1.3.6.1.4.1.44947.1.1.1) must carry an id-qt-cps policy qualifier pointing
+relying parties at the CPS.
+
+Certificates asserting only the CA/Browser Forum reserved policy identifiers
+don't carry the qualifier, and aren't checked.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_subscriber_cert_policy_missing_cps_uri",
+		Description:   "Let's Encrypt Subscriber Certificates asserting the ISRG Domain Validated policy must include a CPS URI policy qualifier for it",
+		Citation:      "CPS: 7.1",
+		Source:        lints.LetsEncryptCPSSubscriber,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewSubscriberCertPolicyMissingCPSURI,
+	})
+}
+
+var (
+	// oidISRGDomainValidatedPolicy is our own domain validated policy OID.
+	oidISRGDomainValidatedPolicy = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 44947, 1, 1, 1}
+	// oidCPSQualifier is id-qt-cps, from RFC 5280 Section 4.2.1.4.
+	oidCPSQualifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 2, 1}
+)
+
+// policyInformation and policyQualifierInfo are the ASN.1 structures of a
+// certificatePolicies extension, described in RFC 5280 Section 4.2.1.4.
+type policyInformation struct {
+	Policy     asn1.ObjectIdentifier
+	Qualifiers []policyQualifierInfo `asn1:"optional"`
+}
+
+type policyQualifierInfo struct {
+	PolicyQualifierID asn1.ObjectIdentifier
+	Qualifier         asn1.RawValue
+}
+
+func NewSubscriberCertPolicyMissingCPSURI() lint.LintInterface {
+	return &subscriberCertPolicyMissingCPSURI{}
+}
+
+func (l *subscriberCertPolicyMissingCPSURI) CheckApplies(c *x509.Certificate) bool {
+	if !util.IsSubscriberCert(c) {
+		return false
+	}
+	for _, policy := range c.PolicyIdentifiers {
+		if policy.Equal(oidISRGDomainValidatedPolicy) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *subscriberCertPolicyMissingCPSURI) Execute(c *x509.Certificate) *lint.LintResult {
+	ext := lints.GetExtWithOID(c.Extensions, util.CertPolicyOID)
+	if ext == nil {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "Certificate does not contain a certificatePolicies extension",
+		}
+	}
+
+	var policies []policyInformation
+	rest, err := asn1.Unmarshal(ext.Value, &policies)
+	if err != nil || len(rest) != 0 {
+		return &lint.LintResult{
+			Status:  lint.Fatal,
+			Details: "Failed to parse certificatePolicies extension",
+		}
+	}
+
+	for _, policy := range policies {
+		if !policy.Policy.Equal(oidISRGDomainValidatedPolicy) {
+			continue
+		}
+		for _, qualifier := range policy.Qualifiers {
+			if qualifier.PolicyQualifierID.Equal(oidCPSQualifier) &&
+				qualifier.Qualifier.Tag == asn1.TagIA5String && len(qualifier.Qualifier.Bytes) > 0 {
+				return &lint.LintResult{Status: lint.Pass}
+			}
+		}
+	}
+	return &lint.LintResult{
+		Status:  lint.Error,
+		Details: fmt.Sprintf("certificatePolicies has no CPS URI qualifier for policy %s", oidISRGDomainValidatedPolicy),
+	}
+}
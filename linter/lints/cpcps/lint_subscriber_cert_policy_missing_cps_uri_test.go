@@ -0,0 +1,61 @@
+package cpcps
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints/test"
+)
+
+func TestSubscriberCertPolicyMissingCPSURI(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		want       lint.LintStatus
+		wantSubStr string
+	}{
+		{
+			name: "subscriber_isrg_policy_cps_uri",
+			want: lint.Pass,
+		},
+		{
+			name:       "subscriber_isrg_policy_no_cps_uri",
+			want:       lint.Error,
+			wantSubStr: "no CPS URI qualifier for policy 1.3.6.1.4.1.44947.1.1.1",
+		},
+		{
+			// Only the CA/Browser Forum domain validated policy is asserted.
+			name: "subscriber_dv_policy",
+			want: lint.NA,
+		},
+		{
+			name: "root_crl_sign",
+			want: lint.NA,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewSubscriberCertPolicyMissingCPSURI()
+			c := test.LoadPEMCert(t, fmt.Sprintf("testdata/cert_%s.pem", tc.name))
+			if !l.CheckApplies(c) {
+				if tc.want != lint.NA {
+					t.Fatalf("expected lint to apply to %s", tc.name)
+				}
+				return
+			}
+			r := l.Execute(c)
+
+			if r.Status != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, r.Status)
+			}
+			if !strings.Contains(r.Details, tc.wantSubStr) {
+				t.Errorf("expected %q, got %q", tc.wantSubStr, r.Details)
+			}
+		})
+	}
+}
@@ -31,6 +31,12 @@ func (l *certValidityNotRound) CheckApplies(c *x509.Certificate) bool {
 }
 
 func (l *certValidityNotRound) Execute(c *x509.Certificate) *lint.LintResult {
+	// Certificates with no well-defined expiration date have a validity period
+	// too long to be represented as a time.Duration, and are exempt.
+	if c.NotAfter.Equal(lints.NoWellDefinedExpiration) {
+		return &lint.LintResult{Status: lint.Pass}
+	}
+
 	// RFC 5280 4.1.2.5: "The validity period for a certificate is the period
 	// of time from notBefore through notAfter, inclusive."
 	certValidity := c.NotAfter.Add(time.Second).Sub(c.NotBefore)
@@ -0,0 +1,53 @@
+package cpcps
+
+import (
+	"github.com/zmap/zcrypto/x509"
+	"github.com/zmap/zlint/v3/lint"
+
+	"github.com/letsencrypt/boulder/linter/lints"
+)
+
+type validityPeriodNotPositive struct{}
+
+/************************************************
+RFC 5280: 4.1.2.5
+The validity period for a certificate is the period of time from notBefore
+through notAfter, inclusive.
+
+A certificate whose notAfter is equal to or precedes its notBefore is valid
+for at most an instant, and indicates a mistake in the profile which issued
+it. This backstops the ordering check performed on ceremony configs.
+
+A notAfter which precedes the notBefore is already flagged by zlint's
+e_validity_time_not_positive, but that lint passes a notAfter equal to the
+notBefore, so only that case is flagged here.
+************************************************/
+
+func init() {
+	lint.RegisterLint(&lint.Lint{
+		Name:          "e_validity_period_not_positive",
+		Description:   "Let's Encrypt Certificates must not have a NotAfter equal to their NotBefore",
+		Citation:      "RFC 5280: 4.1.2.5",
+		Source:        lints.LetsEncryptCPSAll,
+		EffectiveDate: lints.CPSV33Date,
+		Lint:          NewValidityPeriodNotPositive,
+	})
+}
+
+func NewValidityPeriodNotPositive() lint.LintInterface {
+	return &validityPeriodNotPositive{}
+}
+
+func (l *validityPeriodNotPositive) CheckApplies(c *x509.Certificate) bool {
+	return true
+}
+
+func (l *validityPeriodNotPositive) Execute(c *x509.Certificate) *lint.LintResult {
+	if c.NotAfter.Equal(c.NotBefore) {
+		return &lint.LintResult{
+			Status:  lint.Error,
+			Details: "Certificate NotAfter is equal to its NotBefore",
+		}
+	}
+	return &lint.LintResult{Status: lint.Pass}
+}
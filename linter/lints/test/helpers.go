@@ -21,3 +21,15 @@ func LoadPEMCRL(t *testing.T, filename string) *x509.RevocationList {
 	test.AssertNotError(t, err, "parsing CRL bytes")
 	return crl
 }
+
+func LoadPEMCert(t *testing.T, filename string) *x509.Certificate {
+	t.Helper()
+	file, err := os.ReadFile(filename)
+	test.AssertNotError(t, err, "reading certificate file")
+	block, rest := pem.Decode(file)
+	test.AssertEquals(t, block.Type, "CERTIFICATE")
+	test.AssertEquals(t, len(rest), 0)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	test.AssertNotError(t, err, "parsing certificate bytes")
+	return cert
+}
@@ -16,6 +16,7 @@ import (
 
 type PKCtx interface {
 	GenerateKeyPair(pkcs11.SessionHandle, []*pkcs11.Mechanism, []*pkcs11.Attribute, []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error)
+	GenerateKey(pkcs11.SessionHandle, []*pkcs11.Mechanism, []*pkcs11.Attribute) (pkcs11.ObjectHandle, error)
 	GetAttributeValue(pkcs11.SessionHandle, pkcs11.ObjectHandle, []*pkcs11.Attribute) ([]*pkcs11.Attribute, error)
 	SignInit(pkcs11.SessionHandle, []*pkcs11.Mechanism, pkcs11.ObjectHandle) error
 	Sign(pkcs11.SessionHandle, []byte) ([]byte, error)
@@ -32,24 +33,28 @@ type Session struct {
 	Session pkcs11.SessionHandle
 }
 
-func Initialize(module string, slot uint, pin string) (*Session, error) {
+// Initialize opens a session with the token in slot and logs in to it as
+// userType, either pkcs11.CKU_USER or pkcs11.CKU_SO, with pin.
+func Initialize(module string, slot uint, userType uint, pin string) (*Session, error) {
 	ctx := pkcs11.New(module)
 	if ctx == nil {
 		return nil, errors.New("failed to load module")
 	}
+	// The module may already have been initialized, and the token logged in
+	// to, by an earlier call to Initialize in the same process.
 	err := ctx.Initialize()
-	if err != nil {
-		return nil, fmt.Errorf("couldn't initialize context: %s", err)
+	if err != nil && err != pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		return nil, fmt.Errorf("couldn't initialize context: %w", err)
 	}
 
 	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't open session: %s", err)
+		return nil, fmt.Errorf("couldn't open session: %w", err)
 	}
 
-	err = ctx.Login(session, pkcs11.CKU_USER, pin)
-	if err != nil {
-		return nil, fmt.Errorf("couldn't login: %s", err)
+	err = ctx.Login(session, userType, pin)
+	if err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		return nil, fmt.Errorf("couldn't login: %w", err)
 	}
 
 	return &Session{ctx, session}, nil
@@ -136,6 +141,10 @@ func (s *Session) GenerateKeyPair(m []*pkcs11.Mechanism, pubAttrs []*pkcs11.Attr
 	return s.Module.GenerateKeyPair(s.Session, m, pubAttrs, privAttrs)
 }
 
+func (s *Session) GenerateKey(m []*pkcs11.Mechanism, attrs []*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+	return s.Module.GenerateKey(s.Session, m, attrs)
+}
+
 func (s *Session) GetRSAPublicKey(object pkcs11.ObjectHandle) (*rsa.PublicKey, error) {
 	// Retrieve the public exponent and modulus for the public key
 	attrs, err := s.Module.GetAttributeValue(s.Session, object, []*pkcs11.Attribute{
@@ -143,7 +152,7 @@ func (s *Session) GetRSAPublicKey(object pkcs11.ObjectHandle) (*rsa.PublicKey, e
 		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Failed to retrieve key attributes: %s", err)
+		return nil, fmt.Errorf("Failed to retrieve key attributes: %w", err)
 	}
 
 	// Attempt to build the public key from the retrieved attributes
@@ -182,7 +191,7 @@ func (s *Session) GetECDSAPublicKey(object pkcs11.ObjectHandle) (*ecdsa.PublicKe
 		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Failed to retrieve key attributes: %s", err)
+		return nil, fmt.Errorf("Failed to retrieve key attributes: %w", err)
 	}
 
 	pubKey := &ecdsa.PublicKey{}
@@ -211,7 +220,7 @@ func (s *Session) GetECDSAPublicKey(object pkcs11.ObjectHandle) (*ecdsa.PublicKe
 		var point asn1.RawValue
 		_, err = asn1.Unmarshal(pointBytes, &point)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to unmarshal returned CKA_EC_POINT: %s", err)
+			return nil, fmt.Errorf("Failed to unmarshal returned CKA_EC_POINT: %w", err)
 		}
 		if len(point.Bytes) == 0 {
 			return nil, errors.New("Invalid CKA_EC_POINT value returned, OCTET string is empty")
@@ -261,11 +270,11 @@ func (s *Session) Sign(object pkcs11.ObjectHandle, keyType keyType, digest []byt
 
 	err := s.Module.SignInit(s.Session, mech, object)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize signing operation: %s", err)
+		return nil, fmt.Errorf("failed to initialize signing operation: %w", err)
 	}
 	signature, err := s.Module.Sign(s.Session, digest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to sign data: %s", err)
+		return nil, fmt.Errorf("failed to sign data: %w", err)
 	}
 
 	return signature, nil
@@ -327,7 +336,7 @@ func (p *x509Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts)
 			R, S *big.Int
 		}{R: r, S: s})
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert signature to RFC 5480 format: %s", err)
+			return nil, fmt.Errorf("failed to convert signature to RFC 5480 format: %w", err)
 		}
 	}
 	return signature, nil
@@ -352,13 +361,13 @@ func (s *Session) NewSigner(label string, publicKey crypto.PublicKey) (crypto.Si
 
 	publicKeyID, err := s.getPublicKeyID(label, publicKey)
 	if err != nil {
-		return nil, fmt.Errorf("looking up public key: %s", err)
+		return nil, fmt.Errorf("looking up public key: %w", err)
 	}
 
 	// Fetch the private key by matching its id to the public key handle.
 	privateKeyHandle, err := s.getPrivateKey(publicKeyID)
 	if err != nil {
-		return nil, fmt.Errorf("getting private key: %s", err)
+		return nil, fmt.Errorf("getting private key: %w", err)
 	}
 	return &x509Signer{
 		session:      s,
@@ -379,6 +388,7 @@ func NewSessionWithMock() (*Session, *MockCtx) {
 
 type MockCtx struct {
 	GenerateKeyPairFunc   func(pkcs11.SessionHandle, []*pkcs11.Mechanism, []*pkcs11.Attribute, []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error)
+	GenerateKeyFunc       func(pkcs11.SessionHandle, []*pkcs11.Mechanism, []*pkcs11.Attribute) (pkcs11.ObjectHandle, error)
 	GetAttributeValueFunc func(pkcs11.SessionHandle, pkcs11.ObjectHandle, []*pkcs11.Attribute) ([]*pkcs11.Attribute, error)
 	SignInitFunc          func(pkcs11.SessionHandle, []*pkcs11.Mechanism, pkcs11.ObjectHandle) error
 	SignFunc              func(pkcs11.SessionHandle, []byte) ([]byte, error)
@@ -392,6 +402,10 @@ func (mc MockCtx) GenerateKeyPair(s pkcs11.SessionHandle, m []*pkcs11.Mechanism,
 	return mc.GenerateKeyPairFunc(s, m, a1, a2)
 }
 
+func (mc MockCtx) GenerateKey(s pkcs11.SessionHandle, m []*pkcs11.Mechanism, a []*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+	return mc.GenerateKeyFunc(s, m, a)
+}
+
 func (mc MockCtx) GetAttributeValue(s pkcs11.SessionHandle, o pkcs11.ObjectHandle, a []*pkcs11.Attribute) ([]*pkcs11.Attribute, error) {
 	return mc.GetAttributeValueFunc(s, o, a)
 }